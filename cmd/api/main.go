@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"log"
 	"net/http"
 	"os"
@@ -11,8 +12,12 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/yourusername/go-sqlc-starter/internal/api"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
 	"github.com/yourusername/go-sqlc-starter/internal/config"
 	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+	"github.com/yourusername/go-sqlc-starter/internal/mail"
 )
 
 func main() {
@@ -45,8 +50,42 @@ func main() {
 
 	logger.Info().Msg("Database migrations completed")
 
+	// New and rehashed passwords use the configured algorithm. Unknown
+	// emails take as long to refuse as the slowest stored hash.
+	auth.SetPasswordHasher(setupPasswordHasher(cfg))
+	hashes, err := sqlc.New(database).ListPasswordHashSamples(context.Background())
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to sample password hashes")
+	}
+	auth.CalibratePasswordCheck(hashes)
+
+	// Set up token signing
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	// Encrypts secrets stored in the database
+	cipher, err := auth.NewCipher(cfg.EncryptionKey)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to set up encryption")
+	}
+
+	jwtManager, err := setupJWTManager(ctx, cfg, database, cipher, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to set up token signing")
+	}
+
+	mailer, err := setupMailer(cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to set up mail delivery")
+	}
+
+	passwordPolicy, err := setupPasswordPolicy(cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to set up password policy")
+	}
+
 	// Initialize router with all dependencies
-	router := api.NewRouter(cfg, database, logger)
+	router := api.NewRouter(cfg, database, jwtManager, cipher, mailer, passwordPolicy, logger)
 
 	// Configure HTTP server
 	server := &http.Server{
@@ -75,17 +114,49 @@ func main() {
 
 	logger.Info().Msg("Shutting down server...")
 
+	// Stop background jobs
+	stop()
+
 	// Give outstanding requests 10 seconds to complete
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Fatal().Err(err).Msg("Server forced to shutdown")
 	}
 
+	// Let mail started by earlier requests go out
+	handlers.WaitForBackground()
+
 	logger.Info().Msg("Server exited gracefully")
 }
 
+// setupJWTManager creates the token signer. Asymmetric algorithms load their
+// keys from the database and rotate them in the background until ctx is done.
+func setupJWTManager(ctx context.Context, cfg *config.Config, database *sql.DB, cipher *auth.Cipher, logger zerolog.Logger) (*auth.JWTManager, error) {
+	if cfg.JWTAlgorithm == "HS256" {
+		return auth.NewJWTManager(cfg.JWTSecret, cfg.JWTAccessExpiry, cfg.JWTRefreshExpiry), nil
+	}
+
+	// Retired keys must outlive every token they signed
+	keyStore := auth.NewPostgresKeyStore(sqlc.New(database), cipher)
+	keys := auth.NewKeyManager(keyStore, cfg.JWTAlgorithm, cfg.JWTKeyRotationInterval, cfg.JWTRefreshExpiry)
+	if err := keys.Load(ctx); err != nil {
+		return nil, err
+	}
+	go keys.Run(ctx, time.Minute, logger)
+
+	return auth.NewAsymmetricJWTManager(keys, cfg.JWTAccessExpiry, cfg.JWTRefreshExpiry), nil
+}
+
+// setupMailer selects the mail backend from configuration
+func setupMailer(cfg *config.Config) (mail.Mailer, error) {
+	if cfg.MailDriver == "smtp" {
+		return mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	}
+	return mail.NewFileMailer(cfg.MailOutboxDir, cfg.MailFrom)
+}
+
 // setupLogger configures structured logging based on environment
 func setupLogger(env string) zerolog.Logger {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
@@ -102,3 +173,37 @@ func setupLogger(env string) zerolog.Logger {
 	}
 	return zerolog.New(output).With().Timestamp().Caller().Logger()
 }
+
+// setupPasswordHasher returns the hasher selected by configuration
+func setupPasswordHasher(cfg *config.Config) auth.Hasher {
+	if cfg.PasswordHashAlgorithm == auth.AlgorithmBcrypt {
+		return auth.BcryptHasher{Cost: cfg.BcryptCost}
+	}
+	return auth.Argon2idHasher{
+		Memory:      cfg.Argon2Memory,
+		Time:        cfg.Argon2Time,
+		Parallelism: cfg.Argon2Parallelism,
+	}
+}
+
+// setupPasswordPolicy builds the policy new passwords must satisfy
+func setupPasswordPolicy(cfg *config.Config) (*auth.PasswordPolicy, error) {
+	policy := &auth.PasswordPolicy{
+		MinLength:          cfg.PasswordMinLength,
+		MaxLength:          cfg.PasswordMaxLength,
+		RequireUpper:       cfg.PasswordRequireUpper,
+		RequireLower:       cfg.PasswordRequireLower,
+		RequireDigit:       cfg.PasswordRequireDigit,
+		RequireSymbol:      cfg.PasswordRequireSymbol,
+		MinStrength:        cfg.PasswordMinStrength,
+		RejectPersonalInfo: cfg.PasswordRejectPersonalInfo,
+	}
+	if cfg.BreachedPasswordsPath != "" {
+		breached, err := auth.LoadBreachedPasswords(cfg.BreachedPasswordsPath)
+		if err != nil {
+			return nil, err
+		}
+		policy.Breached = breached
+	}
+	return policy, nil
+}
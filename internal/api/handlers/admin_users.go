@@ -0,0 +1,784 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// adminRole is the built-in role that is_admin grants
+const adminRole = "admin"
+
+var (
+	errUserInactive    = errors.New("user is deactivated")
+	errUserActive      = errors.New("user is active")
+	errUserOwnsOrg     = errors.New("user is the last owner of an organization")
+	errEmailRegistered = errors.New("email already registered")
+	errUserIsStaff     = errors.New("user is staff")
+)
+
+// AdminUserHandler lets staff manage other users' accounts
+type AdminUserHandler struct {
+	store               db.Store
+	jwtManager          *auth.JWTManager
+	revocations         *auth.RevocationList
+	throttle            *auth.LoginThrottle
+	passwords           *PasswordHandler
+	verification        *VerificationHandler
+	impersonationExpiry time.Duration
+	logger              zerolog.Logger
+}
+
+func NewAdminUserHandler(store db.Store, jwtManager *auth.JWTManager, revocations *auth.RevocationList, throttle *auth.LoginThrottle, passwords *PasswordHandler, verification *VerificationHandler, impersonationExpiry time.Duration, logger zerolog.Logger) *AdminUserHandler {
+	return &AdminUserHandler{
+		store:               store,
+		jwtManager:          jwtManager,
+		revocations:         revocations,
+		throttle:            throttle,
+		passwords:           passwords,
+		verification:        verification,
+		impersonationExpiry: impersonationExpiry,
+		logger:              logger,
+	}
+}
+
+// AdminCreateUserRequest represents the admin create user request body.
+// Password is generated when it is left out.
+type AdminCreateUserRequest struct {
+	Email         string `json:"email" binding:"required,email,max=255"`
+	FullName      string `json:"full_name" binding:"required,max=255"`
+	Password      string `json:"password,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	IsAdmin       bool   `json:"is_admin,omitempty"`
+}
+
+// AdminUpdateUserRequest represents the admin update user request body
+type AdminUpdateUserRequest struct {
+	Email         *string `json:"email,omitempty" binding:"omitempty,email,max=255"`
+	FullName      *string `json:"full_name,omitempty" binding:"omitempty,min=1,max=255"`
+	EmailVerified *bool   `json:"email_verified,omitempty"`
+	IsAdmin       *bool   `json:"is_admin,omitempty"`
+}
+
+// ImpersonateResponse is an access token for acting as a user. There is no
+// refresh token; the admin starts over once it expires.
+type ImpersonateResponse struct {
+	AccessToken string     `json:"access_token"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	User        UserInfo   `json:"user"`
+	Actor       auth.Actor `json:"actor"`
+}
+
+// AdminUserInfo represents a user as staff see it
+type AdminUserInfo struct {
+	ID                     int64     `json:"id"`
+	Email                  string    `json:"email"`
+	FullName               string    `json:"full_name"`
+	IsActive               bool      `json:"is_active"`
+	IsAdmin                bool      `json:"is_admin"`
+	EmailVerified          bool      `json:"email_verified"`
+	PasswordChangeRequired bool      `json:"password_change_required"`
+	HasPassword            bool      `json:"has_password"`
+	CreatedAt              time.Time `json:"created_at"`
+	UpdatedAt              time.Time `json:"updated_at"`
+}
+
+// ListUsers returns users newest first, including deactivated accounts.
+// status=active or status=inactive narrows the list.
+func (h *AdminUserHandler) ListUsers(c *gin.Context) {
+	var isActive *bool
+	switch c.Query("status") {
+	case "":
+	case "active":
+		isActive = new(bool)
+		*isActive = true
+	case "inactive":
+		isActive = new(bool)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be active or inactive"})
+		return
+	}
+
+	page := 1
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	limit := 20
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	ctx := c.Request.Context()
+	users, err := h.store.AdminListUsers(ctx, sqlc.AdminListUsersParams{
+		IsActive: isActive,
+		Limit:    int32(limit),
+		Offset:   int32((page - 1) * limit),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list users"})
+		return
+	}
+
+	total, err := h.store.AdminCountUsers(ctx, isActive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count users"})
+		return
+	}
+
+	infos := make([]AdminUserInfo, 0, len(users))
+	for _, user := range users {
+		infos = append(infos, newAdminUserInfo(user))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users": infos,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetUser returns any user, including a deactivated one
+func (h *AdminUserHandler) GetUser(c *gin.Context) {
+	id, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.store.AdminGetUser(c.Request.Context(), id)
+	if err != nil {
+		respondAdminUserError(c, err, "failed to get user")
+		return
+	}
+
+	c.JSON(http.StatusOK, newAdminUserInfo(user))
+}
+
+// CreateUser creates an account with a temporary password the user is asked
+// to change. The password is generated, and returned once, when none is
+// given. Making the user an admin needs roles:write.
+func (h *AdminUserHandler) CreateUser(c *gin.Context) {
+	var req AdminCreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.IsAdmin && !canManageRoles(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		return
+	}
+
+	password := req.Password
+	if password == "" {
+		var err error
+		if password, err = auth.GenerateOpaqueToken(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate password"})
+			return
+		}
+	} else if !checkPasswordPolicy(c, h.passwords.policy, password, req.Email, req.FullName) {
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		return
+	}
+
+	var verifiedAt sql.NullTime
+	if req.EmailVerified {
+		verifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+
+	var user sqlc.User
+	ctx := c.Request.Context()
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		user, err = q.AdminCreateUser(ctx, sqlc.AdminCreateUserParams{
+			Email:           req.Email,
+			PasswordHash:    passwordHash,
+			FullName:        req.FullName,
+			IsAdmin:         req.IsAdmin,
+			EmailVerifiedAt: verifiedAt,
+		})
+		if err != nil {
+			if isUniqueViolation(err) {
+				return errEmailRegistered
+			}
+			return err
+		}
+		if req.IsAdmin {
+			if err := setAdminRole(c, q, user.ID, true); err != nil {
+				return err
+			}
+		}
+
+		event := auditEvent(c, audit.ActionUserCreated, audit.TargetUser, user.ID)
+		event.Diff = map[string]audit.Change{
+			"email":          {To: user.Email},
+			"full_name":      {To: user.FullName},
+			"is_admin":       {To: user.IsAdmin},
+			"email_verified": {To: user.EmailVerifiedAt.Valid},
+		}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		respondAdminUserError(c, err, "failed to create user")
+		return
+	}
+
+	if !user.EmailVerifiedAt.Valid {
+		// The account exists either way; the user can ask for another link
+		if err := h.verification.sendVerification(ctx, user, user.Email); err != nil {
+			h.logger.Error().
+				Err(err).
+				Int64("user_id", user.ID).
+				Str("request_id", c.GetString("request_id")).
+				Msg("Failed to send verification email")
+		}
+	}
+
+	resp := gin.H{"user": newAdminUserInfo(user)}
+	if req.Password == "" {
+		resp["temporary_password"] = password
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// UpdateUser edits any field of an account. Changing the email clears its
+// verification unless email_verified is sent too. is_admin grants or removes
+// the admin role and needs roles:write; admins cannot demote themselves.
+func (h *AdminUserHandler) UpdateUser(c *gin.Context) {
+	id, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+
+	var req AdminUpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.IsAdmin != nil {
+		if !canManageRoles(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+			return
+		}
+		if !*req.IsAdmin && id == c.GetInt64("user_id") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot remove your own admin access"})
+			return
+		}
+	}
+
+	var (
+		user   sqlc.User
+		revoke bool
+	)
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		previous, err := q.AdminGetUserForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		params := sqlc.AdminUpdateUserParams{
+			ID:              id,
+			Email:           previous.Email,
+			FullName:        previous.FullName,
+			IsAdmin:         previous.IsAdmin,
+			EmailVerifiedAt: previous.EmailVerifiedAt,
+		}
+		if req.FullName != nil {
+			params.FullName = *req.FullName
+		}
+		if req.Email != nil && *req.Email != previous.Email {
+			params.Email = *req.Email
+			params.EmailVerifiedAt = sql.NullTime{}
+		}
+		if req.EmailVerified != nil {
+			switch {
+			case !*req.EmailVerified:
+				params.EmailVerifiedAt = sql.NullTime{}
+			case !params.EmailVerifiedAt.Valid:
+				params.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			}
+		}
+		if req.IsAdmin != nil {
+			params.IsAdmin = *req.IsAdmin
+		}
+
+		user, err = q.AdminUpdateUser(ctx, params)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return errEmailRegistered
+			}
+			return err
+		}
+		if user.IsAdmin != previous.IsAdmin {
+			if err := setAdminRole(c, q, id, user.IsAdmin); err != nil {
+				return err
+			}
+		}
+
+		diff := map[string]audit.Change{}
+		if user.Email != previous.Email {
+			diff["email"] = audit.Change{From: previous.Email, To: user.Email}
+		}
+		if user.FullName != previous.FullName {
+			diff["full_name"] = audit.Change{From: previous.FullName, To: user.FullName}
+		}
+		if user.IsAdmin != previous.IsAdmin {
+			diff["is_admin"] = audit.Change{From: previous.IsAdmin, To: user.IsAdmin}
+		}
+		if user.EmailVerifiedAt.Valid != previous.EmailVerifiedAt.Valid {
+			diff["email_verified"] = audit.Change{From: previous.EmailVerifiedAt.Valid, To: user.EmailVerifiedAt.Valid}
+		}
+		if len(diff) == 0 {
+			return nil
+		}
+
+		// Tokens carry the email and the admin flag and role
+		revoke = user.Email != previous.Email || user.IsAdmin != previous.IsAdmin
+
+		event := auditEvent(c, audit.ActionUserUpdated, audit.TargetUser, id)
+		event.Diff = diff
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		respondAdminUserError(c, err, "failed to update user")
+		return
+	}
+
+	if revoke {
+		if err := h.revocations.RevokeUser(ctx, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, newAdminUserInfo(user))
+}
+
+// DeactivateUser soft-deletes an account and logs it out everywhere
+func (h *AdminUserHandler) DeactivateUser(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+// ReactivateUser restores a deactivated account
+func (h *AdminUserHandler) ReactivateUser(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+// ForcePasswordReset removes an account's password and logs it out
+// everywhere, then emails the user a reset link. Until they reset it they can
+// only sign in by other means, such as a passkey.
+func (h *AdminUserHandler) ForcePasswordReset(c *gin.Context) {
+	id, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+
+	var user sqlc.User
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		user, err = q.AdminGetUserForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !user.IsActive {
+			return errUserInactive
+		}
+
+		if err := q.ClearUserPassword(ctx, id); err != nil {
+			return err
+		}
+		if err := q.DeleteUserRefreshTokens(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, q, auditEvent(c, audit.ActionPasswordResetForced, audit.TargetUser, id))
+	})
+	if err != nil {
+		respondAdminUserError(c, err, "failed to reset password")
+		return
+	}
+
+	// Kill outstanding access tokens
+	if err := h.revocations.RevokeUser(ctx, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+
+	if err := h.passwords.sendResetLink(c.Request.Context(), user.Email); err != nil {
+		h.logger.Error().
+			Err(err).
+			Int64("user_id", id).
+			Str("request_id", c.GetString("request_id")).
+			Msg("Failed to send password reset link")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "password removed but the reset email could not be sent"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset link sent"})
+}
+
+// RevokeSessions logs a user out of every session
+func (h *AdminUserHandler) RevokeSessions(c *gin.Context) {
+	id, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		if _, err := q.AdminGetUser(ctx, id); err != nil {
+			return err
+		}
+		if err := q.DeleteUserRefreshTokens(ctx, id); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, q, auditEvent(c, audit.ActionSessionsRevoked, audit.TargetUser, id))
+	})
+	if err != nil {
+		respondAdminUserError(c, err, "failed to revoke sessions")
+		return
+	}
+
+	// Kill outstanding access tokens
+	if err := h.revocations.RevokeUser(ctx, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "sessions revoked successfully"})
+}
+
+// DeleteUser permanently deletes an account and everything it owns. The last
+// owner of an organization cannot be deleted, and admins cannot delete
+// themselves.
+func (h *AdminUserHandler) DeleteUser(c *gin.Context) {
+	id, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+	if id == c.GetInt64("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot delete your own account here"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		user, err := q.AdminGetUserForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		owned, err := q.CountSoleOwnedOrganizations(ctx, id)
+		if err != nil {
+			return err
+		}
+		if owned > 0 {
+			return errUserOwnsOrg
+		}
+
+		if _, err := q.HardDeleteUser(ctx, id); err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionUserHardDeleted, audit.TargetUser, id)
+		event.Diff = map[string]audit.Change{
+			"email":     {From: user.Email},
+			"full_name": {From: user.FullName},
+		}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		respondAdminUserError(c, err, "failed to delete user")
+		return
+	}
+
+	// Clears cached checks; the user's tokens fail from now on
+	if err := h.revocations.RevokeUser(ctx, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user deleted successfully"})
+}
+
+// Impersonate issues a short-lived access token for the user whose act claim
+// names the admin. Accounts holding any permission cannot be impersonated, so
+// the token never grants more than the admin already has.
+func (h *AdminUserHandler) Impersonate(c *gin.Context) {
+	id, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+	adminID := c.GetInt64("user_id")
+	if id == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot impersonate yourself"})
+		return
+	}
+
+	var user sqlc.User
+	var roles []string
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		user, err = q.AdminGetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !user.IsActive {
+			return errUserInactive
+		}
+
+		permissions, err := q.ListUserPermissions(ctx, id)
+		if err != nil {
+			return err
+		}
+		if user.IsAdmin || len(permissions) > 0 {
+			return errUserIsStaff
+		}
+
+		roles, err = q.ListUserRoles(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// The token is only issued once the audit log has the event
+		return audit.Record(ctx, q, auditEvent(c, audit.ActionUserImpersonated, audit.TargetUser, id))
+	})
+	if err != nil {
+		respondAdminUserError(c, err, "failed to impersonate user")
+		return
+	}
+
+	actor := auth.Actor{
+		Subject: strconv.FormatInt(adminID, 10),
+		UserID:  adminID,
+		Email:   c.GetString("user_email"),
+	}
+	accessToken, err := h.jwtManager.IssueAccessTokenWithExpiry(auth.Claims{
+		UserID: user.ID,
+		Email:  user.Email,
+		Roles:  roles,
+		Actor:  &actor,
+	}, h.impersonationExpiry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	h.logger.Info().
+		Str("event", "impersonation_started").
+		Int64("user_id", user.ID).
+		Int64("admin_id", adminID).
+		Str("request_id", c.GetString("request_id")).
+		Msg("Admin started impersonating user")
+
+	c.JSON(http.StatusOK, ImpersonateResponse{
+		AccessToken: accessToken,
+		ExpiresAt:   time.Now().Add(h.impersonationExpiry),
+		User:        newUserInfo(user),
+		Actor:       actor,
+	})
+}
+
+// UnlockUser clears failed login attempts and any lockout on an account
+func (h *AdminUserHandler) UnlockUser(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	user, err := h.store.GetUserByID(c.Request.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var unlocked bool
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		unlocked, err = h.throttle.Unlock(ctx, q, user.Email)
+		if err != nil || !unlocked {
+			return err
+		}
+		return audit.Record(ctx, q, auditEvent(c, audit.ActionUserUnlocked, audit.TargetUser, user.ID))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlock user"})
+		return
+	}
+
+	if unlocked {
+		h.logger.Info().
+			Str("event", "login_unlock").
+			Int64("user_id", user.ID).
+			Int64("admin_id", c.GetInt64("user_id")).
+			Str("request_id", c.GetString("request_id")).
+			Msg("Account unlocked by admin")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user unlocked successfully"})
+}
+
+// setActive deactivates or reactivates the account in the :id parameter
+func (h *AdminUserHandler) setActive(c *gin.Context, active bool) {
+	id, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+	if !active && id == c.GetInt64("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot deactivate your own account here"})
+		return
+	}
+
+	action, message := audit.ActionUserReactivated, "user reactivated successfully"
+	if !active {
+		action, message = audit.ActionUserDeactivated, "user deactivated successfully"
+	}
+
+	var user sqlc.User
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		previous, err := q.AdminGetUserForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if previous.IsActive == active {
+			if active {
+				return errUserActive
+			}
+			return errUserInactive
+		}
+
+		user, err = q.SetUserActive(ctx, sqlc.SetUserActiveParams{ID: id, IsActive: active})
+		if err != nil {
+			return err
+		}
+		if !active {
+			if err := q.DeleteUserRefreshTokens(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		event := auditEvent(c, action, audit.TargetUser, id)
+		event.Diff = map[string]audit.Change{"is_active": {From: previous.IsActive, To: active}}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		respondAdminUserError(c, err, "failed to update user")
+		return
+	}
+
+	if !active {
+		// Kill outstanding access tokens
+		if err := h.revocations.RevokeUser(ctx, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"user":    newAdminUserInfo(user),
+	})
+}
+
+// setAdminRole grants or removes the built-in admin role to match is_admin
+func setAdminRole(c *gin.Context, q sqlc.Querier, userID int64, isAdmin bool) error {
+	ctx := c.Request.Context()
+	role, err := q.GetRoleByName(ctx, adminRole)
+	if err != nil {
+		return err
+	}
+
+	if isAdmin {
+		return q.AssignUserRole(ctx, sqlc.AssignUserRoleParams{UserID: userID, RoleID: role.ID})
+	}
+	_, err = removeUserRole(ctx, q, userID, role)
+	return err
+}
+
+// canManageRoles reports whether the caller may grant roles
+func canManageRoles(c *gin.Context) bool {
+	value, _ := c.Get("claims")
+	claims, ok := value.(*auth.Claims)
+	return ok && claims.HasPermission("roles:write")
+}
+
+// parseAdminUserID reads the :id path parameter, writing the error response
+// itself when it is malformed
+func parseAdminUserID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondAdminUserError maps account management errors to responses, falling
+// back to a 500 with message
+func respondAdminUserError(c *gin.Context, err error, message string) {
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, errEmailRegistered):
+		c.JSON(http.StatusConflict, gin.H{"error": "email already registered"})
+	case errors.Is(err, errUserInactive):
+		c.JSON(http.StatusConflict, gin.H{"error": "user is deactivated"})
+	case errors.Is(err, errUserActive):
+		c.JSON(http.StatusConflict, gin.H{"error": "user is already active"})
+	case errors.Is(err, errUserOwnsOrg):
+		c.JSON(http.StatusConflict, gin.H{"error": "user is the last owner of an organization"})
+	case errors.Is(err, errLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": "user is the last admin"})
+	case errors.Is(err, errUserIsStaff):
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot impersonate a staff account"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func newAdminUserInfo(user sqlc.User) AdminUserInfo {
+	return AdminUserInfo{
+		ID:                     user.ID,
+		Email:                  user.Email,
+		FullName:               user.FullName,
+		IsActive:               user.IsActive,
+		IsAdmin:                user.IsAdmin,
+		EmailVerified:          user.EmailVerifiedAt.Valid,
+		PasswordChangeRequired: user.PasswordChangeRequired,
+		HasPassword:            user.PasswordHash != "",
+		CreatedAt:              user.CreatedAt,
+		UpdatedAt:              user.UpdatedAt,
+	}
+}
@@ -0,0 +1,174 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/api/middleware"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// newAdminServer adds the account management routes to a test server whose
+// first user holds the admin role
+func newAdminServer(t *testing.T, emails ...string) *testServer {
+	t.Helper()
+
+	s := newTestServer(t, emails...)
+	s.store.roles[1] = []string{"admin"}
+	passwordHandler := handlers.NewPasswordHandler(s.store, s.authHandler, s.revocations, &auth.PasswordPolicy{MinLength: 8}, s.outbox, "http://localhost:3000", time.Hour, zerolog.Nop())
+	s.router.POST("/api/v1/auth/password/reset", passwordHandler.ResetPassword)
+
+	adminUserHandler := handlers.NewAdminUserHandler(s.store, s.jwtManager, s.revocations, s.throttle, passwordHandler, s.verification, 15*time.Minute, zerolog.Nop())
+	s.admin.PUT("/users/:id", middleware.RequirePermission("users:write"), adminUserHandler.UpdateUser)
+	s.admin.DELETE("/users/:id", middleware.RequirePermission("users:delete"), adminUserHandler.DeleteUser)
+	s.admin.POST("/users/:id/deactivate", middleware.RequirePermission("users:delete"), adminUserHandler.DeactivateUser)
+	s.admin.POST("/users/:id/reactivate", middleware.RequirePermission("users:write"), adminUserHandler.ReactivateUser)
+	s.admin.POST("/users/:id/password-reset", middleware.RequirePermission("users:write"), adminUserHandler.ForcePasswordReset)
+	return s
+}
+
+// canLogIn reports whether email can sign in with password
+func (s *testServer) canLogIn(email, password string) bool {
+	w := s.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{"email": email, "password": password})
+	return w.Code == http.StatusOK
+}
+
+func TestAdminDeactivateUser(t *testing.T) {
+	s := newAdminServer(t, "admin@example.com", "user@example.com")
+	admin := s.login("admin@example.com").AccessToken
+	user := s.login("user@example.com")
+
+	w := s.do(http.MethodPost, "/api/v1/admin/users/2/deactivate", admin, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.False(t, s.store.users[2].IsActive)
+	assert.Contains(t, s.store.actions(), audit.ActionUserDeactivated)
+
+	assert.False(t, s.signedIn(user.AccessToken), "the user is logged out everywhere")
+	w = s.do(http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refresh_token": user.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.False(t, s.canLogIn("user@example.com", testPassword))
+
+	w = s.do(http.MethodPost, "/api/v1/admin/users/2/deactivate", admin, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = s.do(http.MethodPost, "/api/v1/admin/users/1/deactivate", admin, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "admins cannot lock themselves out")
+	w = s.do(http.MethodPost, "/api/v1/admin/users/99/deactivate", admin, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = s.do(http.MethodPost, "/api/v1/admin/users/2/reactivate", admin, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.True(t, s.store.users[2].IsActive)
+	assert.Contains(t, s.store.actions(), audit.ActionUserReactivated)
+
+	assert.True(t, s.canLogIn("user@example.com", testPassword))
+	assert.False(t, s.signedIn(user.AccessToken), "tokens revoked on deactivation stay revoked")
+
+	w = s.do(http.MethodPost, "/api/v1/admin/users/2/reactivate", admin, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestAdminForcePasswordReset(t *testing.T) {
+	s := newAdminServer(t, "admin@example.com", "user@example.com")
+	admin := s.login("admin@example.com").AccessToken
+	user := s.login("user@example.com").AccessToken
+
+	w := s.do(http.MethodPost, "/api/v1/admin/users/2/password-reset", admin, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, s.store.actions(), audit.ActionPasswordResetForced)
+
+	assert.False(t, s.signedIn(user), "the user is logged out everywhere")
+	assert.False(t, s.canLogIn("user@example.com", testPassword), "the old password is gone")
+
+	messages := s.mail()
+	require.Len(t, messages, 1, "the user is mailed a reset link")
+	w = s.do(http.MethodPost, "/api/v1/auth/password/reset", "", map[string]string{
+		"token":        linkToken(t, messages[0]),
+		"new_password": "correct-horse-battery",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.True(t, s.canLogIn("user@example.com", "correct-horse-battery"))
+
+	// Deactivated accounts keep their password until they are restored
+	w = s.do(http.MethodPost, "/api/v1/admin/users/2/deactivate", admin, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = s.do(http.MethodPost, "/api/v1/admin/users/2/password-reset", admin, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NotEmpty(t, s.store.users[2].PasswordHash)
+}
+
+func TestAdminDeleteUser(t *testing.T) {
+	s := newAdminServer(t, "admin@example.com", "user@example.com", "owner@example.com")
+	admin := s.login("admin@example.com").AccessToken
+	user := s.login("user@example.com")
+
+	w := s.do(http.MethodDelete, "/api/v1/admin/users/2", admin, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, s.store.users, int64(2))
+	assert.Contains(t, s.store.actions(), audit.ActionUserHardDeleted)
+
+	assert.False(t, s.signedIn(user.AccessToken))
+	w = s.do(http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refresh_token": user.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = s.do(http.MethodDelete, "/api/v1/admin/users/2", admin, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = s.do(http.MethodDelete, "/api/v1/admin/users/1", admin, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// An organization cannot be left without an owner
+	s.store.members = append(s.store.members, sqlc.OrganizationMember{OrgID: 1, UserID: 3, Role: "owner"})
+	w = s.do(http.MethodDelete, "/api/v1/admin/users/3", admin, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, s.store.users, int64(3))
+}
+
+func TestAdminUserPermissions(t *testing.T) {
+	s := newAdminServer(t, "admin@example.com", "support@example.com", "user@example.com")
+	s.store.permissions[2] = []string{"users:read", "users:write"}
+	support := s.login("support@example.com").AccessToken
+	user := s.login("user@example.com").AccessToken
+
+	// Each action needs its own permission
+	for _, path := range []string{"/users/3/deactivate", "/users/3/reactivate", "/users/3/password-reset"} {
+		w := s.do(http.MethodPost, "/api/v1/admin"+path, user, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code, path)
+	}
+	w := s.do(http.MethodDelete, "/api/v1/admin/users/3", user, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = s.do(http.MethodPost, "/api/v1/admin/users/3/deactivate", support, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code, "deactivating needs users:delete")
+	w = s.do(http.MethodDelete, "/api/v1/admin/users/3", support, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.True(t, s.store.users[3].IsActive)
+	assert.True(t, s.signedIn(user))
+
+	// Granting admin needs roles:write as well
+	w = s.do(http.MethodPut, "/api/v1/admin/users/3", support, map[string]bool{"is_admin": true})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, s.store.roles[3])
+
+	// Tokens a client holds on its own behalf cannot manage users
+	s.store.clients = append(s.store.clients, sqlc.OauthClient{ClientID: "reports"})
+	clientToken, err := s.jwtManager.IssueAccessToken(auth.Claims{ClientID: "reports", Permissions: []string{"users:write", "users:delete"}})
+	require.NoError(t, err)
+	w = s.do(http.MethodPost, "/api/v1/admin/users/3/deactivate", clientToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Staff who manage roles still cannot take admin access from its last holder
+	s.store.permissions[2] = append(s.store.permissions[2], "roles:write")
+	admin := s.store.users[1]
+	admin.IsAdmin = true
+	s.store.users[1] = admin
+	support = s.login("support@example.com").AccessToken
+	w = s.do(http.MethodPut, "/api/v1/admin/users/1", support, map[string]bool{"is_admin": false})
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Equal(t, []string{"admin"}, s.store.roles[1])
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// maxAPIKeysPerUser bounds how many keys one user can hold
+const maxAPIKeysPerUser = 25
+
+type APIKeyHandler struct {
+	store db.Store
+}
+
+func NewAPIKeyHandler(store db.Store) *APIKeyHandler {
+	return &APIKeyHandler{store: store}
+}
+
+// CreateAPIKeyRequest represents the create API key request body
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyInfo describes one of the user's API keys. The secret is never shown
+// again after creation.
+type APIKeyInfo struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP *string    `json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ListAPIKeys returns the authenticated user's API keys
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	keys, err := h.store.ListUserAPIKeys(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list API keys"})
+		return
+	}
+
+	apiKeys := make([]APIKeyInfo, 0, len(keys))
+	for _, key := range keys {
+		apiKeys = append(apiKeys, newAPIKeyInfo(key))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": apiKeys})
+}
+
+// CreateAPIKey issues a new API key for the authenticated user. Scopes must be
+// permissions the user holds; the key is returned once.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	existing, err := h.store.ListUserAPIKeys(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list API keys"})
+		return
+	}
+	if len(existing) >= maxAPIKeysPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": "too many API keys; revoke one first"})
+		return
+	}
+
+	// A key can never do more than its owner
+	granted, err := h.store.ListUserPermissions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get permissions"})
+		return
+	}
+	held := make(map[string]bool, len(granted))
+	for _, permission := range granted {
+		held[permission] = true
+	}
+	scopes := []string{}
+	seen := make(map[string]bool, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !held[scope] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scope not granted to user: " + scope})
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	key, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate API key"})
+		return
+	}
+
+	var expiresAt sql.NullTime
+	if req.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *req.ExpiresAt, Valid: true}
+	}
+
+	var apiKey sqlc.ApiKey
+	ctx := c.Request.Context()
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		apiKey, err = q.CreateAPIKey(ctx, sqlc.CreateAPIKeyParams{
+			UserID:    userID,
+			Name:      req.Name,
+			Prefix:    prefix,
+			KeyHash:   auth.HashToken(key),
+			Scopes:    scopes,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionAPIKeyCreated, audit.TargetAPIKey, apiKey.ID)
+		event.Diff = map[string]audit.Change{
+			"name":   {To: apiKey.Name},
+			"scopes": {To: scopes},
+		}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"key":     key,
+		"api_key": newAPIKeyInfo(apiKey),
+	})
+}
+
+// RevokeAPIKey deletes one of the authenticated user's API keys
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid API key ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		rows, err := q.DeleteUserAPIKey(ctx, sqlc.DeleteUserAPIKeyParams{
+			ID:     id,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		return audit.Record(ctx, q, auditEvent(c, audit.ActionAPIKeyRevoked, audit.TargetAPIKey, id))
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// newAPIKeyInfo returns the public view of key
+func newAPIKeyInfo(key sqlc.ApiKey) APIKeyInfo {
+	info := APIKeyInfo{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    auth.APIKeyDisplayPrefix(key.Prefix),
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if info.Scopes == nil {
+		info.Scopes = []string{}
+	}
+	if key.ExpiresAt.Valid {
+		info.ExpiresAt = &key.ExpiresAt.Time
+	}
+	if key.LastUsedAt.Valid {
+		info.LastUsedAt = &key.LastUsedAt.Time
+	}
+	if key.LastUsedIp.Valid {
+		info.LastUsedIP = &key.LastUsedIp.String
+	}
+	return info
+}
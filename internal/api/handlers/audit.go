@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+type AuditHandler struct {
+	store db.Store
+}
+
+func NewAuditHandler(store db.Store) *AuditHandler {
+	return &AuditHandler{store: store}
+}
+
+// AuditEventInfo represents an audit log entry
+type AuditEventInfo struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorID    *int64          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   *int64          `json:"target_id"`
+	IPAddress  string          `json:"ip_address"`
+	UserAgent  string          `json:"user_agent"`
+	RequestID  string          `json:"request_id"`
+	Diff       json.RawMessage `json:"diff"`
+	Hash       string          `json:"hash"`
+}
+
+// ListEvents returns audit events newest first, optionally filtered by actor,
+// action and time range. Pass next_cursor back as cursor for the next page.
+func (h *AuditHandler) ListEvents(c *gin.Context) {
+	var (
+		params sqlc.ListAuditEventsParams
+		ok     bool
+	)
+	if params.ActorID, ok = queryID(c, "actor_id"); !ok {
+		return
+	}
+	if params.BeforeID, ok = queryID(c, "cursor"); !ok {
+		return
+	}
+	if params.Since, ok = queryTime(c, "since"); !ok {
+		return
+	}
+	if params.Until, ok = queryTime(c, "until"); !ok {
+		return
+	}
+	if action := c.Query("action"); action != "" {
+		params.Action = &action
+	}
+
+	limit := 50
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 200 {
+			limit = l
+		}
+	}
+	params.Limit = int32(limit)
+
+	events, err := h.store.ListAuditEvents(c.Request.Context(), params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list audit events"})
+		return
+	}
+
+	infos := make([]AuditEventInfo, 0, len(events))
+	for _, event := range events {
+		infos = append(infos, newAuditEventInfo(event))
+	}
+
+	// A full page may have more behind it
+	var nextCursor *int64
+	if len(events) == limit {
+		nextCursor = &events[len(events)-1].ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":      infos,
+		"next_cursor": nextCursor,
+	})
+}
+
+// VerifyChain recomputes the hash chain and reports the first event that was
+// altered, or that follows a removed event
+func (h *AuditHandler) VerifyChain(c *gin.Context) {
+	result, err := h.store.VerifyAuditChain(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify audit log"})
+		return
+	}
+
+	resp := gin.H{
+		"valid":   result.BrokenID == 0,
+		"checked": result.Checked,
+	}
+	if result.BrokenID != 0 {
+		resp["first_invalid_id"] = result.BrokenID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func newAuditEventInfo(event sqlc.AuditEvent) AuditEventInfo {
+	info := AuditEventInfo{
+		ID:         event.ID,
+		OccurredAt: event.OccurredAt,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		IPAddress:  event.IpAddress,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+		Diff:       event.Diff,
+		Hash:       event.Hash,
+	}
+	if event.ActorID.Valid {
+		info.ActorID = &event.ActorID.Int64
+	}
+	if event.TargetID.Valid {
+		info.TargetID = &event.TargetID.Int64
+	}
+	return info
+}
+
+// queryID reads an optional ID from the query string, responding with 400
+// when it is malformed
+func queryID(c *gin.Context, name string) (*int64, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return nil, false
+	}
+	return &id, true
+}
+
+// queryTime reads an optional RFC 3339 timestamp from the query string,
+// responding with 400 when it is malformed
+func queryTime(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be an RFC 3339 timestamp"})
+		return nil, false
+	}
+	return &t, true
+}
+
+// auditEvent describes an action taken during the request c. The actor is the
+// signed-in user, if there is one, or the admin impersonating them.
+func auditEvent(c *gin.Context, action, targetType string, targetID int64) audit.Event {
+	actorID := c.GetInt64("user_id")
+	if impersonator := c.GetInt64("actor_id"); impersonator != 0 {
+		actorID = impersonator
+	}
+
+	return audit.Event{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		RequestID:  c.GetString("request_id"),
+	}
+}
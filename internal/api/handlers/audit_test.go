@@ -0,0 +1,74 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+func TestAuditEventsAreRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hash, err := auth.HashPassword("password123")
+	require.NoError(t, err)
+	verified := sqlc.User{ID: 1, Email: "known@example.com", PasswordHash: hash, IsActive: true}
+	verified.EmailVerifiedAt.Valid = true
+
+	setup := func(store *memStore) *gin.Engine {
+		jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, 7*24*time.Hour)
+		throttle := auth.NewLoginThrottle(store, auth.LockoutPolicy{})
+		authHandler := handlers.NewAuthHandler(store, jwtManager, nil, nil, nil, throttle, &auth.PasswordPolicy{MinLength: 8}, false, false, false, zerolog.Nop())
+
+		router := gin.New()
+		router.POST("/register", authHandler.Register)
+		router.POST("/login", authHandler.Login)
+		return router
+	}
+	post := func(router *gin.Engine, path string, body map[string]interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	login := map[string]interface{}{"email": "known@example.com", "password": "password123"}
+
+	t.Run("events are recorded", func(t *testing.T) {
+		store := newMemStore(verified)
+		router := setup(store)
+
+		assert.Equal(t, http.StatusOK, post(router, "/login", login).Code)
+		assert.Equal(t, []string{audit.ActionLogin}, store.actions())
+	})
+
+	t.Run("a failed write fails the request", func(t *testing.T) {
+		store := newMemStore(verified)
+		store.auditErr = errors.New("audit log unavailable")
+		router := setup(store)
+
+		w := post(router, "/login", login)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NotContains(t, w.Body.String(), "access_token")
+
+		w = post(router, "/register", map[string]interface{}{
+			"email":     "new@example.com",
+			"password":  "correct-horse-battery",
+			"full_name": "New User",
+		})
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -1,40 +1,72 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
 	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
 	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
 )
 
+var (
+	errRefreshTokenNotFound = errors.New("refresh token not found")
+	errRefreshTokenMismatch = errors.New("refresh token does not belong to user")
+)
+
 type AuthHandler struct {
-	queries    *sqlc.Queries
-	jwtManager *auth.JWTManager
-	db         *sql.DB
+	store                db.Store
+	jwtManager           *auth.JWTManager
+	revocations          *auth.RevocationList
+	verification         *VerificationHandler
+	mfa                  *MFAHandler
+	throttle             *auth.LoginThrottle
+	passwordPolicy       *auth.PasswordPolicy
+	requireVerifiedEmail bool
+	concealEmails        bool
+	inviteOnly           bool
+	logger               zerolog.Logger
 }
 
-func NewAuthHandler(queries *sqlc.Queries, jwtManager *auth.JWTManager, db *sql.DB) *AuthHandler {
+func NewAuthHandler(store db.Store, jwtManager *auth.JWTManager, revocations *auth.RevocationList, verification *VerificationHandler, mfa *MFAHandler, throttle *auth.LoginThrottle, passwordPolicy *auth.PasswordPolicy, requireVerifiedEmail, concealEmails, inviteOnly bool, logger zerolog.Logger) *AuthHandler {
 	return &AuthHandler{
-		queries:    queries,
-		jwtManager: jwtManager,
-		db:         db,
+		store:                store,
+		jwtManager:           jwtManager,
+		revocations:          revocations,
+		verification:         verification,
+		mfa:                  mfa,
+		throttle:             throttle,
+		passwordPolicy:       passwordPolicy,
+		requireVerifiedEmail: requireVerifiedEmail,
+		concealEmails:        concealEmails,
+		inviteOnly:           inviteOnly,
+		logger:               logger,
 	}
 }
 
 // RegisterRequest represents the registration request body
 type RegisterRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8"`
-	FullName string `json:"full_name" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	FullName   string `json:"full_name" binding:"required"`
+	DeviceName string `json:"device_name,omitempty" binding:"max=100"`
 }
 
 // LoginRequest represents the login request body
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name,omitempty" binding:"max=100"`
 }
 
 // RefreshTokenRequest represents the refresh token request body
@@ -52,20 +84,44 @@ type AuthResponse struct {
 
 // UserInfo represents basic user information
 type UserInfo struct {
-	ID       int64  `json:"id"`
-	Email    string `json:"email"`
-	FullName string `json:"full_name"`
-	IsAdmin  bool   `json:"is_admin"`
+	ID                     int64  `json:"id"`
+	Email                  string `json:"email"`
+	FullName               string `json:"full_name"`
+	IsAdmin                bool   `json:"is_admin"`
+	EmailVerified          bool   `json:"email_verified"`
+	PasswordChangeRequired bool   `json:"password_change_required"`
+}
+
+// newUserInfo returns the public view of user
+func newUserInfo(user sqlc.User) UserInfo {
+	return UserInfo{
+		ID:                     user.ID,
+		Email:                  user.Email,
+		FullName:               user.FullName,
+		IsAdmin:                user.IsAdmin,
+		EmailVerified:          user.EmailVerifiedAt.Valid,
+		PasswordChangeRequired: user.PasswordChangeRequired,
+	}
 }
 
-// Register creates a new user account
+// Register creates a new user account. In invite-only mode accounts can only
+// be created by accepting an invitation.
 func (h *AuthHandler) Register(c *gin.Context) {
+	if h.inviteOnly {
+		c.JSON(http.StatusForbidden, gin.H{"error": "registration is by invitation only"})
+		return
+	}
+
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if !checkPasswordPolicy(c, h.passwordPolicy, req.Password, req.Email, req.FullName) {
+		return
+	}
+
 	// Hash password
 	passwordHash, err := auth.HashPassword(req.Password)
 	if err != nil {
@@ -74,57 +130,89 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// Create user
-	user, err := h.queries.CreateUser(c.Request.Context(), sqlc.CreateUserParams{
-		Email:        req.Email,
-		PasswordHash: passwordHash,
-		FullName:     req.FullName,
+	ctx := c.Request.Context()
+	var user sqlc.User
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		user, err = q.CreateUser(ctx, sqlc.CreateUserParams{
+			Email:        req.Email,
+			PasswordHash: passwordHash,
+			FullName:     req.FullName,
+		})
+		if err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionRegister, audit.TargetUser, user.ID)
+		event.ActorID = user.ID
+		return audit.Record(ctx, q, event)
 	})
 	if err != nil {
 		// Check if email already exists
-		if err.Error() == "duplicate key value violates unique constraint" {
-			c.JSON(http.StatusConflict, gin.H{"error": "email already registered"})
+		if isUniqueViolation(err) {
+			h.emailTaken(c, req.Email)
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
 		return
 	}
 
+	h.welcome(c, user, newSession(c, req.DeviceName))
+}
+
+// welcome responds to the creation of user's account, signing it in unless
+// its email has to be verified first
+func (h *AuthHandler) welcome(c *gin.Context, user sqlc.User, sess session) {
+	if !user.EmailVerifiedAt.Valid {
+		// The account exists either way; the user can ask for another link
+		if err := h.verification.sendVerification(c.Request.Context(), user, user.Email); err != nil {
+			h.logger.Error().
+				Err(err).
+				Int64("user_id", user.ID).
+				Str("request_id", c.GetString("request_id")).
+				Msg("Failed to send verification email")
+		}
+
+		if h.concealEmails {
+			c.JSON(http.StatusCreated, gin.H{"message": "verification email sent"})
+			return
+		}
+		if h.requireVerifiedEmail {
+			c.JSON(http.StatusCreated, gin.H{
+				"message": "verification email sent",
+				"user":    newUserInfo(user),
+			})
+			return
+		}
+	}
+
 	// Generate tokens
-	accessToken, err := h.jwtManager.GenerateAccessToken(user.ID, user.Email, user.IsAdmin)
+	resp, err := h.issueTokens(c.Request.Context(), h.store, user, sess)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate access token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue tokens"})
 		return
 	}
 
-	refreshToken, err := h.jwtManager.GenerateRefreshToken(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate refresh token"})
+	c.JSON(http.StatusCreated, resp)
+}
+
+// emailTaken answers a registration for an email that already has an account.
+// The password has been hashed by now, so this takes as long as a new account.
+func (h *AuthHandler) emailTaken(c *gin.Context, email string) {
+	if !h.concealEmails {
+		c.JSON(http.StatusConflict, gin.H{"error": "email already registered"})
 		return
 	}
 
-	// Store refresh token
-	expiresAt := time.Now().Add(168 * time.Hour) // 7 days
-	_, err = h.queries.CreateRefreshToken(c.Request.Context(), sqlc.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: expiresAt,
-	})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store refresh token"})
-		return
+	// Tell the owner rather than the caller
+	if err := h.verification.sendAccountExists(c.Request.Context(), email); err != nil {
+		h.logger.Error().
+			Err(err).
+			Str("request_id", c.GetString("request_id")).
+			Msg("Failed to send account exists email")
 	}
 
-	c.JSON(http.StatusCreated, AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresAt:    time.Now().Add(15 * time.Minute),
-		User: UserInfo{
-			ID:       user.ID,
-			Email:    user.Email,
-			FullName: user.FullName,
-			IsAdmin:  user.IsAdmin,
-		},
-	})
+	c.JSON(http.StatusCreated, gin.H{"message": "verification email sent"})
 }
 
 // Login authenticates a user
@@ -135,59 +223,189 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// Refuse attempts while the account or client is backing off
+	if h.throttled(c, req.Email) {
+		return
+	}
+
 	// Get user by email
-	user, err := h.queries.GetUserByEmail(c.Request.Context(), req.Email)
+	user, err := h.store.GetUserByEmail(c.Request.Context(), req.Email)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+			// Unknown and deactivated accounts cost as much as a wrong password
+			auth.SimulatePasswordCheck(req.Password)
+			h.loginFailed(c, req.Email)
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find user"})
 		return
 	}
 
-	// Verify password
-	if err := auth.VerifyPassword(req.Password, user.PasswordHash); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+	// Accounts created through an identity provider have no password
+	if user.PasswordHash == "" {
+		auth.SimulatePasswordCheck(req.Password)
+		h.loginFailed(c, req.Email)
 		return
 	}
 
-	// Generate tokens
-	accessToken, err := h.jwtManager.GenerateAccessToken(user.ID, user.Email, user.IsAdmin)
+	// Verify password
+	rehash, err := auth.VerifyPassword(req.Password, user.PasswordHash)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate access token"})
+		h.loginFailed(c, req.Email)
 		return
 	}
+	if rehash {
+		h.rehashPassword(c, user, req.Password)
+	}
 
-	refreshToken, err := h.jwtManager.GenerateRefreshToken(user.ID)
+	h.signIn(c, user, req.DeviceName)
+}
+
+// signIn finishes a login once the user has proven who they are. Unless the
+// email must be verified first or a second factor is required, it responds
+// with session tokens. Failed logins are forgotten only once no second factor
+// is outstanding, so a known password cannot reset the count between guesses
+// at the code.
+func (h *AuthHandler) signIn(c *gin.Context, user sqlc.User, deviceName string) {
+	if h.requireVerifiedEmail && !user.EmailVerifiedAt.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "email not verified"})
+		return
+	}
+
+	// A second factor is required before any tokens are issued
+	mfaEnabled, err := h.store.IsMFAEnabled(c.Request.Context(), user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check mfa"})
 		return
 	}
+	if mfaEnabled {
+		h.startMFAChallenge(c, user, deviceName)
+		return
+	}
+
+	if err := h.throttle.Succeed(c.Request.Context(), user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record login"})
+		return
+	}
+
+	h.respondWithTokens(c, user, deviceName)
+}
+
+// signInMultiFactor finishes a login proven with two factors at once, such as
+// a passkey with user verification, so no second factor is asked for
+func (h *AuthHandler) signInMultiFactor(c *gin.Context, user sqlc.User, deviceName string) {
+	if h.requireVerifiedEmail && !user.EmailVerifiedAt.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "email not verified"})
+		return
+	}
+
+	h.respondWithTokens(c, user, deviceName)
+}
 
-	// Store refresh token
-	expiresAt := time.Now().Add(168 * time.Hour)
-	_, err = h.queries.CreateRefreshToken(c.Request.Context(), sqlc.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: expiresAt,
+// respondWithTokens starts a session for user and responds with its tokens
+func (h *AuthHandler) respondWithTokens(c *gin.Context, user sqlc.User, deviceName string) {
+	ctx := c.Request.Context()
+	sess := newSession(c, deviceName)
+
+	var resp AuthResponse
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		resp, err = h.issueTokens(ctx, q, user, sess)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, q, loginEvent(c, user, sess))
 	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue tokens"})
 		return
 	}
 
-	c.JSON(http.StatusOK, AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresAt:    time.Now().Add(15 * time.Minute),
-		User: UserInfo{
-			ID:       user.ID,
-			Email:    user.Email,
-			FullName: user.FullName,
-			IsAdmin:  user.IsAdmin,
-		},
-	})
+	c.JSON(http.StatusOK, resp)
+}
+
+// loginEvent describes user signing in to the session sess
+func loginEvent(c *gin.Context, user sqlc.User, sess session) audit.Event {
+	event := auditEvent(c, audit.ActionLogin, audit.TargetUser, user.ID)
+	event.ActorID = user.ID
+	event.Diff = map[string]audit.Change{
+		"session_id": {To: sess.familyID},
+	}
+	return event
+}
+
+// rehashPassword upgrades a stored hash made with an outdated algorithm or
+// parameters. It is best effort: the old hash keeps working if this fails.
+// The update only applies while the stored hash is still the one verified,
+// so it cannot undo a password change made meanwhile.
+func (h *AuthHandler) rehashPassword(c *gin.Context, user sqlc.User, password string) {
+	hash, err := auth.HashPassword(password)
+	if err == nil {
+		err = h.store.RehashUserPassword(c.Request.Context(), sqlc.RehashUserPasswordParams{
+			PasswordHash: hash,
+			ID:           user.ID,
+			OldHash:      user.PasswordHash,
+		})
+	}
+	if err != nil {
+		h.logger.Error().
+			Err(err).
+			Int64("user_id", user.ID).
+			Str("request_id", c.GetString("request_id")).
+			Msg("Failed to rehash password")
+	}
+}
+
+// loginFailed records a failed login and responds with the generic error.
+// Unknown emails and wrong passwords take exactly the same path.
+func (h *AuthHandler) loginFailed(c *gin.Context, email string) {
+	h.recordFailure(c, email)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+}
+
+// recordFailure counts a failed login for email towards backoff and lockout,
+// logging any lockout it triggers
+func (h *AuthHandler) recordFailure(c *gin.Context, email string) {
+	lockouts, err := h.throttle.Fail(c.Request.Context(), email, c.ClientIP())
+	if err != nil {
+		h.logger.Error().
+			Err(err).
+			Str("request_id", c.GetString("request_id")).
+			Msg("Failed to record failed login")
+	}
+
+	for _, lockout := range lockouts {
+		h.logger.Warn().
+			Str("event", "login_lockout").
+			Str("scope", lockout.Scope).
+			Str("subject", lockout.Subject).
+			Int32("failures", lockout.Failures).
+			Time("locked_until", lockout.Until).
+			Str("client_ip", c.ClientIP()).
+			Str("request_id", c.GetString("request_id")).
+			Msg("Login locked out after repeated failures")
+	}
+}
+
+// throttled responds 429 if logins for email are backing off or locked out,
+// reporting whether it did
+func (h *AuthHandler) throttled(c *gin.Context, email string) bool {
+	wait, err := h.throttle.Check(c.Request.Context(), email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check login attempts"})
+		return true
+	}
+	if wait > 0 {
+		tooManyAttempts(c, wait)
+		return true
+	}
+	return false
+}
+
+// tooManyAttempts responds 429, telling the client to wait before retrying
+func tooManyAttempts(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts, try again later"})
 }
 
 // RefreshToken issues a new access token using a refresh token
@@ -205,83 +423,233 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Check if refresh token exists in database
-	storedToken, err := h.queries.GetRefreshToken(c.Request.Context(), req.RefreshToken)
+	// Rotate inside a transaction: the row lock makes concurrent refreshes with
+	// the same token serialize, so only the first one can consume it.
+	var (
+		resp   AuthResponse
+		reused *sqlc.RefreshToken
+	)
+	ctx := c.Request.Context()
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		storedToken, err := q.GetRefreshTokenForUpdate(ctx, auth.HashToken(req.RefreshToken))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errRefreshTokenNotFound
+			}
+			return err
+		}
+		if storedToken.UserID != userID {
+			return errRefreshTokenMismatch
+		}
+
+		// A consumed token being presented again means it was stolen, either by
+		// whoever is calling now or by whoever rotated it first. Revoke the family.
+		if storedToken.ConsumedAt.Valid {
+			reused = &storedToken
+			return q.DeleteRefreshTokenFamily(ctx, storedToken.FamilyID)
+		}
+
+		if _, err := q.ConsumeRefreshToken(ctx, storedToken.ID); err != nil {
+			return err
+		}
+
+		user, err := q.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		// The rotated token continues the same session
+		sess := newSession(c, storedToken.DeviceName.String)
+		sess.familyID = storedToken.FamilyID
+		sess.startedAt = storedToken.SessionStartedAt
+		sess.orgID = storedToken.ActiveOrgID.Int64
+
+		resp, err = h.issueTokens(ctx, q, user, sess)
+		return err
+	})
 	if err != nil {
-		if err == sql.ErrNoRows {
+		switch {
+		case errors.Is(err, errRefreshTokenNotFound), errors.Is(err, errRefreshTokenMismatch):
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token not found or expired"})
-			return
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate refresh token"})
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify refresh token"})
 		return
 	}
 
-	// Get user
-	user, err := h.queries.GetUserByID(c.Request.Context(), userID)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+	if reused != nil {
+		// Access tokens already issued from the family go with it
+		if err := h.revocations.RevokeSession(ctx, reused.UserID, reused.FamilyID.String()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate refresh token"})
+			return
+		}
+
+		h.logger.Warn().
+			Str("event", "refresh_token_reuse").
+			Int64("user_id", reused.UserID).
+			Str("family_id", reused.FamilyID.String()).
+			Str("client_ip", c.ClientIP()).
+			Str("request_id", c.GetString("request_id")).
+			Msg("Refresh token reuse detected, token family revoked")
+
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token not found or expired"})
 		return
 	}
 
-	// Generate new access token
-	accessToken, err := h.jwtManager.GenerateAccessToken(user.ID, user.Email, user.IsAdmin)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate access token"})
+	c.JSON(http.StatusOK, resp)
+}
+
+// Logout ends the session a refresh token belongs to, rejecting its access
+// tokens as well as its refresh token
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Optionally rotate refresh token (recommended for security)
-	newRefreshToken, err := h.jwtManager.GenerateRefreshToken(user.ID)
+	ctx := c.Request.Context()
+
+	// Revoke access token. Tokens issued before sessions existed can only be
+	// revoked one at a time.
+	if parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2); len(parts) == 2 && parts[0] == "Bearer" {
+		if claims, err := h.jwtManager.ValidateToken(parts[1]); err == nil {
+			if claims.SessionID != "" {
+				err = h.revocations.RevokeSession(ctx, claims.UserID, claims.SessionID)
+			} else {
+				err = h.revocations.Revoke(ctx, claims)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke access token"})
+				return
+			}
+		}
+	}
+
+	token, err := h.store.GetRefreshToken(ctx, auth.HashToken(req.RefreshToken))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate refresh token"})
+		// Don't expose if token doesn't exist
+		c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
 		return
 	}
 
-	// Delete old refresh token
-	if err := h.queries.DeleteRefreshToken(c.Request.Context(), storedToken.Token); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate refresh token"})
+	// Revoke the session's access tokens before deleting its refresh tokens
+	if err := h.revocations.RevokeSession(ctx, token.UserID, token.FamilyID.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
 		return
 	}
+	if err := h.store.DeleteRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+}
+
+// session describes the login a refresh token belongs to
+type session struct {
+	familyID   uuid.UUID
+	startedAt  time.Time
+	deviceName string
+	userAgent  string
+	ipAddress  string
+	orgID      int64
+}
+
+// newSession starts a session for the client making the request
+func newSession(c *gin.Context, deviceName string) session {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
 
-	// Store new refresh token
-	expiresAt := time.Now().Add(168 * time.Hour)
-	_, err = h.queries.CreateRefreshToken(c.Request.Context(), sqlc.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		Token:     newRefreshToken,
-		ExpiresAt: expiresAt,
+	return session{
+		familyID:   uuid.New(),
+		startedAt:  time.Now(),
+		deviceName: deviceName,
+		userAgent:  userAgent,
+		ipAddress:  c.ClientIP(),
+	}
+}
+
+// issueTokens creates an access token and a stored refresh token for user
+// within sess
+func (h *AuthHandler) issueTokens(ctx context.Context, q sqlc.Querier, user sqlc.User, sess session) (AuthResponse, error) {
+	accessToken, orgID, err := h.issueAccessToken(ctx, q, user, sess)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+
+	refreshToken, err := h.jwtManager.GenerateRefreshToken(user.ID)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+
+	// Store refresh token digest, never the token itself
+	_, err = q.CreateRefreshToken(ctx, sqlc.CreateRefreshTokenParams{
+		UserID:           user.ID,
+		TokenHash:        auth.HashToken(refreshToken),
+		ExpiresAt:        time.Now().Add(h.jwtManager.RefreshExpiry()),
+		FamilyID:         sess.familyID,
+		UserAgent:        sess.userAgent,
+		IpAddress:        sess.ipAddress,
+		DeviceName:       sql.NullString{String: sess.deviceName, Valid: sess.deviceName != ""},
+		SessionStartedAt: sess.startedAt,
+		ActiveOrgID:      sql.NullInt64{Int64: orgID, Valid: orgID != 0},
 	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store refresh token"})
-		return
+		return AuthResponse{}, err
 	}
 
-	c.JSON(http.StatusOK, AuthResponse{
+	return AuthResponse{
 		AccessToken:  accessToken,
-		RefreshToken: newRefreshToken,
-		ExpiresAt:    time.Now().Add(15 * time.Minute),
-		User: UserInfo{
-			ID:       user.ID,
-			Email:    user.Email,
-			FullName: user.FullName,
-			IsAdmin:  user.IsAdmin,
-		},
-	})
+		RefreshToken: refreshToken,
+		ExpiresAt:    time.Now().Add(h.jwtManager.AccessExpiry()),
+		User:         newUserInfo(user),
+	}, nil
 }
 
-// Logout invalidates a user's refresh token
-func (h *AuthHandler) Logout(c *gin.Context) {
-	var req RefreshTokenRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// issueAccessToken signs an access token for user within sess. A session
+// whose user has left its organization carries on without one, so the
+// organization the token acts in is returned.
+func (h *AuthHandler) issueAccessToken(ctx context.Context, q sqlc.Querier, user sqlc.User, sess session) (string, int64, error) {
+	// Authorization travels in the token so requests need no lookup
+	roles, err := q.ListUserRoles(ctx, user.ID)
+	if err != nil {
+		return "", 0, err
+	}
+	permissions, err := q.ListUserPermissions(ctx, user.ID)
+	if err != nil {
+		return "", 0, err
 	}
 
-	// Delete refresh token
-	if err := h.queries.DeleteRefreshToken(c.Request.Context(), req.RefreshToken); err != nil {
-		// Don't expose if token doesn't exist
-		c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
-		return
+	claims := auth.Claims{
+		UserID:      user.ID,
+		Email:       user.Email,
+		IsAdmin:     user.IsAdmin,
+		SessionID:   sess.familyID.String(),
+		Roles:       roles,
+		Permissions: permissions,
+	}
+	if sess.orgID != 0 {
+		member, err := q.GetOrganizationMember(ctx, sqlc.GetOrganizationMemberParams{
+			OrgID:  sess.orgID,
+			UserID: user.ID,
+		})
+		if err != nil && err != sql.ErrNoRows {
+			return "", 0, err
+		}
+		if err == nil {
+			claims.OrgID = member.OrgID
+			claims.OrgRole = member.Role
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+	accessToken, err := h.jwtManager.IssueAccessToken(claims)
+	if err != nil {
+		return "", 0, err
+	}
+	return accessToken, claims.OrgID, nil
 }
@@ -2,26 +2,38 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
 	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/mail"
 )
 
 func TestRegisterHandler(t *testing.T) {
 	// Setup
 	gin.SetMode(gin.TestMode)
-	
-	// This is a simplified example - in real tests you'd:
-	// 1. Set up a test database
-	// 2. Create mock SQLC queries
-	// 3. Initialize the handler with mocks
-	
+
+	// Validation failures are rejected before the database is touched, so the
+	// handler runs without one unless DATABASE_URL points at a test database.
+	testDB := setupTestDB(t)
+	router := setupTestRouter(t, testDB)
+
 	tests := []struct {
 		name           string
 		requestBody    map[string]interface{}
@@ -32,7 +44,7 @@ func TestRegisterHandler(t *testing.T) {
 			name: "Valid Registration",
 			requestBody: map[string]interface{}{
 				"email":     "test@example.com",
-				"password":  "password123",
+				"password":  "correct-horse-battery",
 				"full_name": "Test User",
 			},
 			expectedStatus: http.StatusCreated,
@@ -68,6 +80,14 @@ func TestRegisterHandler(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			if tt.expectedStatus == http.StatusCreated {
+				if testDB == nil {
+					t.Skip("DATABASE_URL not set")
+				}
+				_, err := testDB.Exec("DELETE FROM users WHERE email = $1", tt.requestBody["email"])
+				assert.NoError(t, err)
+			}
+
 			// Create request
 			body, _ := json.Marshal(tt.requestBody)
 			req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/register", bytes.NewBuffer(body))
@@ -75,9 +95,7 @@ func TestRegisterHandler(t *testing.T) {
 
 			// Create response recorder
 			w := httptest.NewRecorder()
-
-			// Note: In a real test, you'd create a router with your handler
-			// This is just demonstrating the test structure
+			router.ServeHTTP(w, req)
 
 			// Assert
 			assert.Equal(t, tt.expectedStatus, w.Code)
@@ -85,6 +103,84 @@ func TestRegisterHandler(t *testing.T) {
 	}
 }
 
+// setupTestDB connects to the database in DATABASE_URL and applies migrations.
+// It returns nil when no database is configured.
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return nil
+	}
+
+	testDB, err := db.Connect(databaseURL)
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	t.Cleanup(func() { testDB.Close() })
+
+	if err := db.RunMigrations(databaseURL); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+
+	return testDB
+}
+
+// testPasswordPolicy mirrors the default configuration
+func testPasswordPolicy() *auth.PasswordPolicy {
+	return &auth.PasswordPolicy{
+		MinLength:          8,
+		MaxLength:          128,
+		MinStrength:        2,
+		RejectPersonalInfo: true,
+	}
+}
+
+// setupTestRouter wires the auth handlers the same way api.NewRouter does
+func setupTestRouter(t *testing.T, testDB *sql.DB) *gin.Engine {
+	t.Helper()
+
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, 7*24*time.Hour)
+
+	mailer, err := mail.NewFileMailer(t.TempDir(), "noreply@example.com")
+	if err != nil {
+		t.Fatalf("failed to create mailer: %v", err)
+	}
+
+	var (
+		store       db.Store
+		revocations *auth.RevocationList
+		throttle    *auth.LoginThrottle
+	)
+	if testDB != nil {
+		store = db.NewStore(testDB)
+		revocations = auth.NewRevocationList(store, 15*time.Minute, time.Second)
+		throttle = auth.NewLoginThrottle(store, auth.LockoutPolicy{
+			MaxAttempts:     10,
+			IPMaxAttempts:   100,
+			LockoutDuration: 15 * time.Minute,
+			BackoffBase:     time.Second,
+		})
+	}
+	verificationHandler := handlers.NewVerificationHandler(store, mailer, "http://localhost:3000", 24*time.Hour, zerolog.Nop())
+	cipher, err := auth.NewCipher("test-encryption-key")
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+	mfaHandler := handlers.NewMFAHandler(store, cipher, "Test")
+	authHandler := handlers.NewAuthHandler(store, jwtManager, revocations, verificationHandler, mfaHandler, throttle, testPasswordPolicy(), false, false, false, zerolog.Nop())
+
+	router := gin.New()
+	v1 := router.Group("/api/v1")
+	v1.POST("/auth/register", authHandler.Register)
+	v1.POST("/auth/login", authHandler.Login)
+	v1.POST("/auth/refresh", authHandler.RefreshToken)
+	v1.POST("/auth/logout", authHandler.Logout)
+	v1.POST("/auth/verify-email", verificationHandler.VerifyEmail)
+	v1.POST("/auth/mfa/verify", authHandler.VerifyMFA)
+	return router
+}
+
 func TestPasswordHashing(t *testing.T) {
 	password := "testpassword123"
 
@@ -95,16 +191,34 @@ func TestPasswordHashing(t *testing.T) {
 	assert.NotEqual(t, password, hash)
 
 	// Test verification - correct password
-	err = auth.VerifyPassword(password, hash)
+	rehash, err := auth.VerifyPassword(password, hash)
 	assert.NoError(t, err)
+	assert.False(t, rehash)
 
 	// Test verification - wrong password
-	err = auth.VerifyPassword("wrongpassword", hash)
+	_, err = auth.VerifyPassword("wrongpassword", hash)
+	assert.Error(t, err)
+
+	// Hashes from another algorithm or with other parameters keep verifying
+	// but are flagged for rehashing
+	bcryptHash, err := auth.BcryptHasher{Cost: 4}.Hash(password)
+	assert.NoError(t, err)
+	rehash, err = auth.VerifyPassword(password, bcryptHash)
+	assert.NoError(t, err)
+	assert.True(t, rehash)
+
+	weakHash, err := auth.Argon2idHasher{Memory: 1024, Time: 1, Parallelism: 1}.Hash(password)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(weakHash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	rehash, err = auth.VerifyPassword(password, weakHash)
+	assert.NoError(t, err)
+	assert.True(t, rehash)
+	_, err = auth.VerifyPassword("wrongpassword", weakHash)
 	assert.Error(t, err)
 }
 
 func TestJWTGeneration(t *testing.T) {
-	jwtManager := auth.NewJWTManager("test-secret-key", 15*60, 7*24*60*60)
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, 7*24*time.Hour)
 
 	// Test access token generation
 	token, err := jwtManager.GenerateAccessToken(1, "test@example.com", false)
@@ -124,7 +238,7 @@ func TestJWTGeneration(t *testing.T) {
 }
 
 func TestRefreshTokenGeneration(t *testing.T) {
-	jwtManager := auth.NewJWTManager("test-secret-key", 15*60, 7*24*60*60)
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, 7*24*time.Hour)
 
 	// Generate refresh token
 	token, err := jwtManager.GenerateRefreshToken(42)
@@ -137,6 +251,113 @@ func TestRefreshTokenGeneration(t *testing.T) {
 	assert.Equal(t, int64(42), userID)
 }
 
+func TestAsymmetricJWT(t *testing.T) {
+	for _, algorithm := range []string{auth.AlgorithmRS256, auth.AlgorithmES256, auth.AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			keys := auth.NewKeyManager(auth.NewMemoryKeyStore(), algorithm, time.Hour, 7*24*time.Hour)
+			assert.NoError(t, keys.Load(context.Background()))
+			jwtManager := auth.NewAsymmetricJWTManager(keys, 15*time.Minute, 7*24*time.Hour)
+
+			token, err := jwtManager.GenerateAccessToken(1, "test@example.com", false)
+			assert.NoError(t, err)
+
+			claims, err := jwtManager.ValidateToken(token)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), claims.UserID)
+
+			jwks := jwtManager.JWKS()
+			assert.Len(t, jwks.Keys, 1)
+			assert.Equal(t, algorithm, jwks.Keys[0].Alg)
+
+			// Tokens signed before a rotation keep verifying
+			assert.NoError(t, keys.Rotate(context.Background()))
+			_, err = jwtManager.ValidateToken(token)
+			assert.NoError(t, err)
+			assert.Len(t, jwtManager.JWKS().Keys, 2)
+
+			// A token signed with a shared secret is rejected
+			hmacToken, err := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour).
+				GenerateAccessToken(1, "test@example.com", true)
+			assert.NoError(t, err)
+			_, err = jwtManager.ValidateToken(hmacToken)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B vectors, truncated to six digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range vectors {
+		code, err := auth.TOTPCode(secret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, want, code)
+	}
+
+	// Codes from adjacent periods are accepted to tolerate clock drift
+	now := time.Unix(1234567890, 0)
+	previous, _ := auth.TOTPCode(secret, now.Add(-auth.TOTPPeriod))
+	step, ok := auth.ValidateTOTP(secret, previous, now, 0)
+	assert.True(t, ok)
+
+	// A code is rejected once its step has been used
+	_, ok = auth.ValidateTOTP(secret, previous, now, step)
+	assert.False(t, ok)
+
+	stale, _ := auth.TOTPCode(secret, now.Add(-5*auth.TOTPPeriod))
+	_, ok = auth.ValidateTOTP(secret, stale, now, 0)
+	assert.False(t, ok)
+
+	// Recovery codes match however they are typed
+	codes, err := auth.GenerateRecoveryCodes(2)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 2)
+	assert.NotEqual(t, codes[0], codes[1])
+	assert.Equal(t,
+		auth.NormalizeRecoveryCode(codes[0]),
+		auth.NormalizeRecoveryCode(strings.ToLower(strings.ReplaceAll(codes[0], "-", " "))))
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	corpus := filepath.Join(t.TempDir(), "pwned.txt")
+	sum := sha1.Sum([]byte("Breached-Passphrase-2019"))
+	line := strings.ToUpper(hex.EncodeToString(sum[:])) + ":42\n"
+	require.NoError(t, os.WriteFile(corpus, []byte(line), 0o600))
+
+	breached, err := auth.LoadBreachedPasswords(corpus)
+	require.NoError(t, err)
+	policy := testPasswordPolicy()
+	policy.RequireDigit = true
+	policy.Breached = breached
+
+	rules := func(password string) []string {
+		violations, err := policy.Check(password, "jane.doe@example.com", "Jane Doe")
+		require.NoError(t, err)
+		var rules []string
+		for _, v := range violations {
+			rules = append(rules, v.Rule)
+		}
+		return rules
+	}
+
+	assert.Empty(t, rules("violet-canyon-47-drift"))
+	assert.Equal(t, []string{"min_length", "strength"}, rules("abc1"))
+	assert.Equal(t, []string{"digit", "strength"}, rules("qwertyuiop"))
+	assert.Equal(t, []string{"strength"}, rules("Password123"))
+	assert.Equal(t, []string{"strength"}, rules("p@ssw0rd2024"))
+	assert.Equal(t, []string{"personal_info"}, rules("janedoe-ridge-91-lantern"))
+	assert.Equal(t, []string{"breached"}, rules("Breached-Passphrase-2019"))
+
+	assert.Equal(t, 0, auth.PasswordStrength("aaaaaaaa"))
+	assert.Equal(t, 4, auth.PasswordStrength("violet-canyon-47-drift"))
+}
+
 // Example of how you'd test with a real database connection
 // Uncomment and adapt for integration tests
 
@@ -152,7 +373,7 @@ func TestRegisterIntegration(t *testing.T) {
 	defer testDB.Close()
 
 	// Create test router
-	router := setupTestRouter(testDB)
+	router := setupTestRouter(t, testDB)
 
 	// Test registration
 	reqBody := map[string]interface{}{
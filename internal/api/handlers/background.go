@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// background tracks work that outlives the request that started it
+var background sync.WaitGroup
+
+// inBackground runs fn after the request c responds, logging msg if it fails.
+// Mail about an account goes out this way where the time it takes would
+// otherwise tell a caller whether the account exists.
+func inBackground(c *gin.Context, logger zerolog.Logger, msg string, fn func(ctx context.Context) error) {
+	ctx := context.WithoutCancel(c.Request.Context())
+	requestID := c.GetString("request_id")
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		if err := fn(ctx); err != nil {
+			logger.Error().
+				Err(err).
+				Str("request_id", requestID).
+				Msg(msg)
+		}
+	}()
+}
+
+// WaitForBackground blocks until work started in the background by earlier
+// requests has finished. Call it once the server has stopped taking requests.
+func WaitForBackground() {
+	background.Wait()
+}
@@ -0,0 +1,500 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// externalLoginExpiry is how long a user has to finish signing in at an
+// identity provider
+const externalLoginExpiry = 10 * time.Minute
+
+var (
+	errIdentityNotFound   = errors.New("identity not found")
+	errLastSignInMethod   = errors.New("identity is the last way to sign in")
+	errExternalLoginState = errors.New("invalid or expired state")
+)
+
+type IdentityHandler struct {
+	store       db.Store
+	authHandler *AuthHandler
+	connectors  []auth.Connector
+	appURL      string
+	logger      zerolog.Logger
+}
+
+func NewIdentityHandler(store db.Store, authHandler *AuthHandler, connectors []auth.Connector, appURL string, logger zerolog.Logger) *IdentityHandler {
+	return &IdentityHandler{
+		store:       store,
+		authHandler: authHandler,
+		connectors:  connectors,
+		appURL:      appURL,
+		logger:      logger,
+	}
+}
+
+// ExternalCallbackRequest represents the body the frontend posts when an
+// identity provider redirects the user back to it
+type ExternalCallbackRequest struct {
+	Code       string `json:"code" binding:"required"`
+	State      string `json:"state" binding:"required"`
+	DeviceName string `json:"device_name,omitempty" binding:"max=100"`
+}
+
+// ProviderInfo describes an identity provider users can sign in with
+type ProviderInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// IdentityInfo describes an identity provider account linked to the user
+type IdentityInfo struct {
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ListProviders returns the identity providers users can sign in with
+func (h *IdentityHandler) ListProviders(c *gin.Context) {
+	providers := make([]ProviderInfo, 0, len(h.connectors))
+	for _, connector := range h.connectors {
+		providers = append(providers, ProviderInfo{ID: connector.ID(), Name: connector.Name()})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"providers": providers})
+}
+
+// StartLogin returns the URL that sends the user to an identity provider to
+// sign in
+func (h *IdentityHandler) StartLogin(c *gin.Context) {
+	h.start(c, 0)
+}
+
+// FinishLogin signs in the user an identity provider redirected back. The
+// first sign-in with an identity creates an account for its verified email;
+// an email that already has an account must be linked from that account.
+func (h *IdentityHandler) FinishLogin(c *gin.Context) {
+	var req ExternalCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	connector, identity, ok := h.finish(c, req, 0)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	existing, err := h.store.GetIdentity(ctx, sqlc.GetIdentityParams{
+		Provider: connector.ID(),
+		Subject:  identity.Subject,
+	})
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find identity"})
+		return
+	}
+
+	var user sqlc.User
+	if err == nil {
+		user, err = h.store.GetUserByID(ctx, existing.UserID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "account is disabled"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+			return
+		}
+
+		// Best effort: the email is informational
+		_ = h.store.TouchIdentity(ctx, sqlc.TouchIdentityParams{ID: existing.ID, Email: identity.Email})
+	} else {
+		user, ok = h.createUser(c, connector, identity)
+		if !ok {
+			return
+		}
+	}
+
+	h.logger.Info().
+		Str("event", "external_login").
+		Int64("user_id", user.ID).
+		Str("provider", connector.ID()).
+		Str("client_ip", c.ClientIP()).
+		Str("request_id", c.GetString("request_id")).
+		Msg("User signed in with identity provider")
+
+	h.authHandler.signIn(c, user, req.DeviceName)
+}
+
+// createUser creates an account for an identity signing in for the first
+// time. Accounts are only created for emails the provider has verified, and
+// never attached to an existing account without its owner signing in. In
+// invite-only mode no accounts are created; invited users register first and
+// link the provider afterwards.
+func (h *IdentityHandler) createUser(c *gin.Context, connector auth.Connector, identity auth.ExternalIdentity) (sqlc.User, bool) {
+	if identity.Email == "" || !identity.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "identity provider did not verify the email address"})
+		return sqlc.User{}, false
+	}
+	if h.authHandler.inviteOnly {
+		c.JSON(http.StatusForbidden, gin.H{"error": "registration is by invitation only"})
+		return sqlc.User{}, false
+	}
+
+	name := identity.Name
+	if name == "" {
+		name = identity.Email
+	}
+
+	var user sqlc.User
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		user, err = q.CreateExternalUser(ctx, sqlc.CreateExternalUserParams{
+			Email:    identity.Email,
+			FullName: name,
+			Provider: connector.ID(),
+			Subject:  identity.Subject,
+		})
+		if err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionRegister, audit.TargetUser, user.ID)
+		event.ActorID = user.ID
+		event.Diff = map[string]audit.Change{"provider": {To: connector.ID()}}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "an account with this email already exists; sign in and link " + connector.Name() + " from your account settings"})
+			return sqlc.User{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
+		return sqlc.User{}, false
+	}
+
+	h.logger.Info().
+		Str("event", "external_signup").
+		Int64("user_id", user.ID).
+		Str("provider", connector.ID()).
+		Str("request_id", c.GetString("request_id")).
+		Msg("User created from identity provider")
+
+	return user, true
+}
+
+// ListIdentities returns the identity provider accounts linked to the
+// authenticated user
+func (h *IdentityHandler) ListIdentities(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	user, err := h.store.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+		return
+	}
+
+	identities, err := h.store.ListUserIdentities(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list identities"})
+		return
+	}
+
+	infos := make([]IdentityInfo, 0, len(identities))
+	for _, identity := range identities {
+		infos = append(infos, newIdentityInfo(identity))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"identities":   infos,
+		"has_password": user.PasswordHash != "",
+	})
+}
+
+// StartLink returns the URL that sends the authenticated user to an identity
+// provider to link their account there
+func (h *IdentityHandler) StartLink(c *gin.Context) {
+	h.start(c, c.GetInt64("user_id"))
+}
+
+// FinishLink links the identity provider account the user signed in to, once
+// the provider redirects them back
+func (h *IdentityHandler) FinishLink(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var req ExternalCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	connector, identity, ok := h.finish(c, req, userID)
+	if !ok {
+		return
+	}
+
+	var linked sqlc.Identity
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		linked, err = q.CreateIdentity(ctx, sqlc.CreateIdentityParams{
+			UserID:   userID,
+			Provider: connector.ID(),
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		})
+		if err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionIdentityLinked, audit.TargetIdentity, linked.ID)
+		event.Diff = map[string]audit.Change{"provider": {To: connector.ID()}}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this " + connector.Name() + " account or provider is already linked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to link identity"})
+		return
+	}
+
+	h.logger.Info().
+		Str("event", "identity_linked").
+		Int64("user_id", userID).
+		Str("provider", connector.ID()).
+		Str("request_id", c.GetString("request_id")).
+		Msg("User linked identity provider account")
+
+	c.JSON(http.StatusCreated, gin.H{"identity": newIdentityInfo(linked)})
+}
+
+// Unlink removes the authenticated user's identity at a provider. An account
+// without a password keeps at least one identity so it can still sign in.
+func (h *IdentityHandler) Unlink(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	provider := c.Param("provider")
+
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		identities, err := q.ListUserIdentitiesForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		var identityID int64
+		for _, identity := range identities {
+			if identity.Provider == provider {
+				identityID = identity.ID
+			}
+		}
+		if identityID == 0 {
+			return errIdentityNotFound
+		}
+
+		user, err := q.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user.PasswordHash == "" && len(identities) == 1 {
+			return errLastSignInMethod
+		}
+
+		if _, err := q.DeleteUserIdentity(ctx, sqlc.DeleteUserIdentityParams{
+			UserID:   userID,
+			Provider: provider,
+		}); err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionIdentityUnlinked, audit.TargetIdentity, identityID)
+		event.Diff = map[string]audit.Change{"provider": {From: provider}}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errIdentityNotFound), errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "identity not found"})
+		case errors.Is(err, errLastSignInMethod):
+			c.JSON(http.StatusConflict, gin.H{"error": "set a password before unlinking your last identity provider"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlink identity"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "identity unlinked successfully"})
+}
+
+// start records a new sign-in at the provider named in the path and responds
+// with the URL to send the user to. userID is set when the sign-in links an
+// identity to that user instead of logging in.
+func (h *IdentityHandler) start(c *gin.Context, userID int64) {
+	connector := h.connector(c.Param("provider"))
+	if connector == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "identity provider not found"})
+		return
+	}
+
+	state, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start sign-in"})
+		return
+	}
+	nonce, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start sign-in"})
+		return
+	}
+	verifier, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start sign-in"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	authorizationURL, err := connector.AuthCodeURL(ctx, h.redirectURI(connector), state, nonce, auth.PKCEChallenge(verifier))
+	if err != nil {
+		h.providerUnavailable(c, connector, err)
+		return
+	}
+
+	// Best effort: expired states can no longer be used
+	_ = h.store.DeleteExpiredExternalLoginStates(ctx)
+
+	err = h.store.CreateExternalLoginState(ctx, sqlc.CreateExternalLoginStateParams{
+		StateHash:    auth.HashToken(state),
+		Provider:     connector.ID(),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		UserID:       sql.NullInt64{Int64: userID, Valid: userID != 0},
+		ExpiresAt:    time.Now().Add(externalLoginExpiry),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start sign-in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"authorization_url": authorizationURL,
+		"state":             state,
+	})
+}
+
+// finish consumes the state of a sign-in started for userID and redeems the
+// code with the provider. On failure it has already responded.
+func (h *IdentityHandler) finish(c *gin.Context, req ExternalCallbackRequest, userID int64) (auth.Connector, auth.ExternalIdentity, bool) {
+	connector := h.connector(c.Param("provider"))
+	if connector == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "identity provider not found"})
+		return nil, auth.ExternalIdentity{}, false
+	}
+
+	ctx := c.Request.Context()
+	state, err := h.consumeState(ctx, connector, req.State, userID)
+	if err != nil {
+		if errors.Is(err, errExternalLoginState) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired state"})
+			return nil, auth.ExternalIdentity{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to finish sign-in"})
+		return nil, auth.ExternalIdentity{}, false
+	}
+
+	identity, err := connector.Exchange(ctx, h.redirectURI(connector), req.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		if errors.Is(err, auth.ErrExternalLoginRejected) {
+			h.logger.Warn().
+				Err(err).
+				Str("event", "external_login_rejected").
+				Str("provider", connector.ID()).
+				Str("client_ip", c.ClientIP()).
+				Str("request_id", c.GetString("request_id")).
+				Msg("Identity provider sign-in rejected")
+
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "sign-in with " + connector.Name() + " failed"})
+			return nil, auth.ExternalIdentity{}, false
+		}
+		h.providerUnavailable(c, connector, err)
+		return nil, auth.ExternalIdentity{}, false
+	}
+
+	return connector, identity, true
+}
+
+// consumeState redeems a state value once. A state only finishes the kind of
+// sign-in it was started for: a login, or linking for the same user.
+func (h *IdentityHandler) consumeState(ctx context.Context, connector auth.Connector, state string, userID int64) (sqlc.ExternalLoginState, error) {
+	stored, err := h.store.ConsumeExternalLoginState(ctx, sqlc.ConsumeExternalLoginStateParams{
+		StateHash: auth.HashToken(state),
+		Provider:  connector.ID(),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return stored, errExternalLoginState
+		}
+		return stored, err
+	}
+	if stored.UserID.Int64 != userID {
+		return stored, errExternalLoginState
+	}
+	return stored, nil
+}
+
+// providerUnavailable responds for a provider that could not be reached
+func (h *IdentityHandler) providerUnavailable(c *gin.Context, connector auth.Connector, err error) {
+	h.logger.Error().
+		Err(err).
+		Str("provider", connector.ID()).
+		Str("request_id", c.GetString("request_id")).
+		Msg("Identity provider request failed")
+
+	c.JSON(http.StatusBadGateway, gin.H{"error": connector.Name() + " is unavailable, try again later"})
+}
+
+// connector returns the connector for provider, or nil
+func (h *IdentityHandler) connector(provider string) auth.Connector {
+	for _, connector := range h.connectors {
+		if connector.ID() == provider {
+			return connector
+		}
+	}
+	return nil
+}
+
+// redirectURI is where the provider sends the user back to: a frontend page
+// that posts the code and state to the callback endpoint
+func (h *IdentityHandler) redirectURI(connector auth.Connector) string {
+	return h.appURL + "/auth/callback/" + url.PathEscape(connector.ID())
+}
+
+// newIdentityInfo returns the public view of identity
+func newIdentityInfo(identity sqlc.Identity) IdentityInfo {
+	info := IdentityInfo{
+		Provider:  identity.Provider,
+		Email:     identity.Email,
+		CreatedAt: identity.CreatedAt,
+	}
+	if identity.LastLoginAt.Valid {
+		info.LastLoginAt = &identity.LastLoginAt.Time
+	}
+	return info
+}
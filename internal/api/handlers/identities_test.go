@@ -0,0 +1,317 @@
+package handlers_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/api/middleware"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// mockIssuer is an OpenID Connect provider that signs in whoever user is set
+// to, checking client authentication, redirect URIs and PKCE like a real one
+type mockIssuer struct {
+	t            *testing.T
+	server       *httptest.Server
+	key          *rsa.PrivateKey
+	clientID     string
+	clientSecret string
+	user         auth.ExternalIdentity
+	// signingKey, when set, signs ID tokens instead of the published key
+	signingKey *rsa.PrivateKey
+	codes      map[string]url.Values
+}
+
+func newMockIssuer(t *testing.T, clientID, clientSecret string) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	m := &mockIssuer{
+		t:            t,
+		key:          key,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		codes:        map[string]url.Values{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := m.server.URL
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/jwks",
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic"},
+	})
+}
+
+func (m *mockIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	json.NewEncoder(w).Encode(auth.JWKS{Keys: []auth.JWK{{
+		Kty: "RSA",
+		Kid: "mock-key",
+		Use: "sig",
+		Alg: "RS256",
+		N:   b64(m.key.N.Bytes()),
+		E:   b64(big.NewInt(int64(m.key.E)).Bytes()),
+	}}})
+}
+
+// authorize signs the user in at once and redirects back with a code
+func (m *mockIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if params.Get("client_id") != m.clientID || params.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	code, err := auth.GenerateOpaqueToken()
+	require.NoError(m.t, err)
+	m.codes[code] = params
+
+	callback := url.Values{"code": {code}, "state": {params.Get("state")}}
+	http.Redirect(w, r, params.Get("redirect_uri")+"?"+callback.Encode(), http.StatusFound)
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, _ := r.BasicAuth()
+	if clientID != m.clientID || clientSecret != m.clientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	params, ok := m.codes[r.PostFormValue("code")]
+	delete(m.codes, r.PostFormValue("code"))
+	if !ok || r.PostFormValue("redirect_uri") != params.Get("redirect_uri") ||
+		!auth.VerifyPKCE(r.PostFormValue("code_verifier"), params.Get("code_challenge")) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            m.user.Subject,
+		"aud":            m.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          params.Get("nonce"),
+		"email":          m.user.Email,
+		"email_verified": m.user.EmailVerified,
+		"name":           m.user.Name,
+	})
+	token.Header["kid"] = "mock-key"
+	key := m.key
+	if m.signingKey != nil {
+		key = m.signingKey
+	}
+	idToken, err := token.SignedString(key)
+	require.NoError(m.t, err)
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func TestExternalIdentityProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	issuer := newMockIssuer(t, "starter", "starter-secret")
+	connector := auth.NewOIDCConnector(auth.OIDCConfig{
+		ID:           "mock",
+		Name:         "Mock",
+		Issuer:       issuer.server.URL,
+		ClientID:     "starter",
+		ClientSecret: "starter-secret",
+	})
+
+	passwordHash, err := auth.BcryptHasher{Cost: 4}.Hash("correct-horse-battery")
+	require.NoError(t, err)
+	store := newMemStore(sqlc.User{ID: 1, Email: "existing@example.com", PasswordHash: passwordHash, FullName: "Existing User", IsActive: true})
+
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	authHandler := handlers.NewAuthHandler(store, jwtManager, nil, nil, nil, auth.NewLoginThrottle(store, auth.LockoutPolicy{}), testPasswordPolicy(), false, false, false, zerolog.Nop())
+	identityHandler := handlers.NewIdentityHandler(store, authHandler, []auth.Connector{connector}, "https://app.example.com", zerolog.Nop())
+	authRequired := middleware.AuthRequired(jwtManager, auth.NewRevocationList(store, 15*time.Minute, time.Second), auth.NewAPIKeyAuthenticator(store))
+
+	router := gin.New()
+	router.GET("/api/v1/auth/providers", identityHandler.ListProviders)
+	router.POST("/api/v1/auth/providers/:provider/authorize", identityHandler.StartLogin)
+	router.POST("/api/v1/auth/providers/:provider/callback", identityHandler.FinishLogin)
+	router.POST("/api/v1/users/me/identities/:provider/authorize", authRequired, middleware.SessionRequired(), identityHandler.StartLink)
+	router.POST("/api/v1/users/me/identities/:provider/callback", authRequired, middleware.SessionRequired(), identityHandler.FinishLink)
+
+	post := func(path, bearer string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// signInAtProvider starts a sign-in and plays the browser: it visits the
+	// provider, which redirects to the frontend callback page with the code
+	signInAtProvider := func(startPath, bearer string) (code, state string) {
+		w := post(startPath, bearer, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var start struct {
+			AuthorizationURL string `json:"authorization_url"`
+			State            string `json:"state"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &start))
+		require.True(t, strings.HasPrefix(start.AuthorizationURL, issuer.server.URL+"/authorize?"))
+
+		browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		resp, err := browser.Get(start.AuthorizationURL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		callback, err := url.Parse(resp.Header.Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "https://app.example.com/auth/callback/mock", callback.Scheme+"://"+callback.Host+callback.Path)
+		assert.Equal(t, start.State, callback.Query().Get("state"))
+		return callback.Query().Get("code"), callback.Query().Get("state")
+	}
+
+	login := func() *httptest.ResponseRecorder {
+		code, state := signInAtProvider("/api/v1/auth/providers/mock/authorize", "")
+		return post("/api/v1/auth/providers/mock/callback", "", gin.H{"code": code, "state": state})
+	}
+
+	t.Run("list providers", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/providers", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.JSONEq(t, `{"providers":[{"id":"mock","name":"Mock"}]}`, w.Body.String())
+	})
+
+	t.Run("first sign-in creates an account", func(t *testing.T) {
+		issuer.user = auth.ExternalIdentity{Subject: "alice-sub", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+
+		w := login()
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp handlers.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.NotEmpty(t, resp.AccessToken)
+		assert.Equal(t, "alice@example.com", resp.User.Email)
+		assert.Equal(t, "Alice", resp.User.FullName)
+		assert.True(t, resp.User.EmailVerified)
+		assert.Empty(t, store.users[resp.User.ID].PasswordHash)
+
+		// Signing in again finds the same account
+		w = login()
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var again handlers.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &again))
+		assert.Equal(t, resp.User.ID, again.User.ID)
+		assert.Len(t, store.users, 2)
+
+		actions := []string{}
+		for _, event := range store.events {
+			actions = append(actions, event.Action)
+		}
+		assert.Equal(t, []string{"auth.register", "auth.login", "auth.login"}, actions)
+	})
+
+	t.Run("state works once", func(t *testing.T) {
+		issuer.user = auth.ExternalIdentity{Subject: "alice-sub", Email: "alice@example.com", EmailVerified: true}
+
+		code, state := signInAtProvider("/api/v1/auth/providers/mock/authorize", "")
+		w := post("/api/v1/auth/providers/mock/callback", "", gin.H{"code": code, "state": state})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = post("/api/v1/auth/providers/mock/callback", "", gin.H{"code": code, "state": state})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("unverified email is refused", func(t *testing.T) {
+		issuer.user = auth.ExternalIdentity{Subject: "mallory-sub", Email: "mallory@example.com", EmailVerified: false}
+
+		w := login()
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Len(t, store.users, 2)
+	})
+
+	t.Run("existing email must be linked", func(t *testing.T) {
+		issuer.user = auth.ExternalIdentity{Subject: "existing-sub", Email: "existing@example.com", EmailVerified: true}
+
+		w := login()
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("forged ID token is rejected", func(t *testing.T) {
+		forger, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		issuer.signingKey = forger
+		defer func() { issuer.signingKey = nil }()
+		issuer.user = auth.ExternalIdentity{Subject: "alice-sub", Email: "alice@example.com", EmailVerified: true}
+
+		w := login()
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("link and sign in", func(t *testing.T) {
+		session, err := jwtManager.IssueAccessToken(auth.Claims{UserID: 1, Email: "existing@example.com"})
+		require.NoError(t, err)
+		issuer.user = auth.ExternalIdentity{Subject: "existing-sub", Email: "work@example.com", EmailVerified: true}
+
+		// A state for linking cannot finish a login
+		code, state := signInAtProvider("/api/v1/users/me/identities/mock/authorize", session)
+		w := post("/api/v1/auth/providers/mock/callback", "", gin.H{"code": code, "state": state})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		code, state = signInAtProvider("/api/v1/users/me/identities/mock/authorize", session)
+		w = post("/api/v1/users/me/identities/mock/callback", session, gin.H{"code": code, "state": state})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"provider":"mock"`)
+
+		// The linked account now signs in to the existing user
+		w = login()
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp handlers.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, int64(1), resp.User.ID)
+
+		// An identity belongs to one user
+		alice, err := jwtManager.IssueAccessToken(auth.Claims{UserID: 2, Email: "alice@example.com"})
+		require.NoError(t, err)
+		code, state = signInAtProvider("/api/v1/users/me/identities/mock/authorize", alice)
+		w = post("/api/v1/users/me/identities/mock/callback", alice, gin.H{"code": code, "state": state})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
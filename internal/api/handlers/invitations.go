@@ -0,0 +1,704 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+	"github.com/yourusername/go-sqlc-starter/internal/mail"
+)
+
+var (
+	errInvitationEmail      = errors.New("invitation is for a different email")
+	errInvitationUnverified = errors.New("email must be verified to accept invitation")
+	errInvitationNotFound   = errors.New("invitation not found")
+	errAlreadyMember        = errors.New("already a member of the organization")
+)
+
+// InvitationHandler onboards users with invitations. An invitation is for the
+// system, optionally granting a role, or for an organization with a member
+// role. It can be limited to one email or an email domain.
+type InvitationHandler struct {
+	store       db.Store
+	authHandler *AuthHandler
+	mailer      mail.Mailer
+	appURL      string
+	expiry      time.Duration
+	logger      zerolog.Logger
+}
+
+func NewInvitationHandler(store db.Store, authHandler *AuthHandler, mailer mail.Mailer, appURL string, expiry time.Duration, logger zerolog.Logger) *InvitationHandler {
+	return &InvitationHandler{
+		store:       store,
+		authHandler: authHandler,
+		mailer:      mailer,
+		appURL:      appURL,
+		expiry:      expiry,
+		logger:      logger,
+	}
+}
+
+// CreateInvitationRequest represents the create system invitation request body
+type CreateInvitationRequest struct {
+	Email       string `json:"email,omitempty" binding:"omitempty,email,max=255"`
+	EmailDomain string `json:"email_domain,omitempty" binding:"omitempty,fqdn,max=255"`
+	Role        string `json:"role,omitempty"`
+}
+
+// CreateOrgInvitationRequest represents the create organization invitation
+// request body
+type CreateOrgInvitationRequest struct {
+	Email       string `json:"email,omitempty" binding:"omitempty,email,max=255"`
+	EmailDomain string `json:"email_domain,omitempty" binding:"omitempty,fqdn,max=255"`
+	Role        string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+// AcceptInvitationRequest represents the accept invitation request body
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// InvitationRegisterRequest represents the register with invitation request
+// body
+type InvitationRegisterRequest struct {
+	Token      string `json:"token" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	FullName   string `json:"full_name" binding:"required"`
+	DeviceName string `json:"device_name,omitempty" binding:"max=100"`
+}
+
+// InvitationInfo describes a pending invitation. Role is the organization
+// role for organization invitations and the system role, if any, otherwise.
+// ClaimedBy is the account that registered with a domain invitation and has
+// yet to verify its email.
+type InvitationInfo struct {
+	ID          int64     `json:"id"`
+	OrgID       *int64    `json:"org_id,omitempty"`
+	Role        string    `json:"role,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	EmailDomain string    `json:"email_domain,omitempty"`
+	ClaimedBy   *int64    `json:"claimed_by,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateInvitation invites someone to create an account, optionally with a
+// role. Granting a role through an invitation needs roles:write, as assigning
+// it directly would.
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var roleID sql.NullInt64
+	if req.Role != "" {
+		if !canManageRoles(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+			return
+		}
+
+		role, err := h.store.GetRoleByName(c.Request.Context(), req.Role)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role: " + req.Role})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get role"})
+			return
+		}
+		roleID = sql.NullInt64{Int64: role.ID, Valid: true}
+	}
+
+	h.create(c, sqlc.CreateInvitationParams{
+		RoleID:      roleID,
+		Email:       req.Email,
+		EmailDomain: strings.ToLower(req.EmailDomain),
+	}, req.Role)
+}
+
+// ListInvitations returns the pending system invitations
+func (h *InvitationHandler) ListInvitations(c *gin.Context) {
+	ctx := c.Request.Context()
+	invitations, err := h.store.ListSystemInvitations(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list invitations"})
+		return
+	}
+
+	roles, err := h.store.ListRoles(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list roles"})
+		return
+	}
+	roleNames := make(map[int64]string, len(roles))
+	for _, role := range roles {
+		roleNames[role.ID] = role.Name
+	}
+
+	infos := make([]InvitationInfo, 0, len(invitations))
+	for _, inv := range invitations {
+		infos = append(infos, newInvitationInfo(inv, roleNames[inv.RoleID.Int64]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": infos})
+}
+
+// RevokeInvitation deletes a pending system invitation
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	invitationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid invitation ID"})
+		return
+	}
+
+	h.revoke(c, func(q sqlc.Querier) (sqlc.Invitation, error) {
+		return q.DeleteSystemInvitation(c.Request.Context(), invitationID)
+	})
+}
+
+// CreateOrgInvitation invites someone to the active organization. Only owners
+// can invite owners.
+func (h *InvitationHandler) CreateOrgInvitation(c *gin.Context) {
+	var req CreateOrgInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.create(c, sqlc.CreateInvitationParams{
+		OrgID:       sql.NullInt64{Int64: c.GetInt64("org_id"), Valid: true},
+		OrgRole:     req.Role,
+		Email:       req.Email,
+		EmailDomain: strings.ToLower(req.EmailDomain),
+	}, req.Role)
+}
+
+// ListOrgInvitations returns the pending invitations to the active
+// organization
+func (h *InvitationHandler) ListOrgInvitations(c *gin.Context) {
+	orgID := sql.NullInt64{Int64: c.GetInt64("org_id"), Valid: true}
+
+	var invitations []sqlc.Invitation
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		invitations, err = q.ListOrganizationInvitations(ctx, orgID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list invitations"})
+		return
+	}
+
+	infos := make([]InvitationInfo, 0, len(invitations))
+	for _, inv := range invitations {
+		infos = append(infos, newInvitationInfo(inv, inv.OrgRole))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": infos})
+}
+
+// RevokeOrgInvitation deletes a pending invitation to the active organization
+func (h *InvitationHandler) RevokeOrgInvitation(c *gin.Context) {
+	invitationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid invitation ID"})
+		return
+	}
+
+	orgID := sql.NullInt64{Int64: c.GetInt64("org_id"), Valid: true}
+	h.revoke(c, func(q sqlc.Querier) (sqlc.Invitation, error) {
+		return q.DeleteOrganizationInvitation(c.Request.Context(), sqlc.DeleteOrganizationInvitationParams{
+			ID:    invitationID,
+			OrgID: orgID,
+		})
+	})
+}
+
+// CheckInvitation reports what an invitation grants without accepting it
+func (h *InvitationHandler) CheckInvitation(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	inv, err := h.store.GetInvitation(ctx, auth.HashToken(token))
+	if err != nil {
+		invitationFailed(c, err, "failed to check invitation")
+		return
+	}
+
+	resp := gin.H{
+		"email":        inv.Email,
+		"email_domain": inv.EmailDomain,
+		"expires_at":   inv.ExpiresAt,
+	}
+	if inv.OrgID.Valid {
+		org, err := h.store.GetOrganization(ctx, inv.OrgID.Int64)
+		if err != nil {
+			invitationFailed(c, err, "failed to check invitation")
+			return
+		}
+		resp["organization"] = gin.H{"id": org.ID, "name": org.Name, "slug": org.Slug}
+		resp["role"] = inv.OrgRole
+	} else if inv.RoleID.Valid {
+		role, err := h.store.GetRoleByID(ctx, inv.RoleID.Int64)
+		if err != nil {
+			invitationFailed(c, err, "failed to check invitation")
+			return
+		}
+		resp["role"] = role.Name
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Register creates an account by accepting an invitation. Registration works
+// this way in invite-only mode too. An invitation sent to an email proves the
+// user owns it, so registering with that email verifies it. An invitation
+// limited to an email domain proves nothing about the address, so the new
+// account claims it and is granted what it offers once the email is verified.
+// Either way the invitation admits one registration.
+func (h *InvitationHandler) Register(c *gin.Context) {
+	var req InvitationRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !checkPasswordPolicy(c, h.authHandler.passwordPolicy, req.Password, req.Email, req.FullName) {
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var (
+		user sqlc.User
+		inv  sqlc.Invitation
+	)
+	tokenHash := auth.HashToken(req.Token)
+	ctx := c.Request.Context()
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		inv, err = q.GetInvitation(ctx, tokenHash)
+		if err != nil {
+			return err
+		}
+		if !invitationAllows(inv, req.Email) {
+			return errInvitationEmail
+		}
+
+		user, err = q.CreateUser(ctx, sqlc.CreateUserParams{
+			Email:        req.Email,
+			PasswordHash: passwordHash,
+			FullName:     req.FullName,
+		})
+		if err != nil {
+			return err
+		}
+		if inv.Email != "" {
+			user, err = q.VerifyUserEmail(ctx, sqlc.VerifyUserEmailParams{
+				Email: user.Email,
+				ID:    user.ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		event := auditEvent(c, audit.ActionRegister, audit.TargetUser, user.ID)
+		event.ActorID = user.ID
+		if err := audit.Record(ctx, q, event); err != nil {
+			return err
+		}
+
+		if inv.EmailDomain != "" && !user.EmailVerifiedAt.Valid {
+			// Nothing is granted yet, so the session starts outside any
+			// organization
+			_, err = q.ClaimInvitation(ctx, sqlc.ClaimInvitationParams{
+				ClaimedBy: sql.NullInt64{Int64: user.ID, Valid: true},
+				TokenHash: tokenHash,
+			})
+			inv = sqlc.Invitation{}
+			return err
+		}
+		inv, err = h.accept(c, q, tokenHash, user.ID)
+		return err
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			h.authHandler.emailTaken(c, req.Email)
+			return
+		}
+		invitationFailed(c, err, "failed to create user")
+		return
+	}
+
+	// The new session starts in the organization the user was invited to
+	sess := newSession(c, req.DeviceName)
+	sess.orgID = inv.OrgID.Int64
+	h.authHandler.welcome(c, user, sess)
+}
+
+// Accept accepts an invitation for the authenticated user. A role granted by
+// it takes effect when the user next refreshes their token.
+func (h *InvitationHandler) Accept(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var org *OrgInfo
+	tokenHash := auth.HashToken(req.Token)
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		user, err := q.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		inv, err := q.GetInvitation(ctx, tokenHash)
+		if err != nil {
+			return err
+		}
+		if !invitationAllows(inv, user.Email) {
+			return errInvitationEmail
+		}
+		// An unverified email says nothing about who the user is
+		if (inv.Email != "" || inv.EmailDomain != "") && !user.EmailVerifiedAt.Valid {
+			return errInvitationUnverified
+		}
+
+		inv, err = h.accept(c, q, tokenHash, userID)
+		if err != nil {
+			return err
+		}
+		if !inv.OrgID.Valid {
+			return nil
+		}
+
+		o, err := q.GetOrganization(ctx, inv.OrgID.Int64)
+		if err != nil {
+			return err
+		}
+		info := newOrgInfo(o, inv.OrgRole)
+		org = &info
+		return nil
+	})
+	if err != nil {
+		invitationFailed(c, err, "failed to accept invitation")
+		return
+	}
+
+	resp := gin.H{"message": "invitation accepted"}
+	if org != nil {
+		resp["organization"] = org
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// create stores an invitation and mails it when it is for one email. The
+// token is returned only when it is not mailed, for the inviter to pass on;
+// holding a mailed token proves the email is the holder's. role names what
+// the invitation grants.
+func (h *InvitationHandler) create(c *gin.Context, params sqlc.CreateInvitationParams, role string) {
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create invitation"})
+		return
+	}
+
+	actorID := c.GetInt64("user_id")
+	params.TokenHash = auth.HashToken(token)
+	params.InvitedBy = sql.NullInt64{Int64: actorID, Valid: true}
+	params.ExpiresAt = time.Now().Add(h.expiry)
+
+	// Best effort: expired invitations can no longer be accepted
+	_ = h.store.DeleteExpiredInvitations(c.Request.Context())
+
+	var (
+		inv     sqlc.Invitation
+		orgName string
+	)
+	ctx := c.Request.Context()
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		if params.OrgID.Valid {
+			// The role in the token may be stale
+			member, err := q.GetOrganizationMember(ctx, sqlc.GetOrganizationMemberParams{
+				OrgID:  params.OrgID.Int64,
+				UserID: actorID,
+			})
+			if err != nil {
+				if err == sql.ErrNoRows {
+					return errOrgForbidden
+				}
+				return err
+			}
+			switch {
+			case member.Role == orgRoleOwner:
+			case member.Role == orgRoleAdmin && role != orgRoleOwner:
+			default:
+				return errOrgForbidden
+			}
+
+			org, err := q.GetOrganization(ctx, params.OrgID.Int64)
+			if err != nil {
+				return err
+			}
+			orgName = org.Name
+		}
+
+		var err error
+		inv, err = q.CreateInvitation(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionInvitationCreated, audit.TargetInvitation, inv.ID)
+		event.Diff = invitationDiff(inv, role)
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		respondOrgError(c, err, "failed to create invitation")
+		return
+	}
+
+	info := newInvitationInfo(inv, role)
+	if inv.Email == "" {
+		c.JSON(http.StatusCreated, gin.H{
+			"invitation": info,
+			"token":      token,
+		})
+		return
+	}
+
+	// The invitation stands whether or not the mail goes out; it can be
+	// revoked and sent again
+	inBackground(c, h.logger, "Failed to send invitation email", func(ctx context.Context) error {
+		return h.send(ctx, inv, token, orgName)
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"invitation": info})
+}
+
+// revoke deletes a pending invitation with del and records it
+func (h *InvitationHandler) revoke(c *gin.Context, del func(sqlc.Querier) (sqlc.Invitation, error)) {
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		inv, err := del(q)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errInvitationNotFound
+			}
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionInvitationRevoked, audit.TargetInvitation, inv.ID)
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		invitationFailed(c, err, "failed to revoke invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "invitation revoked successfully"})
+}
+
+// accept marks the invitation with tokenHash accepted by userID and grants
+// what it offers
+func (h *InvitationHandler) accept(c *gin.Context, q sqlc.Querier, tokenHash string, userID int64) (sqlc.Invitation, error) {
+	inv, err := q.AcceptInvitation(c.Request.Context(), sqlc.AcceptInvitationParams{
+		AcceptedBy: sql.NullInt64{Int64: userID, Valid: true},
+		TokenHash:  tokenHash,
+	})
+	if err != nil {
+		return sqlc.Invitation{}, err
+	}
+	return inv, grantInvitation(c, q, inv, userID)
+}
+
+// acceptClaimedInvitations accepts the invitations user claimed when
+// registering, once their email is verified. An invitation the email no
+// longer matches stays claimed, and membership of an organization the user
+// has joined meanwhile is left as it is.
+func acceptClaimedInvitations(c *gin.Context, q sqlc.Querier, user sqlc.User) error {
+	ctx := c.Request.Context()
+	claimed, err := q.ListClaimedInvitations(ctx, sql.NullInt64{Int64: user.ID, Valid: true})
+	if err != nil {
+		return err
+	}
+
+	for _, inv := range claimed {
+		if !invitationAllows(inv, user.Email) {
+			continue
+		}
+
+		inv, err = q.AcceptClaimedInvitation(ctx, inv.ID)
+		if err != nil {
+			return err
+		}
+		if inv.OrgID.Valid {
+			_, err := q.GetOrganizationMember(ctx, sqlc.GetOrganizationMemberParams{
+				OrgID:  inv.OrgID.Int64,
+				UserID: user.ID,
+			})
+			if err == nil {
+				continue
+			}
+			if err != sql.ErrNoRows {
+				return err
+			}
+		}
+		if err := grantInvitation(c, q, inv, user.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grantInvitation gives userID what the accepted invitation inv offers and
+// records it
+func grantInvitation(c *gin.Context, q sqlc.Querier, inv sqlc.Invitation, userID int64) error {
+	ctx := c.Request.Context()
+
+	var role string
+	if inv.OrgID.Valid {
+		role = inv.OrgRole
+		if _, err := q.AddOrganizationMember(ctx, sqlc.AddOrganizationMemberParams{
+			OrgID:  inv.OrgID.Int64,
+			UserID: userID,
+			Role:   inv.OrgRole,
+		}); err != nil {
+			if isUniqueViolation(err) {
+				return errAlreadyMember
+			}
+			return err
+		}
+	} else if inv.RoleID.Valid {
+		r, err := q.GetRoleByID(ctx, inv.RoleID.Int64)
+		if err != nil {
+			return err
+		}
+		role = r.Name
+		if err := q.AssignUserRole(ctx, sqlc.AssignUserRoleParams{
+			UserID: userID,
+			RoleID: r.ID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	event := auditEvent(c, audit.ActionInvitationAccepted, audit.TargetInvitation, inv.ID)
+	event.ActorID = userID
+	event.Diff = invitationDiff(inv, role)
+	return audit.Record(ctx, q, event)
+}
+
+// send mails an invitation to the email it is for
+func (h *InvitationHandler) send(ctx context.Context, inv sqlc.Invitation, token, orgName string) error {
+	to := "to create an account"
+	if orgName != "" {
+		to = "to join " + orgName
+	}
+
+	link := fmt.Sprintf("%s/invitation?token=%s", h.appURL, url.QueryEscape(token))
+	return h.mailer.Send(ctx, mail.Message{
+		To:      inv.Email,
+		Subject: "You have been invited",
+		Body: fmt.Sprintf("Hi,\n\n"+
+			"You have been invited %s. Follow this link within %s to accept:\n\n%s\n\n"+
+			"If you weren't expecting this, you can ignore this email.\n",
+			to, h.expiry, link),
+	})
+}
+
+// invitationAllows reports whether the account with email may accept inv
+func invitationAllows(inv sqlc.Invitation, email string) bool {
+	if inv.Email != "" && !strings.EqualFold(inv.Email, email) {
+		return false
+	}
+	if inv.EmailDomain != "" {
+		at := strings.LastIndex(email, "@")
+		if at < 0 || !strings.EqualFold(email[at+1:], inv.EmailDomain) {
+			return false
+		}
+	}
+	return true
+}
+
+// invitationDiff describes what inv grants for the audit log
+func invitationDiff(inv sqlc.Invitation, role string) map[string]audit.Change {
+	diff := map[string]audit.Change{}
+	if inv.OrgID.Valid {
+		diff["org_id"] = audit.Change{To: inv.OrgID.Int64}
+		diff["org_role"] = audit.Change{To: role}
+	} else if role != "" {
+		diff["role"] = audit.Change{To: role}
+	}
+	if inv.Email != "" {
+		diff["email"] = audit.Change{To: inv.Email}
+	}
+	if inv.EmailDomain != "" {
+		diff["email_domain"] = audit.Change{To: inv.EmailDomain}
+	}
+	return diff
+}
+
+// invitationFailed responds to an invitation that could not be used,
+// falling back to a 500 with message
+func invitationFailed(c *gin.Context, err error, message string) {
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired invitation"})
+	case errors.Is(err, errInvitationEmail):
+		c.JSON(http.StatusForbidden, gin.H{"error": "this invitation is for a different email address"})
+	case errors.Is(err, errInvitationUnverified):
+		c.JSON(http.StatusForbidden, gin.H{"error": "verify your email address before accepting this invitation"})
+	case errors.Is(err, errAlreadyMember):
+		c.JSON(http.StatusConflict, gin.H{"error": "already a member of this organization"})
+	case errors.Is(err, errInvitationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "invitation not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func newInvitationInfo(inv sqlc.Invitation, role string) InvitationInfo {
+	info := InvitationInfo{
+		ID:          inv.ID,
+		Role:        role,
+		Email:       inv.Email,
+		EmailDomain: inv.EmailDomain,
+		ExpiresAt:   inv.ExpiresAt,
+		CreatedAt:   inv.CreatedAt,
+	}
+	if inv.OrgID.Valid {
+		info.OrgID = &inv.OrgID.Int64
+	}
+	if inv.ClaimedBy.Valid {
+		info.ClaimedBy = &inv.ClaimedBy.Int64
+	}
+	return info
+}
@@ -0,0 +1,133 @@
+package handlers_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+	"github.com/yourusername/go-sqlc-starter/internal/mail"
+)
+
+// failingMailer refuses every message
+type failingMailer struct{}
+
+func (failingMailer) Send(ctx context.Context, msg mail.Message) error {
+	return errors.New("mail server unavailable")
+}
+
+func TestCreateInvitation(t *testing.T) {
+	s := newTestServer(t, "admin@example.com")
+	newHandler := func(mailer mail.Mailer) *handlers.InvitationHandler {
+		return handlers.NewInvitationHandler(s.store, s.authHandler, mailer, "http://localhost:3000", time.Hour, zerolog.Nop())
+	}
+	s.users.POST("/invitations", newHandler(s.outbox).CreateInvitation)
+	s.users.POST("/invitations/unsent", newHandler(failingMailer{}).CreateInvitation)
+	token := s.login("admin@example.com").AccessToken
+
+	w := s.do(http.MethodPost, "/api/v1/users/invitations", token, map[string]string{"email": "alice@example.com"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "token", "the token only goes out by mail")
+	assert.Len(t, s.mail(), 1)
+
+	// A failed mail is logged after the response; the invitation stands
+	w = s.do(http.MethodPost, "/api/v1/users/invitations/unsent", token, map[string]string{"email": "bob@example.com"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Len(t, s.mail(), 1)
+	require.Len(t, s.store.invitations, 2)
+	assert.Equal(t, "bob@example.com", s.store.invitations[1].Email)
+}
+
+func TestRegisterWithInvitation(t *testing.T) {
+	s := newTestServer(t)
+	invitationHandler := handlers.NewInvitationHandler(s.store, s.authHandler, s.outbox, "http://localhost:3000", time.Hour, zerolog.Nop())
+	s.router.POST("/api/v1/auth/invitation/register", invitationHandler.Register)
+	s.users.POST("/me/invitations/accept", invitationHandler.Accept)
+	s.router.POST("/api/v1/auth/verify-email", s.verification.VerifyEmail)
+
+	s.store.organizations[1] = sqlc.Organization{ID: 1, Name: "Acme", Slug: "acme"}
+	invite := func(token, email, domain string) {
+		s.store.invitations = append(s.store.invitations, sqlc.Invitation{
+			ID:          int64(len(s.store.invitations) + 1),
+			TokenHash:   auth.HashToken(token),
+			OrgID:       sql.NullInt64{Int64: 1, Valid: true},
+			OrgRole:     "member",
+			Email:       email,
+			EmailDomain: domain,
+			ExpiresAt:   time.Now().Add(time.Hour),
+		})
+	}
+	register := func(token, email string) handlers.AuthResponse {
+		w := s.do(http.MethodPost, "/api/v1/auth/invitation/register", "", map[string]string{
+			"token":     token,
+			"email":     email,
+			"password":  testPassword,
+			"full_name": "Test User",
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var resp handlers.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+	member := func(userID int64) bool {
+		_, err := s.store.GetOrganizationMember(context.Background(), sqlc.GetOrganizationMemberParams{OrgID: 1, UserID: userID})
+		return err == nil
+	}
+
+	t.Run("an email invitation verifies the email and joins", func(t *testing.T) {
+		invite("email-invite", "alice@example.com", "")
+		resp := register("email-invite", "alice@example.com")
+
+		assert.True(t, s.store.users[resp.User.ID].EmailVerifiedAt.Valid)
+		assert.True(t, member(resp.User.ID))
+		assert.True(t, s.store.invitations[0].AcceptedAt.Valid)
+	})
+
+	t.Run("a domain invitation waits for the email to be verified", func(t *testing.T) {
+		invite("domain-invite", "", "example.com")
+		resp := register("domain-invite", "bob@example.com")
+
+		assert.False(t, s.store.users[resp.User.ID].EmailVerifiedAt.Valid)
+		assert.False(t, member(resp.User.ID), "nothing is granted to an unverified email")
+		assert.False(t, s.store.invitations[1].AcceptedAt.Valid)
+		assert.Len(t, s.mail(), 1, "a verification link is sent")
+
+		w := s.do(http.MethodPost, "/api/v1/users/me/invitations/accept", resp.AccessToken, map[string]string{"token": "domain-invite"})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "the invitation is claimed by the new account")
+		assert.False(t, member(resp.User.ID))
+
+		s.store.verificationTokens = append(s.store.verificationTokens, sqlc.EmailVerificationToken{
+			ID:        int64(len(s.store.verificationTokens) + 1),
+			UserID:    resp.User.ID,
+			Email:     "bob@example.com",
+			TokenHash: auth.HashToken("verify-bob"),
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+		w = s.do(http.MethodPost, "/api/v1/auth/verify-email", "", map[string]string{"token": "verify-bob"})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.True(t, member(resp.User.ID))
+		assert.True(t, s.store.invitations[1].AcceptedAt.Valid)
+	})
+
+	t.Run("a domain invitation admits one registration", func(t *testing.T) {
+		invite("shared-invite", "", "example.com")
+		register("shared-invite", "carol@example.com")
+
+		w := s.do(http.MethodPost, "/api/v1/auth/invitation/register", "", map[string]string{
+			"token":     "shared-invite",
+			"email":     "dave@example.com",
+			"password":  testPassword,
+			"full_name": "Test User",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	})
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+	"github.com/yourusername/go-sqlc-starter/internal/mail"
+)
+
+var errMagicLinkInvalid = errors.New("sign-in link invalid or expired")
+
+// MagicLinkHandler signs users in with one-time links sent to their email.
+// Opening a link never redeems it, so mail scanners that prefetch links
+// cannot use them up; the user confirms the sign-in with a POST.
+type MagicLinkHandler struct {
+	store       db.Store
+	authHandler *AuthHandler
+	mailer      mail.Mailer
+	appURL      string
+	expiry      time.Duration
+	logger      zerolog.Logger
+}
+
+func NewMagicLinkHandler(store db.Store, authHandler *AuthHandler, mailer mail.Mailer, appURL string, expiry time.Duration, logger zerolog.Logger) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		store:       store,
+		authHandler: authHandler,
+		mailer:      mailer,
+		appURL:      appURL,
+		expiry:      expiry,
+		logger:      logger,
+	}
+}
+
+// MagicLinkRequest represents the magic link request body
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerifyMagicLinkRequest represents the verify magic link request body
+type VerifyMagicLinkRequest struct {
+	Token      string `json:"token" binding:"required"`
+	DeviceName string `json:"device_name,omitempty" binding:"max=100"`
+}
+
+// RequestLink emails a sign-in link. The response is the same, and sent
+// before any mail, whether or not the account exists, so it cannot be used to
+// probe emails.
+func (h *MagicLinkHandler) RequestLink(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inBackground(c, h.logger, "Failed to send magic link", func(ctx context.Context) error {
+		return h.sendLink(ctx, req.Email)
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "if the account exists, a sign-in link has been sent"})
+}
+
+// CheckLink reports whether a link can still be used and for which account,
+// without redeeming it. Clients show a confirmation step with this before
+// calling VerifyLink.
+func (h *MagicLinkHandler) CheckLink(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	link, err := h.store.GetMagicLinkToken(ctx, auth.HashToken(token))
+	if err == nil {
+		_, err = h.linkUser(c, link)
+	}
+	if err != nil {
+		h.linkFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email":      link.Email,
+		"expires_at": link.ExpiresAt,
+	})
+}
+
+// VerifyLink redeems a link and signs the user in exactly as a password login
+// would, including the second factor if one is enabled. Following the link
+// proves the user owns the email, so an unverified address becomes verified.
+func (h *MagicLinkHandler) VerifyLink(c *gin.Context) {
+	var req VerifyMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	link, err := h.store.ConsumeMagicLinkToken(ctx, auth.HashToken(req.Token))
+	if err != nil {
+		h.linkFailed(c, err)
+		return
+	}
+
+	user, err := h.linkUser(c, link)
+	if err != nil {
+		h.linkFailed(c, err)
+		return
+	}
+
+	if !user.EmailVerifiedAt.Valid {
+		err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+			var err error
+			user, err = q.VerifyUserEmail(ctx, sqlc.VerifyUserEmailParams{
+				Email: user.Email,
+				ID:    user.ID,
+			})
+			if err != nil {
+				return err
+			}
+
+			event := auditEvent(c, audit.ActionEmailVerified, audit.TargetUser, user.ID)
+			event.ActorID = user.ID
+			event.Diff = map[string]audit.Change{"email_verified": {From: false, To: true}}
+			return audit.Record(ctx, q, event)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email"})
+			return
+		}
+	}
+
+	// Best effort: older links for the account are no longer needed
+	_ = h.store.DeleteUserMagicLinkTokens(ctx, user.ID)
+
+	h.logger.Info().
+		Str("event", "magic_link_login").
+		Int64("user_id", user.ID).
+		Str("client_ip", c.ClientIP()).
+		Str("request_id", c.GetString("request_id")).
+		Msg("User signed in with a magic link")
+
+	h.authHandler.signIn(c, user, req.DeviceName)
+}
+
+// sendLink stores a new sign-in token for the account registered to email,
+// if any, and mails it
+func (h *MagicLinkHandler) sendLink(ctx context.Context, email string) error {
+	user, err := h.store.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	// Best effort: expired tokens can no longer be redeemed
+	_ = h.store.DeleteExpiredMagicLinkTokens(ctx)
+
+	if _, err := h.store.CreateMagicLinkToken(ctx, sqlc.CreateMagicLinkTokenParams{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(h.expiry),
+	}); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/magic-link?token=%s", h.appURL, url.QueryEscape(token))
+	return h.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked for a link to sign in to your account. If it was you, "+
+			"follow this link within %s to sign in:\n\n%s\n\n"+
+			"The link works once. If you didn't ask for it, you can ignore this email.\n",
+			user.FullName, h.expiry, link),
+	})
+}
+
+// linkUser returns the account a link signs in to. Links stop working when
+// the account is deactivated or its email changes.
+func (h *MagicLinkHandler) linkUser(c *gin.Context, link sqlc.MagicLinkToken) (sqlc.User, error) {
+	user, err := h.store.GetUserByID(c.Request.Context(), link.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return sqlc.User{}, errMagicLinkInvalid
+		}
+		return sqlc.User{}, err
+	}
+	if user.Email != link.Email {
+		return sqlc.User{}, errMagicLinkInvalid
+	}
+	return user, nil
+}
+
+// linkFailed responds to a link that could not be looked up or redeemed
+func (h *MagicLinkHandler) linkFailed(c *gin.Context, err error) {
+	if err == sql.ErrNoRows || errors.Is(err, errMagicLinkInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired sign-in link"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check sign-in link"})
+}
@@ -0,0 +1,36 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+)
+
+func TestMagicLink(t *testing.T) {
+	s := newTestServer(t, "user@example.com")
+	magicLinkHandler := handlers.NewMagicLinkHandler(s.store, s.authHandler, s.outbox, "http://localhost:3000", time.Hour, zerolog.Nop())
+	s.router.POST("/api/v1/auth/magic-link", magicLinkHandler.RequestLink)
+	s.router.POST("/api/v1/auth/magic-link/verify", magicLinkHandler.VerifyLink)
+
+	known := s.do(http.MethodPost, "/api/v1/auth/magic-link", "", map[string]string{"email": "user@example.com"})
+	unknown := s.do(http.MethodPost, "/api/v1/auth/magic-link", "", map[string]string{"email": "nobody@example.com"})
+	assert.Equal(t, http.StatusOK, known.Code)
+	assert.Equal(t, known.Code, unknown.Code)
+	assert.JSONEq(t, known.Body.String(), unknown.Body.String())
+
+	// The mail goes out after the response
+	messages := s.mail()
+	require.Len(t, messages, 1, "only the existing account is mailed")
+
+	token := linkToken(t, messages[0])
+	w := s.do(http.MethodPost, "/api/v1/auth/magic-link/verify", "", map[string]string{"token": token})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = s.do(http.MethodPost, "/api/v1/auth/magic-link/verify", "", map[string]string{"token": token})
+	assert.NotEqual(t, http.StatusOK, w.Code, "a link works once")
+}
@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+const (
+	// mfaChallengeExpiry is how long a login may wait on its second factor
+	mfaChallengeExpiry = 5 * time.Minute
+
+	// mfaMaxAttempts is how many wrong codes a challenge tolerates before it
+	// is discarded and the user must log in again
+	mfaMaxAttempts = 5
+
+	// recoveryCodeCount is how many recovery codes are issued at a time
+	recoveryCodeCount = 10
+)
+
+var (
+	errMFANotEnrolled      = errors.New("mfa enrollment not started")
+	errMFANotEnabled       = errors.New("mfa not enabled")
+	errMFAAlreadyEnabled   = errors.New("mfa already enabled")
+	errMFACodeInvalid      = errors.New("mfa code invalid")
+	errMFAChallengeInvalid = errors.New("mfa challenge invalid or expired")
+)
+
+// MFAHandler manages TOTP enrollment and recovery codes for the current user
+type MFAHandler struct {
+	store  db.Store
+	cipher *auth.Cipher
+	issuer string
+}
+
+func NewMFAHandler(store db.Store, cipher *auth.Cipher, issuer string) *MFAHandler {
+	return &MFAHandler{
+		store:  store,
+		cipher: cipher,
+		issuer: issuer,
+	}
+}
+
+// MFACodeRequest carries a TOTP code or a recovery code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// VerifyMFARequest represents the MFA verification request body
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// GetStatus reports whether MFA is enabled for the authenticated user
+func (h *MFAHandler) GetStatus(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	enabled, err := h.store.IsMFAEnabled(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get mfa status"})
+		return
+	}
+
+	remaining, err := h.store.CountUnusedRecoveryCodes(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get mfa status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  enabled,
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// Enroll starts TOTP enrollment. MFA stays off until Confirm receives a
+// valid code; enrolling again before then replaces the secret.
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	user, err := h.store.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll mfa"})
+		return
+	}
+
+	encrypted, err := h.cipher.Encrypt([]byte(secret))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll mfa"})
+		return
+	}
+
+	rows, err := h.store.UpsertPendingTOTPCredential(c.Request.Context(), sqlc.UpsertPendingTOTPCredentialParams{
+		UserID:          userID,
+		SecretEncrypted: encrypted,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll mfa"})
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "mfa is already enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": auth.TOTPURI(h.issuer, user.Email, secret),
+	})
+}
+
+// Confirm enables MFA once the user proves their authenticator produces
+// valid codes, and returns the first set of recovery codes
+func (h *MFAHandler) Confirm(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var codes []string
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		cred, err := q.GetTOTPCredentialForUpdate(ctx, userID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errMFANotEnrolled
+			}
+			return err
+		}
+		if cred.ConfirmedAt.Valid {
+			return errMFAAlreadyEnabled
+		}
+
+		secret, err := h.cipher.Decrypt(cred.SecretEncrypted)
+		if err != nil {
+			return err
+		}
+
+		step, ok := auth.ValidateTOTP(string(secret), req.Code, time.Now(), cred.LastUsedStep)
+		if !ok {
+			return errMFACodeInvalid
+		}
+
+		if err := q.ConfirmTOTPCredential(ctx, sqlc.ConfirmTOTPCredentialParams{
+			UserID:       userID,
+			LastUsedStep: step,
+		}); err != nil {
+			return err
+		}
+
+		if codes, err = replaceRecoveryCodes(ctx, q, userID); err != nil {
+			return err
+		}
+		return audit.Record(ctx, q, auditEvent(c, audit.ActionMFAEnabled, audit.TargetUser, userID))
+	})
+	if err != nil {
+		h.respondError(c, err, "failed to confirm mfa")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// Disable turns MFA off after checking a current TOTP or recovery code
+func (h *MFAHandler) Disable(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		if err := h.verifyCode(ctx, q, userID, req.Code); err != nil {
+			return err
+		}
+		if err := q.DeleteTOTPCredential(ctx, userID); err != nil {
+			return err
+		}
+		if err := q.DeleteUserRecoveryCodes(ctx, userID); err != nil {
+			return err
+		}
+		return audit.Record(ctx, q, auditEvent(c, audit.ActionMFADisabled, audit.TargetUser, userID))
+	})
+	if err != nil {
+		h.respondError(c, err, "failed to disable mfa")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "mfa disabled successfully"})
+}
+
+// RegenerateRecoveryCodes replaces every recovery code after checking a
+// current TOTP or recovery code
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var codes []string
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		if err := h.verifyCode(ctx, q, userID, req.Code); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(ctx, q, userID)
+		return err
+	})
+	if err != nil {
+		h.respondError(c, err, "failed to regenerate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// respondError maps MFA errors to responses, falling back to a 500 with message
+func (h *MFAHandler) respondError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, errMFANotEnrolled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa enrollment not started"})
+	case errors.Is(err, errMFANotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa is not enabled"})
+	case errors.Is(err, errMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "mfa is already enabled"})
+	case errors.Is(err, errMFACodeInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// verifyCode checks a TOTP code or an unused recovery code for userID,
+// consuming it so it cannot be used again. It must run inside a transaction.
+func (h *MFAHandler) verifyCode(ctx context.Context, q sqlc.Querier, userID int64, code string) error {
+	cred, err := q.GetTOTPCredentialForUpdate(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errMFANotEnabled
+		}
+		return err
+	}
+	if !cred.ConfirmedAt.Valid {
+		return errMFANotEnabled
+	}
+
+	if len(code) == auth.TOTPDigits {
+		secret, err := h.cipher.Decrypt(cred.SecretEncrypted)
+		if err != nil {
+			return err
+		}
+
+		step, ok := auth.ValidateTOTP(string(secret), code, time.Now(), cred.LastUsedStep)
+		if !ok {
+			return errMFACodeInvalid
+		}
+		return q.UpdateTOTPLastUsedStep(ctx, sqlc.UpdateTOTPLastUsedStepParams{
+			UserID:       userID,
+			LastUsedStep: step,
+		})
+	}
+
+	rows, err := q.UseRecoveryCode(ctx, sqlc.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errMFACodeInvalid
+	}
+	return nil
+}
+
+// replaceRecoveryCodes discards userID's recovery codes and stores digests of
+// a fresh set, returning the plaintext codes to show the user once
+func replaceRecoveryCodes(ctx context.Context, q sqlc.Querier, userID int64) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.DeleteUserRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		if err := q.CreateRecoveryCode(ctx, sqlc.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+// startMFAChallenge answers a successful first factor for an MFA-enabled
+// account with a challenge token instead of session tokens
+func (h *AuthHandler) startMFAChallenge(c *gin.Context, user sqlc.User, deviceName string) {
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start mfa challenge"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Best effort: expired challenges can no longer be completed
+	_ = h.store.DeleteExpiredMFAChallenges(ctx)
+
+	challenge, err := h.store.CreateMFAChallenge(ctx, sqlc.CreateMFAChallengeParams{
+		UserID:     user.ID,
+		TokenHash:  auth.HashToken(token),
+		DeviceName: sql.NullString{String: deviceName, Valid: deviceName != ""},
+		ExpiresAt:  time.Now().Add(mfaChallengeExpiry),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start mfa challenge"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mfa_required": true,
+		"mfa_token":    token,
+		"expires_at":   challenge.ExpiresAt,
+	})
+}
+
+// VerifyMFA completes a login by exchanging an MFA challenge token and a TOTP
+// or recovery code for session tokens. Wrong codes count as failed logins, so
+// they back off and lock out the account like wrong passwords do.
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var (
+		resp   AuthResponse
+		user   sqlc.User
+		wait   time.Duration
+		failed bool
+	)
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		challenge, err := q.GetMFAChallengeForUpdate(ctx, auth.HashToken(req.MFAToken))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errMFAChallengeInvalid
+			}
+			return err
+		}
+
+		user, err = q.GetUserByID(ctx, challenge.UserID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errMFAChallengeInvalid
+			}
+			return err
+		}
+
+		// No guesses while the account is backing off
+		if wait, err = h.throttle.Check(ctx, user.Email, c.ClientIP()); err != nil || wait > 0 {
+			return err
+		}
+
+		if err := h.mfa.verifyCode(ctx, q, challenge.UserID, req.Code); err != nil {
+			if errors.Is(err, errMFANotEnabled) {
+				return errMFAChallengeInvalid
+			}
+			if !errors.Is(err, errMFACodeInvalid) {
+				return err
+			}
+
+			// Count the failure and commit it; too many discard the challenge
+			failed = true
+			attempts, err := q.IncrementMFAChallengeAttempts(ctx, challenge.ID)
+			if err != nil {
+				return err
+			}
+			if attempts >= mfaMaxAttempts {
+				return q.DeleteMFAChallenge(ctx, challenge.ID)
+			}
+			return nil
+		}
+
+		if err := q.DeleteMFAChallenge(ctx, challenge.ID); err != nil {
+			return err
+		}
+		if _, err := h.throttle.Unlock(ctx, q, user.Email); err != nil {
+			return err
+		}
+
+		sess := newSession(c, challenge.DeviceName.String)
+		if resp, err = h.issueTokens(ctx, q, user, sess); err != nil {
+			return err
+		}
+		return audit.Record(ctx, q, loginEvent(c, user, sess))
+	})
+	if err != nil {
+		if errors.Is(err, errMFAChallengeInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired mfa token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify mfa"})
+		return
+	}
+
+	if wait > 0 {
+		tooManyAttempts(c, wait)
+		return
+	}
+
+	if failed {
+		h.recordFailure(c, user.Email)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,85 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+func TestVerifyMFAThrottling(t *testing.T) {
+	s := newTestServer(t, "user@example.com", "other@example.com")
+	secret, err := auth.GenerateTOTPSecret()
+	require.NoError(t, err)
+	encrypted, err := s.cipher.Encrypt([]byte(secret))
+	require.NoError(t, err)
+	for _, id := range []int64{1, 2} {
+		s.store.mfaEnabled[id] = true
+		s.store.totp[id] = sqlc.TotpCredential{UserID: id, SecretEncrypted: encrypted}
+		cred := s.store.totp[id]
+		cred.ConfirmedAt.Valid = true
+		s.store.totp[id] = cred
+	}
+
+	challenge := func(email string) string {
+		w := s.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{"email": email, "password": testPassword})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			MFAToken string `json:"mfa_token"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.NotEmpty(t, resp.MFAToken)
+		return resp.MFAToken
+	}
+	verify := func(token, code string) int {
+		return s.do(http.MethodPost, "/api/v1/auth/mfa/verify", "", map[string]string{"mfa_token": token, "code": code}).Code
+	}
+	validCode := func() string {
+		// Each TOTP step is accepted once; move the last used step back so
+		// the current code works again
+		cred := s.store.totp[1]
+		cred.LastUsedStep = 0
+		s.store.totp[1] = cred
+		code, err := auth.TOTPCode(secret, time.Now())
+		require.NoError(t, err)
+		return code
+	}
+
+	t.Run("a correct code clears earlier failures", func(t *testing.T) {
+		token := challenge("other@example.com")
+		assert.Equal(t, http.StatusUnauthorized, verify(token, "wrong-recovery-code"))
+		assert.Contains(t, s.store.loginAttempts, "account:other@example.com")
+
+		code, err := auth.TOTPCode(secret, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, verify(token, code))
+		assert.NotContains(t, s.store.loginAttempts, "account:other@example.com")
+	})
+
+	t.Run("wrong codes count as failed logins", func(t *testing.T) {
+		token := challenge("user@example.com")
+		for i := 0; i < 4; i++ {
+			assert.Equal(t, http.StatusUnauthorized, verify(token, "wrong-recovery-code"))
+		}
+		assert.Equal(t, int32(4), s.store.loginAttempts["account:user@example.com"].Failures)
+
+		// The password alone does not reset the count
+		token = challenge("user@example.com")
+		assert.Equal(t, int32(4), s.store.loginAttempts["account:user@example.com"].Failures)
+
+		assert.Equal(t, http.StatusUnauthorized, verify(token, "wrong-recovery-code"))
+		assert.Equal(t, http.StatusTooManyRequests, verify(token, validCode()), "no guesses while locked out")
+	})
+
+	t.Run("wrong codes lock the account", func(t *testing.T) {
+		assert.Equal(t, http.StatusTooManyRequests, s.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+			"email":    "user@example.com",
+			"password": testPassword,
+		}).Code)
+	})
+}
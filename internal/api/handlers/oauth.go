@@ -0,0 +1,870 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// authorizationCodeExpiry bounds how long a client has to exchange a code
+const authorizationCodeExpiry = 5 * time.Minute
+
+// oauthError is an OAuth error response (RFC 6749 section 5.2)
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+var (
+	errInvalidClient = &oauthError{Code: "invalid_client", Description: "client authentication failed"}
+	errInvalidGrant  = &oauthError{Code: "invalid_grant", Description: "authorization grant is invalid, expired or revoked"}
+)
+
+type OAuthHandler struct {
+	store       db.Store
+	jwtManager  *auth.JWTManager
+	revocations *auth.RevocationList
+	issuer      string
+	appURL      string
+	logger      zerolog.Logger
+}
+
+func NewOAuthHandler(store db.Store, jwtManager *auth.JWTManager, revocations *auth.RevocationList, issuer, appURL string, logger zerolog.Logger) *OAuthHandler {
+	return &OAuthHandler{
+		store:       store,
+		jwtManager:  jwtManager,
+		revocations: revocations,
+		issuer:      issuer,
+		appURL:      appURL,
+		logger:      logger,
+	}
+}
+
+// AuthorizeRequest represents an OAuth authorization request
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Nonce               string `form:"nonce" json:"nonce"`
+}
+
+// AuthorizeDecisionRequest represents the user's answer on the consent screen
+type AuthorizeDecisionRequest struct {
+	AuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+// OAuthTokenResponse represents a token endpoint response (RFC 6749 section 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+// IntrospectionResponse represents a token introspection response (RFC 7662
+// section 2.2). Inactive tokens carry no other fields.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
+
+// authorization is a validated authorization request. redirectURISupplied
+// records whether the client named the redirect URI or left it to default.
+type authorization struct {
+	client              sqlc.OauthClient
+	redirectURI         string
+	redirectURISupplied bool
+	state               string
+	scopes              []string
+	challenge           string
+	nonce               string
+}
+
+// redirectURL returns the client's redirect URI with params and the state added
+func (a *authorization) redirectURL(params url.Values) string {
+	// Redirect URIs are validated when the client is registered
+	u, _ := url.Parse(a.redirectURI)
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if a.state != "" {
+		query.Set("state", a.state)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Authorize starts the authorization code flow. Valid requests are sent to
+// the frontend consent screen with their parameters; the frontend signs the
+// user in and answers through the /api/v1/oauth/authorize endpoints.
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	var req AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, &oauthError{Code: "invalid_request", Description: err.Error()})
+		return
+	}
+
+	az, err := h.validateAuthorization(c.Request.Context(), &req)
+	if err != nil {
+		var oauthErr *oauthError
+		switch {
+		case !errors.As(err, &oauthErr):
+			c.JSON(http.StatusInternalServerError, &oauthError{Code: "server_error"})
+		case az == nil:
+			// Never redirect to a URI that is not known to belong to the client
+			c.JSON(http.StatusBadRequest, oauthErr)
+		default:
+			c.Redirect(http.StatusFound, az.redirectURL(oauthErrorParams(oauthErr)))
+		}
+		return
+	}
+
+	c.Redirect(http.StatusFound, h.appURL+"/oauth/consent?"+c.Request.URL.RawQuery)
+}
+
+// GetAuthorization describes an authorization request to the consent screen
+// and whether the user still has to approve it
+func (h *OAuthHandler) GetAuthorization(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var req AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	az, err := h.validateAuthorization(c.Request.Context(), &req)
+	if err != nil {
+		respondAuthorizationError(c, az, err)
+		return
+	}
+
+	consentRequired, err := h.consentRequired(c.Request.Context(), userID, az)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get consent"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"client": gin.H{
+			"client_id": az.client.ClientID,
+			"name":      az.client.Name,
+		},
+		"scopes":           az.scopes,
+		"redirect_uri":     az.redirectURI,
+		"consent_required": consentRequired,
+	})
+}
+
+// DecideAuthorization records the user's answer to an authorization request
+// and returns where to send the browser: back to the client with an
+// authorization code, or with access_denied
+func (h *OAuthHandler) DecideAuthorization(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var req AuthorizeDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	az, err := h.validateAuthorization(c.Request.Context(), &req.AuthorizeRequest)
+	if err != nil {
+		respondAuthorizationError(c, az, err)
+		return
+	}
+
+	if !req.Approve {
+		c.JSON(http.StatusOK, gin.H{"redirect_to": az.redirectURL(url.Values{
+			"error":             {"access_denied"},
+			"error_description": {"the user denied the request"},
+		})})
+		return
+	}
+
+	code, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate authorization code"})
+		return
+	}
+
+	// Only active users can authorize clients
+	ctx := c.Request.Context()
+	if _, err := h.store.GetUserByID(ctx, userID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+		return
+	}
+
+	if err := h.store.UpsertOAuthConsent(ctx, sqlc.UpsertOAuthConsentParams{
+		UserID:   userID,
+		ClientID: az.client.ID,
+		Scopes:   az.scopes,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record consent"})
+		return
+	}
+
+	_ = h.store.DeleteExpiredAuthorizationCodes(ctx)
+	if err := h.store.CreateAuthorizationCode(ctx, sqlc.CreateAuthorizationCodeParams{
+		CodeHash:            auth.HashToken(code),
+		ClientID:            az.client.ID,
+		UserID:              userID,
+		RedirectUri:         az.redirectURI,
+		RedirectUriSupplied: az.redirectURISupplied,
+		Scopes:              az.scopes,
+		CodeChallenge:       az.challenge,
+		Nonce:               az.nonce,
+		ExpiresAt:           time.Now().Add(authorizationCodeExpiry),
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to authorize client"})
+		return
+	}
+
+	h.logger.Info().
+		Str("event", "oauth_authorize").
+		Int64("user_id", userID).
+		Str("client_id", az.client.ClientID).
+		Str("scope", auth.FormatScope(az.scopes)).
+		Str("request_id", c.GetString("request_id")).
+		Msg("User authorized OAuth client")
+
+	c.JSON(http.StatusOK, gin.H{"redirect_to": az.redirectURL(url.Values{"code": {code}})})
+}
+
+// Token issues tokens for the authorization_code, refresh_token and
+// client_credentials grants
+func (h *OAuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	grantType := c.PostForm("grant_type")
+	var resp OAuthTokenResponse
+	switch grantType {
+	case auth.GrantAuthorizationCode, auth.GrantRefreshToken, auth.GrantClientCredentials:
+		if !containsString(client.GrantTypes, grantType) {
+			respondOAuthError(c, &oauthError{Code: "unauthorized_client", Description: "client may not use the " + grantType + " grant"})
+			return
+		}
+	case "":
+		respondOAuthError(c, &oauthError{Code: "invalid_request", Description: "grant_type is required"})
+		return
+	default:
+		respondOAuthError(c, &oauthError{Code: "unsupported_grant_type"})
+		return
+	}
+
+	switch grantType {
+	case auth.GrantAuthorizationCode:
+		resp, err = h.exchangeAuthorizationCode(c, client)
+	case auth.GrantRefreshToken:
+		resp, err = h.refreshAccessToken(c, client)
+	case auth.GrantClientCredentials:
+		resp, err = h.clientCredentials(c, client)
+	}
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Introspect reports whether a token is active and what it grants (RFC 7662).
+// Only confidential clients may introspect, and only tokens issued to them.
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+	if !client.ClientSecretHash.Valid {
+		respondOAuthError(c, &oauthError{Code: "invalid_client", Description: "public clients cannot introspect tokens"})
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		respondOAuthError(c, &oauthError{Code: "invalid_request", Description: "token is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if claims, err := h.jwtManager.ValidateToken(token); err == nil {
+		// A client learns nothing about tokens issued to anyone else,
+		// sessions included
+		if claims.ClientID != client.ClientID {
+			c.JSON(http.StatusOK, IntrospectionResponse{Active: false})
+			return
+		}
+		revoked, err := h.revocations.IsRevoked(ctx, claims)
+		if err != nil {
+			respondOAuthError(c, err)
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusOK, IntrospectionResponse{Active: false})
+			return
+		}
+
+		resp := IntrospectionResponse{
+			Active:    true,
+			Scope:     claims.Scope,
+			ClientID:  claims.ClientID,
+			Username:  claims.Email,
+			TokenType: "Bearer",
+			Sub:       claims.Subject,
+			Iss:       claims.Issuer,
+			Jti:       claims.ID,
+		}
+		if resp.Sub == "" && claims.UserID != 0 {
+			resp.Sub = strconv.FormatInt(claims.UserID, 10)
+		}
+		if claims.ExpiresAt != nil {
+			resp.Exp = claims.ExpiresAt.Unix()
+		}
+		if claims.IssuedAt != nil {
+			resp.Iat = claims.IssuedAt.Unix()
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	stored, err := h.store.GetRefreshToken(ctx, auth.HashToken(token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusOK, IntrospectionResponse{Active: false})
+			return
+		}
+		respondOAuthError(c, err)
+		return
+	}
+	if stored.ClientID.Int64 != client.ID || stored.ConsumedAt.Valid {
+		c.JSON(http.StatusOK, IntrospectionResponse{Active: false})
+		return
+	}
+
+	user, err := h.store.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusOK, IntrospectionResponse{Active: false})
+			return
+		}
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, IntrospectionResponse{
+		Active:   true,
+		Scope:    auth.FormatScope(stored.Scopes),
+		ClientID: client.ClientID,
+		Username: user.Email,
+		Exp:      stored.ExpiresAt.Unix(),
+		Iat:      stored.CreatedAt.Unix(),
+		Sub:      strconv.FormatInt(user.ID, 10),
+		Iss:      h.issuer,
+	})
+}
+
+// Revoke invalidates a token the calling client holds (RFC 7009). Revoking a
+// refresh token ends its whole token family. The response is the same
+// whether or not the token was valid.
+func (h *OAuthHandler) Revoke(c *gin.Context) {
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		respondOAuthError(c, &oauthError{Code: "invalid_request", Description: "token is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if claims, err := h.jwtManager.ValidateToken(token); err == nil {
+		// Client credentials tokens have no user to record the revocation
+		// against; they expire with the access token lifetime
+		if claims.ClientID == client.ClientID && claims.UserID != 0 {
+			if err := h.revocations.Revoke(ctx, claims); err != nil {
+				respondOAuthError(c, err)
+				return
+			}
+		}
+		c.Status(http.StatusOK)
+		return
+	}
+
+	stored, err := h.store.GetRefreshToken(ctx, auth.HashToken(token))
+	if err != nil && err != sql.ErrNoRows {
+		respondOAuthError(c, err)
+		return
+	}
+	if err == nil && stored.ClientID.Int64 == client.ID {
+		if err := h.store.DeleteRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+			respondOAuthError(c, err)
+			return
+		}
+		// The access tokens of the grant go with it (RFC 7009 section 2.1)
+		if err := h.revocations.RevokeSession(ctx, stored.UserID, stored.FamilyID.String()); err != nil {
+			respondOAuthError(c, err)
+			return
+		}
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// validateAuthorization checks an authorization request against the client's
+// registration. Errors come with a nil authorization while the redirect URI
+// is still unverified; after that the error can be sent to the client.
+func (h *OAuthHandler) validateAuthorization(ctx context.Context, req *AuthorizeRequest) (*authorization, error) {
+	client, err := h.store.GetOAuthClientByClientID(ctx, req.ClientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &oauthError{Code: "invalid_client", Description: "unknown client"}
+		}
+		return nil, err
+	}
+
+	// The redirect URI must match a registered one exactly. It may be left
+	// out when only one is registered.
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(client.RedirectUris) == 1 {
+		redirectURI = client.RedirectUris[0]
+	}
+	if !containsString(client.RedirectUris, redirectURI) {
+		return nil, &oauthError{Code: "invalid_request", Description: "redirect_uri is not registered for this client"}
+	}
+
+	az := &authorization{
+		client:              client,
+		redirectURI:         redirectURI,
+		redirectURISupplied: req.RedirectURI != "",
+		state:               req.State,
+		scopes:              auth.ParseScope(req.Scope),
+		challenge:           req.CodeChallenge,
+		nonce:               req.Nonce,
+	}
+
+	switch {
+	case req.ResponseType != "code":
+		return az, &oauthError{Code: "unsupported_response_type", Description: "response_type must be code"}
+	case !containsString(client.GrantTypes, auth.GrantAuthorizationCode):
+		return az, &oauthError{Code: "unauthorized_client", Description: "client may not use the authorization_code grant"}
+	case req.CodeChallengeMethod != auth.PKCEMethodS256 || !auth.ValidPKCEChallenge(req.CodeChallenge):
+		return az, &oauthError{Code: "invalid_request", Description: "code_challenge with code_challenge_method S256 is required"}
+	case !auth.ScopesCovered(az.scopes, client.Scopes):
+		return az, &oauthError{Code: "invalid_scope", Description: "scope is not allowed for this client"}
+	case containsString(az.scopes, auth.ScopeOpenID) && !h.jwtManager.SignsIDTokens():
+		return az, &oauthError{Code: "invalid_scope", Description: "OpenID Connect is not enabled"}
+	case len(req.Nonce) > 255:
+		return az, &oauthError{Code: "invalid_request", Description: "nonce is too long"}
+	}
+
+	return az, nil
+}
+
+// consentRequired reports whether userID has yet to approve az. Trusted
+// clients never ask.
+func (h *OAuthHandler) consentRequired(ctx context.Context, userID int64, az *authorization) (bool, error) {
+	if az.client.Trusted {
+		return false, nil
+	}
+
+	consent, err := h.store.GetOAuthConsent(ctx, sqlc.GetOAuthConsentParams{
+		UserID:   userID,
+		ClientID: az.client.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return true, nil
+		}
+		return false, err
+	}
+	return !auth.ScopesCovered(az.scopes, consent.Scopes), nil
+}
+
+// authenticateClient identifies the client calling a token endpoint from
+// HTTP Basic credentials or the client_id and client_secret form fields.
+// Public clients send only their client_id.
+func (h *OAuthHandler) authenticateClient(c *gin.Context) (sqlc.OauthClient, error) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// Credentials are form-encoded before Basic encoding (RFC 6749
+		// section 2.3.1)
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err != nil {
+			return sqlc.OauthClient{}, errInvalidClient
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return sqlc.OauthClient{}, errInvalidClient
+		}
+	} else {
+		clientID = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+	if clientID == "" {
+		return sqlc.OauthClient{}, errInvalidClient
+	}
+
+	client, err := h.store.GetOAuthClientByClientID(c.Request.Context(), clientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return sqlc.OauthClient{}, errInvalidClient
+		}
+		return sqlc.OauthClient{}, err
+	}
+
+	if client.ClientSecretHash.Valid {
+		if secret == "" || !tokenHashMatches(secret, client.ClientSecretHash.String) {
+			return sqlc.OauthClient{}, errInvalidClient
+		}
+	} else if secret != "" {
+		return sqlc.OauthClient{}, errInvalidClient
+	}
+
+	return client, nil
+}
+
+// exchangeAuthorizationCode redeems an authorization code. The code is
+// deleted as it is read, so a failed attempt also burns it.
+func (h *OAuthHandler) exchangeAuthorizationCode(c *gin.Context, client sqlc.OauthClient) (OAuthTokenResponse, error) {
+	code := c.PostForm("code")
+	if code == "" {
+		return OAuthTokenResponse{}, &oauthError{Code: "invalid_request", Description: "code is required"}
+	}
+
+	ctx := c.Request.Context()
+	grant, err := h.store.ConsumeAuthorizationCode(ctx, auth.HashToken(code))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return OAuthTokenResponse{}, errInvalidGrant
+		}
+		return OAuthTokenResponse{}, err
+	}
+
+	// The redirect URI must be repeated if the authorization request named
+	// it; if it is sent anyway it must still match
+	redirectURI := c.PostForm("redirect_uri")
+	if grant.ClientID != client.ID ||
+		!grant.ExpiresAt.After(time.Now()) ||
+		((grant.RedirectUriSupplied || redirectURI != "") && grant.RedirectUri != redirectURI) ||
+		!auth.VerifyPKCE(c.PostForm("code_verifier"), grant.CodeChallenge) {
+		return OAuthTokenResponse{}, errInvalidGrant
+	}
+
+	user, err := h.store.GetUserByID(ctx, grant.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return OAuthTokenResponse{}, errInvalidGrant
+		}
+		return OAuthTokenResponse{}, err
+	}
+
+	return h.issueTokens(ctx, h.store, client, user, newSession(c, ""), grant.Scopes, grant.Scopes, grant.Nonce)
+}
+
+// refreshAccessToken rotates an OAuth refresh token. As with sessions, a
+// consumed token presented again revokes its family. scope may narrow the
+// new access token but the grant itself is kept.
+func (h *OAuthHandler) refreshAccessToken(c *gin.Context, client sqlc.OauthClient) (OAuthTokenResponse, error) {
+	token := c.PostForm("refresh_token")
+	if token == "" {
+		return OAuthTokenResponse{}, &oauthError{Code: "invalid_request", Description: "refresh_token is required"}
+	}
+
+	var resp OAuthTokenResponse
+	var reused *sqlc.RefreshToken
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		stored, err := q.GetRefreshTokenForUpdate(ctx, auth.HashToken(token))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errInvalidGrant
+			}
+			return err
+		}
+		if !stored.ClientID.Valid || stored.ClientID.Int64 != client.ID {
+			return errInvalidGrant
+		}
+
+		if stored.ConsumedAt.Valid {
+			reused = &stored
+			return q.DeleteRefreshTokenFamily(ctx, stored.FamilyID)
+		}
+
+		scopes := stored.Scopes
+		if scope := c.PostForm("scope"); scope != "" {
+			scopes = auth.ParseScope(scope)
+			if !auth.ScopesCovered(scopes, stored.Scopes) {
+				return &oauthError{Code: "invalid_scope", Description: "scope exceeds the original grant"}
+			}
+		}
+
+		if _, err := q.ConsumeRefreshToken(ctx, stored.ID); err != nil {
+			return err
+		}
+
+		user, err := q.GetUserByID(ctx, stored.UserID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errInvalidGrant
+			}
+			return err
+		}
+
+		sess := newSession(c, "")
+		sess.familyID = stored.FamilyID
+
+		resp, err = h.issueTokens(ctx, q, client, user, sess, stored.Scopes, scopes, "")
+		return err
+	})
+	if err != nil {
+		return OAuthTokenResponse{}, err
+	}
+
+	if reused != nil {
+		// Access tokens already issued from the family go with it
+		if err := h.revocations.RevokeSession(ctx, reused.UserID, reused.FamilyID.String()); err != nil {
+			return OAuthTokenResponse{}, err
+		}
+
+		h.logger.Warn().
+			Str("event", "refresh_token_reuse").
+			Int64("user_id", reused.UserID).
+			Str("client_id", client.ClientID).
+			Str("family_id", reused.FamilyID.String()).
+			Str("client_ip", c.ClientIP()).
+			Str("request_id", c.GetString("request_id")).
+			Msg("OAuth refresh token reuse detected, token family revoked")
+
+		return OAuthTokenResponse{}, errInvalidGrant
+	}
+
+	return resp, nil
+}
+
+// clientCredentials issues an access token to a confidential client acting
+// on its own behalf. Its scopes are used as permissions directly.
+func (h *OAuthHandler) clientCredentials(c *gin.Context, client sqlc.OauthClient) (OAuthTokenResponse, error) {
+	if !client.ClientSecretHash.Valid {
+		return OAuthTokenResponse{}, &oauthError{Code: "unauthorized_client", Description: "public clients cannot use the client_credentials grant"}
+	}
+
+	scopes := client.Scopes
+	if scope := c.PostForm("scope"); scope != "" {
+		scopes = auth.ParseScope(scope)
+		if !auth.ScopesCovered(scopes, client.Scopes) {
+			return OAuthTokenResponse{}, &oauthError{Code: "invalid_scope", Description: "scope is not allowed for this client"}
+		}
+	}
+
+	accessToken, err := h.jwtManager.IssueAccessToken(auth.Claims{
+		Permissions: scopes,
+		ClientID:    client.ClientID,
+		Scope:       auth.FormatScope(scopes),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:  h.issuer,
+			Subject: client.ClientID,
+		},
+	})
+	if err != nil {
+		return OAuthTokenResponse{}, err
+	}
+
+	return OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(h.jwtManager.AccessExpiry().Seconds()),
+		Scope:       auth.FormatScope(scopes),
+	}, nil
+}
+
+// issueTokens creates an access token for user limited to scopes, an ID
+// token carrying nonce if scopes include openid and, if the client may
+// refresh, a stored refresh token carrying granted within sess. The access
+// token names sess, so revoking the refresh token family revokes it too.
+func (h *OAuthHandler) issueTokens(ctx context.Context, q sqlc.Querier, client sqlc.OauthClient, user sqlc.User, sess session, granted, scopes []string, nonce string) (OAuthTokenResponse, error) {
+	// A client can never do more than the user who authorized it
+	permissions, err := q.ListUserPermissions(ctx, user.ID)
+	if err != nil {
+		return OAuthTokenResponse{}, err
+	}
+
+	accessToken, err := h.jwtManager.IssueAccessToken(auth.Claims{
+		UserID:      user.ID,
+		Email:       userInfoClaims(user, scopes).Email,
+		Permissions: auth.FilterScopes(scopes, permissions),
+		SessionID:   sess.familyID.String(),
+		ClientID:    client.ClientID,
+		Scope:       auth.FormatScope(scopes),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:  h.issuer,
+			Subject: strconv.FormatInt(user.ID, 10),
+		},
+	})
+	if err != nil {
+		return OAuthTokenResponse{}, err
+	}
+
+	resp := OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(h.jwtManager.AccessExpiry().Seconds()),
+		Scope:       auth.FormatScope(scopes),
+	}
+
+	// Grants made before the signing algorithm changed to HS256 keep
+	// refreshing, just without ID tokens
+	if containsString(scopes, auth.ScopeOpenID) && h.jwtManager.SignsIDTokens() {
+		claims := userInfoClaims(user, scopes)
+		resp.IDToken, err = h.jwtManager.IssueIDToken(auth.IDTokenClaims{
+			Nonce:         nonce,
+			Email:         claims.Email,
+			EmailVerified: claims.EmailVerified,
+			Name:          claims.Name,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:   h.issuer,
+				Subject:  claims.Sub,
+				Audience: jwt.ClaimStrings{client.ClientID},
+			},
+		})
+		if err != nil {
+			return OAuthTokenResponse{}, err
+		}
+	}
+
+	if !containsString(client.GrantTypes, auth.GrantRefreshToken) {
+		return resp, nil
+	}
+
+	refreshToken, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return OAuthTokenResponse{}, err
+	}
+	if granted == nil {
+		granted = []string{}
+	}
+
+	// Store refresh token digest, never the token itself
+	_, err = q.CreateOAuthRefreshToken(ctx, sqlc.CreateOAuthRefreshTokenParams{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(h.jwtManager.RefreshExpiry()),
+		FamilyID:  sess.familyID,
+		UserAgent: sess.userAgent,
+		IpAddress: sess.ipAddress,
+		ClientID:  sql.NullInt64{Int64: client.ID, Valid: true},
+		Scopes:    granted,
+	})
+	if err != nil {
+		return OAuthTokenResponse{}, err
+	}
+
+	resp.RefreshToken = refreshToken
+	return resp, nil
+}
+
+// respondOAuthError writes err in the format of RFC 6749 section 5.2
+func respondOAuthError(c *gin.Context, err error) {
+	var oauthErr *oauthError
+	if !errors.As(err, &oauthErr) {
+		c.JSON(http.StatusInternalServerError, &oauthError{Code: "server_error"})
+		return
+	}
+
+	if oauthErr.Code == "invalid_client" {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		c.JSON(http.StatusUnauthorized, oauthErr)
+		return
+	}
+	c.JSON(http.StatusBadRequest, oauthErr)
+}
+
+// respondAuthorizationError reports a failed authorization request to the
+// consent screen. Once the redirect URI is verified the response says where
+// to send the browser so the client learns of the error.
+func respondAuthorizationError(c *gin.Context, az *authorization, err error) {
+	var oauthErr *oauthError
+	if !errors.As(err, &oauthErr) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to validate authorization request"})
+		return
+	}
+
+	if az == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": oauthErr.Description})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":       oauthErr.Description,
+		"redirect_to": az.redirectURL(oauthErrorParams(oauthErr)),
+	})
+}
+
+// oauthErrorParams returns err as redirect query parameters
+func oauthErrorParams(err *oauthError) url.Values {
+	params := url.Values{"error": {err.Code}}
+	if err.Description != "" {
+		params.Set("error_description", err.Description)
+	}
+	return params
+}
+
+// tokenHashMatches reports whether token hashes to hash, in constant time
+func tokenHashMatches(token, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(auth.HashToken(token)), []byte(hash)) == 1
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
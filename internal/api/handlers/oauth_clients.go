@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+var (
+	errAuthorizedAppNotFound = errors.New("authorized app not found")
+	errOAuthClientNotFound   = errors.New("client not found")
+)
+
+type OAuthClientHandler struct {
+	store       db.Store
+	revocations *auth.RevocationList
+}
+
+func NewOAuthClientHandler(store db.Store, revocations *auth.RevocationList) *OAuthClientHandler {
+	return &OAuthClientHandler{store: store, revocations: revocations}
+}
+
+// CreateOAuthClientRequest represents the register OAuth client request body
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types"`
+	Scopes       []string `json:"scopes"`
+	// Public clients, such as single-page and native apps, cannot keep a
+	// secret and authenticate with PKCE alone
+	Public bool `json:"public"`
+	// Trusted clients skip the consent screen
+	Trusted bool `json:"trusted"`
+}
+
+// OAuthClientInfo describes a registered OAuth client. The secret is never
+// shown again after registration.
+type OAuthClientInfo struct {
+	ClientID     string    `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"`
+	Trusted      bool      `json:"trusted"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AuthorizedAppInfo describes a client the user has granted access to
+type AuthorizedAppInfo struct {
+	ClientID  string    `json:"client_id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListClients returns all registered OAuth clients
+func (h *OAuthClientHandler) ListClients(c *gin.Context) {
+	clients, err := h.store.ListOAuthClients(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list clients"})
+		return
+	}
+
+	infos := make([]OAuthClientInfo, 0, len(clients))
+	for _, client := range clients {
+		infos = append(infos, newOAuthClientInfo(client))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clients": infos})
+}
+
+// CreateClient registers an OAuth client. Scopes must be OpenID Connect
+// scopes or permissions the admin holds; confidential clients get a secret
+// that is returned once.
+func (h *OAuthClientHandler) CreateClient(c *gin.Context) {
+	var req CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	grantTypes := uniqueStrings(req.GrantTypes)
+	if len(grantTypes) == 0 {
+		grantTypes = []string{auth.GrantAuthorizationCode, auth.GrantRefreshToken}
+	}
+	for _, grantType := range grantTypes {
+		switch grantType {
+		case auth.GrantAuthorizationCode, auth.GrantRefreshToken:
+		case auth.GrantClientCredentials:
+			if req.Public {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "public clients cannot use the client_credentials grant"})
+				return
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported grant type: " + grantType})
+			return
+		}
+	}
+
+	redirectURIs := uniqueStrings(req.RedirectURIs)
+	for _, uri := range redirectURIs {
+		if !validRedirectURI(uri) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid redirect URI: " + uri})
+			return
+		}
+	}
+	if containsString(grantTypes, auth.GrantAuthorizationCode) && len(redirectURIs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorization_code clients need at least one redirect URI"})
+		return
+	}
+
+	// A client can never do more than the admin who registers it; its scopes
+	// become the permissions of its client credentials tokens
+	granted, err := h.store.ListUserPermissions(c.Request.Context(), c.GetInt64("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get permissions"})
+		return
+	}
+	allowed := append(append([]string{}, auth.OIDCScopes...), granted...)
+	scopes := uniqueStrings(req.Scopes)
+	for _, scope := range scopes {
+		if !containsString(allowed, scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scope not granted to user: " + scope})
+			return
+		}
+	}
+
+	clientID, secret, err := auth.GenerateClientCredentials()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate client credentials"})
+		return
+	}
+	var secretHash sql.NullString
+	if req.Public {
+		secret = ""
+	} else {
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := h.store.CreateOAuthClient(c.Request.Context(), sqlc.CreateOAuthClientParams{
+		ClientID:         clientID,
+		ClientSecretHash: secretHash,
+		Name:             req.Name,
+		RedirectUris:     redirectURIs,
+		GrantTypes:       grantTypes,
+		Scopes:           scopes,
+		Trusted:          req.Trusted,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register client"})
+		return
+	}
+
+	resp := gin.H{"client": newOAuthClientInfo(client)}
+	if secret != "" {
+		resp["client_secret"] = secret
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// DeleteClient removes an OAuth client along with its codes, consents and
+// refresh tokens. Every access token issued to it is revoked, whether for a
+// user or through client credentials.
+func (h *OAuthClientHandler) DeleteClient(c *gin.Context) {
+	clientID := c.Param("client_id")
+
+	ctx := c.Request.Context()
+	var sessions []sqlc.DeleteOAuthClientRefreshTokensRow
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		if sessions, err = q.DeleteOAuthClientRefreshTokens(ctx, clientID); err != nil {
+			return err
+		}
+
+		rows, err := q.DeleteOAuthClient(ctx, clientID)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return errOAuthClientNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errOAuthClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "client not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete client"})
+		return
+	}
+
+	// Every token naming the client is rejected from now on. The sessions of
+	// its grants are revoked as well, as wherever refresh tokens are deleted.
+	revoked := map[uuid.UUID]bool{}
+	for _, session := range sessions {
+		if revoked[session.FamilyID] {
+			continue
+		}
+		revoked[session.FamilyID] = true
+		if err := h.revocations.RevokeSession(ctx, session.UserID, session.FamilyID.String()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+			return
+		}
+	}
+	h.revocations.ClientDeleted(clientID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "client deleted successfully"})
+}
+
+// ListAuthorizedApps returns the clients the authenticated user has granted
+// access to
+func (h *OAuthClientHandler) ListAuthorizedApps(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	consents, err := h.store.ListUserOAuthConsents(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list authorized apps"})
+		return
+	}
+
+	apps := make([]AuthorizedAppInfo, 0, len(consents))
+	for _, consent := range consents {
+		apps = append(apps, AuthorizedAppInfo{
+			ClientID:  consent.ClientID,
+			Name:      consent.Name,
+			Scopes:    consent.Scopes,
+			CreatedAt: consent.CreatedAt,
+			UpdatedAt: consent.UpdatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"authorized_apps": apps})
+}
+
+// RevokeAuthorizedApp withdraws the authenticated user's consent for a client
+// and deletes the refresh tokens it holds for them. The access tokens issued
+// from those are revoked.
+func (h *OAuthClientHandler) RevokeAuthorizedApp(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	ctx := c.Request.Context()
+	var families []uuid.UUID
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		client, err := q.GetOAuthClientByClientID(ctx, c.Param("client_id"))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errAuthorizedAppNotFound
+			}
+			return err
+		}
+
+		rows, err := q.DeleteOAuthConsent(ctx, sqlc.DeleteOAuthConsentParams{
+			UserID:   userID,
+			ClientID: client.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return errAuthorizedAppNotFound
+		}
+
+		families, err = q.DeleteUserClientRefreshTokens(ctx, sqlc.DeleteUserClientRefreshTokensParams{
+			UserID:   userID,
+			ClientID: sql.NullInt64{Int64: client.ID, Valid: true},
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errAuthorizedAppNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "authorized app not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke authorized app"})
+		return
+	}
+
+	revoked := map[uuid.UUID]bool{}
+	for _, family := range families {
+		if revoked[family] {
+			continue
+		}
+		revoked[family] = true
+		if err := h.revocations.RevokeSession(ctx, userID, family.String()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "app access revoked successfully"})
+}
+
+// newOAuthClientInfo returns the public view of client
+func newOAuthClientInfo(client sqlc.OauthClient) OAuthClientInfo {
+	return OAuthClientInfo{
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		GrantTypes:   client.GrantTypes,
+		Scopes:       client.Scopes,
+		Public:       !client.ClientSecretHash.Valid,
+		Trusted:      client.Trusted,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// validRedirectURI reports whether uri may be registered as a redirect URI:
+// an absolute URI without a fragment that is https, http on a loopback
+// address, or a private-use scheme for native apps (RFC 8252 section 7)
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		// Private-use schemes are reverse domain names such as com.example.app
+		return strings.Contains(u.Scheme, ".")
+	}
+}
+
+// uniqueStrings returns list without empty or duplicate entries
+func uniqueStrings(list []string) []string {
+	out := []string{}
+	for _, s := range list {
+		if s != "" && !containsString(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
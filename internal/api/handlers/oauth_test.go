@@ -0,0 +1,505 @@
+package handlers_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/api/middleware"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// testOAuthUser is the user the OAuth tests authorize clients for
+func testOAuthUser() sqlc.User {
+	return sqlc.User{
+		ID:              7,
+		Email:           "user@example.com",
+		FullName:        "Test User",
+		IsActive:        true,
+		EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+}
+
+func TestOAuthAuthorizationCodePKCE(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := newMemStore(testOAuthUser())
+	store.permissions[7] = []string{"users:read"}
+	store.clients = []sqlc.OauthClient{{
+		ID:           1,
+		ClientID:     "spa",
+		RedirectUris: []string{"https://app.example.com/callback"},
+		GrantTypes:   []string{auth.GrantAuthorizationCode},
+		Scopes:       []string{"users:read", "users:write"},
+	}}
+	verifier := strings.Repeat("verifier-", 6)
+	addCode := func(code string) {
+		store.codes[auth.HashToken(code)] = sqlc.OauthAuthorizationCode{
+			ClientID:      1,
+			UserID:        7,
+			RedirectUri:   "https://app.example.com/callback",
+			Scopes:        []string{"users:read", "users:write"},
+			CodeChallenge: auth.PKCEChallenge(verifier),
+			ExpiresAt:     time.Now().Add(time.Minute),
+		}
+	}
+
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	oauthHandler := handlers.NewOAuthHandler(store, jwtManager, nil, "https://auth.example.com", "https://app.example.com", zerolog.Nop())
+	router := gin.New()
+	router.POST("/oauth/token", oauthHandler.Token)
+
+	exchange := func(code, verifier string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {"spa"},
+			"code":          {code},
+			"redirect_uri":  {"https://app.example.com/callback"},
+			"code_verifier": {verifier},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A wrong verifier fails and burns the code
+	addCode("stolen")
+	w := exchange("stolen", strings.Repeat("attacker-", 6))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_grant")
+	w = exchange("stolen", verifier)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	addCode("good")
+	w = exchange("good", verifier)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	var resp handlers.OAuthTokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Empty(t, resp.RefreshToken)
+	assert.Equal(t, "users:read users:write", resp.Scope)
+
+	// The token is limited to scopes the user holds
+	claims, err := jwtManager.ValidateToken(resp.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "spa", claims.ClientID)
+	assert.Equal(t, []string{"users:read"}, claims.Permissions)
+	assert.Equal(t, "https://auth.example.com", claims.Issuer)
+	assert.Equal(t, "7", claims.Subject)
+
+	// Codes work once
+	w = exchange("good", verifier)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestOAuthRefreshTokenReuse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := newMemStore(testOAuthUser())
+	store.permissions[7] = []string{"users:read"}
+	store.clients = []sqlc.OauthClient{{
+		ID:           1,
+		ClientID:     "spa",
+		RedirectUris: []string{"https://app.example.com/callback"},
+		GrantTypes:   []string{auth.GrantAuthorizationCode, auth.GrantRefreshToken},
+		Scopes:       []string{"users:read"},
+	}}
+	verifier := strings.Repeat("verifier-", 6)
+	store.codes[auth.HashToken("code")] = sqlc.OauthAuthorizationCode{
+		ClientID:      1,
+		UserID:        7,
+		RedirectUri:   "https://app.example.com/callback",
+		Scopes:        []string{"users:read"},
+		CodeChallenge: auth.PKCEChallenge(verifier),
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	revocations := auth.NewRevocationList(store, 15*time.Minute, time.Second)
+	oauthHandler := handlers.NewOAuthHandler(store, jwtManager, revocations, "https://auth.example.com", "https://app.example.com", zerolog.Nop())
+	router := gin.New()
+	router.POST("/oauth/token", oauthHandler.Token)
+
+	token := func(form url.Values) (handlers.OAuthTokenResponse, int) {
+		form.Set("client_id", "spa")
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp handlers.OAuthTokenResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return resp, w.Code
+	}
+	refresh := func(refreshToken string) (handlers.OAuthTokenResponse, int) {
+		return token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+	}
+	revoked := func(accessToken string) bool {
+		claims, err := jwtManager.ValidateToken(accessToken)
+		require.NoError(t, err)
+		revoked, err := revocations.IsRevoked(context.Background(), claims)
+		require.NoError(t, err)
+		return revoked
+	}
+
+	victim, code := token(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"code"},
+		"redirect_uri":  {"https://app.example.com/callback"},
+		"code_verifier": {verifier},
+	})
+	require.Equal(t, http.StatusOK, code)
+	require.NotEmpty(t, victim.RefreshToken)
+
+	// Whoever stole the refresh token rotates it first
+	attacker, code := refresh(victim.RefreshToken)
+	require.Equal(t, http.StatusOK, code)
+	require.False(t, revoked(attacker.AccessToken))
+
+	// The client's own refresh gives the theft away
+	_, code = refresh(victim.RefreshToken)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	assert.True(t, revoked(attacker.AccessToken), "access tokens of the family are revoked")
+	assert.True(t, revoked(victim.AccessToken))
+	_, code = refresh(attacker.RefreshToken)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+// Revoking a refresh token, withdrawing consent and deleting the client each
+// end the grant, access tokens included (RFC 7009 section 2.1)
+func TestOAuthGrantRevocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := newMemStore(testOAuthUser())
+	store.permissions[7] = []string{"users:read", "clients:write"}
+	store.clients = []sqlc.OauthClient{{
+		ID:               1,
+		ClientID:         "app",
+		ClientSecretHash: sql.NullString{String: auth.HashToken("app-secret"), Valid: true},
+		RedirectUris:     []string{"https://app.example.com/callback"},
+		GrantTypes:       []string{auth.GrantAuthorizationCode, auth.GrantRefreshToken, auth.GrantClientCredentials},
+		Scopes:           []string{"users:read"},
+	}}
+	verifier := strings.Repeat("verifier-", 6)
+
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	revocations := auth.NewRevocationList(store, 15*time.Minute, time.Second)
+	oauthHandler := handlers.NewOAuthHandler(store, jwtManager, revocations, "https://auth.example.com", "https://app.example.com", zerolog.Nop())
+	clientHandler := handlers.NewOAuthClientHandler(store, revocations)
+	authRequired := middleware.AuthRequired(jwtManager, revocations, auth.NewAPIKeyAuthenticator(store))
+	router := gin.New()
+	router.POST("/oauth/token", oauthHandler.Token)
+	router.POST("/oauth/revoke", oauthHandler.Revoke)
+	router.DELETE("/api/v1/users/me/authorized-apps/:client_id", authRequired, middleware.UserRequired(), clientHandler.RevokeAuthorizedApp)
+	router.DELETE("/admin/oauth/clients/:client_id", authRequired, middleware.UserRequired(), middleware.RequirePermission("clients:write"), clientHandler.DeleteClient)
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		form.Set("client_id", "app")
+		form.Set("client_secret", "app-secret")
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	token := func(form url.Values) handlers.OAuthTokenResponse {
+		w := post("/oauth/token", form)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp handlers.OAuthTokenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+	// grant authorizes the client for the user and redeems the code
+	grant := func() handlers.OAuthTokenResponse {
+		require.NoError(t, store.UpsertOAuthConsent(context.Background(), sqlc.UpsertOAuthConsentParams{UserID: 7, ClientID: 1, Scopes: []string{"users:read"}}))
+		store.codes[auth.HashToken("code")] = sqlc.OauthAuthorizationCode{
+			ClientID:      1,
+			UserID:        7,
+			RedirectUri:   "https://app.example.com/callback",
+			Scopes:        []string{"users:read"},
+			CodeChallenge: auth.PKCEChallenge(verifier),
+			ExpiresAt:     time.Now().Add(time.Minute),
+		}
+		return token(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {"code"},
+			"redirect_uri":  {"https://app.example.com/callback"},
+			"code_verifier": {verifier},
+		})
+	}
+	revoked := func(accessToken string) bool {
+		claims, err := jwtManager.ValidateToken(accessToken)
+		require.NoError(t, err)
+		revoked, err := revocations.IsRevoked(context.Background(), claims)
+		require.NoError(t, err)
+		return revoked
+	}
+	userToken, err := jwtManager.IssueAccessToken(auth.Claims{UserID: 7, Email: "user@example.com", Permissions: store.permissions[7]})
+	require.NoError(t, err)
+	remove := func(path string) int {
+		req := httptest.NewRequest(http.MethodDelete, path, nil)
+		req.Header.Set("Authorization", "Bearer "+userToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("revoking the refresh token", func(t *testing.T) {
+		issued := grant()
+		refreshed := token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {issued.RefreshToken}})
+		require.False(t, revoked(refreshed.AccessToken))
+
+		w := post("/oauth/revoke", url.Values{"token": {refreshed.RefreshToken}})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.True(t, revoked(issued.AccessToken))
+		assert.True(t, revoked(refreshed.AccessToken))
+	})
+
+	t.Run("withdrawing consent", func(t *testing.T) {
+		issued := grant()
+		require.False(t, revoked(issued.AccessToken))
+
+		require.Equal(t, http.StatusOK, remove("/api/v1/users/me/authorized-apps/app"))
+
+		assert.True(t, revoked(issued.AccessToken))
+		assert.Empty(t, store.refreshTokens)
+		assert.Equal(t, http.StatusNotFound, remove("/api/v1/users/me/authorized-apps/app"))
+	})
+
+	t.Run("deleting the client", func(t *testing.T) {
+		issued := grant()
+		client := token(url.Values{"grant_type": {auth.GrantClientCredentials}, "scope": {"users:read"}})
+		require.False(t, revoked(issued.AccessToken))
+		require.False(t, revoked(client.AccessToken))
+
+		require.Equal(t, http.StatusOK, remove("/admin/oauth/clients/app"))
+
+		assert.True(t, revoked(issued.AccessToken))
+		assert.True(t, revoked(client.AccessToken), "client credentials tokens are revoked too")
+		assert.True(t, store.revokedSessions[sessionOf(t, jwtManager, issued.AccessToken)])
+		assert.Empty(t, store.refreshTokens)
+		assert.Equal(t, http.StatusNotFound, remove("/admin/oauth/clients/app"))
+	})
+}
+
+// sessionOf returns the sid claim of accessToken
+func sessionOf(t *testing.T, jwtManager *auth.JWTManager, accessToken string) string {
+	t.Helper()
+
+	claims, err := jwtManager.ValidateToken(accessToken)
+	require.NoError(t, err)
+	return claims.SessionID
+}
+
+// A redirect URI left out of the authorization request defaults to the only
+// registered one and may be left out of the token request as well
+// (RFC 6749 section 4.1.3)
+func TestOAuthRedirectURIOmitted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := newMemStore(testOAuthUser())
+	store.clients = []sqlc.OauthClient{{
+		ID:           1,
+		ClientID:     "spa",
+		RedirectUris: []string{"https://app.example.com/callback"},
+		GrantTypes:   []string{auth.GrantAuthorizationCode},
+		Scopes:       []string{"users:read"},
+	}}
+
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	oauthHandler := handlers.NewOAuthHandler(store, jwtManager, nil, "https://auth.example.com", "https://app.example.com", zerolog.Nop())
+	router := gin.New()
+	signedIn := func(c *gin.Context) { c.Set("user_id", int64(7)) }
+	router.POST("/api/v1/oauth/authorize", signedIn, oauthHandler.DecideAuthorization)
+	router.POST("/oauth/token", oauthHandler.Token)
+
+	verifier := strings.Repeat("verifier-", 6)
+	authorize := func(redirectURI string) string {
+		body, _ := json.Marshal(map[string]interface{}{
+			"response_type":         "code",
+			"client_id":             "spa",
+			"redirect_uri":          redirectURI,
+			"scope":                 "users:read",
+			"code_challenge":        auth.PKCEChallenge(verifier),
+			"code_challenge_method": "S256",
+			"approve":               true,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/oauth/authorize", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var decision struct {
+			RedirectTo string `json:"redirect_to"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &decision))
+		location, err := url.Parse(decision.RedirectTo)
+		require.NoError(t, err)
+		return location.Query().Get("code")
+	}
+	exchange := func(code, redirectURI string) int {
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {"spa"},
+			"code":          {code},
+			"code_verifier": {verifier},
+		}
+		if redirectURI != "" {
+			form.Set("redirect_uri", redirectURI)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, exchange(authorize(""), ""), "omitted at both steps")
+	assert.Equal(t, http.StatusOK, exchange(authorize(""), "https://app.example.com/callback"))
+	assert.Equal(t, http.StatusBadRequest, exchange(authorize(""), "https://evil.example.com/callback"))
+	assert.Equal(t, http.StatusBadRequest, exchange(authorize("https://app.example.com/callback"), ""), "named at authorization")
+	assert.Equal(t, http.StatusOK, exchange(authorize("https://app.example.com/callback"), "https://app.example.com/callback"))
+}
+
+func TestOAuthIntrospect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := newMemStore(testOAuthUser())
+	for i, clientID := range []string{"reports", "billing"} {
+		store.clients = append(store.clients, sqlc.OauthClient{
+			ID:               int64(i + 1),
+			ClientID:         clientID,
+			ClientSecretHash: sql.NullString{String: auth.HashToken(clientID + "-secret"), Valid: true},
+			GrantTypes:       []string{auth.GrantClientCredentials},
+		})
+	}
+
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	revocations := auth.NewRevocationList(store, 15*time.Minute, time.Second)
+	oauthHandler := handlers.NewOAuthHandler(store, jwtManager, revocations, "https://auth.example.com", "https://app.example.com", zerolog.Nop())
+	router := gin.New()
+	router.POST("/oauth/introspect", oauthHandler.Introspect)
+
+	introspect := func(clientID, token string) handlers.IntrospectionResponse {
+		form := url.Values{"client_id": {clientID}, "client_secret": {clientID + "-secret"}, "token": {token}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp handlers.IntrospectionResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	clientToken, err := jwtManager.IssueAccessToken(auth.Claims{UserID: 7, Email: "user@example.com", ClientID: "reports", Scope: "users:read"})
+	require.NoError(t, err)
+	sessionToken, err := jwtManager.IssueAccessToken(auth.Claims{UserID: 7, Email: "user@example.com"})
+	require.NoError(t, err)
+
+	resp := introspect("reports", clientToken)
+	assert.True(t, resp.Active)
+	assert.Equal(t, "reports", resp.ClientID)
+	assert.Equal(t, "users:read", resp.Scope)
+
+	assert.Equal(t, handlers.IntrospectionResponse{}, introspect("billing", clientToken), "another client's token")
+	assert.Equal(t, handlers.IntrospectionResponse{}, introspect("reports", sessionToken), "a user session's token")
+}
+
+func TestOAuthClientScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := newMemStore(testOAuthUser())
+	store.permissions[7] = []string{"clients:write", "users:read"}
+
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	revocations := auth.NewRevocationList(store, 15*time.Minute, time.Second)
+	oauthHandler := handlers.NewOAuthHandler(store, jwtManager, revocations, "https://auth.example.com", "https://app.example.com", zerolog.Nop())
+	clientHandler := handlers.NewOAuthClientHandler(store, revocations)
+	router := gin.New()
+	router.POST("/oauth/token", oauthHandler.Token)
+	admin := router.Group("/admin", middleware.AuthRequired(jwtManager, revocations, auth.NewAPIKeyAuthenticator(store)), middleware.UserRequired())
+	admin.POST("/oauth/clients", middleware.RequirePermission("clients:write"), clientHandler.CreateClient)
+	admin.GET("/users", middleware.RequirePermission("users:read"), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	adminToken, err := jwtManager.IssueAccessToken(auth.Claims{UserID: 7, Email: "user@example.com", Permissions: store.permissions[7]})
+	require.NoError(t, err)
+	createClient := func(scopes ...string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"name":        "worker",
+			"grant_types": []string{auth.GrantClientCredentials},
+			"scopes":      scopes,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/admin/oauth/clients", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Permissions the admin does not hold cannot be given to a client
+	for _, scope := range []string{"users:delete", "roles:write"} {
+		w := createClient("users:read", scope)
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), scope)
+	}
+	assert.Empty(t, store.clients)
+
+	w := createClient("users:read", "openid")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Client       handlers.OAuthClientInfo `json:"client"`
+		ClientSecret string                   `json:"client_secret"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// The client's own token does not reach user administration, even
+	// with the permission
+	form := url.Values{
+		"grant_type":    {auth.GrantClientCredentials},
+		"client_id":     {created.Client.ClientID},
+		"client_secret": {created.ClientSecret},
+		"scope":         {"users:read"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp handlers.OAuthTokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.AccessToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// OpenIDConfiguration is the OpenID Provider metadata served for discovery
+// (OpenID Connect Discovery 1.0 section 3)
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// OIDCUserInfo holds the standard claims about a user that the granted scopes
+// release (OpenID Connect Core 1.0 section 5.1)
+type OIDCUserInfo struct {
+	Sub           string `json:"sub"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+}
+
+// Discovery serves the OpenID Provider metadata relying parties configure
+// themselves from. There is no provider while tokens are signed with HS256.
+func (h *OAuthHandler) Discovery(c *gin.Context) {
+	if !h.jwtManager.SignsIDTokens() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OpenID Connect is not enabled"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, OpenIDConfiguration{
+		Issuer:                            h.issuer,
+		AuthorizationEndpoint:             h.issuer + "/oauth/authorize",
+		TokenEndpoint:                     h.issuer + "/oauth/token",
+		UserInfoEndpoint:                  h.issuer + "/oauth/userinfo",
+		JWKSURI:                           h.issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:             h.issuer + "/oauth/introspect",
+		RevocationEndpoint:                h.issuer + "/oauth/revoke",
+		ScopesSupported:                   auth.OIDCScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{auth.GrantAuthorizationCode, auth.GrantRefreshToken, auth.GrantClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{h.jwtManager.SigningAlgorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{auth.PKCEMethodS256},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "nonce", "email", "email_verified", "name"},
+	})
+}
+
+// UserInfo returns the claims about the user that the access token's scopes
+// release. Only tokens issued to a client with the openid scope are accepted.
+func (h *OAuthHandler) UserInfo(c *gin.Context) {
+	value, _ := c.Get("claims")
+	claims, ok := value.(*auth.Claims)
+	if !ok || claims.ClientID == "" || claims.UserID == 0 || !containsString(auth.ParseScope(claims.Scope), auth.ScopeOpenID) {
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		c.JSON(http.StatusForbidden, gin.H{"error": "token does not grant the openid scope"})
+		return
+	}
+
+	user, err := h.store.GetUserByID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, userInfoClaims(user, auth.ParseScope(claims.Scope)))
+}
+
+// userInfoClaims derives the standard claims scopes release about user. The
+// subject is always included.
+func userInfoClaims(user sqlc.User, scopes []string) OIDCUserInfo {
+	info := OIDCUserInfo{Sub: strconv.FormatInt(user.ID, 10)}
+	if containsString(scopes, auth.ScopeEmail) {
+		verified := user.EmailVerifiedAt.Valid
+		info.Email = user.Email
+		info.EmailVerified = &verified
+	}
+	if containsString(scopes, auth.ScopeProfile) {
+		info.Name = user.FullName
+	}
+	return info
+}
@@ -0,0 +1,395 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/api/middleware"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// relyingParty is a minimal OpenID Connect client that configures itself
+// from discovery and checks every response the way the specification
+// requires of a relying party
+type relyingParty struct {
+	t            *testing.T
+	http         *http.Client
+	clientID     string
+	clientSecret string
+	redirectURI  string
+	config       handlers.OpenIDConfiguration
+	keys         map[string]*rsa.PublicKey
+}
+
+// rpTokens is what the relying party holds after a successful sign-in
+type rpTokens struct {
+	accessToken string
+	idToken     string
+	idClaims    jwt.MapClaims
+}
+
+func TestOpenIDConnectRelyingParty(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	keys := auth.NewKeyManager(auth.NewMemoryKeyStore(), auth.AlgorithmRS256, time.Hour, time.Hour)
+	require.NoError(t, keys.Load(context.Background()))
+	jwtManager := auth.NewAsymmetricJWTManager(keys, 15*time.Minute, time.Hour)
+
+	secret := "rp-secret"
+	store := newMemStore(testOAuthUser())
+	store.permissions[7] = []string{"users:read"}
+	store.clients = []sqlc.OauthClient{{
+		ID:               1,
+		ClientID:         "rp",
+		ClientSecretHash: sql.NullString{String: auth.HashToken(secret), Valid: true},
+		RedirectUris:     []string{"https://rp.example.com/callback"},
+		GrantTypes:       []string{auth.GrantAuthorizationCode},
+		Scopes:           []string{auth.ScopeOpenID, auth.ScopeProfile, auth.ScopeEmail, "users:read"},
+	}}
+
+	// The issuer is the address of the in-process server
+	var router *gin.Engine
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	oauthHandler := handlers.NewOAuthHandler(store, jwtManager, auth.NewRevocationList(store, 15*time.Minute, time.Second), server.URL, "https://app.example.com", zerolog.Nop())
+	authRequired := middleware.AuthRequired(jwtManager, auth.NewRevocationList(store, 15*time.Minute, time.Second), auth.NewAPIKeyAuthenticator(store))
+	router = gin.New()
+	router.GET("/.well-known/openid-configuration", oauthHandler.Discovery)
+	router.GET("/.well-known/jwks.json", func(c *gin.Context) { c.JSON(http.StatusOK, jwtManager.JWKS()) })
+	router.GET("/oauth/authorize", oauthHandler.Authorize)
+	router.POST("/oauth/token", oauthHandler.Token)
+	router.GET("/oauth/userinfo", authRequired, oauthHandler.UserInfo)
+	router.POST("/api/v1/oauth/authorize", authRequired, middleware.SessionRequired(), oauthHandler.DecideAuthorization)
+
+	// The user is signed in to the frontend
+	session, err := jwtManager.IssueAccessToken(auth.Claims{UserID: 7, Email: "user@example.com"})
+	require.NoError(t, err)
+
+	rp := newRelyingParty(t, server.URL, "rp", secret, "https://rp.example.com/callback")
+
+	t.Run("discovery", func(t *testing.T) {
+		assert.Equal(t, server.URL, rp.config.Issuer)
+		assert.Contains(t, rp.config.ResponseTypesSupported, "code")
+		assert.Contains(t, rp.config.SubjectTypesSupported, "public")
+		assert.Contains(t, rp.config.IDTokenSigningAlgValuesSupported, "RS256")
+		assert.Contains(t, rp.config.ScopesSupported, "openid")
+		assert.Contains(t, rp.config.CodeChallengeMethodsSupported, "S256")
+		assert.NotEmpty(t, rp.keys)
+	})
+
+	t.Run("sign in", func(t *testing.T) {
+		tokens := rp.signIn(session, "openid profile email", "n-0S6_WzA2Mj")
+
+		assert.Equal(t, "7", tokens.idClaims["sub"])
+		assert.Equal(t, "user@example.com", tokens.idClaims["email"])
+		assert.Equal(t, true, tokens.idClaims["email_verified"])
+		assert.Equal(t, "Test User", tokens.idClaims["name"])
+
+		info := rp.userInfo(tokens.accessToken, http.StatusOK)
+		assert.Equal(t, tokens.idClaims["sub"], info["sub"])
+		assert.Equal(t, "user@example.com", info["email"])
+		assert.Equal(t, "Test User", info["name"])
+
+		// An ID token is not an access token
+		rp.userInfo(tokens.idToken, http.StatusUnauthorized)
+	})
+
+	t.Run("claims follow scopes", func(t *testing.T) {
+		tokens := rp.signIn(session, "openid", "another-nonce")
+
+		assert.Equal(t, "7", tokens.idClaims["sub"])
+		assert.NotContains(t, tokens.idClaims, "email")
+		assert.NotContains(t, tokens.idClaims, "name")
+
+		info := rp.userInfo(tokens.accessToken, http.StatusOK)
+		assert.Equal(t, map[string]interface{}{"sub": "7"}, info)
+	})
+
+	t.Run("plain OAuth", func(t *testing.T) {
+		code, verifier := rp.authorize(session, "users:read", "")
+		resp := rp.exchange(code, verifier)
+		assert.Empty(t, resp.IDToken)
+
+		rp.userInfo(resp.AccessToken, http.StatusForbidden)
+	})
+}
+
+// With the default HS256 configuration there is no OpenID Connect provider:
+// relying parties could only check ID tokens with the secret that signs
+// access tokens
+func TestOpenIDConnectDisabledUnderHS256(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	_, err := jwtManager.IssueIDToken(auth.IDTokenClaims{RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{"rp"}}})
+	assert.Error(t, err)
+
+	secret := "rp-secret"
+	verifier := strings.Repeat("v", 43)
+	store := newMemStore(testOAuthUser())
+	store.clients = []sqlc.OauthClient{{
+		ID:               1,
+		ClientID:         "rp",
+		ClientSecretHash: sql.NullString{String: auth.HashToken(secret), Valid: true},
+		RedirectUris:     []string{"https://rp.example.com/callback"},
+		GrantTypes:       []string{auth.GrantAuthorizationCode},
+		Scopes:           []string{auth.ScopeOpenID, auth.ScopeProfile, auth.ScopeEmail},
+	}}
+	// Granted before the provider was disabled
+	store.codes[auth.HashToken("code")] = sqlc.OauthAuthorizationCode{
+		ClientID:      1,
+		UserID:        7,
+		RedirectUri:   "https://rp.example.com/callback",
+		Scopes:        []string{auth.ScopeOpenID, auth.ScopeEmail},
+		CodeChallenge: auth.PKCEChallenge(verifier),
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+
+	oauthHandler := handlers.NewOAuthHandler(store, jwtManager, nil, "https://auth.example.com", "https://app.example.com", zerolog.Nop())
+	router := gin.New()
+	router.GET("/.well-known/openid-configuration", oauthHandler.Discovery)
+	router.GET("/oauth/authorize", oauthHandler.Authorize)
+	router.POST("/oauth/token", oauthHandler.Token)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"rp"},
+		"scope":                 {"openid email"},
+		"code_challenge":        {auth.PKCEChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil))
+	require.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "rp.example.com", location.Host)
+	assert.Equal(t, "invalid_scope", location.Query().Get("error"))
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"rp"},
+		"client_secret": {secret},
+		"code":          {"code"},
+		"redirect_uri":  {"https://rp.example.com/callback"},
+		"code_verifier": {verifier},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp handlers.OAuthTokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.AccessToken)
+	assert.Empty(t, resp.IDToken)
+}
+
+func newRelyingParty(t *testing.T, issuer, clientID, clientSecret, redirectURI string) *relyingParty {
+	rp := &relyingParty{
+		t: t,
+		http: &http.Client{
+			// The relying party inspects redirects instead of following them
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURI:  redirectURI,
+		keys:         map[string]*rsa.PublicKey{},
+	}
+
+	rp.getJSON(issuer+"/.well-known/openid-configuration", &rp.config)
+	require.Equal(t, issuer, rp.config.Issuer, "issuer must match the discovery URL")
+
+	var set auth.JWKS
+	rp.getJSON(rp.config.JWKSURI, &set)
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		require.NoError(t, err)
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		require.NoError(t, err)
+		rp.keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return rp
+}
+
+// signIn runs the authorization code flow and validates the ID token
+func (rp *relyingParty) signIn(session, scope, nonce string) rpTokens {
+	t := rp.t
+	code, verifier := rp.authorize(session, scope, nonce)
+	resp := rp.exchange(code, verifier)
+	require.NotEmpty(t, resp.IDToken)
+
+	return rpTokens{
+		accessToken: resp.AccessToken,
+		idToken:     resp.IDToken,
+		idClaims:    rp.validateIDToken(resp.IDToken, nonce),
+	}
+}
+
+// authorize sends the user through the authorization endpoint and consent
+// screen and returns the code the client receives
+func (rp *relyingParty) authorize(session, scope, nonce string) (code, verifier string) {
+	t := rp.t
+	verifier = strings.Repeat("v", 43) + nonce
+	state := "state-" + nonce
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {rp.clientID},
+		"redirect_uri":          {rp.redirectURI},
+		"scope":                 {scope},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {auth.PKCEChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	resp, err := rp.http.Get(rp.config.AuthorizationEndpoint + "?" + params.Encode())
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	consent, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, "/oauth/consent", consent.Path)
+
+	// The frontend posts the user's approval with the request parameters
+	body := map[string]interface{}{"approve": true}
+	for key := range consent.Query() {
+		body[key] = consent.Query().Get(key)
+	}
+	var decision struct {
+		RedirectTo string `json:"redirect_to"`
+	}
+	rp.postJSON(strings.TrimSuffix(rp.config.Issuer, "/")+"/api/v1/oauth/authorize", session, body, &decision)
+
+	callback, err := url.Parse(decision.RedirectTo)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(decision.RedirectTo, rp.redirectURI+"?"), decision.RedirectTo)
+	require.Equal(t, state, callback.Query().Get("state"), "state must round-trip")
+	require.Empty(t, callback.Query().Get("error"))
+	return callback.Query().Get("code"), verifier
+}
+
+// exchange redeems code at the token endpoint with client_secret_basic
+func (rp *relyingParty) exchange(code, verifier string) handlers.OAuthTokenResponse {
+	t := rp.t
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {rp.redirectURI},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, rp.config.TokenEndpoint, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(rp.clientID), url.QueryEscape(rp.clientSecret))
+
+	resp, err := rp.http.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+	var tokens handlers.OAuthTokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tokens))
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	return tokens
+}
+
+// validateIDToken performs the ID token validation of OpenID Connect Core
+// section 3.1.3.7 and returns the claims
+func (rp *relyingParty) validateIDToken(idToken, nonce string) jwt.MapClaims {
+	t := rp.t
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := rp.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods(rp.config.IDTokenSigningAlgValuesSupported),
+		jwt.WithIssuer(rp.config.Issuer),
+		jwt.WithAudience(rp.clientID),
+		jwt.WithIssuedAt(),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, nonce, claims["nonce"], "nonce must match the authorization request")
+	assert.NotEmpty(t, claims["sub"])
+	assert.Contains(t, claims, "iat")
+	assert.Contains(t, claims, "exp")
+	return claims
+}
+
+// userInfo calls the userinfo endpoint and checks the response status
+func (rp *relyingParty) userInfo(accessToken string, status int) map[string]interface{} {
+	t := rp.t
+	req, err := http.NewRequest(http.MethodGet, rp.config.UserInfoEndpoint, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := rp.http.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, status, resp.StatusCode)
+
+	var info map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+	return info
+}
+
+func (rp *relyingParty) getJSON(url string, v interface{}) {
+	resp, err := rp.http.Get(url)
+	require.NoError(rp.t, err)
+	defer resp.Body.Close()
+	require.Equal(rp.t, http.StatusOK, resp.StatusCode)
+	require.NoError(rp.t, json.NewDecoder(resp.Body).Decode(v))
+}
+
+func (rp *relyingParty) postJSON(url, bearer string, body, v interface{}) {
+	payload, err := json.Marshal(body)
+	require.NoError(rp.t, err)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	require.NoError(rp.t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+bearer)
+
+	resp, err := rp.http.Do(req)
+	require.NoError(rp.t, err)
+	defer resp.Body.Close()
+	require.Equal(rp.t, http.StatusOK, resp.StatusCode)
+	require.NoError(rp.t, json.NewDecoder(resp.Body).Decode(v))
+}
@@ -0,0 +1,468 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// Roles a member can hold within an organization
+const (
+	orgRoleOwner  = "owner"
+	orgRoleAdmin  = "admin"
+	orgRoleMember = "member"
+)
+
+var (
+	errOrgNotFound       = errors.New("organization not found")
+	errOrgExists         = errors.New("organization slug already taken")
+	errOrgMemberNotFound = errors.New("member not found")
+	errOrgForbidden      = errors.New("not allowed to manage this member")
+	errOrgLastOwner      = errors.New("organization must keep an owner")
+)
+
+// orgSlugPattern matches lowercase slugs such as "acme-corp"
+var orgSlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type OrgHandler struct {
+	store       db.Store
+	authHandler *AuthHandler
+	revocations *auth.RevocationList
+}
+
+func NewOrgHandler(store db.Store, authHandler *AuthHandler, revocations *auth.RevocationList) *OrgHandler {
+	return &OrgHandler{
+		store:       store,
+		authHandler: authHandler,
+		revocations: revocations,
+	}
+}
+
+// CreateOrgRequest represents the create organization request body
+type CreateOrgRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Slug string `json:"slug" binding:"required,min=2,max=63"`
+}
+
+// UpdateOrgMemberRequest represents the update member request body
+type UpdateOrgMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+// OrgInfo describes an organization and the user's role in it
+type OrgInfo struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrgMemberInfo describes a member of an organization
+type OrgMemberInfo struct {
+	UserID   int64     `json:"user_id"`
+	Email    string    `json:"email"`
+	FullName string    `json:"full_name"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// ListOrgs returns the organizations the authenticated user belongs to
+func (h *OrgHandler) ListOrgs(c *gin.Context) {
+	orgs, err := h.store.ListUserOrganizations(c.Request.Context(), c.GetInt64("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list organizations"})
+		return
+	}
+
+	infos := make([]OrgInfo, 0, len(orgs))
+	for _, org := range orgs {
+		infos = append(infos, OrgInfo{
+			ID:        org.ID,
+			Name:      org.Name,
+			Slug:      org.Slug,
+			Role:      org.Role,
+			CreatedAt: org.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": infos})
+}
+
+// CreateOrg creates an organization owned by the authenticated user
+func (h *OrgHandler) CreateOrg(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var req CreateOrgRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !orgSlugPattern.MatchString(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slug may only contain lowercase letters, digits and single hyphens"})
+		return
+	}
+
+	var org sqlc.Organization
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		org, err = q.CreateOrganization(ctx, sqlc.CreateOrganizationParams{
+			Name: req.Name,
+			Slug: req.Slug,
+		})
+		if err != nil {
+			if isUniqueViolation(err) {
+				return errOrgExists
+			}
+			return err
+		}
+
+		if _, err := q.AddOrganizationMember(ctx, sqlc.AddOrganizationMemberParams{
+			OrgID:  org.ID,
+			UserID: userID,
+			Role:   orgRoleOwner,
+		}); err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionOrgCreated, audit.TargetOrg, org.ID)
+		event.Diff = map[string]audit.Change{
+			"name": {To: org.Name},
+			"slug": {To: org.Slug},
+		}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		respondOrgError(c, err, "failed to create organization")
+		return
+	}
+
+	c.JSON(http.StatusCreated, newOrgInfo(org, orgRoleOwner))
+}
+
+// SwitchOrg makes an organization the one the current session acts in and
+// returns an access token for it. Later refreshes of the session stay in it.
+func (h *OrgHandler) SwitchOrg(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	orgID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid organization ID"})
+		return
+	}
+
+	var (
+		accessToken string
+		info        OrgInfo
+	)
+	ctx := c.Request.Context()
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		member, err := q.GetOrganizationMember(ctx, sqlc.GetOrganizationMemberParams{
+			OrgID:  orgID,
+			UserID: userID,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errOrgNotFound
+			}
+			return err
+		}
+		org, err := q.GetOrganization(ctx, orgID)
+		if err != nil {
+			return err
+		}
+		info = newOrgInfo(org, member.Role)
+
+		user, err := q.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		sess := session{familyID: currentSessionID(c), orgID: orgID}
+		if err := q.SetSessionActiveOrganization(ctx, sqlc.SetSessionActiveOrganizationParams{
+			FamilyID:    sess.familyID,
+			UserID:      userID,
+			ActiveOrgID: sql.NullInt64{Int64: orgID, Valid: true},
+		}); err != nil {
+			return err
+		}
+
+		accessToken, _, err = h.authHandler.issueAccessToken(ctx, q, user, sess)
+		return err
+	})
+	if err != nil {
+		respondOrgError(c, err, "failed to switch organization")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": accessToken,
+		"expires_at":   time.Now().Add(h.authHandler.jwtManager.AccessExpiry()),
+		"organization": info,
+	})
+}
+
+// GetActiveOrg returns the organization the session acts in
+func (h *OrgHandler) GetActiveOrg(c *gin.Context) {
+	org, err := h.store.GetOrganization(c.Request.Context(), c.GetInt64("org_id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newOrgInfo(org, c.GetString("org_role")))
+}
+
+// ListMembers returns the members of the active organization
+func (h *OrgHandler) ListMembers(c *gin.Context) {
+	orgID := c.GetInt64("org_id")
+
+	var members []sqlc.ListOrganizationMembersRow
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		members, err = q.ListOrganizationMembers(ctx, orgID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list members"})
+		return
+	}
+
+	infos := make([]OrgMemberInfo, 0, len(members))
+	for _, member := range members {
+		infos = append(infos, OrgMemberInfo{
+			UserID:   member.UserID,
+			Email:    member.Email,
+			FullName: member.FullName,
+			Role:     member.Role,
+			JoinedAt: member.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": infos})
+}
+
+// UpdateMember changes a member's role in the active organization. Only
+// owners can make or change owners, and the last owner cannot step down.
+func (h *OrgHandler) UpdateMember(c *gin.Context) {
+	memberID, ok := parseOrgMemberID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateOrgMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orgID := c.GetInt64("org_id")
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		member, err := h.manageableMember(c, q, orgID, memberID)
+		if err != nil {
+			return err
+		}
+		if req.Role == member.Role {
+			return nil
+		}
+		if req.Role == orgRoleOwner && c.GetString("org_role") != orgRoleOwner {
+			return errOrgForbidden
+		}
+		if member.Role == orgRoleOwner {
+			if err := checkOtherOwners(c, q, orgID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := q.UpdateOrganizationMemberRole(ctx, sqlc.UpdateOrganizationMemberRoleParams{
+			OrgID:  orgID,
+			UserID: memberID,
+			Role:   req.Role,
+		}); err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionOrgMemberUpdated, audit.TargetUser, memberID)
+		event.Diff = map[string]audit.Change{
+			"org_id":   {From: orgID, To: orgID},
+			"org_role": {From: member.Role, To: req.Role},
+		}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		respondOrgError(c, err, "failed to update member")
+		return
+	}
+
+	// The member's tokens carry their old role
+	if err := h.revocations.RevokeUser(ctx, memberID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "member updated successfully"})
+}
+
+// RemoveMember takes a member out of the active organization. Owners and
+// admins can remove others; anyone can leave, except the last owner.
+func (h *OrgHandler) RemoveMember(c *gin.Context) {
+	memberID, ok := parseOrgMemberID(c)
+	if !ok {
+		return
+	}
+
+	orgID := c.GetInt64("org_id")
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		member, err := h.manageableMember(c, q, orgID, memberID)
+		if err != nil {
+			return err
+		}
+		if member.Role == orgRoleOwner {
+			if err := checkOtherOwners(c, q, orgID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := q.RemoveOrganizationMember(ctx, sqlc.RemoveOrganizationMemberParams{
+			OrgID:  orgID,
+			UserID: memberID,
+		}); err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionOrgMemberRemoved, audit.TargetUser, memberID)
+		event.Diff = map[string]audit.Change{
+			"org_id":   {From: orgID},
+			"org_role": {From: member.Role},
+		}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		respondOrgError(c, err, "failed to remove member")
+		return
+	}
+
+	// Tokens for the organization stop working at once
+	if err := h.revocations.RevokeUser(ctx, memberID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "member removed successfully"})
+}
+
+// manageableMember returns memberID's membership of orgID if the
+// authenticated user may change it: their own, or as an owner or admin
+// anyone but an owner, whom only owners manage. The organization row is
+// locked so concurrent changes cannot remove every owner.
+func (h *OrgHandler) manageableMember(c *gin.Context, q sqlc.Querier, orgID, memberID int64) (sqlc.OrganizationMember, error) {
+	ctx := c.Request.Context()
+	if _, err := q.GetOrganizationForUpdate(ctx, orgID); err != nil {
+		if err == sql.ErrNoRows {
+			return sqlc.OrganizationMember{}, errOrgNotFound
+		}
+		return sqlc.OrganizationMember{}, err
+	}
+
+	// The role in the token may be stale
+	actor, err := q.GetOrganizationMember(ctx, sqlc.GetOrganizationMemberParams{
+		OrgID:  orgID,
+		UserID: c.GetInt64("user_id"),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return sqlc.OrganizationMember{}, errOrgForbidden
+		}
+		return sqlc.OrganizationMember{}, err
+	}
+	c.Set("org_role", actor.Role)
+
+	member, err := q.GetOrganizationMember(ctx, sqlc.GetOrganizationMemberParams{
+		OrgID:  orgID,
+		UserID: memberID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return sqlc.OrganizationMember{}, errOrgMemberNotFound
+		}
+		return sqlc.OrganizationMember{}, err
+	}
+
+	switch {
+	case actor.UserID == member.UserID, actor.Role == orgRoleOwner:
+	case actor.Role == orgRoleAdmin && member.Role != orgRoleOwner:
+	default:
+		return sqlc.OrganizationMember{}, errOrgForbidden
+	}
+	return member, nil
+}
+
+// checkOtherOwners fails unless orgID has an owner besides the one about to
+// step down
+func checkOtherOwners(c *gin.Context, q sqlc.Querier, orgID int64) error {
+	owners, err := q.CountOrganizationOwners(c.Request.Context(), orgID)
+	if err != nil {
+		return err
+	}
+	if owners < 2 {
+		return errOrgLastOwner
+	}
+	return nil
+}
+
+// parseOrgMemberID reads the :user_id path parameter, writing the error
+// response itself when it is malformed
+func parseOrgMemberID(c *gin.Context) (int64, bool) {
+	memberID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return 0, false
+	}
+	return memberID, true
+}
+
+// respondOrgError maps organization errors to responses, falling back to a
+// 500 with message
+func respondOrgError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, errOrgNotFound), errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
+	case errors.Is(err, errOrgMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+	case errors.Is(err, errOrgExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "organization slug already taken"})
+	case errors.Is(err, errOrgForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+	case errors.Is(err, errOrgLastOwner):
+		c.JSON(http.StatusConflict, gin.H{"error": "an organization must keep at least one owner"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func newOrgInfo(org sqlc.Organization, role string) OrgInfo {
+	return OrgInfo{
+		ID:        org.ID,
+		Name:      org.Name,
+		Slug:      org.Slug,
+		Role:      role,
+		CreatedAt: org.CreatedAt,
+	}
+}
@@ -0,0 +1,158 @@
+package handlers_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/api/middleware"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// newOrgServer adds the organization routes to a test server with two
+// organizations: Acme, owned by alice with bob as an admin and erin as a
+// member, and Globex, owned by carol with dave as a member. Globex has a
+// pending invitation, numbered 1.
+func newOrgServer(t *testing.T, rowLevelSecurity bool) *testServer {
+	t.Helper()
+
+	s := newTestServer(t, "alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com", "erin@example.com")
+	s.store.organizations[1] = sqlc.Organization{ID: 1, Name: "Acme", Slug: "acme"}
+	s.store.organizations[2] = sqlc.Organization{ID: 2, Name: "Globex", Slug: "globex"}
+	s.store.members = append(s.store.members,
+		sqlc.OrganizationMember{OrgID: 1, UserID: 1, Role: "owner"},
+		sqlc.OrganizationMember{OrgID: 1, UserID: 2, Role: "admin"},
+		sqlc.OrganizationMember{OrgID: 2, UserID: 3, Role: "owner"},
+		sqlc.OrganizationMember{OrgID: 2, UserID: 4, Role: "member"},
+		sqlc.OrganizationMember{OrgID: 1, UserID: 5, Role: "member"},
+	)
+	s.store.invitations = append(s.store.invitations, sqlc.Invitation{
+		ID:        1,
+		TokenHash: auth.HashToken("globex-invite"),
+		OrgID:     sql.NullInt64{Int64: 2, Valid: true},
+		OrgRole:   "member",
+		Email:     "frank@example.com",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+
+	orgHandler := handlers.NewOrgHandler(s.store, s.authHandler, s.revocations)
+	invitationHandler := handlers.NewInvitationHandler(s.store, s.authHandler, s.outbox, "http://localhost:3000", time.Hour, zerolog.Nop())
+	authRequired := middleware.AuthRequired(s.jwtManager, s.revocations, auth.NewAPIKeyAuthenticator(s.store))
+	orgs := s.router.Group("/api/v1/orgs", authRequired)
+	orgs.POST("/:id/switch", middleware.SessionRequired(), orgHandler.SwitchOrg)
+	org := s.router.Group("/api/v1/org", authRequired, middleware.OrgRequired(rowLevelSecurity))
+	org.GET("", orgHandler.GetActiveOrg)
+	org.GET("/members", orgHandler.ListMembers)
+	org.PUT("/members/:user_id", middleware.RequireOrgRole("owner", "admin"), orgHandler.UpdateMember)
+	org.DELETE("/members/:user_id", orgHandler.RemoveMember)
+	org.GET("/invitations", middleware.RequireOrgRole("owner", "admin"), invitationHandler.ListOrgInvitations)
+	org.DELETE("/invitations/:id", middleware.RequireOrgRole("owner", "admin"), invitationHandler.RevokeOrgInvitation)
+	return s
+}
+
+// orgToken signs email in and switches the session to orgID, returning its
+// access token
+func (s *testServer) orgToken(email, orgID string) string {
+	s.t.Helper()
+
+	w := s.do(http.MethodPost, "/api/v1/orgs/"+orgID+"/switch", s.login(email).AccessToken, nil)
+	require.Equal(s.t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	require.NoError(s.t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.AccessToken
+}
+
+// orgRole returns userID's role in orgID, or "" if they are not a member
+func (s *testServer) orgRole(orgID, userID int64) string {
+	for _, member := range s.store.members {
+		if member.OrgID == orgID && member.UserID == userID {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+func TestOrgCrossOrgAccess(t *testing.T) {
+	for _, rowLevelSecurity := range []bool{false, true} {
+		name := "without row-level security"
+		if rowLevelSecurity {
+			name = "with row-level security"
+		}
+		t.Run(name, func(t *testing.T) {
+			s := newOrgServer(t, rowLevelSecurity)
+			alice := s.orgToken("alice@example.com", "1")
+			carol := s.orgToken("carol@example.com", "2")
+
+			// Only members can switch to an organization
+			w := s.do(http.MethodPost, "/api/v1/orgs/2/switch", s.login("alice@example.com").AccessToken, nil)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			w = s.do(http.MethodGet, "/api/v1/org", alice, nil)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), `"slug":"acme"`)
+
+			w = s.do(http.MethodGet, "/api/v1/org/members", alice, nil)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var members struct {
+				Members []handlers.OrgMemberInfo `json:"members"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &members))
+			var ids []int64
+			for _, member := range members.Members {
+				ids = append(ids, member.UserID)
+			}
+			assert.ElementsMatch(t, []int64{1, 2, 5}, ids)
+
+			// Members of another organization are out of reach
+			w = s.do(http.MethodPut, "/api/v1/org/members/4", alice, map[string]string{"role": "admin"})
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, "member", s.orgRole(2, 4))
+			w = s.do(http.MethodDelete, "/api/v1/org/members/3", alice, nil)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, "owner", s.orgRole(2, 3))
+			assert.True(t, s.signedIn(carol))
+
+			// So are its invitations
+			w = s.do(http.MethodGet, "/api/v1/org/invitations", alice, nil)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.JSONEq(t, `{"invitations": []}`, w.Body.String())
+			w = s.do(http.MethodDelete, "/api/v1/org/invitations/1", alice, nil)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Len(t, s.store.invitations, 1)
+
+			w = s.do(http.MethodGet, "/api/v1/org/invitations", carol, nil)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), "frank@example.com")
+		})
+	}
+}
+
+func TestOrgMembershipIsRechecked(t *testing.T) {
+	s := newOrgServer(t, true)
+	bob := s.orgToken("bob@example.com", "1")
+
+	// A token from before a demotion still says admin
+	s.store.members[1].Role = "member"
+	w := s.do(http.MethodPut, "/api/v1/org/members/5", bob, map[string]string{"role": "admin"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "member", s.orgRole(1, 5))
+
+	// One from before a removal still names the organization
+	s.store.members = append(s.store.members[:1], s.store.members[2:]...)
+	w = s.do(http.MethodDelete, "/api/v1/org/members/5", bob, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "member", s.orgRole(1, 5))
+
+	// Tokens without an organization reach none
+	w = s.do(http.MethodGet, "/api/v1/org/members", s.login("dave@example.com").AccessToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
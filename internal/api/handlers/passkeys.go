@@ -0,0 +1,529 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// Passkey ceremonies a challenge can be answered for
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+)
+
+var errPasskeyChallenge = errors.New("invalid or expired passkey challenge")
+
+type PasskeyHandler struct {
+	store       db.Store
+	authHandler *AuthHandler
+	webauthn    *auth.WebAuthn
+	logger      zerolog.Logger
+}
+
+func NewPasskeyHandler(store db.Store, authHandler *AuthHandler, webauthn *auth.WebAuthn, logger zerolog.Logger) *PasskeyHandler {
+	return &PasskeyHandler{
+		store:       store,
+		authHandler: authHandler,
+		webauthn:    webauthn,
+		logger:      logger,
+	}
+}
+
+// PasskeyCredential is the JSON form of a PublicKeyCredential, as returned
+// by its toJSON method in the browser. Binary fields are base64url encoded.
+type PasskeyCredential struct {
+	ID       string `json:"id" binding:"required"`
+	Type     string `json:"type" binding:"required,eq=public-key"`
+	Response struct {
+		ClientDataJSON string `json:"clientDataJSON" binding:"required"`
+		// Registration
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports" binding:"max=10,dive,max=32"`
+		// Sign-in
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// RegisterPasskeyRequest represents the register passkey request body
+type RegisterPasskeyRequest struct {
+	Nickname   string            `json:"nickname" binding:"max=100"`
+	Credential PasskeyCredential `json:"credential" binding:"required"`
+}
+
+// RenamePasskeyRequest represents the rename passkey request body
+type RenamePasskeyRequest struct {
+	Nickname string `json:"nickname" binding:"required,max=100"`
+}
+
+// PasskeyLoginRequest represents the passkey login request body
+type PasskeyLoginRequest struct {
+	Credential PasskeyCredential `json:"credential" binding:"required"`
+	DeviceName string            `json:"device_name,omitempty" binding:"max=100"`
+}
+
+// PasskeyInfo describes a registered passkey
+type PasskeyInfo struct {
+	ID         int64      `json:"id"`
+	Nickname   string     `json:"nickname"`
+	Transports []string   `json:"transports"`
+	Synced     bool       `json:"synced"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ListPasskeys returns the authenticated user's passkeys
+func (h *PasskeyHandler) ListPasskeys(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	credentials, err := h.store.ListUserWebAuthnCredentials(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list passkeys"})
+		return
+	}
+
+	passkeys := make([]PasskeyInfo, 0, len(credentials))
+	for _, credential := range credentials {
+		passkeys = append(passkeys, newPasskeyInfo(credential))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"passkeys": passkeys})
+}
+
+// StartRegistration returns the options the browser needs to create a
+// passkey for the authenticated user
+func (h *PasskeyHandler) StartRegistration(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	ctx := c.Request.Context()
+
+	user, err := h.store.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+		return
+	}
+
+	// Authenticators that already hold one of the user's passkeys refuse to
+	// create another
+	credentials, err := h.store.ListUserWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list passkeys"})
+		return
+	}
+	exclude := make([]auth.WebAuthnCredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		exclude = append(exclude, auth.NewWebAuthnCredentialDescriptor(credential.CredentialID, credential.Transports))
+	}
+
+	challenge, ok := h.startCeremony(c, ceremonyRegistration, userID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"publicKey": h.webauthn.CreationOptions(challenge, user.ID, user.Email, user.FullName, exclude),
+	})
+}
+
+// Register verifies a new passkey created with the options from
+// StartRegistration and saves it
+func (h *PasskeyHandler) Register(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var req RegisterPasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rawID, clientDataJSON, err := decodePasskeyCredential(req.Credential)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attestationObject, err := decodeBase64URL(req.Credential.Response.AttestationObject)
+	if err != nil || len(attestationObject) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attestation object"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	challenge, ok := h.consumeCeremony(c, clientDataJSON, ceremonyRegistration, userID)
+	if !ok {
+		return
+	}
+
+	credential, err := h.webauthn.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !bytes.Equal(credential.ID, rawID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "credential ID does not match authenticator data"})
+		return
+	}
+
+	nickname := strings.TrimSpace(req.Nickname)
+	if nickname == "" {
+		nickname = "Passkey"
+	}
+	transports := req.Credential.Response.Transports
+	if transports == nil {
+		transports = []string{}
+	}
+
+	var stored sqlc.WebauthnCredential
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		var err error
+		stored, err = q.CreateWebAuthnCredential(ctx, sqlc.CreateWebAuthnCredentialParams{
+			UserID:         userID,
+			CredentialID:   credential.ID,
+			PublicKey:      credential.PublicKey,
+			SignCount:      int64(credential.SignCount),
+			Transports:     transports,
+			Aaguid:         credential.AAGUID,
+			BackupEligible: credential.BackupEligible,
+			BackedUp:       credential.BackedUp,
+			Nickname:       nickname,
+		})
+		if err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionPasskeyAdded, audit.TargetPasskey, stored.ID)
+		event.Diff = map[string]audit.Change{"nickname": {To: stored.Nickname}}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "passkey is already registered"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save passkey"})
+		return
+	}
+
+	h.logger.Info().
+		Str("event", "passkey_registered").
+		Int64("user_id", userID).
+		Int64("passkey_id", stored.ID).
+		Str("request_id", c.GetString("request_id")).
+		Msg("User registered a passkey")
+
+	c.JSON(http.StatusCreated, newPasskeyInfo(stored))
+}
+
+// RenamePasskey changes the nickname of one of the authenticated user's passkeys
+func (h *PasskeyHandler) RenamePasskey(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid passkey ID"})
+		return
+	}
+
+	var req RenamePasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential, err := h.store.RenameUserWebAuthnCredential(c.Request.Context(), sqlc.RenameUserWebAuthnCredentialParams{
+		ID:       id,
+		UserID:   userID,
+		Nickname: req.Nickname,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "passkey not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rename passkey"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newPasskeyInfo(credential))
+}
+
+// DeletePasskey removes one of the authenticated user's passkeys. It can no
+// longer be used to sign in.
+func (h *PasskeyHandler) DeletePasskey(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid passkey ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		rows, err := q.DeleteUserWebAuthnCredential(ctx, sqlc.DeleteUserWebAuthnCredentialParams{
+			ID:     id,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+
+		return audit.Record(ctx, q, auditEvent(c, audit.ActionPasskeyRemoved, audit.TargetPasskey, id))
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "passkey not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete passkey"})
+		return
+	}
+
+	h.logger.Info().
+		Str("event", "passkey_deleted").
+		Int64("user_id", userID).
+		Int64("passkey_id", id).
+		Str("request_id", c.GetString("request_id")).
+		Msg("User deleted a passkey")
+
+	c.JSON(http.StatusOK, gin.H{"message": "passkey deleted successfully"})
+}
+
+// StartLogin returns the options the browser needs to sign in with a
+// passkey. The user picks the account on their authenticator, so no email
+// is asked for.
+func (h *PasskeyHandler) StartLogin(c *gin.Context) {
+	challenge, ok := h.startCeremony(c, ceremonyLogin, 0)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": h.webauthn.RequestOptions(challenge)})
+}
+
+// FinishLogin verifies a passkey assertion and signs the user in. A passkey
+// proves possession and user verification together, so no further factor
+// is asked for.
+func (h *PasskeyHandler) FinishLogin(c *gin.Context) {
+	var req PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rawID, clientDataJSON, err := decodePasskeyCredential(req.Credential)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	authenticatorData, err := decodeBase64URL(req.Credential.Response.AuthenticatorData)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid authenticator data"})
+		return
+	}
+	signature, err := decodeBase64URL(req.Credential.Response.Signature)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid signature"})
+		return
+	}
+	userHandle, err := decodeBase64URL(req.Credential.Response.UserHandle)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user handle"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	challenge, ok := h.consumeCeremony(c, clientDataJSON, ceremonyLogin, 0)
+	if !ok {
+		return
+	}
+
+	credential, err := h.store.GetWebAuthnCredential(ctx, rawID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid passkey"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find passkey"})
+		return
+	}
+	if len(userHandle) > 0 && !bytes.Equal(userHandle, auth.WebAuthnUserHandle(credential.UserID)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid passkey"})
+		return
+	}
+
+	assertion, err := h.webauthn.VerifyAssertion(challenge, credential.PublicKey, uint32(credential.SignCount), clientDataJSON, authenticatorData, signature)
+	if err != nil {
+		if errors.Is(err, auth.ErrWebAuthnCounter) {
+			h.counterRegressed(c, credential)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid passkey"})
+		return
+	}
+
+	// A concurrent sign-in may have used the counter since it was read
+	rows, err := h.store.UpdateWebAuthnCredentialUsage(ctx, sqlc.UpdateWebAuthnCredentialUsageParams{
+		ID:        credential.ID,
+		SignCount: int64(assertion.SignCount),
+		BackedUp:  assertion.BackedUp,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update passkey"})
+		return
+	}
+	if rows == 0 {
+		h.counterRegressed(c, credential)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid passkey"})
+		return
+	}
+
+	user, err := h.store.GetUserByID(ctx, credential.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "account is disabled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+		return
+	}
+
+	h.logger.Info().
+		Str("event", "passkey_login").
+		Int64("user_id", user.ID).
+		Int64("passkey_id", credential.ID).
+		Str("client_ip", c.ClientIP()).
+		Str("request_id", c.GetString("request_id")).
+		Msg("User signed in with a passkey")
+
+	h.authHandler.signInMultiFactor(c, user, req.DeviceName)
+}
+
+// counterRegressed logs a sign-in whose signature counter was not ahead of
+// the stored one
+func (h *PasskeyHandler) counterRegressed(c *gin.Context, credential sqlc.WebauthnCredential) {
+	h.logger.Warn().
+		Str("event", "passkey_counter_regression").
+		Int64("user_id", credential.UserID).
+		Int64("passkey_id", credential.ID).
+		Str("client_ip", c.ClientIP()).
+		Str("request_id", c.GetString("request_id")).
+		Msg("Passkey signature counter went backwards, it may have been cloned")
+}
+
+// startCeremony stores a new challenge for ceremony and returns it. userID
+// is zero for sign-in, where the user is not known yet.
+func (h *PasskeyHandler) startCeremony(c *gin.Context, ceremony string, userID int64) (string, bool) {
+	ctx := c.Request.Context()
+
+	challenge, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate challenge"})
+		return "", false
+	}
+
+	// Best effort: expired challenges can no longer be answered
+	_ = h.store.DeleteExpiredWebAuthnChallenges(ctx)
+
+	if err := h.store.CreateWebAuthnChallenge(ctx, sqlc.CreateWebAuthnChallengeParams{
+		ChallengeHash: auth.HashToken(challenge),
+		Ceremony:      ceremony,
+		UserID:        sql.NullInt64{Int64: userID, Valid: userID != 0},
+		ExpiresAt:     time.Now().Add(auth.WebAuthnTimeout),
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save challenge"})
+		return "", false
+	}
+
+	return challenge, true
+}
+
+// consumeCeremony redeems the challenge clientDataJSON answers. It must have
+// been issued for the same ceremony and user.
+func (h *PasskeyHandler) consumeCeremony(c *gin.Context, clientDataJSON []byte, ceremony string, userID int64) (string, bool) {
+	challenge, err := auth.WebAuthnChallenge(clientDataJSON)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errPasskeyChallenge.Error()})
+		return "", false
+	}
+	if err := h.consumeChallenge(c.Request.Context(), challenge, ceremony, userID); err != nil {
+		if errors.Is(err, errPasskeyChallenge) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errPasskeyChallenge.Error()})
+			return "", false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check challenge"})
+		return "", false
+	}
+	return challenge, true
+}
+
+// consumeChallenge deletes a stored challenge, failing if it does not exist,
+// has expired or belongs to another ceremony or user
+func (h *PasskeyHandler) consumeChallenge(ctx context.Context, challenge, ceremony string, userID int64) error {
+	stored, err := h.store.ConsumeWebAuthnChallenge(ctx, sqlc.ConsumeWebAuthnChallengeParams{
+		ChallengeHash: auth.HashToken(challenge),
+		Ceremony:      ceremony,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errPasskeyChallenge
+		}
+		return err
+	}
+	if stored.UserID.Int64 != userID {
+		return errPasskeyChallenge
+	}
+	return nil
+}
+
+// decodePasskeyCredential decodes the fields every ceremony response has
+func decodePasskeyCredential(credential PasskeyCredential) (rawID, clientDataJSON []byte, err error) {
+	rawID, err = decodeBase64URL(credential.ID)
+	if err != nil || len(rawID) == 0 {
+		return nil, nil, errors.New("invalid credential ID")
+	}
+	clientDataJSON, err = decodeBase64URL(credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, nil, errors.New("invalid client data")
+	}
+	return rawID, clientDataJSON, nil
+}
+
+// decodeBase64URL decodes a base64url value with or without padding
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// newPasskeyInfo returns the public view of credential
+func newPasskeyInfo(credential sqlc.WebauthnCredential) PasskeyInfo {
+	info := PasskeyInfo{
+		ID:         credential.ID,
+		Nickname:   credential.Nickname,
+		Transports: credential.Transports,
+		Synced:     credential.BackedUp,
+		CreatedAt:  credential.CreatedAt,
+	}
+	if credential.LastUsedAt.Valid {
+		info.LastUsedAt = &credential.LastUsedAt.Time
+	}
+	return info
+}
@@ -0,0 +1,394 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/api/middleware"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// cborMap is a CBOR map whose keys are encoded in order, as CTAP2 requires
+type cborMap []struct{ key, value interface{} }
+
+// encodeCBOR encodes the values a software authenticator needs
+func encodeCBOR(v interface{}) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		case n < 1<<16:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		default:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		}
+	}
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case cborMap:
+		out := head(5, uint64(len(v)))
+		for _, pair := range v {
+			out = append(out, encodeCBOR(pair.key)...)
+			out = append(out, encodeCBOR(pair.value)...)
+		}
+		return out
+	default:
+		panic("unsupported CBOR value")
+	}
+}
+
+// staleCredentialStore returns credential as it was before another sign-in
+// saved a newer counter, as a concurrent sign-in would read it
+type staleCredentialStore struct {
+	*memStore
+	credential sqlc.WebauthnCredential
+}
+
+func (s *staleCredentialStore) GetWebAuthnCredential(ctx context.Context, credentialID []byte) (sqlc.WebauthnCredential, error) {
+	return s.credential, nil
+}
+
+// softAuthenticator is a platform authenticator in software. It holds one
+// discoverable credential and always verifies the user.
+type softAuthenticator struct {
+	t            *testing.T
+	origin       string
+	ed25519      bool
+	ecKey        *ecdsa.PrivateKey
+	edKey        ed25519.PrivateKey
+	credentialID []byte
+	userHandle   string
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T, origin string, useEd25519 bool) *softAuthenticator {
+	a := &softAuthenticator{t: t, origin: origin, ed25519: useEd25519, credentialID: make([]byte, 16)}
+	_, err := rand.Read(a.credentialID)
+	require.NoError(t, err)
+	if useEd25519 {
+		_, a.edKey, err = ed25519.GenerateKey(rand.Reader)
+	} else {
+		a.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	require.NoError(t, err)
+	return a
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	if a.ed25519 {
+		return encodeCBOR(cborMap{{1, 1}, {3, -8}, {-1, 6}, {-2, []byte(a.edKey.Public().(ed25519.PublicKey))}})
+	}
+	return encodeCBOR(cborMap{
+		{1, 2}, {3, -7}, {-1, 1},
+		{-2, a.ecKey.X.FillBytes(make([]byte, 32))},
+		{-3, a.ecKey.Y.FillBytes(make([]byte, 32))},
+	})
+}
+
+// authData returns authenticator data with the user present and verified
+// and the credential eligible for and in backup, as synced passkeys are
+func (a *softAuthenticator) authData(rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	flags := byte(0x01 | 0x04 | 0x08 | 0x10)
+	if attested {
+		flags |= 0x40
+	}
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+func (a *softAuthenticator) clientData(ceremony, challenge string) []byte {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      a.origin,
+		"crossOrigin": false,
+	})
+	require.NoError(a.t, err)
+	return data
+}
+
+// create answers navigator.credentials.create and returns the credential's
+// toJSON form
+func (a *softAuthenticator) create(options auth.WebAuthnCreationOptions) gin.H {
+	for _, excluded := range options.ExcludeCredentials {
+		require.NotEqual(a.t, b64url(a.credentialID), excluded.ID, "credential already registered")
+	}
+	a.userHandle = options.User.ID
+	attestationObject := encodeCBOR(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", a.authData(options.RP.ID, true)},
+	})
+	return gin.H{
+		"id":    b64url(a.credentialID),
+		"rawId": b64url(a.credentialID),
+		"type":  "public-key",
+		"response": gin.H{
+			"clientDataJSON":    b64url(a.clientData("webauthn.create", options.Challenge)),
+			"attestationObject": b64url(attestationObject),
+			"transports":        []string{"internal", "hybrid"},
+		},
+	}
+}
+
+// get answers navigator.credentials.get and returns the assertion's toJSON form
+func (a *softAuthenticator) get(options auth.WebAuthnRequestOptions) gin.H {
+	a.signCount++
+	authData := a.authData(options.RPID, false)
+	clientData := a.clientData("webauthn.get", options.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+
+	var signature []byte
+	if a.ed25519 {
+		signature = ed25519.Sign(a.edKey, signed)
+	} else {
+		digest := sha256.Sum256(signed)
+		var err error
+		signature, err = ecdsa.SignASN1(rand.Reader, a.ecKey, digest[:])
+		require.NoError(a.t, err)
+	}
+
+	return gin.H{
+		"id":    b64url(a.credentialID),
+		"rawId": b64url(a.credentialID),
+		"type":  "public-key",
+		"response": gin.H{
+			"clientDataJSON":    b64url(clientData),
+			"authenticatorData": b64url(authData),
+			"signature":         b64url(signature),
+			"userHandle":        a.userHandle,
+		},
+	}
+}
+
+func b64url(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestPasskeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const origin = "https://app.example.com"
+	store := newMemStore(
+		sqlc.User{ID: 1, Email: "alice@example.com", FullName: "Alice", IsActive: true},
+		sqlc.User{ID: 2, Email: "bob@example.com", FullName: "Bob", IsActive: true},
+	)
+	// Passkey sign-in satisfies MFA on its own
+	store.mfaEnabled[1] = true
+	store.mfaEnabled[2] = true
+
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	authHandler := handlers.NewAuthHandler(store, jwtManager, nil, nil, nil, nil, nil, false, false, false, zerolog.Nop())
+	webauthn := auth.NewWebAuthn("app.example.com", "Example", []string{origin})
+	passkeyHandler := handlers.NewPasskeyHandler(store, authHandler, webauthn, zerolog.Nop())
+	stale := &staleCredentialStore{memStore: store}
+	racingHandler := handlers.NewPasskeyHandler(stale, authHandler, webauthn, zerolog.Nop())
+	authRequired := middleware.AuthRequired(jwtManager, auth.NewRevocationList(store, 15*time.Minute, time.Second), auth.NewAPIKeyAuthenticator(store))
+
+	router := gin.New()
+	router.POST("/api/v1/auth/passkey/login/options", passkeyHandler.StartLogin)
+	router.POST("/api/v1/auth/passkey/login", passkeyHandler.FinishLogin)
+	router.POST("/api/v1/auth/passkey/login/racing", racingHandler.FinishLogin)
+	users := router.Group("/api/v1/users", authRequired, middleware.SessionRequired())
+	users.GET("/me/passkeys", passkeyHandler.ListPasskeys)
+	users.POST("/me/passkeys/options", passkeyHandler.StartRegistration)
+	users.POST("/me/passkeys", passkeyHandler.Register)
+	users.PUT("/me/passkeys/:id", passkeyHandler.RenamePasskey)
+	users.DELETE("/me/passkeys/:id", passkeyHandler.DeletePasskey)
+
+	session := func(userID int64) string {
+		token, err := jwtManager.IssueAccessToken(auth.Claims{UserID: userID, Email: store.users[userID].Email})
+		require.NoError(t, err)
+		return token
+	}
+	alice, bob := session(1), session(2)
+
+	do := func(method, path, bearer string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	register := func(authenticator *softAuthenticator, bearer, nickname string) *httptest.ResponseRecorder {
+		w := do(http.MethodPost, "/api/v1/users/me/passkeys/options", bearer, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var options struct {
+			PublicKey auth.WebAuthnCreationOptions `json:"publicKey"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &options))
+		return do(http.MethodPost, "/api/v1/users/me/passkeys", bearer, gin.H{
+			"nickname":   nickname,
+			"credential": authenticator.create(options.PublicKey),
+		})
+	}
+
+	loginOptions := func() auth.WebAuthnRequestOptions {
+		w := do(http.MethodPost, "/api/v1/auth/passkey/login/options", "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var options struct {
+			PublicKey auth.WebAuthnRequestOptions `json:"publicKey"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &options))
+		return options.PublicKey
+	}
+
+	login := func(credential gin.H) *httptest.ResponseRecorder {
+		return do(http.MethodPost, "/api/v1/auth/passkey/login", "", gin.H{"credential": credential, "device_name": "Phone"})
+	}
+
+	phone := newSoftAuthenticator(t, origin, false)
+
+	t.Run("register and sign in", func(t *testing.T) {
+		w := register(phone, alice, "Phone")
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var passkey handlers.PasskeyInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &passkey))
+		assert.Equal(t, "Phone", passkey.Nickname)
+		assert.True(t, passkey.Synced)
+		assert.Equal(t, []string{"internal", "hybrid"}, passkey.Transports)
+
+		// The same authenticator is excluded from registering again
+		w = do(http.MethodPost, "/api/v1/users/me/passkeys/options", alice, nil)
+		assert.Contains(t, w.Body.String(), b64url(phone.credentialID))
+
+		// A passkey is both factors, so enabled TOTP is not asked for
+		w = login(phone.get(loginOptions()))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp handlers.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.NotEmpty(t, resp.AccessToken)
+		assert.NotEmpty(t, resp.RefreshToken)
+		assert.Equal(t, int64(1), resp.User.ID)
+		assert.Equal(t, int64(1), store.credentials[0].SignCount)
+		assert.True(t, store.credentials[0].LastUsedAt.Valid)
+
+		// Both the new passkey and the sign-in are audited
+		require.Len(t, store.events, 2)
+		assert.Equal(t, "passkey.added", store.events[0].Action)
+		assert.Equal(t, int64(1), store.events[0].ActorID.Int64)
+		login := store.events[1]
+		assert.Equal(t, "auth.login", login.Action)
+		assert.Equal(t, int64(1), login.ActorID.Int64)
+		assert.Equal(t, int64(1), login.TargetID.Int64)
+		assert.Contains(t, string(login.Diff), `"session_id"`)
+	})
+
+	t.Run("Ed25519 passkey", func(t *testing.T) {
+		key := newSoftAuthenticator(t, origin, true)
+		w := register(key, bob, "")
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"nickname":"Passkey"`)
+
+		w = login(key.get(loginOptions()))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"email":"bob@example.com"`)
+	})
+
+	t.Run("challenge works once", func(t *testing.T) {
+		assertion := phone.get(loginOptions())
+		require.Equal(t, http.StatusOK, login(assertion).Code)
+		assert.Equal(t, http.StatusBadRequest, login(assertion).Code)
+	})
+
+	t.Run("registration challenge cannot sign in", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/v1/users/me/passkeys/options", alice, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var options struct {
+			PublicKey auth.WebAuthnCreationOptions `json:"publicKey"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &options))
+
+		w = login(phone.get(auth.WebAuthnRequestOptions{Challenge: options.PublicKey.Challenge, RPID: "app.example.com"}))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("phishing origin is rejected", func(t *testing.T) {
+		phone.origin = "https://app.example.com.evil.test"
+		defer func() { phone.origin = origin }()
+
+		assert.Equal(t, http.StatusUnauthorized, login(phone.get(loginOptions())).Code)
+	})
+
+	t.Run("cloned authenticator is rejected", func(t *testing.T) {
+		clone := *phone
+		require.Equal(t, http.StatusOK, login(phone.get(loginOptions())).Code)
+
+		// The clone's counter lags behind the original's
+		assert.Equal(t, http.StatusUnauthorized, login(clone.get(loginOptions())).Code)
+	})
+
+	t.Run("concurrent sign-ins cannot share a counter", func(t *testing.T) {
+		clone := *phone
+		stale.credential = store.credentials[0]
+		require.Equal(t, http.StatusOK, login(phone.get(loginOptions())).Code)
+
+		// The clone's sign-in read the counter before the original's was saved
+		w := do(http.MethodPost, "/api/v1/auth/passkey/login/racing", "", gin.H{"credential": clone.get(loginOptions())})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+	})
+
+	t.Run("rename and delete", func(t *testing.T) {
+		id := store.credentials[0].ID
+		path := "/api/v1/users/me/passkeys/" + strconv.FormatInt(id, 10)
+
+		// Other users cannot touch the passkey
+		assert.Equal(t, http.StatusNotFound, do(http.MethodPut, path, bob, gin.H{"nickname": "Mine"}).Code)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, path, bob, nil).Code)
+
+		w := do(http.MethodPut, path, alice, gin.H{"nickname": "Work phone"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"nickname":"Work phone"`)
+
+		w = do(http.MethodDelete, path, alice, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = do(http.MethodGet, "/api/v1/users/me/passkeys", alice, nil)
+		assert.JSONEq(t, `{"passkeys":[]}`, w.Body.String())
+
+		assert.Equal(t, http.StatusUnauthorized, login(phone.get(loginOptions())).Code)
+	})
+}
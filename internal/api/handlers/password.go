@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+	"github.com/yourusername/go-sqlc-starter/internal/mail"
+)
+
+var (
+	errResetTokenInvalid = errors.New("reset token invalid or expired")
+	errPasswordPolicy    = errors.New("password does not meet requirements")
+)
+
+type PasswordHandler struct {
+	store       db.Store
+	authHandler *AuthHandler
+	revocations *auth.RevocationList
+	policy      *auth.PasswordPolicy
+	mailer      mail.Mailer
+	appURL      string
+	resetExpiry time.Duration
+	logger      zerolog.Logger
+}
+
+func NewPasswordHandler(store db.Store, authHandler *AuthHandler, revocations *auth.RevocationList, policy *auth.PasswordPolicy, mailer mail.Mailer, appURL string, resetExpiry time.Duration, logger zerolog.Logger) *PasswordHandler {
+	return &PasswordHandler{
+		store:       store,
+		authHandler: authHandler,
+		revocations: revocations,
+		policy:      policy,
+		mailer:      mailer,
+		appURL:      appURL,
+		resetExpiry: resetExpiry,
+		logger:      logger,
+	}
+}
+
+// ChangePasswordRequest represents the change password request body
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ForgotPasswordRequest represents the forgot password request body
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents the reset password request body
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword sets a new password for the authenticated user after
+// checking the current one. Wrong current passwords count towards the login
+// lockout. Every access token of the user is revoked and every other session
+// logged out; the current session gets a new token.
+func (h *PasswordHandler) ChangePassword(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.store.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+		return
+	}
+
+	// Accounts created through an identity provider set a first password
+	// with a reset link
+	if user.PasswordHash == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "account has no password; request a password reset link to set one"})
+		return
+	}
+
+	// Guesses at the current password are throttled like logins
+	if h.authHandler.throttled(c, user.Email) {
+		return
+	}
+	if _, err := auth.VerifyPassword(req.CurrentPassword, user.PasswordHash); err != nil {
+		h.authHandler.recordFailure(c, user.Email)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+		return
+	}
+
+	if !checkPasswordPolicy(c, h.policy, req.NewPassword, user.Email, user.FullName) {
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process password"})
+		return
+	}
+
+	current := currentSessionID(c)
+	ctx := c.Request.Context()
+
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		if err := q.UpdateUserPassword(ctx, sqlc.UpdateUserPasswordParams{
+			ID:           userID,
+			PasswordHash: hashedPassword,
+		}); err != nil {
+			return err
+		}
+		if err := q.DeleteUserPasswordResetTokens(ctx, userID); err != nil {
+			return err
+		}
+		if err := q.DeleteUserRefreshTokensExceptFamily(ctx, sqlc.DeleteUserRefreshTokensExceptFamilyParams{
+			UserID:   userID,
+			FamilyID: current,
+		}); err != nil {
+			return err
+		}
+		return audit.Record(ctx, q, auditEvent(c, audit.ActionPasswordChanged, audit.TargetUser, userID))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update password"})
+		return
+	}
+
+	// Kill outstanding access tokens. The one issued below comes after the
+	// cutoff and stays valid.
+	if err := h.revocations.RevokeUser(ctx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+
+	sess := session{familyID: current, orgID: c.GetInt64("org_id")}
+	accessToken, _, err := h.authHandler.issueAccessToken(ctx, h.store, user, sess)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "password changed successfully",
+		"access_token": accessToken,
+		"expires_at":   time.Now().Add(h.authHandler.jwtManager.AccessExpiry()),
+	})
+}
+
+// ForgotPassword emails a password reset link. The response is the same, and
+// sent before any mail, whether or not the account exists, so it cannot be
+// used to probe emails.
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inBackground(c, h.logger, "Failed to send password reset link", func(ctx context.Context) error {
+		return h.sendResetLink(ctx, req.Email)
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "if the account exists, a password reset link has been sent"})
+}
+
+// ResetPassword sets a new password using a token from a reset link. All of
+// the user's sessions and access tokens are revoked.
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The row lock makes the token single use under concurrent requests
+	var (
+		userID     int64
+		violations []auth.PolicyViolation
+	)
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		token, err := q.GetPasswordResetTokenForUpdate(ctx, auth.HashToken(req.Token))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errResetTokenInvalid
+			}
+			return err
+		}
+		userID = token.UserID
+
+		// The policy needs the account's email and name, known only from the token
+		user, err := q.AdminGetUser(ctx, token.UserID)
+		if err != nil {
+			return err
+		}
+		// Leave the token unused: it works again if the account is reactivated
+		if !user.IsActive {
+			return errUserInactive
+		}
+		violations, err = h.policy.Check(req.NewPassword, user.Email, user.FullName)
+		if err != nil {
+			return err
+		}
+		if len(violations) > 0 {
+			return errPasswordPolicy
+		}
+
+		hashedPassword, err := auth.HashPassword(req.NewPassword)
+		if err != nil {
+			return err
+		}
+
+		if err := q.UpdateUserPassword(ctx, sqlc.UpdateUserPasswordParams{
+			ID:           token.UserID,
+			PasswordHash: hashedPassword,
+		}); err != nil {
+			return err
+		}
+		if err := q.MarkPasswordResetTokenUsed(ctx, token.ID); err != nil {
+			return err
+		}
+		if err := q.DeleteUserPasswordResetTokens(ctx, token.UserID); err != nil {
+			return err
+		}
+		if err := q.DeleteUserRefreshTokens(ctx, token.UserID); err != nil {
+			return err
+		}
+
+		// Holding the link proves the reset was made by the account's owner
+		event := auditEvent(c, audit.ActionPasswordReset, audit.TargetUser, token.UserID)
+		event.ActorID = token.UserID
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		if errors.Is(err, errResetTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+			return
+		}
+		if errors.Is(err, errPasswordPolicy) {
+			respondPasswordPolicy(c, violations)
+			return
+		}
+		if errors.Is(err, errUserInactive) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "account is deactivated"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+
+	// Kill outstanding access tokens
+	if err := h.revocations.RevokeUser(ctx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset successfully"})
+}
+
+// sendResetLink stores a new reset token for the account registered to email,
+// if any, and mails it
+func (h *PasswordHandler) sendResetLink(ctx context.Context, email string) error {
+	user, err := h.store.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	// Best effort: expired tokens can no longer be redeemed
+	_ = h.store.DeleteExpiredPasswordResetTokens(ctx)
+
+	if _, err := h.store.CreatePasswordResetToken(ctx, sqlc.CreatePasswordResetTokenParams{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(h.resetExpiry),
+	}); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", h.appURL, url.QueryEscape(token))
+	return h.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password for your account. If it was you, "+
+			"follow this link within %s to choose a new password:\n\n%s\n\n"+
+			"If you didn't ask for this, you can ignore this email.\n",
+			user.FullName, h.resetExpiry, link),
+	})
+}
+
+// checkPasswordPolicy responds with every rule password breaks and returns
+// false, or returns true if it is acceptable
+func checkPasswordPolicy(c *gin.Context, policy *auth.PasswordPolicy, password, email, name string) bool {
+	violations, err := policy.Check(password, email, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check password"})
+		return false
+	}
+	if len(violations) > 0 {
+		respondPasswordPolicy(c, violations)
+		return false
+	}
+	return true
+}
+
+// respondPasswordPolicy reports the rules a new password broke
+func respondPasswordPolicy(c *gin.Context, violations []auth.PolicyViolation) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":      errPasswordPolicy.Error(),
+		"violations": violations,
+	})
+}
@@ -0,0 +1,231 @@
+package handlers_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// newPasswordServer adds the password routes to a test server
+func newPasswordServer(t *testing.T, emails ...string) *testServer {
+	t.Helper()
+
+	s := newTestServer(t, emails...)
+	passwordHandler := handlers.NewPasswordHandler(s.store, s.authHandler, s.revocations, &auth.PasswordPolicy{MinLength: 8}, s.outbox, "http://localhost:3000", time.Hour, zerolog.Nop())
+	s.router.POST("/api/v1/auth/password/forgot", passwordHandler.ForgotPassword)
+	s.router.POST("/api/v1/auth/password/reset", passwordHandler.ResetPassword)
+	s.users.PUT("/me/password", passwordHandler.ChangePassword)
+	return s
+}
+
+func TestChangePassword(t *testing.T) {
+	s := newPasswordServer(t, "user@example.com")
+
+	current := s.login("user@example.com").AccessToken
+	other := s.login("user@example.com").AccessToken
+
+	w := s.do(http.MethodPut, "/api/v1/users/me/password", current, map[string]string{
+		"current_password": testPassword,
+		"new_password":     "correct-horse-battery",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	assert.False(t, s.signedIn(other), "other sessions are signed out")
+	assert.False(t, s.signedIn(current), "the old token of the current session is revoked")
+	assert.True(t, s.signedIn(resp.AccessToken), "the current session carries on with the new token")
+	assert.Equal(t, s.sessionID(current), s.sessionID(resp.AccessToken))
+}
+
+func TestChangePasswordThrottling(t *testing.T) {
+	s := newPasswordServer(t, "user@example.com")
+	token := s.login("user@example.com").AccessToken
+
+	change := func(current string) int {
+		return s.do(http.MethodPut, "/api/v1/users/me/password", token, map[string]string{
+			"current_password": current,
+			"new_password":     "correct-horse-battery",
+		}).Code
+	}
+
+	for i := 0; i < testLockout.MaxAttempts; i++ {
+		require.Equal(t, http.StatusUnauthorized, change("wrong-password"))
+	}
+	assert.Equal(t, int32(testLockout.MaxAttempts), s.store.loginAttempts["account:user@example.com"].Failures)
+
+	assert.Equal(t, http.StatusTooManyRequests, change(testPassword), "the account is locked like after failed logins")
+	w := s.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{"email": "user@example.com", "password": testPassword})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+// passwordChangedStore changes the stored password right after login reads
+// the user, as a password change made at the same time would
+type passwordChangedStore struct {
+	*memStore
+	hash string
+}
+
+func (s *passwordChangedStore) GetUserByEmail(ctx context.Context, email string) (sqlc.User, error) {
+	user, err := s.memStore.GetUserByEmail(ctx, email)
+	if err == nil {
+		changed := user
+		changed.PasswordHash = s.hash
+		s.users[user.ID] = changed
+	}
+	return user, err
+}
+
+func TestLoginRehash(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	outdated, err := auth.BcryptHasher{Cost: 4}.Hash(testPassword)
+	require.NoError(t, err)
+	user := sqlc.User{ID: 1, Email: "user@example.com", PasswordHash: outdated, IsActive: true}
+
+	login := func(store db.Store) int {
+		jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+		authHandler := handlers.NewAuthHandler(store, jwtManager, nil, nil, nil, auth.NewLoginThrottle(store, auth.LockoutPolicy{}), nil, false, false, false, zerolog.Nop())
+		router := gin.New()
+		router.POST("/api/v1/auth/login", authHandler.Login)
+		return postJSON(router, "/api/v1/auth/login", map[string]interface{}{"email": user.Email, "password": testPassword}).Code
+	}
+
+	t.Run("outdated hash is upgraded", func(t *testing.T) {
+		store := newMemStore(user)
+		require.Equal(t, http.StatusOK, login(store))
+
+		stored := store.users[1].PasswordHash
+		assert.True(t, strings.HasPrefix(stored, "$argon2id$"), stored)
+		rehash, err := auth.VerifyPassword(testPassword, stored)
+		require.NoError(t, err)
+		assert.False(t, rehash)
+	})
+
+	t.Run("password changed meanwhile is kept", func(t *testing.T) {
+		changed, err := auth.HashPassword("correct-horse-battery")
+		require.NoError(t, err)
+		store := &passwordChangedStore{memStore: newMemStore(user), hash: changed}
+		require.Equal(t, http.StatusOK, login(store))
+
+		assert.Equal(t, changed, store.users[1].PasswordHash)
+	})
+}
+
+func TestForgotPassword(t *testing.T) {
+	s := newPasswordServer(t, "user@example.com")
+
+	known := s.do(http.MethodPost, "/api/v1/auth/password/forgot", "", map[string]string{"email": "user@example.com"})
+	unknown := s.do(http.MethodPost, "/api/v1/auth/password/forgot", "", map[string]string{"email": "nobody@example.com"})
+	assert.Equal(t, http.StatusOK, known.Code)
+	assert.Equal(t, known.Code, unknown.Code)
+	assert.JSONEq(t, known.Body.String(), unknown.Body.String())
+
+	// The mail goes out after the response
+	messages := s.mail()
+	require.Len(t, messages, 1, "only the existing account is mailed")
+
+	token := linkToken(t, messages[0])
+	w := s.do(http.MethodPost, "/api/v1/auth/password/reset", "", map[string]string{
+		"token":        token,
+		"new_password": "correct-horse-battery",
+	})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestResetPasswordDeactivatedAccount(t *testing.T) {
+	s := newPasswordServer(t, "user@example.com")
+
+	s.do(http.MethodPost, "/api/v1/auth/password/forgot", "", map[string]string{"email": "user@example.com"})
+	messages := s.mail()
+	require.Len(t, messages, 1)
+	token := linkToken(t, messages[0])
+
+	user := s.store.users[1]
+	user.IsActive = false
+	s.store.users[1] = user
+
+	reset := map[string]string{"token": token, "new_password": "correct-horse-battery"}
+	w := s.do(http.MethodPost, "/api/v1/auth/password/reset", "", reset)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	// The token is left unused
+	user.IsActive = true
+	s.store.users[1] = user
+	w = s.do(http.MethodPost, "/api/v1/auth/password/reset", "", reset)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestTokensAreStoredHashed(t *testing.T) {
+	s := newPasswordServer(t, "user@example.com")
+
+	t.Run("refresh tokens", func(t *testing.T) {
+		plaintext := s.login("user@example.com").RefreshToken
+		require.Len(t, s.store.refreshTokens, 1)
+		stored := s.store.refreshTokens[0].TokenHash
+		assert.Equal(t, auth.HashToken(plaintext), stored)
+
+		_, err := s.store.GetRefreshToken(context.Background(), plaintext)
+		assert.Equal(t, sql.ErrNoRows, err, "nothing is stored under the plaintext")
+
+		// A leaked digest cannot be replayed
+		w := s.do(http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refresh_token": stored})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = s.do(http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refresh_token": plaintext})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("reset tokens", func(t *testing.T) {
+		s.do(http.MethodPost, "/api/v1/auth/password/forgot", "", map[string]string{"email": "user@example.com"})
+		messages := s.mail()
+		require.Len(t, messages, 1)
+		plaintext := linkToken(t, messages[0])
+		require.Len(t, s.store.resetTokens, 1)
+		stored := s.store.resetTokens[0].TokenHash
+		assert.Equal(t, auth.HashToken(plaintext), stored)
+
+		_, err := s.store.GetPasswordResetTokenForUpdate(context.Background(), plaintext)
+		assert.Equal(t, sql.ErrNoRows, err, "nothing is stored under the plaintext")
+
+		reset := func(token string) int {
+			return s.do(http.MethodPost, "/api/v1/auth/password/reset", "", map[string]string{
+				"token":        token,
+				"new_password": "correct-horse-battery",
+			}).Code
+		}
+		assert.Equal(t, http.StatusBadRequest, reset(stored), "a leaked digest cannot be replayed")
+		assert.Equal(t, http.StatusOK, reset(plaintext))
+	})
+}
+
+// linkToken extracts the token from the link in the mail at path
+func linkToken(t *testing.T, path string) string {
+	t.Helper()
+
+	body, err := os.ReadFile(path)
+	require.NoError(t, err)
+	match := regexp.MustCompile(`token=(\S+)`).FindSubmatch(body)
+	require.NotNil(t, match, "mail has no link")
+	token, err := url.QueryUnescape(string(match[1]))
+	require.NoError(t, err)
+	return token
+}
@@ -0,0 +1,514 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// builtinAdminRole cannot be deleted, so there is always a way to administer roles
+const builtinAdminRole = "admin"
+
+var (
+	errRoleNotFound      = errors.New("role not found")
+	errRoleExists        = errors.New("role already exists")
+	errUnknownPermission = errors.New("unknown permission")
+	errBuiltinRole       = errors.New("built-in role")
+	errRoleNotHeld       = errors.New("user does not have role")
+	errLastAdmin         = errors.New("user is the last admin")
+)
+
+// RoleHandler manages roles, their permissions and who holds them
+type RoleHandler struct {
+	store       db.Store
+	revocations *auth.RevocationList
+}
+
+func NewRoleHandler(store db.Store, revocations *auth.RevocationList) *RoleHandler {
+	return &RoleHandler{
+		store:       store,
+		revocations: revocations,
+	}
+}
+
+// CreateRoleRequest represents the create role request body
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required,max=64"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleRequest represents the update role request body. Permissions,
+// when present, replace the role's current set.
+type UpdateRoleRequest struct {
+	Description *string   `json:"description,omitempty"`
+	Permissions *[]string `json:"permissions,omitempty"`
+}
+
+// AssignRoleRequest represents the assign role request body
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// RoleInfo represents a role and the permissions it grants
+type RoleInfo struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ListRoles returns every role with its permissions
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	roles, err := h.store.ListRoles(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list roles"})
+		return
+	}
+
+	infos := make([]RoleInfo, 0, len(roles))
+	for _, role := range roles {
+		info, err := newRoleInfo(ctx, h.store, role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list roles"})
+			return
+		}
+		infos = append(infos, info)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": infos})
+}
+
+// ListPermissions returns every permission a role can grant
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	permissions, err := h.store.ListPermissions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+}
+
+// CreateRole adds a role
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var info RoleInfo
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		role, err := q.CreateRole(ctx, sqlc.CreateRoleParams{
+			Name:        req.Name,
+			Description: req.Description,
+		})
+		if err != nil {
+			if isUniqueViolation(err) {
+				return errRoleExists
+			}
+			return err
+		}
+
+		if err := setRolePermissions(ctx, q, role.ID, req.Permissions); err != nil {
+			return err
+		}
+
+		if info, err = newRoleInfo(ctx, q, role); err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionRoleCreated, audit.TargetRole, role.ID)
+		event.Diff = map[string]audit.Change{
+			"name":        {To: info.Name},
+			"permissions": {To: info.Permissions},
+		}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		respondRoleError(c, err, "failed to create role")
+		return
+	}
+
+	c.JSON(http.StatusCreated, info)
+}
+
+// UpdateRole changes a role's description or permissions. Access tokens held
+// by the role's members are revoked so the change applies on their next refresh.
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role ID"})
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var (
+		info    RoleInfo
+		holders []int64
+	)
+	ctx := c.Request.Context()
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		role, err := q.GetRoleByID(ctx, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errRoleNotFound
+			}
+			return err
+		}
+		previous, err := newRoleInfo(ctx, q, role)
+		if err != nil {
+			return err
+		}
+
+		if req.Description != nil {
+			role, err = q.UpdateRoleDescription(ctx, sqlc.UpdateRoleDescriptionParams{
+				ID:          id,
+				Description: *req.Description,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if req.Permissions != nil {
+			if err := q.DeleteRolePermissions(ctx, id); err != nil {
+				return err
+			}
+			if err := setRolePermissions(ctx, q, id, *req.Permissions); err != nil {
+				return err
+			}
+			if holders, err = q.ListRoleUserIDs(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		if info, err = newRoleInfo(ctx, q, role); err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionRoleUpdated, audit.TargetRole, id)
+		event.Diff = map[string]audit.Change{
+			"description": {From: previous.Description, To: info.Description},
+			"permissions": {From: previous.Permissions, To: info.Permissions},
+		}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		respondRoleError(c, err, "failed to update role")
+		return
+	}
+
+	if err := h.revokeUsers(ctx, holders); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// DeleteRole removes a role from everyone holding it
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role ID"})
+		return
+	}
+
+	var holders []int64
+	ctx := c.Request.Context()
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		role, err := q.GetRoleByID(ctx, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errRoleNotFound
+			}
+			return err
+		}
+		if role.Name == builtinAdminRole {
+			return errBuiltinRole
+		}
+
+		if holders, err = q.ListRoleUserIDs(ctx, id); err != nil {
+			return err
+		}
+		if _, err = q.DeleteRole(ctx, id); err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionRoleDeleted, audit.TargetRole, id)
+		event.Diff = map[string]audit.Change{
+			"name": {From: role.Name},
+		}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		respondRoleError(c, err, "failed to delete role")
+		return
+	}
+
+	if err := h.revokeUsers(ctx, holders); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role deleted successfully"})
+}
+
+// GetUserRoles returns a user's roles and the permissions they add up to
+func (h *RoleHandler) GetUserRoles(c *gin.Context) {
+	userID, ok := h.parseUserID(c)
+	if !ok {
+		return
+	}
+
+	roles, err := h.store.ListUserRoles(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get roles"})
+		return
+	}
+
+	permissions, err := h.store.ListUserPermissions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles":       roles,
+		"permissions": permissions,
+	})
+}
+
+// AssignUserRole grants a role to a user. It takes effect on their next
+// token refresh.
+func (h *RoleHandler) AssignUserRole(c *gin.Context) {
+	userID, ok := h.parseUserID(c)
+	if !ok {
+		return
+	}
+
+	var req AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.store.GetRoleByName(c.Request.Context(), req.Role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to assign role"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		if err := q.AssignUserRole(ctx, sqlc.AssignUserRoleParams{
+			UserID: userID,
+			RoleID: role.ID,
+		}); err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionRoleAssigned, audit.TargetUser, userID)
+		event.Diff = map[string]audit.Change{"role": {To: role.Name}}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to assign role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role assigned successfully"})
+}
+
+// RemoveUserRole takes a role away from a user. Their outstanding access
+// tokens are revoked so the loss of access is immediate. The admin role cannot
+// be taken from its last holder.
+func (h *RoleHandler) RemoveUserRole(c *gin.Context) {
+	userID, ok := h.parseUserID(c)
+	if !ok {
+		return
+	}
+
+	role, err := h.store.GetRoleByName(c.Request.Context(), c.Param("role"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove role"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		rows, err := removeUserRole(ctx, q, userID, role)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return errRoleNotHeld
+		}
+
+		event := auditEvent(c, audit.ActionRoleRemoved, audit.TargetUser, userID)
+		event.Diff = map[string]audit.Change{"role": {From: role.Name}}
+		return audit.Record(ctx, q, event)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errRoleNotHeld):
+			c.JSON(http.StatusNotFound, gin.H{"error": "user does not have this role"})
+		case errors.Is(err, errLastAdmin):
+			c.JSON(http.StatusConflict, gin.H{"error": "user is the last admin"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove role"})
+		}
+		return
+	}
+
+	if err := h.revocations.RevokeUser(ctx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role removed successfully"})
+}
+
+// parseUserID reads the :id path parameter and checks the user exists,
+// writing the error response itself when it does not
+func (h *RoleHandler) parseUserID(c *gin.Context) (int64, bool) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return 0, false
+	}
+
+	if _, err := h.store.GetUserByID(c.Request.Context(), userID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return 0, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// removeUserRole takes role away from userID, returning the number of rows
+// removed. It refuses to leave the built-in admin role without holders.
+func removeUserRole(ctx context.Context, q sqlc.Querier, userID int64, role sqlc.Role) (int64, error) {
+	if role.Name == builtinAdminRole {
+		// Lock the role so concurrent removals cannot take it from everyone
+		if _, err := q.GetRoleForUpdate(ctx, role.ID); err != nil {
+			return 0, err
+		}
+		holders, err := q.ListRoleUserIDs(ctx, role.ID)
+		if err != nil {
+			return 0, err
+		}
+		if len(holders) == 1 && holders[0] == userID {
+			return 0, errLastAdmin
+		}
+	}
+
+	return q.RemoveUserRole(ctx, sqlc.RemoveUserRoleParams{
+		UserID: userID,
+		RoleID: role.ID,
+	})
+}
+
+// revokeUsers revokes the access tokens of every user in userIDs so changed
+// permissions apply on their next refresh
+func (h *RoleHandler) revokeUsers(ctx context.Context, userIDs []int64) error {
+	for _, userID := range userIDs {
+		if err := h.revocations.RevokeUser(ctx, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// respondRoleError maps role errors to responses, falling back to a 500 with message
+func respondRoleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, errRoleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+	case errors.Is(err, errRoleExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "role already exists"})
+	case errors.Is(err, errUnknownPermission):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errBuiltinRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the admin role cannot be deleted"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// setRolePermissions grants each named permission to roleID
+func setRolePermissions(ctx context.Context, q sqlc.Querier, roleID int64, permissions []string) error {
+	seen := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		if seen[permission] {
+			continue
+		}
+		seen[permission] = true
+
+		rows, err := q.AddRolePermission(ctx, sqlc.AddRolePermissionParams{
+			RoleID:     roleID,
+			Permission: permission,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return fmt.Errorf("%w: %s", errUnknownPermission, permission)
+		}
+	}
+	return nil
+}
+
+// newRoleInfo loads the permissions role grants
+func newRoleInfo(ctx context.Context, q sqlc.Querier, role sqlc.Role) (RoleInfo, error) {
+	permissions, err := q.ListRolePermissions(ctx, role.ID)
+	if err != nil {
+		return RoleInfo{}, err
+	}
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return RoleInfo{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissions,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/api/middleware"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+)
+
+func TestRoles(t *testing.T) {
+	s := newTestServer(t, "admin@example.com", "user@example.com")
+	s.store.roles[1] = []string{"admin"}
+	roleHandler := handlers.NewRoleHandler(s.store, s.revocations)
+	s.admin.POST("/roles", middleware.RequirePermission("roles:write"), roleHandler.CreateRole)
+	s.admin.GET("/users/:id/roles", middleware.RequirePermission("roles:read"), roleHandler.GetUserRoles)
+	s.admin.POST("/users/:id/roles", middleware.RequirePermission("roles:write"), roleHandler.AssignUserRole)
+	s.admin.DELETE("/users/:id/roles/:role", middleware.RequirePermission("roles:write"), roleHandler.RemoveUserRole)
+
+	admin := s.login("admin@example.com").AccessToken
+	permissions := func(accessToken string) []string {
+		claims, err := s.jwtManager.ValidateToken(accessToken)
+		require.NoError(t, err)
+		return claims.Permissions
+	}
+
+	t.Run("creating a role", func(t *testing.T) {
+		w := s.do(http.MethodPost, "/api/v1/admin/roles", admin, map[string]interface{}{
+			"name":        "support",
+			"permissions": []string{"users:read", "users:read"},
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var role handlers.RoleInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &role))
+		assert.Equal(t, "support", role.Name)
+		assert.Equal(t, []string{"users:read"}, role.Permissions)
+		assert.Contains(t, s.store.actions(), audit.ActionRoleCreated)
+
+		w = s.do(http.MethodPost, "/api/v1/admin/roles", admin, map[string]interface{}{"name": "support"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = s.do(http.MethodPost, "/api/v1/admin/roles", admin, map[string]interface{}{
+			"name":        "billing",
+			"permissions": []string{"invoices:read"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("assigning a role applies on the next login", func(t *testing.T) {
+		before := s.login("user@example.com").AccessToken
+
+		w := s.do(http.MethodPost, "/api/v1/admin/users/2/roles", admin, map[string]string{"role": "support"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, s.store.actions(), audit.ActionRoleAssigned)
+
+		w = s.do(http.MethodGet, "/api/v1/admin/users/2/roles", admin, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"roles": ["support"], "permissions": ["users:read"]}`, w.Body.String())
+
+		assert.Empty(t, permissions(before))
+		assert.Equal(t, []string{"users:read"}, permissions(s.login("user@example.com").AccessToken))
+
+		w = s.do(http.MethodPost, "/api/v1/admin/users/2/roles", admin, map[string]string{"role": "auditor"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = s.do(http.MethodPost, "/api/v1/admin/users/99/roles", admin, map[string]string{"role": "support"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("roles are managed with roles:write", func(t *testing.T) {
+		user := s.login("user@example.com").AccessToken
+
+		w := s.do(http.MethodPost, "/api/v1/admin/roles", user, map[string]interface{}{"name": "owner"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = s.do(http.MethodPost, "/api/v1/admin/users/2/roles", user, map[string]string{"role": "admin"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, []string{"support"}, s.store.roles[2])
+	})
+
+	t.Run("removing a role revokes the user's tokens", func(t *testing.T) {
+		user := s.login("user@example.com").AccessToken
+
+		w := s.do(http.MethodDelete, "/api/v1/admin/users/2/roles/support", admin, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, s.store.actions(), audit.ActionRoleRemoved)
+		assert.False(t, s.signedIn(user))
+		assert.Empty(t, permissions(s.login("user@example.com").AccessToken))
+
+		w = s.do(http.MethodDelete, "/api/v1/admin/users/2/roles/support", admin, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("the last admin keeps the admin role", func(t *testing.T) {
+		w := s.do(http.MethodDelete, "/api/v1/admin/users/1/roles/admin", admin, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, []string{"admin"}, s.store.roles[1])
+		assert.True(t, s.signedIn(admin))
+
+		// With a second admin, either can step down, but not both
+		w = s.do(http.MethodPost, "/api/v1/admin/users/2/roles", admin, map[string]string{"role": "admin"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		other := s.login("user@example.com").AccessToken
+
+		w = s.do(http.MethodDelete, "/api/v1/admin/users/1/roles/admin", admin, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.False(t, s.signedIn(admin))
+
+		w = s.do(http.MethodDelete, "/api/v1/admin/users/2/roles/admin", other, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, []string{"admin"}, s.store.roles[2])
+	})
+}
@@ -0,0 +1,130 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/api/middleware"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+	"github.com/yourusername/go-sqlc-starter/internal/mail"
+)
+
+// testPassword is the password of the users newTestServer creates
+const testPassword = "password123"
+
+// testLockout locks an account after five failed logins, with no backoff
+// before that
+var testLockout = auth.LockoutPolicy{MaxAttempts: 5, IPMaxAttempts: 100, LockoutDuration: time.Hour}
+
+// testServer wires sign-in, MFA, refresh and authenticated /api/v1/users and
+// /api/v1/admin groups over a memStore, for tests that follow tokens through
+// their lifetime. Mail goes to the outbox.
+type testServer struct {
+	t            *testing.T
+	router       *gin.Engine
+	users        *gin.RouterGroup
+	admin        *gin.RouterGroup
+	store        *memStore
+	outbox       *mail.FileMailer
+	verification *handlers.VerificationHandler
+	cipher       *auth.Cipher
+	jwtManager   *auth.JWTManager
+	revocations  *auth.RevocationList
+	throttle     *auth.LoginThrottle
+	authHandler  *handlers.AuthHandler
+}
+
+// newTestServer creates a server whose store holds an active, verified user
+// with testPassword for each email, numbered from 1
+func newTestServer(t *testing.T, emails ...string) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	hash, err := auth.HashPassword(testPassword)
+	require.NoError(t, err)
+	users := make([]sqlc.User, 0, len(emails))
+	for i, email := range emails {
+		user := sqlc.User{ID: int64(i + 1), Email: email, FullName: "Test User", PasswordHash: hash, IsActive: true}
+		user.EmailVerifiedAt.Valid = true
+		users = append(users, user)
+	}
+
+	s := &testServer{t: t, store: newMemStore(users...)}
+	s.outbox, err = mail.NewFileMailer(t.TempDir(), "noreply@example.com")
+	require.NoError(t, err)
+	s.verification = handlers.NewVerificationHandler(s.store, s.outbox, "http://localhost:3000", time.Hour, zerolog.Nop())
+	s.cipher, err = auth.NewCipher("test-encryption-key")
+	require.NoError(t, err)
+	s.jwtManager = auth.NewJWTManager("test-secret-key", 15*time.Minute, 7*24*time.Hour)
+	s.revocations = auth.NewRevocationList(s.store, 15*time.Minute, time.Second)
+	s.throttle = auth.NewLoginThrottle(s.store, testLockout)
+	mfaHandler := handlers.NewMFAHandler(s.store, s.cipher, "Test")
+	s.authHandler = handlers.NewAuthHandler(s.store, s.jwtManager, s.revocations, s.verification, mfaHandler, s.throttle, &auth.PasswordPolicy{MinLength: 8}, false, false, false, zerolog.Nop())
+
+	s.router = gin.New()
+	s.router.POST("/api/v1/auth/login", s.authHandler.Login)
+	s.router.POST("/api/v1/auth/refresh", s.authHandler.RefreshToken)
+	s.router.POST("/api/v1/auth/mfa/verify", s.authHandler.VerifyMFA)
+	s.users = s.router.Group("/api/v1/users", middleware.AuthRequired(s.jwtManager, s.revocations, auth.NewAPIKeyAuthenticator(s.store)), middleware.SessionRequired())
+	s.users.GET("/me", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	s.admin = s.router.Group("/api/v1/admin", middleware.AuthRequired(s.jwtManager, s.revocations, auth.NewAPIKeyAuthenticator(s.store)), middleware.UserRequired())
+	return s
+}
+
+// do sends a request with a JSON body, authenticated with bearer if set
+func (s *testServer) do(method, path, bearer string, body interface{}) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// login starts a new session for email
+func (s *testServer) login(email string) handlers.AuthResponse {
+	s.t.Helper()
+
+	w := s.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{"email": email, "password": testPassword})
+	require.Equal(s.t, http.StatusOK, w.Code, w.Body.String())
+	var resp handlers.AuthResponse
+	require.NoError(s.t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+// sessionID returns the session an access token belongs to
+func (s *testServer) sessionID(accessToken string) string {
+	s.t.Helper()
+
+	claims, err := s.jwtManager.ValidateToken(accessToken)
+	require.NoError(s.t, err)
+	return claims.SessionID
+}
+
+// mail returns the paths of the messages sent so far, once mail being sent in
+// the background has gone out
+func (s *testServer) mail() []string {
+	s.t.Helper()
+
+	handlers.WaitForBackground()
+	messages, err := s.outbox.Messages()
+	require.NoError(s.t, err)
+	return messages
+}
+
+// signedIn reports whether accessToken is still accepted
+func (s *testServer) signedIn(accessToken string) bool {
+	return s.do(http.MethodGet, "/api/v1/users/me", accessToken, nil).Code == http.StatusNoContent
+}
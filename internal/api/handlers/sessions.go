@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+type SessionHandler struct {
+	store       db.Store
+	revocations *auth.RevocationList
+}
+
+func NewSessionHandler(store db.Store, revocations *auth.RevocationList) *SessionHandler {
+	return &SessionHandler{store: store, revocations: revocations}
+}
+
+// SessionInfo describes one of the user's logins
+type SessionInfo struct {
+	ID         uuid.UUID `json:"id"`
+	DeviceName *string   `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// ListSessions returns the authenticated user's active sessions
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	current := currentSessionID(c)
+
+	tokens, err := h.store.ListUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
+		return
+	}
+
+	sessions := make([]SessionInfo, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, newSessionInfo(token, current))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession logs the authenticated user out of one session, rejecting
+// its access tokens as well as its refresh token
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session ID"})
+		return
+	}
+
+	rows, err := h.store.DeleteUserSession(c.Request.Context(), sqlc.DeleteUserSessionParams{
+		UserID:   userID,
+		FamilyID: id,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	if err := h.revocations.RevokeSession(c.Request.Context(), userID, id.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked successfully"})
+}
+
+// RevokeOtherSessions logs the authenticated user out everywhere except the
+// session making the request
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	current := currentSessionID(c)
+
+	// Sessions are found through their refresh tokens, so revoke their access
+	// tokens before deleting those
+	var sessionID string
+	if current != uuid.Nil {
+		sessionID = current.String()
+	}
+	if err := h.revocations.RevokeOtherSessions(c.Request.Context(), userID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	// Tokens issued before sessions existed can't identify their own, and
+	// uuid.Nil matches no session
+	if err := h.store.DeleteUserSessionsExcept(c.Request.Context(), sqlc.DeleteUserSessionsExceptParams{
+		UserID:   userID,
+		FamilyID: current,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "other sessions revoked successfully"})
+}
+
+// currentSessionID returns the session the request's access token belongs to
+func currentSessionID(c *gin.Context) uuid.UUID {
+	value, _ := c.Get("claims")
+	claims, ok := value.(*auth.Claims)
+	if !ok {
+		return uuid.Nil
+	}
+
+	id, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+// newSessionInfo converts the newest refresh token of a family to its session
+func newSessionInfo(token sqlc.RefreshToken, current uuid.UUID) SessionInfo {
+	info := SessionInfo{
+		ID:         token.FamilyID,
+		UserAgent:  token.UserAgent,
+		IPAddress:  token.IpAddress,
+		CreatedAt:  token.SessionStartedAt,
+		LastUsedAt: token.LastUsedAt,
+		ExpiresAt:  token.ExpiresAt,
+		Current:    token.FamilyID == current,
+	}
+	if token.DeviceName.Valid {
+		info.DeviceName = &token.DeviceName.String
+	}
+	return info
+}
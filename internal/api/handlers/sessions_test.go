@@ -0,0 +1,159 @@
+package handlers_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+func TestListSessions(t *testing.T) {
+	s := newTestServer(t, "user@example.com", "other@example.com")
+	sessionHandler := handlers.NewSessionHandler(s.store, s.revocations)
+	s.users.GET("/me/sessions", sessionHandler.ListSessions)
+
+	laptop := s.login("user@example.com").AccessToken
+	phone := s.login("user@example.com").AccessToken
+	s.login("other@example.com")
+	grant := grantOAuthApp(t, s, 1)
+
+	w := s.do(http.MethodGet, "/api/v1/users/me/sessions", laptop, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Sessions []handlers.SessionInfo `json:"sessions"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	current := map[string]bool{}
+	for _, session := range resp.Sessions {
+		current[session.ID.String()] = session.Current
+	}
+	assert.Equal(t, map[string]bool{
+		s.sessionID(laptop): true,
+		s.sessionID(phone):  false,
+	}, current, "other users' sessions and OAuth grants are not listed")
+	assert.NotContains(t, current, grant.FamilyID.String())
+}
+
+// grantOAuthApp gives an OAuth client a refresh token for userID, the way an
+// authorization code exchange does
+func grantOAuthApp(t *testing.T, s *testServer, userID int64) sqlc.RefreshToken {
+	t.Helper()
+
+	token, err := s.store.CreateOAuthRefreshToken(context.Background(), sqlc.CreateOAuthRefreshTokenParams{
+		UserID:    userID,
+		TokenHash: auth.HashToken(uuid.NewString()),
+		ExpiresAt: time.Now().Add(time.Hour),
+		FamilyID:  uuid.New(),
+		ClientID:  sql.NullInt64{Int64: 1, Valid: true},
+		Scopes:    []string{"openid"},
+	})
+	require.NoError(t, err)
+	return token
+}
+
+func TestRevokeSessions(t *testing.T) {
+	s := newTestServer(t, "user@example.com")
+	sessionHandler := handlers.NewSessionHandler(s.store, s.revocations)
+	s.users.DELETE("/me/sessions", sessionHandler.RevokeOtherSessions)
+	s.users.DELETE("/me/sessions/:id", sessionHandler.RevokeSession)
+
+	laptop := s.login("user@example.com").AccessToken
+	phone := s.login("user@example.com").AccessToken
+	tablet := s.login("user@example.com").AccessToken
+	require.True(t, s.signedIn(phone))
+	grant := grantOAuthApp(t, s, 1)
+
+	t.Run("revoking a session rejects its access tokens", func(t *testing.T) {
+		w := s.do(http.MethodDelete, "/api/v1/users/me/sessions/"+s.sessionID(phone), laptop, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.False(t, s.signedIn(phone))
+		assert.True(t, s.signedIn(laptop))
+		assert.True(t, s.signedIn(tablet))
+
+		w = s.do(http.MethodDelete, "/api/v1/users/me/sessions/"+s.sessionID(phone), laptop, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("revoking other sessions keeps the current one", func(t *testing.T) {
+		w := s.do(http.MethodDelete, "/api/v1/users/me/sessions", laptop, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.False(t, s.signedIn(tablet))
+		assert.True(t, s.signedIn(laptop))
+	})
+
+	t.Run("OAuth grants are not sessions", func(t *testing.T) {
+		w := s.do(http.MethodDelete, "/api/v1/users/me/sessions/"+grant.FamilyID.String(), laptop, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		_, err := s.store.GetRefreshToken(context.Background(), grant.TokenHash)
+		assert.NoError(t, err, "revoking sessions leaves the grant")
+		assert.False(t, s.store.revokedSessions[grant.FamilyID.String()])
+	})
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t, "user@example.com")
+	s.router.POST("/api/v1/auth/logout", s.authHandler.Logout)
+	refresh := func(refreshToken string) *httptest.ResponseRecorder {
+		return s.do(http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refresh_token": refreshToken})
+	}
+
+	first := s.login("user@example.com")
+	other := s.login("user@example.com")
+
+	w := refresh(first.RefreshToken)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var rotated handlers.AuthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rotated))
+
+	// Without an access token, the refresh token still ends its session
+	w = s.do(http.MethodPost, "/api/v1/auth/logout", "", map[string]string{"refresh_token": rotated.RefreshToken})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.False(t, s.signedIn(first.AccessToken), "every access token of the session is revoked")
+	assert.False(t, s.signedIn(rotated.AccessToken))
+	assert.Equal(t, http.StatusUnauthorized, refresh(rotated.RefreshToken).Code)
+	assert.True(t, s.signedIn(other.AccessToken), "other sessions are untouched")
+
+	w = s.do(http.MethodPost, "/api/v1/auth/logout", "", map[string]string{"refresh_token": "unknown"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	s := newTestServer(t, "user@example.com")
+	refresh := func(refreshToken string) *httptest.ResponseRecorder {
+		return s.do(http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refresh_token": refreshToken})
+	}
+
+	victim := s.login("user@example.com")
+	other := s.login("user@example.com")
+
+	// Whoever stole the refresh token rotates it first
+	w := refresh(victim.RefreshToken)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var attacker handlers.AuthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &attacker))
+	require.True(t, s.signedIn(attacker.AccessToken))
+
+	// The victim's refresh gives the theft away
+	w = refresh(victim.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	assert.False(t, s.signedIn(attacker.AccessToken), "access tokens of the family are revoked")
+	assert.False(t, s.signedIn(victim.AccessToken))
+	assert.Equal(t, http.StatusUnauthorized, refresh(attacker.RefreshToken).Code)
+	assert.True(t, s.signedIn(other.AccessToken), "other sessions are untouched")
+}
@@ -0,0 +1,1278 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// memStore is an in-memory db.Store shared by the handler tests. It answers
+// the queries the tested handlers make; any other query panics. ExecTx runs
+// the function against the store itself, so nothing is rolled back, but
+// confines it to the organization in ctx as row-level security does.
+type memStore struct {
+	sqlc.Querier
+
+	users       map[int64]sqlc.User
+	roles       map[int64][]string
+	permissions map[int64][]string
+	mfaEnabled  map[int64]bool
+	events      []sqlc.CreateAuditEventParams
+	auditErr    error
+
+	// definedRoles are the roles that exist and rolePermissions what each
+	// grants. Users hold roles by name in roles, and permissions on top of
+	// theirs in permissions.
+	definedRoles    []sqlc.Role
+	rolePermissions map[int64][]string
+
+	resetTokens        []sqlc.PasswordResetToken
+	magicLinks         []sqlc.MagicLinkToken
+	verificationTokens []sqlc.EmailVerificationToken
+
+	revokedJTIs     map[string]bool
+	revokedSessions map[string]bool
+	refreshTokens   []sqlc.RefreshToken
+
+	loginAttempts map[string]sqlc.LoginAttempt
+	totp          map[int64]sqlc.TotpCredential
+	mfaChallenges []sqlc.MfaChallenge
+
+	identities []sqlc.Identity
+	states     map[string]sqlc.ExternalLoginState
+
+	credentials []sqlc.WebauthnCredential
+	challenges  map[string]sqlc.WebauthnChallenge
+
+	clients  []sqlc.OauthClient
+	codes    map[string]sqlc.OauthAuthorizationCode
+	consents []sqlc.OauthConsent
+
+	invitations   []sqlc.Invitation
+	organizations map[int64]sqlc.Organization
+	members       []sqlc.OrganizationMember
+
+	// orgID is the organization the running transaction is confined to, or
+	// 0 outside one
+	orgID int64
+}
+
+func newMemStore(users ...sqlc.User) *memStore {
+	s := &memStore{
+		users:           map[int64]sqlc.User{},
+		roles:           map[int64][]string{},
+		permissions:     map[int64][]string{},
+		definedRoles:    []sqlc.Role{{ID: 1, Name: "admin"}},
+		rolePermissions: map[int64][]string{1: knownPermissions},
+		mfaEnabled:      map[int64]bool{},
+		revokedJTIs:     map[string]bool{},
+		revokedSessions: map[string]bool{},
+		loginAttempts:   map[string]sqlc.LoginAttempt{},
+		totp:            map[int64]sqlc.TotpCredential{},
+		states:          map[string]sqlc.ExternalLoginState{},
+		challenges:      map[string]sqlc.WebauthnChallenge{},
+		codes:           map[string]sqlc.OauthAuthorizationCode{},
+		organizations:   map[int64]sqlc.Organization{},
+	}
+	for _, user := range users {
+		s.users[user.ID] = user
+	}
+	return s
+}
+
+func (s *memStore) ExecTx(ctx context.Context, fn func(sqlc.Querier) error) error {
+	if orgID, ok := db.OrgIDFromContext(ctx); ok {
+		s.orgID = orgID
+		defer func() { s.orgID = 0 }()
+	}
+	return fn(s)
+}
+
+// visible reports whether rows of orgID are visible to the running
+// transaction
+func (s *memStore) visible(orgID int64) bool {
+	return s.orgID == 0 || s.orgID == orgID
+}
+
+// nextUserID returns an ID no stored user has
+func (s *memStore) nextUserID() int64 {
+	var id int64
+	for existing := range s.users {
+		if existing > id {
+			id = existing
+		}
+	}
+	return id + 1
+}
+
+func (s *memStore) GetUserByID(ctx context.Context, id int64) (sqlc.User, error) {
+	user, ok := s.users[id]
+	if !ok || !user.IsActive {
+		return sqlc.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *memStore) GetUserByEmail(ctx context.Context, email string) (sqlc.User, error) {
+	for _, user := range s.users {
+		if user.Email == email && user.IsActive {
+			return user, nil
+		}
+	}
+	return sqlc.User{}, sql.ErrNoRows
+}
+
+func (s *memStore) CreateUser(ctx context.Context, arg sqlc.CreateUserParams) (sqlc.User, error) {
+	for _, user := range s.users {
+		if user.Email == arg.Email {
+			return sqlc.User{}, &pq.Error{Code: "23505"}
+		}
+	}
+	user := sqlc.User{
+		ID:           s.nextUserID(),
+		Email:        arg.Email,
+		PasswordHash: arg.PasswordHash,
+		FullName:     arg.FullName,
+		IsActive:     true,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *memStore) RehashUserPassword(ctx context.Context, arg sqlc.RehashUserPasswordParams) error {
+	user, ok := s.users[arg.ID]
+	if ok && user.PasswordHash == arg.OldHash {
+		user.PasswordHash = arg.PasswordHash
+		s.users[arg.ID] = user
+	}
+	return nil
+}
+
+func (s *memStore) CreateExternalUser(ctx context.Context, arg sqlc.CreateExternalUserParams) (sqlc.User, error) {
+	for _, user := range s.users {
+		if user.Email == arg.Email {
+			return sqlc.User{}, &pq.Error{Code: "23505"}
+		}
+	}
+	user := sqlc.User{
+		ID:              s.nextUserID(),
+		Email:           arg.Email,
+		FullName:        arg.FullName,
+		IsActive:        true,
+		EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	s.users[user.ID] = user
+	if _, err := s.CreateIdentity(ctx, sqlc.CreateIdentityParams{
+		UserID:   user.ID,
+		Provider: arg.Provider,
+		Subject:  arg.Subject,
+		Email:    arg.Email,
+	}); err != nil {
+		return sqlc.User{}, err
+	}
+	return user, nil
+}
+
+func (s *memStore) ListUserRoles(ctx context.Context, userID int64) ([]string, error) {
+	return append([]string{}, s.roles[userID]...), nil
+}
+
+func (s *memStore) ListUserPermissions(ctx context.Context, userID int64) ([]string, error) {
+	permissions := append([]string{}, s.permissions[userID]...)
+	for _, name := range s.roles[userID] {
+		if role, err := s.GetRoleByName(ctx, name); err == nil {
+			permissions = append(permissions, s.rolePermissions[role.ID]...)
+		}
+	}
+	return permissions, nil
+}
+
+// knownPermissions are the permissions a role can grant
+var knownPermissions = []string{
+	"audit:read", "clients:read", "clients:write", "roles:read", "roles:write",
+	"users:delete", "users:impersonate", "users:read", "users:write",
+}
+
+func (s *memStore) CreateRole(ctx context.Context, arg sqlc.CreateRoleParams) (sqlc.Role, error) {
+	if _, err := s.GetRoleByName(ctx, arg.Name); err == nil {
+		return sqlc.Role{}, &pq.Error{Code: "23505"}
+	}
+	role := sqlc.Role{
+		ID:          int64(len(s.definedRoles) + 1),
+		Name:        arg.Name,
+		Description: arg.Description,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	s.definedRoles = append(s.definedRoles, role)
+	return role, nil
+}
+
+func (s *memStore) GetRoleByID(ctx context.Context, id int64) (sqlc.Role, error) {
+	for _, role := range s.definedRoles {
+		if role.ID == id {
+			return role, nil
+		}
+	}
+	return sqlc.Role{}, sql.ErrNoRows
+}
+
+func (s *memStore) GetRoleForUpdate(ctx context.Context, id int64) (sqlc.Role, error) {
+	return s.GetRoleByID(ctx, id)
+}
+
+func (s *memStore) GetRoleByName(ctx context.Context, name string) (sqlc.Role, error) {
+	for _, role := range s.definedRoles {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+	return sqlc.Role{}, sql.ErrNoRows
+}
+
+func (s *memStore) AddRolePermission(ctx context.Context, arg sqlc.AddRolePermissionParams) (int64, error) {
+	for _, permission := range knownPermissions {
+		if permission == arg.Permission {
+			s.rolePermissions[arg.RoleID] = append(s.rolePermissions[arg.RoleID], permission)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *memStore) ListRolePermissions(ctx context.Context, roleID int64) ([]string, error) {
+	permissions := append([]string{}, s.rolePermissions[roleID]...)
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+func (s *memStore) ListRoleUserIDs(ctx context.Context, roleID int64) ([]int64, error) {
+	role, err := s.GetRoleByID(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []int64
+	for userID, names := range s.roles {
+		for _, name := range names {
+			if name == role.Name {
+				userIDs = append(userIDs, userID)
+			}
+		}
+	}
+	return userIDs, nil
+}
+
+func (s *memStore) AssignUserRole(ctx context.Context, arg sqlc.AssignUserRoleParams) error {
+	role, err := s.GetRoleByID(ctx, arg.RoleID)
+	if err != nil {
+		return err
+	}
+	for _, name := range s.roles[arg.UserID] {
+		if name == role.Name {
+			return nil
+		}
+	}
+	s.roles[arg.UserID] = append(s.roles[arg.UserID], role.Name)
+	return nil
+}
+
+func (s *memStore) RemoveUserRole(ctx context.Context, arg sqlc.RemoveUserRoleParams) (int64, error) {
+	role, err := s.GetRoleByID(ctx, arg.RoleID)
+	if err != nil {
+		return 0, err
+	}
+	for i, name := range s.roles[arg.UserID] {
+		if name == role.Name {
+			s.roles[arg.UserID] = append(s.roles[arg.UserID][:i:i], s.roles[arg.UserID][i+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *memStore) IsMFAEnabled(ctx context.Context, userID int64) (bool, error) {
+	return s.mfaEnabled[userID], nil
+}
+
+func (s *memStore) CreateAuditEvent(ctx context.Context, arg sqlc.CreateAuditEventParams) error {
+	if s.auditErr != nil {
+		return s.auditErr
+	}
+	s.events = append(s.events, arg)
+	return nil
+}
+
+// actions lists the actions of the recorded audit events in order
+func (s *memStore) actions() []string {
+	actions := make([]string, 0, len(s.events))
+	for _, event := range s.events {
+		actions = append(actions, event.Action)
+	}
+	return actions
+}
+
+func (s *memStore) AdminGetUser(ctx context.Context, id int64) (sqlc.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return sqlc.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *memStore) AdminGetUserForUpdate(ctx context.Context, id int64) (sqlc.User, error) {
+	return s.AdminGetUser(ctx, id)
+}
+
+func (s *memStore) AdminUpdateUser(ctx context.Context, arg sqlc.AdminUpdateUserParams) (sqlc.User, error) {
+	user, ok := s.users[arg.ID]
+	if !ok {
+		return sqlc.User{}, sql.ErrNoRows
+	}
+	user.Email = arg.Email
+	user.FullName = arg.FullName
+	user.IsAdmin = arg.IsAdmin
+	user.EmailVerifiedAt = arg.EmailVerifiedAt
+	user.UpdatedAt = time.Now()
+	s.users[arg.ID] = user
+	return user, nil
+}
+
+func (s *memStore) SetUserActive(ctx context.Context, arg sqlc.SetUserActiveParams) (sqlc.User, error) {
+	user, ok := s.users[arg.ID]
+	if !ok {
+		return sqlc.User{}, sql.ErrNoRows
+	}
+	user.IsActive = arg.IsActive
+	user.UpdatedAt = time.Now()
+	s.users[arg.ID] = user
+	return user, nil
+}
+
+func (s *memStore) ClearUserPassword(ctx context.Context, id int64) error {
+	user := s.users[id]
+	user.PasswordHash = ""
+	s.users[id] = user
+	return nil
+}
+
+// HardDeleteUser also removes what the database deletes along with the user
+func (s *memStore) HardDeleteUser(ctx context.Context, id int64) (int64, error) {
+	if _, ok := s.users[id]; !ok {
+		return 0, nil
+	}
+	delete(s.users, id)
+	delete(s.roles, id)
+	delete(s.permissions, id)
+	s.deleteRefreshTokens(func(token sqlc.RefreshToken) bool { return token.UserID == id })
+	return 1, nil
+}
+
+func (s *memStore) UpdateUserPassword(ctx context.Context, arg sqlc.UpdateUserPasswordParams) error {
+	user := s.users[arg.ID]
+	user.PasswordHash = arg.PasswordHash
+	s.users[arg.ID] = user
+	return nil
+}
+
+func (s *memStore) CreatePasswordResetToken(ctx context.Context, arg sqlc.CreatePasswordResetTokenParams) (sqlc.PasswordResetToken, error) {
+	token := sqlc.PasswordResetToken{
+		ID:        int64(len(s.resetTokens) + 1),
+		UserID:    arg.UserID,
+		TokenHash: arg.TokenHash,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	s.resetTokens = append(s.resetTokens, token)
+	return token, nil
+}
+
+func (s *memStore) GetPasswordResetTokenForUpdate(ctx context.Context, tokenHash string) (sqlc.PasswordResetToken, error) {
+	for _, token := range s.resetTokens {
+		if token.TokenHash == tokenHash && !token.UsedAt.Valid && token.ExpiresAt.After(time.Now()) {
+			return token, nil
+		}
+	}
+	return sqlc.PasswordResetToken{}, sql.ErrNoRows
+}
+
+func (s *memStore) MarkPasswordResetTokenUsed(ctx context.Context, id int64) error {
+	for i := range s.resetTokens {
+		if s.resetTokens[i].ID == id {
+			s.resetTokens[i].UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+func (s *memStore) DeleteUserPasswordResetTokens(ctx context.Context, userID int64) error {
+	kept := s.resetTokens[:0]
+	for _, token := range s.resetTokens {
+		if token.UserID != userID {
+			kept = append(kept, token)
+		}
+	}
+	s.resetTokens = kept
+	return nil
+}
+
+func (s *memStore) DeleteExpiredPasswordResetTokens(ctx context.Context) error {
+	return nil
+}
+
+func (s *memStore) CreateMagicLinkToken(ctx context.Context, arg sqlc.CreateMagicLinkTokenParams) (sqlc.MagicLinkToken, error) {
+	link := sqlc.MagicLinkToken{
+		ID:        int64(len(s.magicLinks) + 1),
+		UserID:    arg.UserID,
+		Email:     arg.Email,
+		TokenHash: arg.TokenHash,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	s.magicLinks = append(s.magicLinks, link)
+	return link, nil
+}
+
+func (s *memStore) GetMagicLinkToken(ctx context.Context, tokenHash string) (sqlc.MagicLinkToken, error) {
+	for _, link := range s.magicLinks {
+		if link.TokenHash == tokenHash && !link.UsedAt.Valid && link.ExpiresAt.After(time.Now()) {
+			return link, nil
+		}
+	}
+	return sqlc.MagicLinkToken{}, sql.ErrNoRows
+}
+
+func (s *memStore) ConsumeMagicLinkToken(ctx context.Context, tokenHash string) (sqlc.MagicLinkToken, error) {
+	link, err := s.GetMagicLinkToken(ctx, tokenHash)
+	if err != nil {
+		return link, err
+	}
+	link.UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	s.magicLinks[link.ID-1] = link
+	return link, nil
+}
+
+func (s *memStore) DeleteUserMagicLinkTokens(ctx context.Context, userID int64) error {
+	return nil
+}
+
+func (s *memStore) DeleteExpiredMagicLinkTokens(ctx context.Context) error {
+	return nil
+}
+
+func (s *memStore) CreateRefreshToken(ctx context.Context, arg sqlc.CreateRefreshTokenParams) (sqlc.RefreshToken, error) {
+	token := sqlc.RefreshToken{
+		ID:               int64(len(s.refreshTokens) + 1),
+		UserID:           arg.UserID,
+		TokenHash:        arg.TokenHash,
+		ExpiresAt:        arg.ExpiresAt,
+		FamilyID:         arg.FamilyID,
+		UserAgent:        arg.UserAgent,
+		IpAddress:        arg.IpAddress,
+		DeviceName:       arg.DeviceName,
+		SessionStartedAt: arg.SessionStartedAt,
+		ActiveOrgID:      arg.ActiveOrgID,
+	}
+	s.refreshTokens = append(s.refreshTokens, token)
+	return token, nil
+}
+
+func (s *memStore) CreateOAuthRefreshToken(ctx context.Context, arg sqlc.CreateOAuthRefreshTokenParams) (sqlc.RefreshToken, error) {
+	token := sqlc.RefreshToken{
+		ID:               int64(len(s.refreshTokens) + 1),
+		UserID:           arg.UserID,
+		TokenHash:        arg.TokenHash,
+		ExpiresAt:        arg.ExpiresAt,
+		FamilyID:         arg.FamilyID,
+		UserAgent:        arg.UserAgent,
+		IpAddress:        arg.IpAddress,
+		ClientID:         arg.ClientID,
+		Scopes:           arg.Scopes,
+		SessionStartedAt: time.Now(),
+	}
+	s.refreshTokens = append(s.refreshTokens, token)
+	return token, nil
+}
+
+func (s *memStore) GetRefreshTokenForUpdate(ctx context.Context, tokenHash string) (sqlc.RefreshToken, error) {
+	for _, token := range s.refreshTokens {
+		if token.TokenHash == tokenHash && token.ExpiresAt.After(time.Now()) {
+			return token, nil
+		}
+	}
+	return sqlc.RefreshToken{}, sql.ErrNoRows
+}
+
+func (s *memStore) GetRefreshToken(ctx context.Context, tokenHash string) (sqlc.RefreshToken, error) {
+	return s.GetRefreshTokenForUpdate(ctx, tokenHash)
+}
+
+func (s *memStore) ConsumeRefreshToken(ctx context.Context, id int64) (int64, error) {
+	for i, token := range s.refreshTokens {
+		if token.ID == id && !token.ConsumedAt.Valid {
+			s.refreshTokens[i].ConsumedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// deleteRefreshTokens removes the refresh tokens match returns true for
+func (s *memStore) deleteRefreshTokens(match func(sqlc.RefreshToken) bool) int64 {
+	kept := s.refreshTokens[:0]
+	for _, token := range s.refreshTokens {
+		if !match(token) {
+			kept = append(kept, token)
+		}
+	}
+	deleted := int64(len(s.refreshTokens) - len(kept))
+	s.refreshTokens = kept
+	return deleted
+}
+
+func (s *memStore) DeleteRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	s.deleteRefreshTokens(func(token sqlc.RefreshToken) bool { return token.FamilyID == familyID })
+	return nil
+}
+
+func (s *memStore) ListUserSessions(ctx context.Context, userID int64) ([]sqlc.RefreshToken, error) {
+	var sessions []sqlc.RefreshToken
+	for _, token := range s.refreshTokens {
+		if token.UserID == userID && !token.ClientID.Valid && !token.ConsumedAt.Valid && token.ExpiresAt.After(time.Now()) {
+			sessions = append(sessions, token)
+		}
+	}
+	return sessions, nil
+}
+
+func (s *memStore) DeleteUserSession(ctx context.Context, arg sqlc.DeleteUserSessionParams) (int64, error) {
+	return s.deleteRefreshTokens(func(token sqlc.RefreshToken) bool {
+		return token.UserID == arg.UserID && !token.ClientID.Valid && token.FamilyID == arg.FamilyID
+	}), nil
+}
+
+func (s *memStore) DeleteUserSessionsExcept(ctx context.Context, arg sqlc.DeleteUserSessionsExceptParams) error {
+	s.deleteRefreshTokens(func(token sqlc.RefreshToken) bool {
+		return token.UserID == arg.UserID && !token.ClientID.Valid && token.FamilyID != arg.FamilyID
+	})
+	return nil
+}
+
+func (s *memStore) DeleteUserRefreshTokensExceptFamily(ctx context.Context, arg sqlc.DeleteUserRefreshTokensExceptFamilyParams) error {
+	s.deleteRefreshTokens(func(token sqlc.RefreshToken) bool {
+		return token.UserID == arg.UserID && token.FamilyID != arg.FamilyID
+	})
+	return nil
+}
+
+func (s *memStore) DeleteUserRefreshTokens(ctx context.Context, userID int64) error {
+	s.deleteRefreshTokens(func(token sqlc.RefreshToken) bool { return token.UserID == userID })
+	return nil
+}
+
+func (s *memStore) RevokeSession(ctx context.Context, arg sqlc.RevokeSessionParams) error {
+	s.revokedSessions[arg.SessionID] = true
+	return nil
+}
+
+func (s *memStore) RevokeUserSessionsExcept(ctx context.Context, arg sqlc.RevokeUserSessionsExceptParams) error {
+	for _, token := range s.refreshTokens {
+		if token.UserID == arg.UserID && !token.ClientID.Valid && token.FamilyID.String() != arg.ExceptSessionID {
+			s.revokedSessions[token.FamilyID.String()] = true
+		}
+	}
+	return nil
+}
+
+func (s *memStore) DeleteExpiredRevokedSessions(ctx context.Context) error {
+	return nil
+}
+
+func (s *memStore) IsTokenRevoked(ctx context.Context, arg sqlc.IsTokenRevokedParams) (bool, error) {
+	if s.revokedJTIs[arg.Jti] || s.revokedSessions[arg.SessionID] {
+		return true, nil
+	}
+	if arg.ClientID != "" {
+		if _, err := s.GetOAuthClientByClientID(ctx, arg.ClientID); err != nil {
+			return true, nil
+		}
+	}
+	if arg.UserID == 0 {
+		return false, nil
+	}
+	user, ok := s.users[arg.UserID]
+	if !ok {
+		return true, nil
+	}
+	return user.TokensInvalidBefore.Valid && !arg.IssuedAt.After(user.TokensInvalidBefore.Time), nil
+}
+
+func (s *memStore) RevokeToken(ctx context.Context, arg sqlc.RevokeTokenParams) error {
+	s.revokedJTIs[arg.Jti] = true
+	return nil
+}
+
+func (s *memStore) InvalidateUserTokens(ctx context.Context, arg sqlc.InvalidateUserTokensParams) error {
+	user, ok := s.users[arg.ID]
+	if ok {
+		user.TokensInvalidBefore = sql.NullTime{Time: arg.TokensInvalidBefore, Valid: true}
+		s.users[arg.ID] = user
+	}
+	return nil
+}
+
+func (s *memStore) DeleteExpiredRevokedTokens(ctx context.Context) error {
+	return nil
+}
+
+func (s *memStore) GetLoginAttempt(ctx context.Context, key string) (sqlc.LoginAttempt, error) {
+	attempt, ok := s.loginAttempts[key]
+	if !ok {
+		return sqlc.LoginAttempt{}, sql.ErrNoRows
+	}
+	return attempt, nil
+}
+
+func (s *memStore) RecordLoginFailure(ctx context.Context, arg sqlc.RecordLoginFailureParams) (sqlc.LoginAttempt, error) {
+	attempt, ok := s.loginAttempts[arg.Key]
+	if !ok || attempt.LastFailureAt.Before(arg.ResetBefore) {
+		attempt = sqlc.LoginAttempt{Key: arg.Key, BlockedUntil: attempt.BlockedUntil}
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = arg.FailedAt
+	s.loginAttempts[arg.Key] = attempt
+	return attempt, nil
+}
+
+func (s *memStore) BlockLoginKey(ctx context.Context, arg sqlc.BlockLoginKeyParams) error {
+	if attempt, ok := s.loginAttempts[arg.Key]; ok {
+		attempt.BlockedUntil = sql.NullTime{Time: arg.BlockedUntil, Valid: true}
+		s.loginAttempts[arg.Key] = attempt
+	}
+	return nil
+}
+
+func (s *memStore) DeleteLoginAttempt(ctx context.Context, key string) (int64, error) {
+	if _, ok := s.loginAttempts[key]; !ok {
+		return 0, nil
+	}
+	delete(s.loginAttempts, key)
+	return 1, nil
+}
+
+func (s *memStore) GetTOTPCredentialForUpdate(ctx context.Context, userID int64) (sqlc.TotpCredential, error) {
+	cred, ok := s.totp[userID]
+	if !ok {
+		return sqlc.TotpCredential{}, sql.ErrNoRows
+	}
+	return cred, nil
+}
+
+func (s *memStore) UpdateTOTPLastUsedStep(ctx context.Context, arg sqlc.UpdateTOTPLastUsedStepParams) error {
+	cred := s.totp[arg.UserID]
+	cred.LastUsedStep = arg.LastUsedStep
+	s.totp[arg.UserID] = cred
+	return nil
+}
+
+func (s *memStore) UseRecoveryCode(ctx context.Context, arg sqlc.UseRecoveryCodeParams) (int64, error) {
+	return 0, nil
+}
+
+func (s *memStore) CreateMFAChallenge(ctx context.Context, arg sqlc.CreateMFAChallengeParams) (sqlc.MfaChallenge, error) {
+	challenge := sqlc.MfaChallenge{
+		ID:         int64(len(s.mfaChallenges) + 1),
+		UserID:     arg.UserID,
+		TokenHash:  arg.TokenHash,
+		DeviceName: arg.DeviceName,
+		ExpiresAt:  arg.ExpiresAt,
+		CreatedAt:  time.Now(),
+	}
+	s.mfaChallenges = append(s.mfaChallenges, challenge)
+	return challenge, nil
+}
+
+func (s *memStore) GetMFAChallengeForUpdate(ctx context.Context, tokenHash string) (sqlc.MfaChallenge, error) {
+	for _, challenge := range s.mfaChallenges {
+		if challenge.TokenHash == tokenHash && challenge.ExpiresAt.After(time.Now()) {
+			return challenge, nil
+		}
+	}
+	return sqlc.MfaChallenge{}, sql.ErrNoRows
+}
+
+func (s *memStore) IncrementMFAChallengeAttempts(ctx context.Context, id int64) (int32, error) {
+	for i := range s.mfaChallenges {
+		if s.mfaChallenges[i].ID == id {
+			s.mfaChallenges[i].Attempts++
+			return s.mfaChallenges[i].Attempts, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (s *memStore) DeleteMFAChallenge(ctx context.Context, id int64) error {
+	for i := range s.mfaChallenges {
+		if s.mfaChallenges[i].ID == id {
+			// Keep IDs unique by expiring rather than removing
+			s.mfaChallenges[i].ExpiresAt = time.Time{}
+		}
+	}
+	return nil
+}
+
+func (s *memStore) DeleteExpiredMFAChallenges(ctx context.Context) error {
+	return nil
+}
+
+func (s *memStore) DeleteStaleLoginAttempts(ctx context.Context, before time.Time) error {
+	return nil
+}
+
+func (s *memStore) CreateEmailVerificationToken(ctx context.Context, arg sqlc.CreateEmailVerificationTokenParams) (sqlc.EmailVerificationToken, error) {
+	token := sqlc.EmailVerificationToken{
+		ID:        int64(len(s.verificationTokens) + 1),
+		UserID:    arg.UserID,
+		Email:     arg.Email,
+		TokenHash: arg.TokenHash,
+		ExpiresAt: arg.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	s.verificationTokens = append(s.verificationTokens, token)
+	return token, nil
+}
+
+func (s *memStore) GetEmailVerificationTokenForUpdate(ctx context.Context, tokenHash string) (sqlc.EmailVerificationToken, error) {
+	for _, token := range s.verificationTokens {
+		if token.TokenHash == tokenHash && !token.UsedAt.Valid && token.ExpiresAt.After(time.Now()) {
+			return token, nil
+		}
+	}
+	return sqlc.EmailVerificationToken{}, sql.ErrNoRows
+}
+
+func (s *memStore) MarkEmailVerificationTokenUsed(ctx context.Context, id int64) error {
+	for i := range s.verificationTokens {
+		if s.verificationTokens[i].ID == id {
+			s.verificationTokens[i].UsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+func (s *memStore) DeleteUserEmailVerificationTokens(ctx context.Context, arg sqlc.DeleteUserEmailVerificationTokensParams) error {
+	return nil
+}
+
+func (s *memStore) DeleteExpiredEmailVerificationTokens(ctx context.Context) error {
+	return nil
+}
+
+func (s *memStore) VerifyUserEmail(ctx context.Context, arg sqlc.VerifyUserEmailParams) (sqlc.User, error) {
+	user, ok := s.users[arg.ID]
+	if !ok || user.Email != arg.Email {
+		return sqlc.User{}, sql.ErrNoRows
+	}
+	user.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *memStore) CreateExternalLoginState(ctx context.Context, arg sqlc.CreateExternalLoginStateParams) error {
+	s.states[arg.StateHash] = sqlc.ExternalLoginState{
+		StateHash:    arg.StateHash,
+		Provider:     arg.Provider,
+		Nonce:        arg.Nonce,
+		CodeVerifier: arg.CodeVerifier,
+		UserID:       arg.UserID,
+		ExpiresAt:    arg.ExpiresAt,
+	}
+	return nil
+}
+
+func (s *memStore) DeleteExpiredExternalLoginStates(ctx context.Context) error {
+	return nil
+}
+
+func (s *memStore) ConsumeExternalLoginState(ctx context.Context, arg sqlc.ConsumeExternalLoginStateParams) (sqlc.ExternalLoginState, error) {
+	state, ok := s.states[arg.StateHash]
+	if !ok || state.Provider != arg.Provider {
+		return sqlc.ExternalLoginState{}, sql.ErrNoRows
+	}
+	delete(s.states, arg.StateHash)
+	return state, nil
+}
+
+func (s *memStore) GetIdentity(ctx context.Context, arg sqlc.GetIdentityParams) (sqlc.Identity, error) {
+	for _, identity := range s.identities {
+		if identity.Provider == arg.Provider && identity.Subject == arg.Subject {
+			return identity, nil
+		}
+	}
+	return sqlc.Identity{}, sql.ErrNoRows
+}
+
+func (s *memStore) TouchIdentity(ctx context.Context, arg sqlc.TouchIdentityParams) error {
+	return nil
+}
+
+func (s *memStore) CreateIdentity(ctx context.Context, arg sqlc.CreateIdentityParams) (sqlc.Identity, error) {
+	for _, identity := range s.identities {
+		if (identity.Provider == arg.Provider && identity.Subject == arg.Subject) ||
+			(identity.Provider == arg.Provider && identity.UserID == arg.UserID) {
+			return sqlc.Identity{}, &pq.Error{Code: "23505"}
+		}
+	}
+	identity := sqlc.Identity{
+		ID:        int64(len(s.identities) + 1),
+		UserID:    arg.UserID,
+		Provider:  arg.Provider,
+		Subject:   arg.Subject,
+		Email:     arg.Email,
+		CreatedAt: time.Now(),
+	}
+	s.identities = append(s.identities, identity)
+	return identity, nil
+}
+
+func (s *memStore) CreateWebAuthnChallenge(ctx context.Context, arg sqlc.CreateWebAuthnChallengeParams) error {
+	s.challenges[arg.ChallengeHash] = sqlc.WebauthnChallenge{
+		ChallengeHash: arg.ChallengeHash,
+		Ceremony:      arg.Ceremony,
+		UserID:        arg.UserID,
+		ExpiresAt:     arg.ExpiresAt,
+	}
+	return nil
+}
+
+func (s *memStore) DeleteExpiredWebAuthnChallenges(ctx context.Context) error {
+	return nil
+}
+
+func (s *memStore) ConsumeWebAuthnChallenge(ctx context.Context, arg sqlc.ConsumeWebAuthnChallengeParams) (sqlc.WebauthnChallenge, error) {
+	challenge, ok := s.challenges[arg.ChallengeHash]
+	if !ok || challenge.Ceremony != arg.Ceremony {
+		return sqlc.WebauthnChallenge{}, sql.ErrNoRows
+	}
+	delete(s.challenges, arg.ChallengeHash)
+	return challenge, nil
+}
+
+func (s *memStore) ListUserWebAuthnCredentials(ctx context.Context, userID int64) ([]sqlc.WebauthnCredential, error) {
+	credentials := []sqlc.WebauthnCredential{}
+	for _, credential := range s.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (s *memStore) CreateWebAuthnCredential(ctx context.Context, arg sqlc.CreateWebAuthnCredentialParams) (sqlc.WebauthnCredential, error) {
+	for _, credential := range s.credentials {
+		if bytes.Equal(credential.CredentialID, arg.CredentialID) {
+			return sqlc.WebauthnCredential{}, &pq.Error{Code: "23505"}
+		}
+	}
+	credential := sqlc.WebauthnCredential{
+		ID:             int64(len(s.credentials) + 1),
+		UserID:         arg.UserID,
+		CredentialID:   arg.CredentialID,
+		PublicKey:      arg.PublicKey,
+		SignCount:      arg.SignCount,
+		Transports:     arg.Transports,
+		Aaguid:         arg.Aaguid,
+		BackupEligible: arg.BackupEligible,
+		BackedUp:       arg.BackedUp,
+		Nickname:       arg.Nickname,
+		CreatedAt:      time.Now(),
+	}
+	s.credentials = append(s.credentials, credential)
+	return credential, nil
+}
+
+func (s *memStore) GetWebAuthnCredential(ctx context.Context, credentialID []byte) (sqlc.WebauthnCredential, error) {
+	for _, credential := range s.credentials {
+		if bytes.Equal(credential.CredentialID, credentialID) {
+			return credential, nil
+		}
+	}
+	return sqlc.WebauthnCredential{}, sql.ErrNoRows
+}
+
+func (s *memStore) UpdateWebAuthnCredentialUsage(ctx context.Context, arg sqlc.UpdateWebAuthnCredentialUsageParams) (int64, error) {
+	for i, credential := range s.credentials {
+		if credential.ID != arg.ID {
+			continue
+		}
+		if credential.SignCount >= arg.SignCount && (credential.SignCount != 0 || arg.SignCount != 0) {
+			return 0, nil
+		}
+		s.credentials[i].SignCount = arg.SignCount
+		s.credentials[i].BackedUp = arg.BackedUp
+		s.credentials[i].LastUsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (s *memStore) RenameUserWebAuthnCredential(ctx context.Context, arg sqlc.RenameUserWebAuthnCredentialParams) (sqlc.WebauthnCredential, error) {
+	for i := range s.credentials {
+		if s.credentials[i].ID == arg.ID && s.credentials[i].UserID == arg.UserID {
+			s.credentials[i].Nickname = arg.Nickname
+			return s.credentials[i], nil
+		}
+	}
+	return sqlc.WebauthnCredential{}, sql.ErrNoRows
+}
+
+func (s *memStore) DeleteUserWebAuthnCredential(ctx context.Context, arg sqlc.DeleteUserWebAuthnCredentialParams) (int64, error) {
+	for i, credential := range s.credentials {
+		if credential.ID == arg.ID && credential.UserID == arg.UserID {
+			s.credentials = append(s.credentials[:i], s.credentials[i+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *memStore) CreateOAuthClient(ctx context.Context, arg sqlc.CreateOAuthClientParams) (sqlc.OauthClient, error) {
+	client := sqlc.OauthClient{
+		ID:               int64(len(s.clients) + 1),
+		ClientID:         arg.ClientID,
+		ClientSecretHash: arg.ClientSecretHash,
+		Name:             arg.Name,
+		RedirectUris:     arg.RedirectUris,
+		GrantTypes:       arg.GrantTypes,
+		Scopes:           arg.Scopes,
+		Trusted:          arg.Trusted,
+		CreatedAt:        time.Now(),
+	}
+	s.clients = append(s.clients, client)
+	return client, nil
+}
+
+func (s *memStore) GetOAuthClientByClientID(ctx context.Context, clientID string) (sqlc.OauthClient, error) {
+	for _, client := range s.clients {
+		if client.ClientID == clientID {
+			return client, nil
+		}
+	}
+	return sqlc.OauthClient{}, sql.ErrNoRows
+}
+
+func (s *memStore) DeleteOAuthClient(ctx context.Context, clientID string) (int64, error) {
+	for i, client := range s.clients {
+		if client.ClientID != clientID {
+			continue
+		}
+		s.clients = append(s.clients[:i], s.clients[i+1:]...)
+		s.deleteRefreshTokens(func(token sqlc.RefreshToken) bool { return token.ClientID.Int64 == client.ID })
+		kept := s.consents[:0]
+		for _, consent := range s.consents {
+			if consent.ClientID != client.ID {
+				kept = append(kept, consent)
+			}
+		}
+		s.consents = kept
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (s *memStore) DeleteOAuthClientRefreshTokens(ctx context.Context, clientID string) ([]sqlc.DeleteOAuthClientRefreshTokensRow, error) {
+	sessions := []sqlc.DeleteOAuthClientRefreshTokensRow{}
+	client, err := s.GetOAuthClientByClientID(ctx, clientID)
+	if err != nil {
+		return sessions, nil
+	}
+	s.deleteRefreshTokens(func(token sqlc.RefreshToken) bool {
+		if token.ClientID.Int64 != client.ID {
+			return false
+		}
+		sessions = append(sessions, sqlc.DeleteOAuthClientRefreshTokensRow{UserID: token.UserID, FamilyID: token.FamilyID})
+		return true
+	})
+	return sessions, nil
+}
+
+func (s *memStore) UpsertOAuthConsent(ctx context.Context, arg sqlc.UpsertOAuthConsentParams) error {
+	for i, consent := range s.consents {
+		if consent.UserID == arg.UserID && consent.ClientID == arg.ClientID {
+			s.consents[i].Scopes = arg.Scopes
+			s.consents[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	s.consents = append(s.consents, sqlc.OauthConsent{
+		UserID:    arg.UserID,
+		ClientID:  arg.ClientID,
+		Scopes:    arg.Scopes,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	return nil
+}
+
+func (s *memStore) DeleteOAuthConsent(ctx context.Context, arg sqlc.DeleteOAuthConsentParams) (int64, error) {
+	for i, consent := range s.consents {
+		if consent.UserID == arg.UserID && consent.ClientID == arg.ClientID {
+			s.consents = append(s.consents[:i], s.consents[i+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *memStore) DeleteUserClientRefreshTokens(ctx context.Context, arg sqlc.DeleteUserClientRefreshTokensParams) ([]uuid.UUID, error) {
+	families := []uuid.UUID{}
+	s.deleteRefreshTokens(func(token sqlc.RefreshToken) bool {
+		if token.UserID != arg.UserID || token.ClientID != arg.ClientID {
+			return false
+		}
+		families = append(families, token.FamilyID)
+		return true
+	})
+	return families, nil
+}
+
+func (s *memStore) CreateAuthorizationCode(ctx context.Context, arg sqlc.CreateAuthorizationCodeParams) error {
+	s.codes[arg.CodeHash] = sqlc.OauthAuthorizationCode{
+		CodeHash:            arg.CodeHash,
+		ClientID:            arg.ClientID,
+		UserID:              arg.UserID,
+		RedirectUri:         arg.RedirectUri,
+		RedirectUriSupplied: arg.RedirectUriSupplied,
+		Scopes:              arg.Scopes,
+		CodeChallenge:       arg.CodeChallenge,
+		Nonce:               arg.Nonce,
+		ExpiresAt:           arg.ExpiresAt,
+	}
+	return nil
+}
+
+func (s *memStore) DeleteExpiredAuthorizationCodes(ctx context.Context) error {
+	return nil
+}
+
+func (s *memStore) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (sqlc.OauthAuthorizationCode, error) {
+	code, ok := s.codes[codeHash]
+	if !ok {
+		return sqlc.OauthAuthorizationCode{}, sql.ErrNoRows
+	}
+	delete(s.codes, codeHash)
+	return code, nil
+}
+
+// openInvitation reports whether inv can still be accepted or claimed
+func openInvitation(inv sqlc.Invitation) bool {
+	return !inv.AcceptedAt.Valid && !inv.ClaimedBy.Valid && inv.ExpiresAt.After(time.Now())
+}
+
+func (s *memStore) CreateInvitation(ctx context.Context, arg sqlc.CreateInvitationParams) (sqlc.Invitation, error) {
+	inv := sqlc.Invitation{
+		ID:          int64(len(s.invitations) + 1),
+		TokenHash:   arg.TokenHash,
+		OrgID:       arg.OrgID,
+		OrgRole:     arg.OrgRole,
+		RoleID:      arg.RoleID,
+		Email:       arg.Email,
+		EmailDomain: arg.EmailDomain,
+		InvitedBy:   arg.InvitedBy,
+		ExpiresAt:   arg.ExpiresAt,
+		CreatedAt:   time.Now(),
+	}
+	s.invitations = append(s.invitations, inv)
+	return inv, nil
+}
+
+func (s *memStore) ListOrganizationInvitations(ctx context.Context, orgID sql.NullInt64) ([]sqlc.Invitation, error) {
+	var invitations []sqlc.Invitation
+	for _, inv := range s.invitations {
+		if inv.OrgID == orgID && s.visible(inv.OrgID.Int64) && !inv.AcceptedAt.Valid && inv.ExpiresAt.After(time.Now()) {
+			invitations = append(invitations, inv)
+		}
+	}
+	return invitations, nil
+}
+
+func (s *memStore) DeleteOrganizationInvitation(ctx context.Context, arg sqlc.DeleteOrganizationInvitationParams) (sqlc.Invitation, error) {
+	for i, inv := range s.invitations {
+		if inv.ID == arg.ID && inv.OrgID == arg.OrgID && s.visible(inv.OrgID.Int64) && !inv.AcceptedAt.Valid {
+			s.invitations = append(s.invitations[:i], s.invitations[i+1:]...)
+			return inv, nil
+		}
+	}
+	return sqlc.Invitation{}, sql.ErrNoRows
+}
+
+func (s *memStore) DeleteExpiredInvitations(ctx context.Context) error {
+	return nil
+}
+
+func (s *memStore) GetInvitation(ctx context.Context, tokenHash string) (sqlc.Invitation, error) {
+	for _, inv := range s.invitations {
+		if inv.TokenHash == tokenHash && openInvitation(inv) {
+			return inv, nil
+		}
+	}
+	return sqlc.Invitation{}, sql.ErrNoRows
+}
+
+func (s *memStore) AcceptInvitation(ctx context.Context, arg sqlc.AcceptInvitationParams) (sqlc.Invitation, error) {
+	for i, inv := range s.invitations {
+		if inv.TokenHash == arg.TokenHash && openInvitation(inv) {
+			inv.AcceptedBy = arg.AcceptedBy
+			inv.AcceptedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			s.invitations[i] = inv
+			return inv, nil
+		}
+	}
+	return sqlc.Invitation{}, sql.ErrNoRows
+}
+
+func (s *memStore) ClaimInvitation(ctx context.Context, arg sqlc.ClaimInvitationParams) (sqlc.Invitation, error) {
+	for i, inv := range s.invitations {
+		if inv.TokenHash == arg.TokenHash && openInvitation(inv) {
+			inv.ClaimedBy = arg.ClaimedBy
+			s.invitations[i] = inv
+			return inv, nil
+		}
+	}
+	return sqlc.Invitation{}, sql.ErrNoRows
+}
+
+func (s *memStore) ListClaimedInvitations(ctx context.Context, claimedBy sql.NullInt64) ([]sqlc.Invitation, error) {
+	var claimed []sqlc.Invitation
+	for _, inv := range s.invitations {
+		if inv.ClaimedBy == claimedBy && !inv.AcceptedAt.Valid {
+			claimed = append(claimed, inv)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *memStore) AcceptClaimedInvitation(ctx context.Context, id int64) (sqlc.Invitation, error) {
+	for i, inv := range s.invitations {
+		if inv.ID == id && inv.ClaimedBy.Valid && !inv.AcceptedAt.Valid {
+			inv.AcceptedBy = inv.ClaimedBy
+			inv.AcceptedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			s.invitations[i] = inv
+			return inv, nil
+		}
+	}
+	return sqlc.Invitation{}, sql.ErrNoRows
+}
+
+func (s *memStore) GetOrganization(ctx context.Context, id int64) (sqlc.Organization, error) {
+	org, ok := s.organizations[id]
+	if !ok || !s.visible(id) {
+		return sqlc.Organization{}, sql.ErrNoRows
+	}
+	return org, nil
+}
+
+func (s *memStore) GetOrganizationForUpdate(ctx context.Context, id int64) (sqlc.Organization, error) {
+	return s.GetOrganization(ctx, id)
+}
+
+func (s *memStore) GetOrganizationMember(ctx context.Context, arg sqlc.GetOrganizationMemberParams) (sqlc.OrganizationMember, error) {
+	for _, member := range s.members {
+		if member.OrgID == arg.OrgID && member.UserID == arg.UserID && s.visible(member.OrgID) {
+			return member, nil
+		}
+	}
+	return sqlc.OrganizationMember{}, sql.ErrNoRows
+}
+
+func (s *memStore) ListOrganizationMembers(ctx context.Context, orgID int64) ([]sqlc.ListOrganizationMembersRow, error) {
+	var members []sqlc.ListOrganizationMembersRow
+	for _, member := range s.members {
+		user, ok := s.users[member.UserID]
+		if member.OrgID != orgID || !s.visible(member.OrgID) || !ok || !user.IsActive {
+			continue
+		}
+		members = append(members, sqlc.ListOrganizationMembersRow{
+			UserID:    member.UserID,
+			Email:     user.Email,
+			FullName:  user.FullName,
+			Role:      member.Role,
+			CreatedAt: member.CreatedAt,
+		})
+	}
+	return members, nil
+}
+
+func (s *memStore) UpdateOrganizationMemberRole(ctx context.Context, arg sqlc.UpdateOrganizationMemberRoleParams) (sqlc.OrganizationMember, error) {
+	for i, member := range s.members {
+		if member.OrgID == arg.OrgID && member.UserID == arg.UserID && s.visible(member.OrgID) {
+			s.members[i].Role = arg.Role
+			return s.members[i], nil
+		}
+	}
+	return sqlc.OrganizationMember{}, sql.ErrNoRows
+}
+
+func (s *memStore) RemoveOrganizationMember(ctx context.Context, arg sqlc.RemoveOrganizationMemberParams) (int64, error) {
+	for i, member := range s.members {
+		if member.OrgID == arg.OrgID && member.UserID == arg.UserID && s.visible(member.OrgID) {
+			s.members = append(s.members[:i], s.members[i+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *memStore) CountOrganizationOwners(ctx context.Context, orgID int64) (int64, error) {
+	var count int64
+	for _, member := range s.members {
+		if member.OrgID == orgID && member.Role == "owner" && s.visible(member.OrgID) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memStore) SetSessionActiveOrganization(ctx context.Context, arg sqlc.SetSessionActiveOrganizationParams) error {
+	for i, token := range s.refreshTokens {
+		if token.FamilyID == arg.FamilyID && token.UserID == arg.UserID {
+			s.refreshTokens[i].ActiveOrgID = arg.ActiveOrgID
+		}
+	}
+	return nil
+}
+
+func (s *memStore) AddOrganizationMember(ctx context.Context, arg sqlc.AddOrganizationMemberParams) (sqlc.OrganizationMember, error) {
+	if _, err := s.GetOrganizationMember(ctx, sqlc.GetOrganizationMemberParams{OrgID: arg.OrgID, UserID: arg.UserID}); err == nil {
+		return sqlc.OrganizationMember{}, &pq.Error{Code: "23505"}
+	}
+	member := sqlc.OrganizationMember{OrgID: arg.OrgID, UserID: arg.UserID, Role: arg.Role, CreatedAt: time.Now()}
+	s.members = append(s.members, member)
+	return member, nil
+}
+
+func (s *memStore) CountSoleOwnedOrganizations(ctx context.Context, userID int64) (int64, error) {
+	var count int64
+	for _, member := range s.members {
+		if member.UserID != userID || member.Role != "owner" {
+			continue
+		}
+		sole := true
+		for _, other := range s.members {
+			if other.OrgID == member.OrgID && other.Role == "owner" && other.UserID != userID {
+				sole = false
+			}
+		}
+		if sole {
+			count++
+		}
+	}
+	return count, nil
+}
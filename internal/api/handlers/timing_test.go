@@ -0,0 +1,222 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/handlers"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+	"github.com/yourusername/go-sqlc-starter/internal/mail"
+)
+
+// The timing tests check each path does the same password work, which is
+// what dominates the time. Response times themselves are compared too, within
+// a tolerance; that is noisy, so it is skipped in short mode.
+
+const (
+	// timingSamples is how many requests are timed per path. Samples of the
+	// two paths alternate, so load from other tests slows both alike.
+	timingSamples = 21
+
+	// timingTolerance is how far apart the median response times of two
+	// paths may be, as a fraction of the slower one
+	timingTolerance = 0.25
+)
+
+// countingHasher counts the hashes and checks made with it
+type countingHasher struct {
+	auth.Hasher
+	hashes   atomic.Int32
+	verifies atomic.Int32
+}
+
+func (h *countingHasher) Hash(password string) (string, error) {
+	h.hashes.Add(1)
+	return h.Hasher.Hash(password)
+}
+
+func (h *countingHasher) Verify(password, hash string) error {
+	h.verifies.Add(1)
+	return h.Hasher.Verify(password, hash)
+}
+
+func (h *countingHasher) reset() {
+	h.hashes.Store(0)
+	h.verifies.Store(0)
+}
+
+// useCountingHasher makes new passwords hashed, and unknown accounts checked,
+// with a counting hasher for the rest of the test
+func useCountingHasher(t *testing.T) *countingHasher {
+	t.Helper()
+
+	hasher := &countingHasher{Hasher: auth.Argon2idHasher{Memory: 8 * 1024, Time: 1, Parallelism: 1}}
+	auth.SetPasswordHasher(hasher)
+	t.Cleanup(func() { auth.SetPasswordHasher(auth.DefaultArgon2idHasher()) })
+	return hasher
+}
+
+func TestLoginTimingDoesNotRevealAccounts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hasher := useCountingHasher(t)
+	hash, err := auth.HashPassword("password123")
+	require.NoError(t, err)
+	router := setupTimingRouter(t, newMemStore(sqlc.User{ID: 1, Email: "known@example.com", PasswordHash: hash, IsActive: true}))
+
+	wrongPassword := postJSON(router, "/api/v1/auth/login", map[string]interface{}{"email": "known@example.com", "password": "wrongpassword"})
+
+	hasher.reset()
+	unknown := postJSON(router, "/api/v1/auth/login", map[string]interface{}{"email": "unknown@example.com", "password": "wrongpassword"})
+
+	assert.Equal(t, http.StatusUnauthorized, unknown.Code)
+	assert.Equal(t, wrongPassword.Code, unknown.Code)
+	assert.JSONEq(t, wrongPassword.Body.String(), unknown.Body.String())
+	assert.Equal(t, int32(1), hasher.verifies.Load(), "an unknown email is checked against a dummy hash")
+}
+
+func TestRegisterTimingDoesNotRevealAccounts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hasher := useCountingHasher(t)
+	router := setupTimingRouter(t, newMemStore(sqlc.User{ID: 1, Email: "known@example.com", IsActive: true}))
+
+	created := postJSON(router, "/api/v1/auth/register", map[string]interface{}{"email": "new@example.com", "password": "password123", "full_name": "Test User"})
+	assert.Equal(t, int32(1), hasher.hashes.Load())
+
+	hasher.reset()
+	existing := postJSON(router, "/api/v1/auth/register", map[string]interface{}{"email": "known@example.com", "password": "password123", "full_name": "Test User"})
+
+	assert.Equal(t, http.StatusCreated, existing.Code)
+	assert.Equal(t, created.Code, existing.Code)
+	assert.JSONEq(t, created.Body.String(), existing.Body.String())
+	assert.Equal(t, int32(1), hasher.hashes.Load(), "the password is hashed for a taken email too")
+}
+
+func TestLoginResponseTimeDoesNotRevealAccounts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping timing test in short mode")
+	}
+	gin.SetMode(gin.TestMode)
+
+	hash, err := auth.HashPassword("password123")
+	require.NoError(t, err)
+	router := setupTimingRouter(t, newMemStore(sqlc.User{ID: 1, Email: "known@example.com", PasswordHash: hash, IsActive: true}))
+
+	unknown, wrongPassword := timeRequests(t, router, "/api/v1/auth/login", func(int) map[string]interface{} {
+		return map[string]interface{}{"email": "unknown@example.com", "password": "wrongpassword"}
+	}, func(int) map[string]interface{} {
+		return map[string]interface{}{"email": "known@example.com", "password": "wrongpassword"}
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, unknown.status)
+	assert.Equal(t, wrongPassword.status, unknown.status)
+	assertSimilarTiming(t, wrongPassword.median, unknown.median)
+}
+
+func TestRegisterResponseTimeDoesNotRevealAccounts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping timing test in short mode")
+	}
+	gin.SetMode(gin.TestMode)
+
+	router := setupTimingRouter(t, newMemStore(sqlc.User{ID: 1, Email: "known@example.com", IsActive: true}))
+
+	// Every sample registers a fresh account
+	existing, created := timeRequests(t, router, "/api/v1/auth/register", func(int) map[string]interface{} {
+		return map[string]interface{}{"email": "known@example.com", "password": "password123", "full_name": "Test User"}
+	}, func(i int) map[string]interface{} {
+		return map[string]interface{}{"email": fmt.Sprintf("new%d@example.com", i), "password": "password123", "full_name": "Test User"}
+	})
+
+	assert.Equal(t, http.StatusCreated, existing.status)
+	assert.Equal(t, created.status, existing.status)
+	assertSimilarTiming(t, created.median, existing.median)
+}
+
+// setupTimingRouter wires login and registration with email concealment on
+func setupTimingRouter(t *testing.T, store *memStore) *gin.Engine {
+	t.Helper()
+
+	mailer, err := mail.NewFileMailer(t.TempDir(), "noreply@example.com")
+	require.NoError(t, err)
+
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, 7*24*time.Hour)
+	throttle := auth.NewLoginThrottle(store, auth.LockoutPolicy{})
+	verificationHandler := handlers.NewVerificationHandler(store, mailer, "http://localhost:3000", 24*time.Hour, zerolog.Nop())
+	authHandler := handlers.NewAuthHandler(store, jwtManager, nil, verificationHandler, nil, throttle, &auth.PasswordPolicy{MinLength: 8}, true, true, false, zerolog.Nop())
+
+	router := gin.New()
+	router.POST("/api/v1/auth/register", authHandler.Register)
+	router.POST("/api/v1/auth/login", authHandler.Login)
+	return router
+}
+
+// postJSON posts body to path
+func postJSON(router *gin.Engine, path string, body map[string]interface{}) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+type timingResult struct {
+	status int
+	median time.Duration
+}
+
+// timeRequests posts the i-th body of a and of b to path in turn and returns
+// the median duration of each. One untimed request of each warms up first.
+func timeRequests(t *testing.T, router *gin.Engine, path string, a, b func(i int) map[string]interface{}) (timingResult, timingResult) {
+	t.Helper()
+
+	post := func(body map[string]interface{}) (int, time.Duration) {
+		start := time.Now()
+		w := postJSON(router, path, body)
+		return w.Code, time.Since(start)
+	}
+
+	post(a(-1))
+	post(b(-1))
+
+	var results [2]timingResult
+	var durations [2][]time.Duration
+	for i := 0; i < timingSamples; i++ {
+		for j, body := range []func(int) map[string]interface{}{a, b} {
+			status, elapsed := post(body(i))
+			durations[j] = append(durations[j], elapsed)
+			results[j].status = status
+		}
+	}
+
+	for j := range results {
+		sort.Slice(durations[j], func(x, y int) bool { return durations[j][x] < durations[j][y] })
+		results[j].median = durations[j][len(durations[j])/2]
+	}
+	return results[0], results[1]
+}
+
+func assertSimilarTiming(t *testing.T, a, b time.Duration) {
+	t.Helper()
+
+	diff, slower := a-b, a
+	if diff < 0 {
+		diff, slower = -diff, b
+	}
+	assert.LessOrEqualf(t, float64(diff), timingTolerance*float64(slower),
+		"response times differ too much: %s vs %s", a, b)
+}
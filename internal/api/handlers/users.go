@@ -6,16 +6,23 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
 	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
 )
 
 type UserHandler struct {
-	queries *sqlc.Queries
+	store        db.Store
+	revocations  *auth.RevocationList
+	verification *VerificationHandler
 }
 
-func NewUserHandler(queries *sqlc.Queries) *UserHandler {
+func NewUserHandler(store db.Store, revocations *auth.RevocationList, verification *VerificationHandler) *UserHandler {
 	return &UserHandler{
-		queries: queries,
+		store:        store,
+		revocations:  revocations,
+		verification: verification,
 	}
 }
 
@@ -29,7 +36,7 @@ type UpdateUserRequest struct {
 func (h *UserHandler) GetCurrentUser(c *gin.Context) {
 	userID := c.GetInt64("user_id")
 
-	user, err := h.queries.GetUserByID(c.Request.Context(), userID)
+	user, err := h.store.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
@@ -40,16 +47,18 @@ func (h *UserHandler) GetCurrentUser(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":         user.ID,
-		"email":      user.Email,
-		"full_name":  user.FullName,
-		"is_admin":   user.IsAdmin,
-		"created_at": user.CreatedAt,
-		"updated_at": user.UpdatedAt,
+		"id":             user.ID,
+		"email":          user.Email,
+		"email_verified": user.EmailVerifiedAt.Valid,
+		"full_name":      user.FullName,
+		"is_admin":       user.IsAdmin,
+		"created_at":     user.CreatedAt,
+		"updated_at":     user.UpdatedAt,
 	})
 }
 
-// UpdateCurrentUser updates the authenticated user's information
+// UpdateCurrentUser updates the authenticated user's information. A new email
+// only takes effect once the user follows the link sent to it.
 func (h *UserHandler) UpdateCurrentUser(c *gin.Context) {
 	userID := c.GetInt64("user_id")
 
@@ -59,11 +68,44 @@ func (h *UserHandler) UpdateCurrentUser(c *gin.Context) {
 		return
 	}
 
+	// Refuse an address that already belongs to another account up front
+	if req.Email != nil {
+		existing, err := h.store.GetUserByEmail(c.Request.Context(), *req.Email)
+		if err == nil && existing.ID != userID {
+			c.JSON(http.StatusConflict, gin.H{"error": "email already in use"})
+			return
+		}
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
+			return
+		}
+	}
+
 	// Update user
-	user, err := h.queries.UpdateUser(c.Request.Context(), sqlc.UpdateUserParams{
-		ID:       userID,
-		FullName: req.FullName,
-		Email:    req.Email,
+	var user sqlc.User
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		previous, err := q.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		user, err = q.UpdateUser(ctx, sqlc.UpdateUserParams{
+			ID:       userID,
+			FullName: req.FullName,
+		})
+		if err != nil {
+			return err
+		}
+		if user.FullName == previous.FullName {
+			return nil
+		}
+
+		event := auditEvent(c, audit.ActionUserUpdated, audit.TargetUser, userID)
+		event.Diff = map[string]audit.Change{
+			"full_name": {From: previous.FullName, To: user.FullName},
+		}
+		return audit.Record(ctx, q, event)
 	})
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -74,24 +116,56 @@ func (h *UserHandler) UpdateCurrentUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":         user.ID,
-		"email":      user.Email,
-		"full_name":  user.FullName,
-		"is_admin":   user.IsAdmin,
-		"updated_at": user.UpdatedAt,
-	})
+	resp := gin.H{
+		"id":             user.ID,
+		"email":          user.Email,
+		"email_verified": user.EmailVerifiedAt.Valid,
+		"full_name":      user.FullName,
+		"is_admin":       user.IsAdmin,
+		"updated_at":     user.UpdatedAt,
+	}
+
+	// Email changes wait for the new address to be verified
+	if req.Email != nil && *req.Email != user.Email {
+		if err := h.verification.sendVerification(c.Request.Context(), user, *req.Email); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification email"})
+			return
+		}
+		resp["pending_email"] = *req.Email
+	}
+
+	c.JSON(http.StatusOK, resp)
 }
 
 // DeleteCurrentUser soft-deletes the authenticated user's account
 func (h *UserHandler) DeleteCurrentUser(c *gin.Context) {
 	userID := c.GetInt64("user_id")
 
-	if err := h.queries.DeleteUser(c.Request.Context(), userID); err != nil {
+	err := h.store.ExecTx(c.Request.Context(), func(q sqlc.Querier) error {
+		if err := q.DeleteUser(c.Request.Context(), userID); err != nil {
+			return err
+		}
+		if err := q.DeleteUserRefreshTokens(c.Request.Context(), userID); err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionUserDeleted, audit.TargetUser, userID)
+		event.Diff = map[string]audit.Change{
+			"is_active": {From: true, To: false},
+		}
+		return audit.Record(c.Request.Context(), q, event)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user"})
 		return
 	}
 
+	// Kill outstanding access tokens
+	if err := h.revocations.RevokeUser(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "account deleted successfully"})
 }
 
@@ -104,7 +178,7 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		return
 	}
 
-	user, err := h.queries.GetUserByID(c.Request.Context(), id)
+	user, err := h.store.GetUserByID(c.Request.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
@@ -145,7 +219,7 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 	offset := (page - 1) * limit
 
 	// Get users
-	users, err := h.queries.ListUsers(c.Request.Context(), sqlc.ListUsersParams{
+	users, err := h.store.ListUsers(c.Request.Context(), sqlc.ListUsersParams{
 		Limit:  int32(limit),
 		Offset: int32(offset),
 	})
@@ -155,7 +229,7 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 	}
 
 	// Get total count
-	total, err := h.queries.CountUsers(c.Request.Context())
+	total, err := h.store.CountUsers(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count users"})
 		return
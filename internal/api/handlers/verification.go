@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog"
+	"github.com/yourusername/go-sqlc-starter/internal/audit"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+	"github.com/yourusername/go-sqlc-starter/internal/mail"
+)
+
+var (
+	errVerificationTokenInvalid = errors.New("verification token invalid or expired")
+	errEmailTaken               = errors.New("email already in use")
+)
+
+// VerificationHandler proves ownership of email addresses, both on
+// registration and when a user changes their email
+type VerificationHandler struct {
+	store  db.Store
+	mailer mail.Mailer
+	appURL string
+	expiry time.Duration
+	logger zerolog.Logger
+}
+
+func NewVerificationHandler(store db.Store, mailer mail.Mailer, appURL string, expiry time.Duration, logger zerolog.Logger) *VerificationHandler {
+	return &VerificationHandler{
+		store:  store,
+		mailer: mailer,
+		appURL: appURL,
+		expiry: expiry,
+		logger: logger,
+	}
+}
+
+// VerifyEmailRequest represents the verify email request body
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequest represents the resend verification request body
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerifyEmail confirms an address using a token from a verification link.
+// For a pending email change this is when the new address takes effect.
+// Invitations claimed on registration are accepted now.
+func (h *VerificationHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user sqlc.User
+	ctx := c.Request.Context()
+	err := h.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		token, err := q.GetEmailVerificationTokenForUpdate(ctx, auth.HashToken(req.Token))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errVerificationTokenInvalid
+			}
+			return err
+		}
+
+		previous, err := q.GetUserByID(ctx, token.UserID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errVerificationTokenInvalid
+			}
+			return err
+		}
+
+		user, err = q.VerifyUserEmail(ctx, sqlc.VerifyUserEmailParams{
+			Email: token.Email,
+			ID:    token.UserID,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errVerificationTokenInvalid
+			}
+			if isUniqueViolation(err) {
+				return errEmailTaken
+			}
+			return err
+		}
+
+		if err := q.MarkEmailVerificationTokenUsed(ctx, token.ID); err != nil {
+			return err
+		}
+		if err := q.DeleteUserEmailVerificationTokens(ctx, sqlc.DeleteUserEmailVerificationTokensParams{
+			UserID: token.UserID,
+			Email:  token.Email,
+		}); err != nil {
+			return err
+		}
+
+		event := auditEvent(c, audit.ActionEmailVerified, audit.TargetUser, user.ID)
+		event.ActorID = user.ID
+		event.Diff = map[string]audit.Change{
+			"email":          {From: previous.Email, To: user.Email},
+			"email_verified": {From: previous.EmailVerifiedAt.Valid, To: true},
+		}
+		if err := audit.Record(ctx, q, event); err != nil {
+			return err
+		}
+
+		return acceptClaimedInvitations(c, q, user)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errVerificationTokenInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired verification token"})
+		case errors.Is(err, errEmailTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "email already in use"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "email verified successfully",
+		"email":   user.Email,
+	})
+}
+
+// ResendVerification emails a new verification link to an unverified
+// account. The response is the same, and sent before any mail, whether or
+// not the account exists.
+func (h *VerificationHandler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inBackground(c, h.logger, "Failed to resend verification email", func(ctx context.Context) error {
+		user, err := h.store.GetUserByEmail(ctx, req.Email)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+		if user.EmailVerifiedAt.Valid {
+			return nil
+		}
+		return h.sendVerification(ctx, user, user.Email)
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "if the account exists and is unverified, a verification link has been sent"})
+}
+
+// sendVerification stores a token proving user owns email and mails the link
+// to that address
+func (h *VerificationHandler) sendVerification(ctx context.Context, user sqlc.User, email string) error {
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	// Best effort: expired tokens can no longer be redeemed
+	_ = h.store.DeleteExpiredEmailVerificationTokens(ctx)
+
+	if _, err := h.store.CreateEmailVerificationToken(ctx, sqlc.CreateEmailVerificationTokenParams{
+		UserID:    user.ID,
+		Email:     email,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(h.expiry),
+	}); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", h.appURL, url.QueryEscape(token))
+	return h.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm that %s is your email address by following this link "+
+			"within %s:\n\n%s\n\n"+
+			"If you didn't ask for this, you can ignore this email.\n",
+			user.FullName, email, h.expiry, link),
+	})
+}
+
+// sendAccountExists tells the owner of email that someone tried to register
+// with it, without revealing that to the caller
+func (h *VerificationHandler) sendAccountExists(ctx context.Context, email string) error {
+	return h.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "You already have an account",
+		Body: fmt.Sprintf("Hi,\n\n"+
+			"Someone tried to create a new account with %s, which is already registered. "+
+			"If that was you, sign in or reset your password at:\n\n%s\n\n"+
+			"If you didn't ask for this, you can ignore this email.\n",
+			email, h.appURL),
+	})
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
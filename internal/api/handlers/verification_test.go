@@ -0,0 +1,29 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResendVerification(t *testing.T) {
+	s := newTestServer(t, "verified@example.com", "unverified@example.com")
+	user := s.store.users[2]
+	user.EmailVerifiedAt.Valid = false
+	s.store.users[2] = user
+
+	s.router.POST("/api/v1/auth/verify-email/resend", s.verification.ResendVerification)
+
+	var bodies []string
+	for _, email := range []string{"unverified@example.com", "verified@example.com", "nobody@example.com"} {
+		w := s.do(http.MethodPost, "/api/v1/auth/verify-email/resend", "", map[string]string{"email": email})
+		assert.Equal(t, http.StatusOK, w.Code)
+		bodies = append(bodies, w.Body.String())
+	}
+	assert.JSONEq(t, bodies[0], bodies[1])
+	assert.JSONEq(t, bodies[0], bodies[2])
+
+	// The mail goes out after the response
+	assert.Len(t, s.mail(), 1, "only the unverified account is mailed")
+}
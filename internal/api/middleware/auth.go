@@ -1,19 +1,26 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
 )
 
-// AuthRequired is middleware that validates JWT tokens
-func AuthRequired(jwtManager *auth.JWTManager) gin.HandlerFunc {
+// AuthRequired is middleware that authenticates requests with a JWT access
+// token or a personal API key, rejecting revoked tokens. API keys are accepted
+// as "Authorization: ApiKey <key>" or in the X-API-Key header. When an admin
+// is impersonating the user, the admin is exposed as actor_id and actor_email
+// alongside the user.
+func AuthRequired(jwtManager *auth.JWTManager, revocations *auth.RevocationList, apiKeys *auth.APIKeyAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get token from Authorization header
+		// Get credentials from Authorization or X-API-Key header
 		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		apiKey := c.GetHeader("X-API-Key")
+		if authHeader == "" && apiKey == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "authorization header required",
 			})
@@ -21,36 +28,197 @@ func AuthRequired(jwtManager *auth.JWTManager) gin.HandlerFunc {
 			return
 		}
 
-		// Check Bearer format
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "invalid authorization header format",
+		var token string
+		if apiKey == "" {
+			// Check Bearer or ApiKey format
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) != 2 || (parts[0] != "Bearer" && parts[0] != "ApiKey") {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "invalid authorization header format",
+				})
+				c.Abort()
+				return
+			}
+			if parts[0] == "ApiKey" {
+				apiKey = parts[1]
+			} else {
+				token = parts[1]
+			}
+		}
+
+		var claims *auth.Claims
+		if apiKey != "" {
+			// Validate API key
+			var err error
+			claims, err = apiKeys.Authenticate(c.Request.Context(), apiKey, c.ClientIP())
+			if err != nil {
+				if errors.Is(err, auth.ErrInvalidAPIKey) {
+					c.JSON(http.StatusUnauthorized, gin.H{
+						"error": "invalid or expired API key",
+					})
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "failed to verify API key",
+					})
+				}
+				c.Abort()
+				return
+			}
+		} else {
+			// Validate token
+			var err error
+			claims, err = jwtManager.ValidateToken(token)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "invalid or expired token",
+				})
+				c.Abort()
+				return
+			}
+
+			// Check revocation list
+			revoked, err := revocations.IsRevoked(c.Request.Context(), claims)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "failed to verify token",
+				})
+				c.Abort()
+				return
+			}
+			if revoked {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "invalid or expired token",
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		// Store user info in context for handlers to use
+		c.Set("claims", claims)
+		c.Set("user_id", claims.UserID)
+		c.Set("user_email", claims.Email)
+		c.Set("is_admin", claims.IsAdmin)
+		if claims.Actor != nil {
+			c.Set("actor_id", claims.Actor.UserID)
+			c.Set("actor_email", claims.Actor.Email)
+		}
+
+		c.Next()
+	}
+}
+
+// SessionRequired is middleware that refuses requests authenticated with an
+// API key, a token issued to an OAuth client or an impersonation token. It
+// guards account security endpoints, which need the user themselves signed
+// in with their password.
+func SessionRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("claims")
+		claims, ok := value.(*auth.Claims)
+		if !ok || claims.APIKeyID != 0 || claims.ClientID != "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "this endpoint requires a user session",
+			})
+			c.Abort()
+			return
+		}
+		if claims.Actor != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "this endpoint is not available while impersonating",
 			})
 			c.Abort()
 			return
 		}
 
-		// Validate token
-		claims, err := jwtManager.ValidateToken(parts[1])
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "invalid or expired token",
+		c.Next()
+	}
+}
+
+// UserRequired is middleware that refuses tokens a client holds on its own
+// behalf through the client_credentials grant. It guards user administration,
+// which must be traceable to a person.
+func UserRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("claims")
+		claims, ok := value.(*auth.Claims)
+		if !ok || (claims.ClientID != "" && claims.UserID == 0) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "this endpoint requires a user",
 			})
 			c.Abort()
 			return
 		}
 
-		// Store user info in context for handlers to use
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("is_admin", claims.IsAdmin)
+		c.Next()
+	}
+}
+
+// RequirePermission is middleware that checks the access token grants permission
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("claims")
+		claims, ok := value.(*auth.Claims)
+		if !ok || !claims.HasPermission(permission) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "permission denied",
+			})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
 }
 
+// OrgRequired is middleware for organization-scoped routes. It refuses
+// requests without an active organization and exposes the organization as
+// org_id and the user's role there as org_role. With rowLevelSecurity set, the
+// request context also confines database transactions to the organization.
+func OrgRequired(rowLevelSecurity bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("claims")
+		claims, ok := value.(*auth.Claims)
+		if !ok || claims.OrgID == 0 {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "no active organization",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("org_id", claims.OrgID)
+		c.Set("org_role", claims.OrgRole)
+		if rowLevelSecurity {
+			c.Request = c.Request.WithContext(db.WithOrgID(c.Request.Context(), claims.OrgID))
+		}
+
+		c.Next()
+	}
+}
+
+// RequireOrgRole is middleware that checks the user holds one of roles in the
+// active organization. It must run after OrgRequired.
+func RequireOrgRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("org_role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "permission denied",
+		})
+		c.Abort()
+	}
+}
+
 // AdminRequired is middleware that checks if user is an admin
+//
+// Deprecated: is_admin is kept for compatibility; use RequirePermission.
 func AdminRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		isAdmin, exists := c.Get("is_admin")
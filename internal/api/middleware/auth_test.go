@@ -0,0 +1,299 @@
+package middleware_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/api/middleware"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// apiKeyQuerier serves one user and their API keys from memory. No access
+// token is ever revoked.
+type apiKeyQuerier struct {
+	sqlc.Querier
+	keys map[string]sqlc.ApiKey
+}
+
+func (q *apiKeyQuerier) GetAPIKeyByPrefix(ctx context.Context, prefix string) (sqlc.ApiKey, error) {
+	key, ok := q.keys[prefix]
+	if !ok {
+		return sqlc.ApiKey{}, sql.ErrNoRows
+	}
+	return key, nil
+}
+
+func (q *apiKeyQuerier) GetUserByID(ctx context.Context, id int64) (sqlc.User, error) {
+	return sqlc.User{ID: id, Email: "ci@example.com", IsActive: true}, nil
+}
+
+func (q *apiKeyQuerier) ListUserRoles(ctx context.Context, userID int64) ([]string, error) {
+	return []string{"support"}, nil
+}
+
+func (q *apiKeyQuerier) ListUserPermissions(ctx context.Context, userID int64) ([]string, error) {
+	return []string{"users:read"}, nil
+}
+
+func (q *apiKeyQuerier) TouchAPIKey(ctx context.Context, arg sqlc.TouchAPIKeyParams) error {
+	return nil
+}
+
+func (q *apiKeyQuerier) IsTokenRevoked(ctx context.Context, arg sqlc.IsTokenRevokedParams) (bool, error) {
+	return false, nil
+}
+
+func (q *apiKeyQuerier) DeleteExpiredRevokedTokens(ctx context.Context) error {
+	return nil
+}
+
+func (q *apiKeyQuerier) DeleteExpiredRevokedSessions(ctx context.Context) error {
+	return nil
+}
+
+func TestAuthRequiredAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	querier := &apiKeyQuerier{keys: map[string]sqlc.ApiKey{}}
+	addKey := func(id int64, scopes []string, expiresAt sql.NullTime) string {
+		key, prefix, err := auth.GenerateAPIKey()
+		require.NoError(t, err)
+		querier.keys[prefix] = sqlc.ApiKey{
+			ID:        id,
+			UserID:    7,
+			Prefix:    prefix,
+			KeyHash:   auth.HashToken(key),
+			Scopes:    scopes,
+			ExpiresAt: expiresAt,
+		}
+		return key
+	}
+	// Scopes the user no longer holds are ignored
+	key := addKey(1, []string{"users:read", "roles:write"}, sql.NullTime{})
+	expired := addKey(2, nil, sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true})
+
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	router := gin.New()
+	router.Use(middleware.AuthRequired(jwtManager, auth.NewRevocationList(querier, 15*time.Minute, time.Second), auth.NewAPIKeyAuthenticator(querier)))
+	router.GET("/me", func(c *gin.Context) {
+		value, _ := c.Get("claims")
+		claims := value.(*auth.Claims)
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetInt64("user_id"), "perms": claims.Permissions})
+	})
+	router.GET("/password", middleware.SessionRequired(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path string, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/me", "Authorization", "ApiKey "+key)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id": 7, "perms": ["users:read"]}`, w.Body.String())
+
+	w = request("/me", "X-API-Key", key)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = request("/me", "X-API-Key", key[:len(key)-1]+"x")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = request("/me", "X-API-Key", expired)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = request("/me", "Authorization", "Basic "+key)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Account security endpoints need a signed-in user
+	w = request("/password", "X-API-Key", key)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	token, err := jwtManager.GenerateAccessToken(7, "ci@example.com", false)
+	require.NoError(t, err)
+	w = request("/password", "Authorization", "Bearer "+token)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Tokens issued to OAuth clients are refused like API keys
+	token, err = jwtManager.IssueAccessToken(auth.Claims{UserID: 7, ClientID: "reports"})
+	require.NoError(t, err)
+	w = request("/password", "Authorization", "Bearer "+token)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestOrgRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	querier := &apiKeyQuerier{keys: map[string]sqlc.ApiKey{}}
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	router := gin.New()
+	router.Use(middleware.AuthRequired(jwtManager, auth.NewRevocationList(querier, 15*time.Minute, time.Second), auth.NewAPIKeyAuthenticator(querier)))
+	router.Use(middleware.OrgRequired(true))
+	router.GET("/org", func(c *gin.Context) {
+		orgID, _ := db.OrgIDFromContext(c.Request.Context())
+		c.JSON(http.StatusOK, gin.H{"org_id": c.GetInt64("org_id"), "tx_org_id": orgID})
+	})
+	router.DELETE("/org", middleware.RequireOrgRole("owner"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(method string, claims auth.Claims) *httptest.ResponseRecorder {
+		token, err := jwtManager.IssueAccessToken(claims)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, "/org", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A session that has not picked an organization cannot use its routes
+	w := request(http.MethodGet, auth.Claims{UserID: 7})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = request(http.MethodGet, auth.Claims{UserID: 7, OrgID: 3, OrgRole: "member"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"org_id": 3, "tx_org_id": 3}`, w.Body.String())
+
+	w = request(http.MethodDelete, auth.Claims{UserID: 7, OrgID: 3, OrgRole: "member"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = request(http.MethodDelete, auth.Claims{UserID: 7, OrgID: 3, OrgRole: "owner"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAuthRequiredImpersonation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	querier := &apiKeyQuerier{keys: map[string]sqlc.ApiKey{}}
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	router := gin.New()
+	router.Use(middleware.AuthRequired(jwtManager, auth.NewRevocationList(querier, 15*time.Minute, time.Second), auth.NewAPIKeyAuthenticator(querier)))
+	router.GET("/me", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetInt64("user_id"), "actor_id": c.GetInt64("actor_id")})
+	})
+	router.GET("/password", middleware.SessionRequired(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	token, err := jwtManager.IssueAccessTokenWithExpiry(auth.Claims{
+		UserID: 7,
+		Actor:  &auth.Actor{Subject: "1", UserID: 1, Email: "admin@example.com"},
+	}, 5*time.Minute)
+	require.NoError(t, err)
+
+	claims, err := jwtManager.ValidateToken(token)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), claims.ExpiresAt.Time, 5*time.Second)
+
+	request := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Both the user and the admin acting as them are exposed
+	w := request("/me")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id": 7, "actor_id": 1}`, w.Body.String())
+
+	// Account security endpoints refuse impersonation
+	w = request("/password")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// revokingQuerier is an apiKeyQuerier that also honours RevokeUser, rejecting
+// the tokens issued to a user up to their cutoff
+type revokingQuerier struct {
+	apiKeyQuerier
+	invalidBefore map[int64]time.Time
+}
+
+func (q *revokingQuerier) InvalidateUserTokens(ctx context.Context, arg sqlc.InvalidateUserTokensParams) error {
+	q.invalidBefore[arg.ID] = arg.TokensInvalidBefore
+	return nil
+}
+
+func (q *revokingQuerier) IsTokenRevoked(ctx context.Context, arg sqlc.IsTokenRevokedParams) (bool, error) {
+	cutoff, ok := q.invalidBefore[arg.UserID]
+	return ok && !arg.IssuedAt.After(cutoff), nil
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	querier := &revokingQuerier{
+		apiKeyQuerier: apiKeyQuerier{keys: map[string]sqlc.ApiKey{}},
+		invalidBefore: map[int64]time.Time{},
+	}
+	jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+	revocations := auth.NewRevocationList(querier, 15*time.Minute, time.Second)
+	router := gin.New()
+	router.GET("/public", middleware.RequirePermission("users:read"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	authed := router.Group("", middleware.AuthRequired(jwtManager, revocations, auth.NewAPIKeyAuthenticator(querier)))
+	authed.GET("/users", middleware.RequirePermission("users:read"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path string, claims *auth.Claims) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if claims != nil {
+			token, err := jwtManager.IssueAccessToken(*claims)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/users", &auth.Claims{UserID: 7, Permissions: []string{"roles:read", "users:read"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = request("/users", &auth.Claims{UserID: 7, Permissions: []string{"roles:read", "users:write"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"error": "permission denied"}`, w.Body.String())
+
+	// Tokens from before roles existed carry no permissions
+	w = request("/users", &auth.Claims{UserID: 7, IsAdmin: true})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Without authentication there are no claims to check
+	w = request("/public", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// A token still carrying a permission the user lost is rejected once their
+	// tokens are revoked, and the next token reflects their roles
+	token, err := jwtManager.IssueAccessToken(auth.Claims{UserID: 7, Permissions: []string{"users:read"}})
+	require.NoError(t, err)
+	stale := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	require.Equal(t, http.StatusOK, stale())
+
+	require.NoError(t, revocations.RevokeUser(context.Background(), 7))
+	assert.Equal(t, http.StatusUnauthorized, stale())
+
+	w = request("/users", &auth.Claims{UserID: 7})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
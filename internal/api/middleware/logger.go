@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,7 +14,7 @@ func Logger(logger zerolog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
-		raw := c.Request.URL.RawQuery
+		raw := redactQuery(c.Request.URL.RawQuery)
 
 		// Process request
 		c.Next()
@@ -49,10 +51,40 @@ func Logger(logger zerolog.Logger) gin.HandlerFunc {
 			logEvent.Str("error", errorMessage)
 		}
 
+		// Requests made while impersonating name both the user and the admin
+		if actorID := c.GetInt64("actor_id"); actorID != 0 {
+			logEvent.
+				Int64("user_id", c.GetInt64("user_id")).
+				Int64("actor_id", actorID)
+		}
+
 		logEvent.Msg("HTTP request")
 	}
 }
 
+// secretParams are query parameters that carry credentials, such as magic
+// link and invitation tokens or authorization codes, and are kept out of logs
+var secretParams = map[string]bool{
+	"token": true,
+	"code":  true,
+}
+
+// redactQuery replaces the values of secretParams in a raw query string
+func redactQuery(raw string) string {
+	if raw == "" {
+		return raw
+	}
+
+	pairs := strings.Split(raw, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err != nil || secretParams[strings.ToLower(name)] {
+			pairs[i] = key + "=REDACTED"
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
 // Recovery returns a middleware that recovers from panics
 func Recovery(logger zerolog.Logger) gin.HandlerFunc {
 	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
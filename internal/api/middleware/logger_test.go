@@ -0,0 +1,31 @@
+package middleware_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/yourusername/go-sqlc-starter/internal/api/middleware"
+)
+
+func TestLoggerRedactsTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var out bytes.Buffer
+	router := gin.New()
+	router.Use(middleware.Logger(zerolog.New(&out)))
+	router.GET("/verify", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/verify?token=s3cret&next=%2Fhome&co%64e=abc", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	logged := out.String()
+	assert.NotContains(t, logged, "s3cret")
+	assert.NotContains(t, logged, "abc")
+	assert.Contains(t, logged, "token=REDACTED")
+	assert.Contains(t, logged, "next=%2Fhome", "other parameters are kept")
+}
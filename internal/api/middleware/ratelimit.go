@@ -0,0 +1,246 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitAlgorithm selects how requests are counted against a limit
+type RateLimitAlgorithm string
+
+const (
+	// TokenBucket refills tokens continuously and allows short bursts up to the limit
+	TokenBucket RateLimitAlgorithm = "token_bucket"
+
+	// SlidingWindow weights the previous window's count to smooth the fixed window edge
+	SlidingWindow RateLimitAlgorithm = "sliding_window"
+)
+
+// KeyFunc extracts the identity a rate limit is tracked against
+type KeyFunc func(c *gin.Context) string
+
+// KeyByIP tracks limits per client IP address
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUser tracks limits per authenticated user, falling back to the client IP
+// for anonymous requests. It must run after AuthRequired.
+func KeyByUser(c *gin.Context) string {
+	if userID := c.GetInt64("user_id"); userID != 0 {
+		return "user:" + strconv.FormatInt(userID, 10)
+	}
+	return KeyByIP(c)
+}
+
+// KeyByRoute tracks a single shared limit for every caller of a route
+func KeyByRoute(c *gin.Context) string {
+	return "route:" + c.FullPath()
+}
+
+// maxKeyedBody caps how much of a request body KeyByJSONField reads
+const maxKeyedBody = 64 << 10
+
+// KeyByJSONField tracks limits per value of a top-level string field in the
+// JSON request body, such as an email address, compared case-insensitively.
+// Values are hashed so they are never stored. Requests without the field fall
+// back to the client IP. The body is left in place for the handler.
+func KeyByJSONField(field string) KeyFunc {
+	return func(c *gin.Context) string {
+		if c.Request.Body == nil {
+			return KeyByIP(c)
+		}
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxKeyedBody))
+		if err != nil {
+			return KeyByIP(c)
+		}
+		c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+
+		var fields map[string]any
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return KeyByIP(c)
+		}
+		value, ok := fields[field].(string)
+		value = strings.ToLower(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return KeyByIP(c)
+		}
+		digest := sha256.Sum256([]byte(value))
+		return field + ":" + hex.EncodeToString(digest[:])
+	}
+}
+
+// RateLimitRule describes a single limit applied to a group of routes
+type RateLimitRule struct {
+	// Name namespaces the counters so different rules never share a bucket
+	Name      string
+	Requests  int
+	Window    time.Duration
+	Algorithm RateLimitAlgorithm
+	Key       KeyFunc
+}
+
+// RateLimitResult is the outcome of counting a single request
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAt    time.Time
+	RetryAfter time.Duration
+}
+
+// RateLimitStore persists rate limit state. Implementations must apply the
+// rule's algorithm atomically so concurrent requests cannot overspend a bucket.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error)
+}
+
+// RateLimit returns a middleware that enforces rule using store
+func RateLimit(store RateLimitStore, rule RateLimitRule) gin.HandlerFunc {
+	if rule.Key == nil {
+		rule.Key = KeyByIP
+	}
+
+	return func(c *gin.Context) {
+		key := rule.Name + ":" + rule.Key(c)
+
+		result, err := store.Take(c.Request.Context(), key, rule)
+		if err != nil {
+			// Fail open: a broken limiter should not take the API down with it
+			_ = c.Error(fmt.Errorf("rate limit: %w", err))
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "rate limit exceeded",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitState is the per-key state shared by every algorithm and store
+type rateLimitState struct {
+	Tokens      float64
+	WindowStart time.Time
+	WindowCount int
+	PrevCount   int
+	UpdatedAt   time.Time
+}
+
+// newRateLimitState returns the state for a key that has never been seen
+func newRateLimitState(rule RateLimitRule, now time.Time) rateLimitState {
+	return rateLimitState{
+		Tokens:      float64(rule.Requests),
+		WindowStart: now.Truncate(rule.Window),
+		UpdatedAt:   now,
+	}
+}
+
+// take counts one request against state and reports whether it is allowed
+func take(state *rateLimitState, rule RateLimitRule, now time.Time) RateLimitResult {
+	if rule.Algorithm == SlidingWindow {
+		return takeSlidingWindow(state, rule, now)
+	}
+	return takeTokenBucket(state, rule, now)
+}
+
+func takeTokenBucket(state *rateLimitState, rule RateLimitRule, now time.Time) RateLimitResult {
+	capacity := float64(rule.Requests)
+	refillRate := capacity / rule.Window.Seconds() // tokens per second
+
+	elapsed := now.Sub(state.UpdatedAt).Seconds()
+	if elapsed > 0 {
+		state.Tokens = math.Min(capacity, state.Tokens+elapsed*refillRate)
+	}
+	state.UpdatedAt = now
+
+	result := RateLimitResult{Limit: rule.Requests}
+
+	if state.Tokens >= 1 {
+		state.Tokens--
+		result.Allowed = true
+	} else {
+		missing := 1 - state.Tokens
+		result.RetryAfter = time.Duration(missing / refillRate * float64(time.Second))
+	}
+
+	result.Remaining = int(math.Floor(state.Tokens))
+	untilFull := (capacity - state.Tokens) / refillRate
+	result.ResetAt = now.Add(time.Duration(untilFull * float64(time.Second)))
+
+	return result
+}
+
+func takeSlidingWindow(state *rateLimitState, rule RateLimitRule, now time.Time) RateLimitResult {
+	windowStart := now.Truncate(rule.Window)
+
+	// Roll the window forward, discarding counts older than the previous window
+	switch {
+	case windowStart.Equal(state.WindowStart):
+	case windowStart.Sub(state.WindowStart) == rule.Window:
+		state.PrevCount = state.WindowCount
+		state.WindowCount = 0
+	default:
+		state.PrevCount = 0
+		state.WindowCount = 0
+	}
+	state.WindowStart = windowStart
+	state.UpdatedAt = now
+
+	// Weight the previous window by how much of it still overlaps the sliding window
+	overlap := 1 - float64(now.Sub(windowStart))/float64(rule.Window)
+	estimated := float64(state.PrevCount)*overlap + float64(state.WindowCount)
+
+	result := RateLimitResult{
+		Limit:   rule.Requests,
+		ResetAt: windowStart.Add(rule.Window),
+	}
+
+	if estimated+1 <= float64(rule.Requests) {
+		state.WindowCount++
+		estimated++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = result.ResetAt.Sub(now)
+		if state.PrevCount > 0 && state.WindowCount < rule.Requests {
+			// The previous window's weight decays linearly, so a slot frees up
+			// as soon as enough of it has slid out of view
+			needed := (estimated + 1 - float64(rule.Requests)) / float64(state.PrevCount)
+			result.RetryAfter = time.Duration(needed * float64(rule.Window))
+		}
+	}
+
+	result.Remaining = rule.Requests - int(math.Ceil(estimated))
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+
+	return result
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// MemoryRateLimitStore keeps rate limit state in process memory.
+// Counters are not shared between replicas.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryBucket struct {
+	state     rateLimitState
+	expiresAt time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*memoryBucket),
+		now:     time.Now,
+	}
+}
+
+// Take counts one request for key under rule
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now, rule.Window)
+
+	bucket, ok := s.buckets[key]
+	if !ok || now.After(bucket.expiresAt) {
+		bucket = &memoryBucket{state: newRateLimitState(rule, now)}
+		s.buckets[key] = bucket
+	}
+
+	result := take(&bucket.state, rule, now)
+
+	// A bucket idle for two windows is indistinguishable from a fresh one
+	bucket.expiresAt = now.Add(2 * rule.Window)
+
+	return result, nil
+}
+
+// sweep drops expired buckets at most once per window
+func (s *MemoryRateLimitStore) sweep(now time.Time, window time.Duration) {
+	if now.Sub(s.lastSweep) < window {
+		return
+	}
+	s.lastSweep = now
+
+	for key, bucket := range s.buckets {
+		if now.After(bucket.expiresAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// PostgresRateLimitStore keeps rate limit state in the rate_limit_buckets table
+// so every replica enforces the same counters
+type PostgresRateLimitStore struct {
+	store     db.Store
+	retention time.Duration
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewPostgresRateLimitStore creates a store backed by Postgres. Buckets idle for
+// longer than retention are pruned, so it must exceed twice the longest window.
+func NewPostgresRateLimitStore(store db.Store, retention time.Duration) *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{
+		store:     store,
+		retention: retention,
+	}
+}
+
+// Take counts one request for key under rule. The bucket row is locked for the
+// duration of the transaction so concurrent replicas serialize on it.
+func (s *PostgresRateLimitStore) Take(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	s.pruneIdle(ctx)
+
+	var result RateLimitResult
+
+	err := s.store.ExecTx(ctx, func(q sqlc.Querier) error {
+		if err := q.EnsureRateLimitBucket(ctx, sqlc.EnsureRateLimitBucketParams{
+			Key:    key,
+			Tokens: float64(rule.Requests),
+		}); err != nil {
+			return err
+		}
+
+		bucket, err := q.GetRateLimitBucketForUpdate(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		state := rateLimitState{
+			Tokens:      bucket.Tokens,
+			WindowStart: bucket.WindowStart,
+			WindowCount: int(bucket.WindowCount),
+			PrevCount:   int(bucket.PrevCount),
+			UpdatedAt:   bucket.UpdatedAt,
+		}
+		if now.Sub(state.UpdatedAt) > 2*rule.Window {
+			state = newRateLimitState(rule, now)
+		}
+
+		result = take(&state, rule, now)
+
+		return q.UpdateRateLimitBucket(ctx, sqlc.UpdateRateLimitBucketParams{
+			Key:         key,
+			Tokens:      state.Tokens,
+			WindowStart: state.WindowStart,
+			WindowCount: int32(state.WindowCount),
+			PrevCount:   int32(state.PrevCount),
+			UpdatedAt:   state.UpdatedAt,
+		})
+	})
+
+	return result, err
+}
+
+// pruneIdle removes idle buckets, at most once per retention period
+func (s *PostgresRateLimitStore) pruneIdle(ctx context.Context) {
+	s.mu.Lock()
+	now := time.Now()
+	if now.Sub(s.lastPrune) < s.retention {
+		s.mu.Unlock()
+		return
+	}
+	s.lastPrune = now
+	s.mu.Unlock()
+
+	// Best effort: stale rows only cost disk space
+	_ = s.Prune(ctx, now.Add(-s.retention))
+}
+
+// Prune deletes buckets that have been idle since before olderThan
+func (s *PostgresRateLimitStore) Prune(ctx context.Context, olderThan time.Time) error {
+	return s.store.DeleteStaleRateLimitBuckets(ctx, olderThan)
+}
@@ -0,0 +1,124 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/yourusername/go-sqlc-starter/internal/api/middleware"
+)
+
+func setupRateLimitRouter(rule middleware.RateLimitRule) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middleware.RateLimit(middleware.NewMemoryRateLimitStore(), rule))
+	router.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	return router
+}
+
+func doRequest(router *gin.Engine, remoteAddr string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitAlgorithms(t *testing.T) {
+	algorithms := []middleware.RateLimitAlgorithm{
+		middleware.TokenBucket,
+		middleware.SlidingWindow,
+	}
+
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			router := setupRateLimitRouter(middleware.RateLimitRule{
+				Name:      "test",
+				Requests:  3,
+				Window:    time.Hour,
+				Algorithm: algorithm,
+				Key:       middleware.KeyByIP,
+			})
+
+			for i := 0; i < 3; i++ {
+				w := doRequest(router, "10.0.0.1:1234")
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+				assert.NotEmpty(t, w.Header().Get("X-RateLimit-Reset"))
+			}
+
+			// Fourth request in the window is rejected
+			w := doRequest(router, "10.0.0.1:1234")
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+			assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+			assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+			// Other clients have their own bucket
+			w = doRequest(router, "10.0.0.2:1234")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "2", w.Header().Get("X-RateLimit-Remaining"))
+		})
+	}
+}
+
+func TestRateLimitKeyByRoute(t *testing.T) {
+	router := setupRateLimitRouter(middleware.RateLimitRule{
+		Name:      "test",
+		Requests:  1,
+		Window:    time.Hour,
+		Algorithm: middleware.TokenBucket,
+		Key:       middleware.KeyByRoute,
+	})
+
+	assert.Equal(t, http.StatusOK, doRequest(router, "10.0.0.1:1234").Code)
+
+	// The route shares one bucket regardless of caller
+	assert.Equal(t, http.StatusTooManyRequests, doRequest(router, "10.0.0.2:1234").Code)
+}
+
+func TestRateLimitKeyByJSONField(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middleware.RateLimit(middleware.NewMemoryRateLimitStore(), middleware.RateLimitRule{
+		Name:      "test",
+		Requests:  1,
+		Window:    time.Hour,
+		Algorithm: middleware.TokenBucket,
+		Key:       middleware.KeyByJSONField("email"),
+	}))
+	router.POST("/echo", func(c *gin.Context) {
+		var body struct {
+			Email string `json:"email"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusOK, body.Email)
+	})
+
+	post := func(remoteAddr, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/echo", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The handler still sees the body
+	w := post("10.0.0.1:1234", `{"email":"alice@example.com"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice@example.com", w.Body.String())
+
+	// The same value shares a bucket across clients and letter case
+	assert.Equal(t, http.StatusTooManyRequests, post("10.0.0.2:1234", `{"email":" Alice@Example.com"}`).Code)
+	assert.Equal(t, http.StatusOK, post("10.0.0.2:1234", `{"email":"bob@example.com"}`).Code)
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"database/sql"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
@@ -10,11 +11,12 @@ import (
 	"github.com/yourusername/go-sqlc-starter/internal/api/middleware"
 	"github.com/yourusername/go-sqlc-starter/internal/auth"
 	"github.com/yourusername/go-sqlc-starter/internal/config"
-	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/mail"
 )
 
 // NewRouter creates and configures the application router
-func NewRouter(cfg *config.Config, db *sql.DB, logger zerolog.Logger) *gin.Engine {
+func NewRouter(cfg *config.Config, database *sql.DB, jwtManager *auth.JWTManager, cipher *auth.Cipher, mailer mail.Mailer, passwordPolicy *auth.PasswordPolicy, logger zerolog.Logger) *gin.Engine {
 	// Set Gin mode based on environment
 	if cfg.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -22,6 +24,12 @@ func NewRouter(cfg *config.Config, db *sql.DB, logger zerolog.Logger) *gin.Engin
 
 	router := gin.New()
 
+	// Without trusted proxies, X-Forwarded-For is ignored and the client IP
+	// behind rate limits, lockouts and the audit log is the peer address
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Fatal().Err(err).Msg("Invalid trusted proxies")
+	}
+
 	// Global middleware
 	router.Use(middleware.Logger(logger))
 	router.Use(middleware.Recovery(logger))
@@ -29,12 +37,16 @@ func NewRouter(cfg *config.Config, db *sql.DB, logger zerolog.Logger) *gin.Engin
 	router.Use(middleware.RequestID())
 
 	// Initialize dependencies
-	queries := sqlc.New(db)
-	jwtManager := auth.NewJWTManager(
-		cfg.JWTSecret,
-		cfg.JWTAccessExpiry,
-		cfg.JWTRefreshExpiry,
-	)
+	store := db.NewStore(database)
+	revocations := auth.NewRevocationList(store, cfg.JWTAccessExpiry, cfg.TokenRevocationCacheTTL)
+	apiKeys := auth.NewAPIKeyAuthenticator(store)
+	throttle := auth.NewLoginThrottle(store, auth.LockoutPolicy{
+		MaxAttempts:     cfg.LoginMaxAttempts,
+		IPMaxAttempts:   cfg.LoginIPMaxAttempts,
+		LockoutDuration: cfg.LoginLockoutDuration,
+		BackoffBase:     cfg.LoginBackoffBase,
+	})
+	webAuthn := auth.NewWebAuthn(cfg.WebAuthnRPID, cfg.WebAuthnRPName, cfg.WebAuthnOrigins)
 
 	// Health check endpoints (no auth required)
 	router.GET("/health", func(c *gin.Context) {
@@ -44,9 +56,15 @@ func NewRouter(cfg *config.Config, db *sql.DB, logger zerolog.Logger) *gin.Engin
 		})
 	})
 
+	// Public keys for verifying asymmetrically signed tokens
+	router.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, jwtManager.JWKS())
+	})
+
 	router.GET("/ready", func(c *gin.Context) {
 		// Check database connection
-		if err := db.Ping(); err != nil {
+		if err := database.Ping(); err != nil {
 			c.JSON(http.StatusServiceUnavailable, gin.H{
 				"status": "not ready",
 				"error":  "database unavailable",
@@ -58,31 +76,239 @@ func NewRouter(cfg *config.Config, db *sql.DB, logger zerolog.Logger) *gin.Engin
 		})
 	})
 
+	// Rate limiting
+	rateLimitStore := newRateLimitStore(cfg, store)
+	algorithm := middleware.RateLimitAlgorithm(cfg.RateLimitAlgorithm)
+	globalLimit := middleware.RateLimit(rateLimitStore, middleware.RateLimitRule{
+		Name:      "global",
+		Requests:  cfg.RateLimitRequests,
+		Window:    cfg.RateLimitWindow,
+		Algorithm: algorithm,
+		Key:       middleware.KeyByIP,
+	})
+	credentialLimit := middleware.RateLimit(rateLimitStore, middleware.RateLimitRule{
+		Name:      "auth",
+		Requests:  cfg.RateLimitAuthRequests,
+		Window:    cfg.RateLimitAuthWindow,
+		Algorithm: algorithm,
+		Key:       middleware.KeyByIP,
+	})
+	userLimit := middleware.RateLimit(rateLimitStore, middleware.RateLimitRule{
+		Name:      "user",
+		Requests:  cfg.RateLimitRequests,
+		Window:    cfg.RateLimitWindow,
+		Algorithm: algorithm,
+		Key:       middleware.KeyByUser,
+	})
+	magicLinkLimit := middleware.RateLimit(rateLimitStore, middleware.RateLimitRule{
+		Name:      "magic_link",
+		Requests:  cfg.MagicLinkEmailRequests,
+		Window:    cfg.MagicLinkEmailWindow,
+		Algorithm: algorithm,
+		Key:       middleware.KeyByJSONField("email"),
+	})
+
+	// Access tokens, personal API keys and OAuth client tokens all
+	// authenticate; account security routes are limited to signed-in users
+	authRequired := middleware.AuthRequired(jwtManager, revocations, apiKeys)
+	sessionOnly := middleware.SessionRequired()
+
+	// OAuth 2.0 authorization server and OpenID Connect provider endpoints.
+	// ID tokens need an asymmetric JWT_ALGORITHM.
+	if !jwtManager.SignsIDTokens() {
+		logger.Warn().Msg("OpenID Connect is disabled; set JWT_ALGORITHM to RS256, ES256 or EdDSA to enable it")
+	}
+	oauthHandler := handlers.NewOAuthHandler(store, jwtManager, revocations, cfg.IssuerURL, cfg.AppURL, logger)
+	router.GET("/.well-known/openid-configuration", oauthHandler.Discovery)
+	oauth := router.Group("/oauth")
+	oauth.Use(globalLimit)
+	{
+		oauth.GET("/authorize", oauthHandler.Authorize)
+		oauth.POST("/token", credentialLimit, oauthHandler.Token)
+		oauth.POST("/introspect", oauthHandler.Introspect)
+		oauth.POST("/revoke", oauthHandler.Revoke)
+		oauth.GET("/userinfo", authRequired, oauthHandler.UserInfo)
+		oauth.POST("/userinfo", authRequired, oauthHandler.UserInfo)
+	}
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
+	v1.Use(globalLimit)
 	{
 		// Public authentication routes
-		authHandler := handlers.NewAuthHandler(queries, jwtManager, db)
+		verificationHandler := handlers.NewVerificationHandler(store, mailer, cfg.AppURL, cfg.EmailVerificationExpiry, logger)
+		mfaHandler := handlers.NewMFAHandler(store, cipher, cfg.MFAIssuer)
+		authHandler := handlers.NewAuthHandler(store, jwtManager, revocations, verificationHandler, mfaHandler, throttle, passwordPolicy, cfg.RequireEmailVerification, cfg.ConcealRegisteredEmails, cfg.InviteOnly, logger)
+		passwordHandler := handlers.NewPasswordHandler(store, authHandler, revocations, passwordPolicy, mailer, cfg.AppURL, cfg.PasswordResetExpiry, logger)
+		identityHandler := handlers.NewIdentityHandler(store, authHandler, newConnectors(cfg), cfg.AppURL, logger)
+		magicLinkHandler := handlers.NewMagicLinkHandler(store, authHandler, mailer, cfg.AppURL, cfg.MagicLinkExpiry, logger)
+		passkeyHandler := handlers.NewPasskeyHandler(store, authHandler, webAuthn, logger)
+		invitationHandler := handlers.NewInvitationHandler(store, authHandler, mailer, cfg.AppURL, cfg.InvitationExpiry, logger)
 		auth := v1.Group("/auth")
 		{
-			auth.POST("/register", authHandler.Register)
-			auth.POST("/login", authHandler.Login)
+			auth.POST("/register", credentialLimit, authHandler.Register)
+			auth.POST("/login", credentialLimit, authHandler.Login)
 			auth.POST("/refresh", authHandler.RefreshToken)
 			auth.POST("/logout", authHandler.Logout)
+			auth.POST("/mfa/verify", credentialLimit, authHandler.VerifyMFA)
+
+			// Email verification
+			auth.POST("/verify-email", verificationHandler.VerifyEmail)
+			auth.POST("/verify-email/resend", credentialLimit, verificationHandler.ResendVerification)
+
+			// Password recovery
+			auth.POST("/password/forgot", credentialLimit, passwordHandler.ForgotPassword)
+			auth.POST("/password/reset", credentialLimit, passwordHandler.ResetPassword)
+
+			// Passwordless sign-in links
+			auth.POST("/magic-link", credentialLimit, magicLinkLimit, magicLinkHandler.RequestLink)
+			auth.GET("/magic-link/verify", credentialLimit, magicLinkHandler.CheckLink)
+			auth.POST("/magic-link/verify", credentialLimit, magicLinkHandler.VerifyLink)
+
+			// Passkey sign-in
+			auth.POST("/passkey/login/options", credentialLimit, passkeyHandler.StartLogin)
+			auth.POST("/passkey/login", credentialLimit, passkeyHandler.FinishLogin)
+
+			// Invitations
+			auth.GET("/invitation", credentialLimit, invitationHandler.CheckInvitation)
+			auth.POST("/invitation/register", credentialLimit, invitationHandler.Register)
+
+			// Sign-in through external identity providers
+			auth.GET("/providers", identityHandler.ListProviders)
+			auth.POST("/providers/:provider/authorize", credentialLimit, identityHandler.StartLogin)
+			auth.POST("/providers/:provider/callback", credentialLimit, identityHandler.FinishLogin)
+		}
+
+		// OAuth consent screen
+		consent := v1.Group("/oauth")
+		consent.Use(authRequired, sessionOnly, userLimit)
+		{
+			consent.GET("/authorize", oauthHandler.GetAuthorization)
+			consent.POST("/authorize", oauthHandler.DecideAuthorization)
 		}
 
 		// Protected user routes
-		userHandler := handlers.NewUserHandler(queries)
+		userHandler := handlers.NewUserHandler(store, revocations, verificationHandler)
+		sessionHandler := handlers.NewSessionHandler(store, revocations)
+		apiKeyHandler := handlers.NewAPIKeyHandler(store)
+		oauthClientHandler := handlers.NewOAuthClientHandler(store, revocations)
 		users := v1.Group("/users")
-		users.Use(middleware.AuthRequired(jwtManager))
+		users.Use(authRequired, userLimit)
 		{
 			users.GET("/me", userHandler.GetCurrentUser)
-			users.PUT("/me", userHandler.UpdateCurrentUser)
-			users.DELETE("/me", userHandler.DeleteCurrentUser)
-			
-			// Admin only routes
-			users.GET("/:id", middleware.AdminRequired(), userHandler.GetUserByID)
-			users.GET("", middleware.AdminRequired(), userHandler.ListUsers)
+			users.PUT("/me", sessionOnly, userHandler.UpdateCurrentUser)
+			users.DELETE("/me", sessionOnly, userHandler.DeleteCurrentUser)
+			users.POST("/me/password", sessionOnly, passwordHandler.ChangePassword)
+
+			// Multi-factor authentication
+			users.GET("/me/mfa", sessionOnly, mfaHandler.GetStatus)
+			users.POST("/me/mfa/enroll", sessionOnly, mfaHandler.Enroll)
+			users.POST("/me/mfa/confirm", sessionOnly, mfaHandler.Confirm)
+			users.POST("/me/mfa/disable", sessionOnly, mfaHandler.Disable)
+			users.POST("/me/mfa/recovery-codes", sessionOnly, mfaHandler.RegenerateRecoveryCodes)
+
+			// Session management
+			users.GET("/me/sessions", sessionOnly, sessionHandler.ListSessions)
+			users.DELETE("/me/sessions", sessionOnly, sessionHandler.RevokeOtherSessions)
+			users.DELETE("/me/sessions/:id", sessionOnly, sessionHandler.RevokeSession)
+
+			// Personal API keys
+			users.GET("/me/api-keys", sessionOnly, apiKeyHandler.ListAPIKeys)
+			users.POST("/me/api-keys", sessionOnly, apiKeyHandler.CreateAPIKey)
+			users.DELETE("/me/api-keys/:id", sessionOnly, apiKeyHandler.RevokeAPIKey)
+
+			// OAuth clients the user has authorized
+			users.GET("/me/authorized-apps", sessionOnly, oauthClientHandler.ListAuthorizedApps)
+			users.DELETE("/me/authorized-apps/:client_id", sessionOnly, oauthClientHandler.RevokeAuthorizedApp)
+
+			// Linked identity provider accounts
+			users.GET("/me/identities", sessionOnly, identityHandler.ListIdentities)
+			users.POST("/me/identities/:provider/authorize", sessionOnly, identityHandler.StartLink)
+			users.POST("/me/identities/:provider/callback", sessionOnly, identityHandler.FinishLink)
+			users.DELETE("/me/identities/:provider", sessionOnly, identityHandler.Unlink)
+
+			// Passkeys
+			users.GET("/me/passkeys", sessionOnly, passkeyHandler.ListPasskeys)
+			users.POST("/me/passkeys/options", sessionOnly, passkeyHandler.StartRegistration)
+			users.POST("/me/passkeys", sessionOnly, passkeyHandler.Register)
+			users.PUT("/me/passkeys/:id", sessionOnly, passkeyHandler.RenamePasskey)
+			users.DELETE("/me/passkeys/:id", sessionOnly, passkeyHandler.DeletePasskey)
+
+			// Invitations from admins and organizations
+			users.POST("/me/invitations/accept", sessionOnly, invitationHandler.Accept)
+
+			// Routes for staff with user permissions
+			users.GET("/:id", middleware.RequirePermission("users:read"), userHandler.GetUserByID)
+			users.GET("", middleware.RequirePermission("users:read"), userHandler.ListUsers)
+		}
+
+		// Organizations
+		orgHandler := handlers.NewOrgHandler(store, authHandler, revocations)
+		orgs := v1.Group("/orgs")
+		orgs.Use(authRequired, userLimit)
+		{
+			orgs.GET("", orgHandler.ListOrgs)
+			orgs.POST("", sessionOnly, orgHandler.CreateOrg)
+			orgs.POST("/:id/switch", sessionOnly, orgHandler.SwitchOrg)
+		}
+
+		// The organization the session acts in
+		org := v1.Group("/org")
+		org.Use(authRequired, userLimit, middleware.OrgRequired(cfg.OrgRowLevelSecurity))
+		{
+			org.GET("", orgHandler.GetActiveOrg)
+			org.GET("/members", orgHandler.ListMembers)
+			org.PUT("/members/:user_id", middleware.RequireOrgRole("owner", "admin"), orgHandler.UpdateMember)
+			org.DELETE("/members/:user_id", orgHandler.RemoveMember)
+
+			org.GET("/invitations", middleware.RequireOrgRole("owner", "admin"), invitationHandler.ListOrgInvitations)
+			org.POST("/invitations", middleware.RequireOrgRole("owner", "admin"), invitationHandler.CreateOrgInvitation)
+			org.DELETE("/invitations/:id", middleware.RequireOrgRole("owner", "admin"), invitationHandler.RevokeOrgInvitation)
+		}
+
+		// Administration
+		roleHandler := handlers.NewRoleHandler(store, revocations)
+		adminUserHandler := handlers.NewAdminUserHandler(store, jwtManager, revocations, throttle, passwordHandler, verificationHandler, cfg.ImpersonationExpiry, logger)
+		auditHandler := handlers.NewAuditHandler(store)
+		admin := v1.Group("/admin")
+		admin.Use(authRequired, userLimit)
+		{
+			// Tokens a client holds on its own behalf cannot manage users,
+			// roles, invitations or clients
+			userAdmin := admin.Group("", middleware.UserRequired())
+
+			userAdmin.GET("/permissions", middleware.RequirePermission("roles:read"), roleHandler.ListPermissions)
+			userAdmin.GET("/roles", middleware.RequirePermission("roles:read"), roleHandler.ListRoles)
+			userAdmin.POST("/roles", middleware.RequirePermission("roles:write"), roleHandler.CreateRole)
+			userAdmin.PUT("/roles/:id", middleware.RequirePermission("roles:write"), roleHandler.UpdateRole)
+			userAdmin.DELETE("/roles/:id", middleware.RequirePermission("roles:write"), roleHandler.DeleteRole)
+
+			userAdmin.GET("/users/:id/roles", middleware.RequirePermission("roles:read"), roleHandler.GetUserRoles)
+			userAdmin.POST("/users/:id/roles", middleware.RequirePermission("roles:write"), roleHandler.AssignUserRole)
+			userAdmin.DELETE("/users/:id/roles/:role", middleware.RequirePermission("roles:write"), roleHandler.RemoveUserRole)
+
+			userAdmin.GET("/users", middleware.RequirePermission("users:read"), adminUserHandler.ListUsers)
+			userAdmin.POST("/users", middleware.RequirePermission("users:write"), adminUserHandler.CreateUser)
+			userAdmin.GET("/users/:id", middleware.RequirePermission("users:read"), adminUserHandler.GetUser)
+			userAdmin.PUT("/users/:id", middleware.RequirePermission("users:write"), adminUserHandler.UpdateUser)
+			userAdmin.DELETE("/users/:id", middleware.RequirePermission("users:delete"), adminUserHandler.DeleteUser)
+			userAdmin.POST("/users/:id/deactivate", middleware.RequirePermission("users:delete"), adminUserHandler.DeactivateUser)
+			userAdmin.POST("/users/:id/reactivate", middleware.RequirePermission("users:write"), adminUserHandler.ReactivateUser)
+			userAdmin.POST("/users/:id/password-reset", middleware.RequirePermission("users:write"), adminUserHandler.ForcePasswordReset)
+			userAdmin.DELETE("/users/:id/sessions", middleware.RequirePermission("users:write"), adminUserHandler.RevokeSessions)
+			userAdmin.POST("/users/:id/unlock", middleware.RequirePermission("users:write"), adminUserHandler.UnlockUser)
+			userAdmin.POST("/users/:id/impersonate", sessionOnly, middleware.RequirePermission("users:impersonate"), adminUserHandler.Impersonate)
+
+			userAdmin.GET("/invitations", middleware.RequirePermission("users:read"), invitationHandler.ListInvitations)
+			userAdmin.POST("/invitations", middleware.RequirePermission("users:write"), invitationHandler.CreateInvitation)
+			userAdmin.DELETE("/invitations/:id", middleware.RequirePermission("users:write"), invitationHandler.RevokeInvitation)
+
+			userAdmin.GET("/oauth/clients", middleware.RequirePermission("clients:read"), oauthClientHandler.ListClients)
+			userAdmin.POST("/oauth/clients", middleware.RequirePermission("clients:write"), oauthClientHandler.CreateClient)
+			userAdmin.DELETE("/oauth/clients/:client_id", middleware.RequirePermission("clients:write"), oauthClientHandler.DeleteClient)
+
+			admin.GET("/audit", middleware.RequirePermission("audit:read"), auditHandler.ListEvents)
+			admin.GET("/audit/verify", middleware.RequirePermission("audit:read"), auditHandler.VerifyChain)
 		}
 	}
 
@@ -95,3 +321,40 @@ func NewRouter(cfg *config.Config, db *sql.DB, logger zerolog.Logger) *gin.Engin
 
 	return router
 }
+
+// newRateLimitStore selects the rate limit backend from configuration
+func newRateLimitStore(cfg *config.Config, store db.Store) middleware.RateLimitStore {
+	if cfg.RateLimitStore == "postgres" {
+		// Keep idle buckets well past the longest window before pruning them
+		retention := 2 * cfg.RateLimitWindow
+		if authRetention := 2 * cfg.RateLimitAuthWindow; authRetention > retention {
+			retention = authRetention
+		}
+		if magicLinkRetention := 2 * cfg.MagicLinkEmailWindow; magicLinkRetention > retention {
+			retention = magicLinkRetention
+		}
+		return middleware.NewPostgresRateLimitStore(store, retention+time.Minute)
+	}
+	return middleware.NewMemoryRateLimitStore()
+}
+
+// newConnectors creates a connector for every configured identity provider
+func newConnectors(cfg *config.Config) []auth.Connector {
+	var connectors []auth.Connector
+	if cfg.GoogleClientID != "" {
+		connectors = append(connectors, auth.NewGoogleConnector(cfg.GoogleClientID, cfg.GoogleClientSecret))
+	}
+	if cfg.GitHubClientID != "" {
+		connectors = append(connectors, auth.NewGitHubConnector(cfg.GitHubClientID, cfg.GitHubClientSecret))
+	}
+	if cfg.OIDCClientID != "" {
+		connectors = append(connectors, auth.NewOIDCConnector(auth.OIDCConfig{
+			ID:           cfg.OIDCProviderID,
+			Name:         cfg.OIDCProviderName,
+			Issuer:       cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+		}))
+	}
+	return connectors
+}
@@ -0,0 +1,63 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/yourusername/go-sqlc-starter/internal/api"
+	"github.com/yourusername/go-sqlc-starter/internal/auth"
+	"github.com/yourusername/go-sqlc-starter/internal/config"
+)
+
+func TestRouterClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(trustedProxies ...string) http.Handler {
+		cfg := &config.Config{
+			Env:                    "test",
+			RateLimitRequests:      100,
+			RateLimitWindow:        time.Minute,
+			RateLimitAuthRequests:  1,
+			RateLimitAuthWindow:    time.Minute,
+			RateLimitAlgorithm:     "token_bucket",
+			RateLimitStore:         "memory",
+			MagicLinkEmailRequests: 3,
+			MagicLinkEmailWindow:   time.Minute,
+			ImpersonationExpiry:    time.Minute,
+			TrustedProxies:         trustedProxies,
+			CORSAllowedOrigins:     []string{"*"},
+		}
+		jwtManager := auth.NewJWTManager("test-secret-key", 15*time.Minute, time.Hour)
+		return api.NewRouter(cfg, nil, jwtManager, nil, nil, &auth.PasswordPolicy{MinLength: 8}, zerolog.Nop())
+	}
+	// login is refused before it reaches the database, but still counts
+	// against the credential rate limit
+	login := func(router http.Handler, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("a spoofed X-Forwarded-For does not reset the limit", func(t *testing.T) {
+		router := newRouter()
+		assert.Equal(t, http.StatusBadRequest, login(router, "198.51.100.1"))
+		assert.Equal(t, http.StatusTooManyRequests, login(router, "198.51.100.2"))
+	})
+
+	t.Run("a trusted proxy names the client", func(t *testing.T) {
+		router := newRouter("10.0.0.0/8")
+		assert.Equal(t, http.StatusBadRequest, login(router, "198.51.100.1"))
+		assert.Equal(t, http.StatusBadRequest, login(router, "198.51.100.2"))
+		assert.Equal(t, http.StatusTooManyRequests, login(router, "198.51.100.1"))
+	})
+}
@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionRegister            = "auth.register"
+	ActionLogin               = "auth.login"
+	ActionUserCreated         = "user.created"
+	ActionUserUpdated         = "user.updated"
+	ActionUserDeleted         = "user.deleted"
+	ActionUserDeactivated     = "user.deactivated"
+	ActionUserReactivated     = "user.reactivated"
+	ActionUserHardDeleted     = "user.hard_deleted"
+	ActionSessionsRevoked     = "user.sessions_revoked"
+	ActionPasswordResetForced = "user.password_reset_forced"
+	ActionUserImpersonated    = "user.impersonated"
+	ActionUserUnlocked        = "user.unlocked"
+	ActionEmailVerified       = "user.email_verified"
+	ActionPasswordChanged     = "user.password_changed"
+	ActionPasswordReset       = "user.password_reset"
+	ActionMFAEnabled          = "mfa.enabled"
+	ActionMFADisabled         = "mfa.disabled"
+	ActionPasskeyAdded        = "passkey.added"
+	ActionPasskeyRemoved      = "passkey.removed"
+	ActionIdentityLinked      = "identity.linked"
+	ActionIdentityUnlinked    = "identity.unlinked"
+	ActionAPIKeyCreated       = "api_key.created"
+	ActionAPIKeyRevoked       = "api_key.revoked"
+	ActionRoleCreated         = "role.created"
+	ActionRoleUpdated         = "role.updated"
+	ActionRoleDeleted         = "role.deleted"
+	ActionRoleAssigned        = "role.assigned"
+	ActionRoleRemoved         = "role.removed"
+	ActionOrgCreated          = "org.created"
+	ActionOrgMemberUpdated    = "org.member_updated"
+	ActionOrgMemberRemoved    = "org.member_removed"
+	ActionInvitationCreated   = "invitation.created"
+	ActionInvitationRevoked   = "invitation.revoked"
+	ActionInvitationAccepted  = "invitation.accepted"
+)
+
+// Kinds of record an event can target
+const (
+	TargetUser       = "user"
+	TargetRole       = "role"
+	TargetPasskey    = "passkey"
+	TargetIdentity   = "identity"
+	TargetAPIKey     = "api_key"
+	TargetOrg        = "organization"
+	TargetInvitation = "invitation"
+)
+
+// Change is a field's value before and after an action
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Event is one security-relevant action. ActorID and TargetID are zero when
+// there is no signed-in actor or no target record.
+type Event struct {
+	ActorID    int64
+	Action     string
+	TargetType string
+	TargetID   int64
+	IPAddress  string
+	UserAgent  string
+	RequestID  string
+	Diff       map[string]Change
+}
+
+// Record appends e to the audit log. Pass the transaction's queries when the
+// action happens in one, so the event commits or rolls back with it. Writers
+// queue behind each other until commit to keep the hash chain in order, so
+// keep transactions that record events short.
+func Record(ctx context.Context, q sqlc.Querier, e Event) error {
+	diff := []byte("{}")
+	if len(e.Diff) > 0 {
+		var err error
+		if diff, err = json.Marshal(e.Diff); err != nil {
+			return err
+		}
+	}
+
+	return q.CreateAuditEvent(ctx, sqlc.CreateAuditEventParams{
+		ActorID:    nullID(e.ActorID),
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   nullID(e.TargetID),
+		IpAddress:  truncate(e.IPAddress, 45),
+		UserAgent:  truncate(e.UserAgent, 512),
+		RequestID:  truncate(e.RequestID, 128),
+		Diff:       diff,
+	})
+}
+
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+// truncate cuts s to at most n bytes to fit its column, dropping invalid
+// UTF-8 that Postgres would reject
+func truncate(s string, n int) string {
+	if len(s) > n {
+		s = s[:n]
+	}
+	return strings.ToValidUTF8(s, "")
+}
@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/go-sqlc-starter/internal/db"
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// recorder captures the events Record writes
+type recorder struct {
+	sqlc.Querier
+	events []sqlc.CreateAuditEventParams
+}
+
+func (r *recorder) CreateAuditEvent(ctx context.Context, arg sqlc.CreateAuditEventParams) error {
+	r.events = append(r.events, arg)
+	return nil
+}
+
+func TestRecord(t *testing.T) {
+	q := &recorder{}
+
+	err := Record(context.Background(), q, Event{
+		Action:     ActionUserUpdated,
+		TargetType: TargetUser,
+		TargetID:   7,
+		UserAgent:  strings.Repeat("a", 600),
+		Diff:       map[string]Change{"email": {From: "old@example.com", To: "new@example.com"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, q.events, 1)
+
+	e := q.events[0]
+	assert.False(t, e.ActorID.Valid, "no actor is stored as NULL")
+	assert.Equal(t, sql.NullInt64{Int64: 7, Valid: true}, e.TargetID)
+	assert.Len(t, e.UserAgent, 512)
+	assert.JSONEq(t, `{"email":{"from":"old@example.com","to":"new@example.com"}}`, string(e.Diff))
+
+	require.NoError(t, Record(context.Background(), q, Event{Action: ActionLogin}))
+	assert.Equal(t, "{}", string(q.events[1].Diff))
+}
+
+func TestAuditChainIntegration(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" || testing.Short() {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	conn, err := db.Connect(databaseURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, db.RunMigrations(databaseURL))
+
+	ctx := context.Background()
+	store := db.NewStore(conn)
+
+	for _, action := range []string{ActionRegister, ActionLogin, ActionPasswordChanged} {
+		require.NoError(t, Record(ctx, store, Event{
+			Action:     action,
+			TargetType: TargetUser,
+			Diff:       map[string]Change{"field": {From: 1, To: 2}},
+		}))
+	}
+
+	result, err := store.VerifyAuditChain(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, result.Checked, int64(3))
+	assert.Zero(t, result.BrokenID, "a freshly written chain verifies")
+
+	var lastID int64
+	require.NoError(t, conn.QueryRow("SELECT MAX(id) FROM audit_events").Scan(&lastID))
+
+	_, err = conn.Exec("UPDATE audit_events SET action = 'tampered' WHERE id = $1", lastID)
+	assert.Error(t, err, "recorded events cannot be changed")
+	_, err = conn.Exec("DELETE FROM audit_events WHERE id = $1", lastID)
+	assert.Error(t, err, "recorded events cannot be removed")
+
+	// Tamper with the guard triggers off, as someone with direct table access
+	// could, and roll back so the shared log stays intact
+	tx, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	_, err = tx.Exec("ALTER TABLE audit_events DISABLE TRIGGER protect_audit_events")
+	require.NoError(t, err)
+	_, err = tx.Exec("UPDATE audit_events SET action = 'tampered' WHERE id = $1", lastID-1)
+	require.NoError(t, err)
+
+	result, err = sqlc.New(tx).VerifyAuditChain(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, lastID-1, result.BrokenID, "verification reports the first edited event")
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+const (
+	// apiKeyScheme starts every API key so leaked keys are easy to spot
+	apiKeyScheme = "sk_"
+
+	// apiKeyPrefixLength is the length of the public part that identifies a key
+	apiKeyPrefixLength = 8
+)
+
+// ErrInvalidAPIKey is returned for unknown, expired or malformed API keys
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+var apiKeyPrefixEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateAPIKey returns a new API key of the form sk_<prefix>_<secret> and
+// its prefix. Only the prefix and HashToken(key) should be stored.
+func GenerateAPIKey() (key, prefix string, err error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	prefix = strings.ToLower(apiKeyPrefixEncoding.EncodeToString(b))
+
+	secret, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	return apiKeyScheme + prefix + "_" + secret, prefix, nil
+}
+
+// APIKeyDisplayPrefix returns the part of a key that is safe to show, such
+// as sk_abcd2345
+func APIKeyDisplayPrefix(prefix string) string {
+	return apiKeyScheme + prefix
+}
+
+// parseAPIKey extracts the prefix from key
+func parseAPIKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, apiKeyScheme) {
+		return "", false
+	}
+	rest := key[len(apiKeyScheme):]
+	if len(rest) <= apiKeyPrefixLength+1 || rest[apiKeyPrefixLength] != '_' {
+		return "", false
+	}
+	return rest[:apiKeyPrefixLength], true
+}
+
+// APIKeyAuthenticator resolves API keys to the claims of the user who owns
+// them. A key's permissions are its scopes, limited to what the user holds
+// at the time of the request, so role changes apply immediately.
+type APIKeyAuthenticator struct {
+	queries sqlc.Querier
+}
+
+// NewAPIKeyAuthenticator creates an API key authenticator backed by Postgres
+func NewAPIKeyAuthenticator(queries sqlc.Querier) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{queries: queries}
+}
+
+// Authenticate checks key and returns claims equivalent to an access token
+// for its owner. ip is recorded as the key's last use.
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, key, ip string) (*Claims, error) {
+	prefix, ok := parseAPIKey(key)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := a.queries.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(HashToken(key)), []byte(apiKey.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if apiKey.ExpiresAt.Valid && !apiKey.ExpiresAt.Time.After(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Keys of deactivated users stop working
+	user, err := a.queries.GetUserByID(ctx, apiKey.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	roles, err := a.queries.ListUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	granted, err := a.queries.ListUserPermissions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Best effort: last use is informational
+	_ = a.queries.TouchAPIKey(ctx, sqlc.TouchAPIKeyParams{
+		UsedAt:    now,
+		IpAddress: sql.NullString{String: ip, Valid: ip != ""},
+		ID:        apiKey.ID,
+	})
+
+	return &Claims{
+		UserID:      user.ID,
+		Email:       user.Email,
+		IsAdmin:     user.IsAdmin,
+		Roles:       roles,
+		Permissions: FilterScopes(apiKey.Scopes, granted),
+		APIKeyID:    apiKey.ID,
+	}, nil
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedPasswords checks passwords against a local copy of a breached
+// password corpus such as HaveIBeenPwned's Pwned Passwords, so no password or
+// hash prefix ever leaves the server. Lines hold an uppercase SHA-1 hash,
+// optionally followed by ":count".
+//
+// The corpus is either a single file, loaded into memory, or a directory of
+// range files as produced by the Pwned Passwords downloader: one file per
+// five-character hash prefix (00000.txt to FFFFF.txt) whose lines hold the
+// remaining 35 characters. Range files are read on demand, so the directory
+// may hold the full corpus.
+type BreachedPasswords struct {
+	dir    string
+	hashes map[string]struct{}
+}
+
+// LoadBreachedPasswords opens the corpus at path
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password corpus: %w", err)
+	}
+	if info.IsDir() {
+		return &BreachedPasswords{dir: path}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password corpus: %w", err)
+	}
+	defer f.Close()
+
+	hashes := make(map[string]struct{})
+	err = scanHashes(f, func(hash string) bool {
+		hashes[hash] = struct{}{}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read breached password corpus: %w", err)
+	}
+	return &BreachedPasswords{hashes: hashes}, nil
+}
+
+// Contains reports whether password appears in the corpus
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	if b.hashes != nil {
+		_, ok := b.hashes[hash]
+		return ok, nil
+	}
+
+	f, err := os.Open(filepath.Join(b.dir, hash[:5]+".txt"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	found := false
+	err = scanHashes(f, func(suffix string) bool {
+		found = suffix == hash[5:]
+		return !found
+	})
+	return found, err
+}
+
+// scanHashes calls fn with each hash in f, uppercased and without its count,
+// until fn returns false
+func scanHashes(f *os.File, fn func(hash string) bool) error {
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			line = line[:i]
+		}
+		if line == "" {
+			continue
+		}
+		if !fn(strings.ToUpper(line)) {
+			break
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,509 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// connectorTimeout bounds every request to an identity provider
+	connectorTimeout = 10 * time.Second
+
+	// connectorMaxResponse caps how much of a provider response is read
+	connectorMaxResponse = 1 << 20
+
+	// jwksRefreshInterval is how often an unknown kid may trigger refetching
+	// a provider's keys, so forged tokens cannot hammer the provider
+	jwksRefreshInterval = time.Minute
+)
+
+// ErrExternalLoginRejected is returned when an identity provider refuses an
+// authorization code or returns an identity that fails validation.
+// Other errors mean the provider could not be reached.
+var ErrExternalLoginRejected = errors.New("external login rejected")
+
+// ExternalIdentity is a user as asserted by an external identity provider
+type ExternalIdentity struct {
+	// Subject identifies the user at the provider and never changes
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Connector signs users in through an external identity provider with the
+// authorization code flow and PKCE
+type Connector interface {
+	// ID names the provider in URLs and in the identities table
+	ID() string
+	// Name is the provider name shown to users
+	Name() string
+	// AuthCodeURL returns the provider URL that starts a sign-in
+	AuthCodeURL(ctx context.Context, redirectURI, state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems an authorization code and returns who signed in
+	Exchange(ctx context.Context, redirectURI, code, codeVerifier, nonce string) (ExternalIdentity, error)
+}
+
+// OAuth2Config configures a connector for a plain OAuth 2.0 provider, which
+// has no ID token and describes the user through its own API
+type OAuth2Config struct {
+	ID           string
+	Name         string
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	Scopes       []string
+	// Profile fetches the signed-in user with an access token
+	Profile func(ctx context.Context, client *http.Client, accessToken string) (ExternalIdentity, error)
+	// HTTPClient defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+// OAuth2Connector signs users in through a plain OAuth 2.0 provider
+type OAuth2Connector struct {
+	cfg OAuth2Config
+}
+
+// NewOAuth2Connector creates a connector for a plain OAuth 2.0 provider
+func NewOAuth2Connector(cfg OAuth2Config) *OAuth2Connector {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: connectorTimeout}
+	}
+	return &OAuth2Connector{cfg: cfg}
+}
+
+func (c *OAuth2Connector) ID() string   { return c.cfg.ID }
+func (c *OAuth2Connector) Name() string { return c.cfg.Name }
+
+func (c *OAuth2Connector) AuthCodeURL(ctx context.Context, redirectURI, state, nonce, codeChallenge string) (string, error) {
+	return authCodeURL(c.cfg.AuthURL, c.cfg.ClientID, redirectURI, c.cfg.Scopes, state, "", codeChallenge), nil
+}
+
+func (c *OAuth2Connector) Exchange(ctx context.Context, redirectURI, code, codeVerifier, nonce string) (ExternalIdentity, error) {
+	token, err := exchangeCode(ctx, c.cfg.HTTPClient, c.cfg.TokenURL, c.cfg.ClientID, c.cfg.ClientSecret, false, redirectURI, code, codeVerifier)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+
+	identity, err := c.cfg.Profile(ctx, c.cfg.HTTPClient, token.AccessToken)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	if identity.Subject == "" {
+		return ExternalIdentity{}, fmt.Errorf("%w: provider returned no subject", ErrExternalLoginRejected)
+	}
+	return identity, nil
+}
+
+// NewGitHubConnector creates a connector for GitHub. GitHub accounts have
+// no ID token, so the user and their primary verified email come from the
+// REST API.
+func NewGitHubConnector(clientID, clientSecret string) *OAuth2Connector {
+	return NewOAuth2Connector(OAuth2Config{
+		ID:           "github",
+		Name:         "GitHub",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		AuthURL:      "https://github.com/login/oauth/authorize",
+		TokenURL:     "https://github.com/login/oauth/access_token",
+		Scopes:       []string{"read:user", "user:email"},
+		Profile:      githubProfile("https://api.github.com"),
+	})
+}
+
+// githubProfile returns a profile fetcher for the GitHub API at baseURL
+func githubProfile(baseURL string) func(ctx context.Context, client *http.Client, accessToken string) (ExternalIdentity, error) {
+	return func(ctx context.Context, client *http.Client, accessToken string) (ExternalIdentity, error) {
+		var user struct {
+			ID    int64  `json:"id"`
+			Login string `json:"login"`
+			Name  string `json:"name"`
+		}
+		if err := getJSON(ctx, client, baseURL+"/user", accessToken, &user); err != nil {
+			return ExternalIdentity{}, err
+		}
+
+		var emails []struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}
+		if err := getJSON(ctx, client, baseURL+"/user/emails", accessToken, &emails); err != nil {
+			return ExternalIdentity{}, err
+		}
+
+		identity := ExternalIdentity{Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+		if identity.Name == "" {
+			identity.Name = user.Login
+		}
+		for _, email := range emails {
+			if email.Primary {
+				identity.Email = email.Email
+				identity.EmailVerified = email.Verified
+			}
+		}
+		return identity, nil
+	}
+}
+
+// OIDCConfig configures a connector for an OpenID Connect provider
+type OIDCConfig struct {
+	ID           string
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes defaults to openid, email and profile
+	Scopes []string
+	// HTTPClient defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+// OIDCConnector signs users in through an OpenID Connect provider. The
+// provider is configured from its discovery document, fetched on first use,
+// and ID tokens are verified against its published keys.
+type OIDCConnector struct {
+	cfg OIDCConfig
+
+	mu          sync.Mutex
+	metadata    *oidcMetadata
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+// oidcMetadata is the part of a provider's discovery document connectors use
+type oidcMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// externalIDTokenClaims are the ID token claims connectors read
+type externalIDTokenClaims struct {
+	Nonce           string    `json:"nonce"`
+	Email           string    `json:"email"`
+	EmailVerified   claimBool `json:"email_verified"`
+	Name            string    `json:"name"`
+	AuthorizedParty string    `json:"azp"`
+	jwt.RegisteredClaims
+}
+
+// claimBool is a boolean claim some providers send as a string
+type claimBool bool
+
+func (b *claimBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", `"true"`:
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// NewOIDCConnector creates a connector for the OpenID Connect provider at
+// cfg.Issuer
+func NewOIDCConnector(cfg OIDCConfig) *OIDCConnector {
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{ScopeOpenID, ScopeEmail, ScopeProfile}
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: connectorTimeout}
+	}
+	return &OIDCConnector{cfg: cfg}
+}
+
+// NewGoogleConnector creates a connector for Google accounts
+func NewGoogleConnector(clientID, clientSecret string) *OIDCConnector {
+	return NewOIDCConnector(OIDCConfig{
+		ID:           "google",
+		Name:         "Google",
+		Issuer:       "https://accounts.google.com",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+	})
+}
+
+func (c *OIDCConnector) ID() string   { return c.cfg.ID }
+func (c *OIDCConnector) Name() string { return c.cfg.Name }
+
+func (c *OIDCConnector) AuthCodeURL(ctx context.Context, redirectURI, state, nonce, codeChallenge string) (string, error) {
+	metadata, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return authCodeURL(metadata.AuthorizationEndpoint, c.cfg.ClientID, redirectURI, c.cfg.Scopes, state, nonce, codeChallenge), nil
+}
+
+func (c *OIDCConnector) Exchange(ctx context.Context, redirectURI, code, codeVerifier, nonce string) (ExternalIdentity, error) {
+	metadata, err := c.discover(ctx)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+
+	// client_secret_basic is the default unless the provider only takes the
+	// secret in the form
+	supports := func(method string) bool {
+		return ScopesCovered([]string{method}, metadata.TokenEndpointAuthMethodsSupported)
+	}
+	basic := supports("client_secret_basic") || !supports("client_secret_post")
+	token, err := exchangeCode(ctx, c.cfg.HTTPClient, metadata.TokenEndpoint, c.cfg.ClientID, c.cfg.ClientSecret, basic, redirectURI, code, codeVerifier)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	if token.IDToken == "" {
+		return ExternalIdentity{}, fmt.Errorf("%w: provider returned no ID token", ErrExternalLoginRejected)
+	}
+
+	claims, err := c.verifyIDToken(ctx, metadata, token.IDToken, nonce)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	identity := ExternalIdentity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}
+
+	// Some providers keep profile claims out of the ID token
+	if identity.Email == "" && metadata.UserInfoEndpoint != "" {
+		var info struct {
+			Sub           string    `json:"sub"`
+			Email         string    `json:"email"`
+			EmailVerified claimBool `json:"email_verified"`
+			Name          string    `json:"name"`
+		}
+		if err := getJSON(ctx, c.cfg.HTTPClient, metadata.UserInfoEndpoint, token.AccessToken, &info); err != nil {
+			return ExternalIdentity{}, err
+		}
+		if info.Sub != identity.Subject {
+			return ExternalIdentity{}, fmt.Errorf("%w: userinfo subject does not match the ID token", ErrExternalLoginRejected)
+		}
+		identity.Email = info.Email
+		identity.EmailVerified = bool(info.EmailVerified)
+		if identity.Name == "" {
+			identity.Name = info.Name
+		}
+	}
+
+	return identity, nil
+}
+
+// verifyIDToken validates an ID token as OpenID Connect Core section 3.1.3.7
+// requires: signature, issuer, audience, expiry and nonce
+func (c *OIDCConnector) verifyIDToken(ctx context.Context, metadata *oidcMetadata, idToken, nonce string) (*externalIDTokenClaims, error) {
+	claims := &externalIDTokenClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return c.publicKey(ctx, metadata, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(c.cfg.Issuer),
+		jwt.WithAudience(c.cfg.ClientID),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ID token: %v", ErrExternalLoginRejected, err)
+	}
+
+	switch {
+	case claims.ExpiresAt == nil:
+		return nil, fmt.Errorf("%w: ID token has no expiry", ErrExternalLoginRejected)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: ID token has no subject", ErrExternalLoginRejected)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != c.cfg.ClientID:
+		return nil, fmt.Errorf("%w: ID token was issued to another client", ErrExternalLoginRejected)
+	case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return nil, fmt.Errorf("%w: ID token nonce does not match", ErrExternalLoginRejected)
+	}
+	return claims, nil
+}
+
+// discover returns the provider's metadata, fetching it on first use
+func (c *OIDCConnector) discover(ctx context.Context) (*oidcMetadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.metadata != nil {
+		return c.metadata, nil
+	}
+
+	var metadata oidcMetadata
+	if err := getJSON(ctx, c.cfg.HTTPClient, c.cfg.Issuer+"/.well-known/openid-configuration", "", &metadata); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != c.cfg.Issuer {
+		return nil, fmt.Errorf("discovery document issuer %q does not match %q", metadata.Issuer, c.cfg.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document for %s is missing endpoints", c.cfg.Issuer)
+	}
+
+	c.metadata = &metadata
+	return c.metadata, nil
+}
+
+// publicKey returns the provider key named kid, refetching the key set when
+// the provider may have rotated keys since it was last read
+func (c *OIDCConnector) publicKey(ctx context.Context, metadata *oidcMetadata, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key, ok := c.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(c.keysFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set JWKS
+	if err := getJSON(ctx, c.cfg.HTTPClient, metadata.JWKSURI, "", &set); err != nil {
+		return nil, err
+	}
+	c.keys = make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if key, err := jwk.PublicKey(); err == nil {
+			c.keys[jwk.Kid] = key
+		}
+	}
+	c.keysFetched = time.Now()
+
+	if key, ok := c.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds kid among the cached keys. A token without a kid can only
+// be matched when the provider publishes a single key.
+func (c *OIDCConnector) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+// authCodeURL builds an authorization request for endpoint
+func authCodeURL(endpoint, clientID, redirectURI string, scopes []string, state, nonce, codeChallenge string) string {
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {FormatScope(scopes)},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {PKCEMethodS256},
+	}
+	if nonce != "" {
+		params.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+	return endpoint + separator + params.Encode()
+}
+
+// externalTokenResponse is a provider's token endpoint response
+type externalTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// exchangeCode redeems an authorization code at a provider's token endpoint,
+// sending the client secret with HTTP Basic or in the form
+func exchangeCode(ctx context.Context, client *http.Client, tokenURL, clientID, clientSecret string, basic bool, redirectURI, code, codeVerifier string) (*externalTokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {GrantAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {codeVerifier},
+	}
+	if !basic {
+		form.Set("client_id", clientID)
+		form.Set("client_secret", clientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basic {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token externalTokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, connectorMaxResponse)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("invalid token response (status %d): %w", resp.StatusCode, err)
+	}
+	// Some providers report errors with a 200 status
+	if token.Error != "" {
+		return nil, fmt.Errorf("%w: %s: %s", ErrExternalLoginRejected, token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("%w: provider returned no access token", ErrExternalLoginRejected)
+	}
+	return &token, nil
+}
+
+// getJSON fetches url into v, with bearer as the access token if set
+func getJSON(ctx context.Context, client *http.Client, url, bearer string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, connectorMaxResponse)).Decode(v); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", url, err)
+	}
+	return nil
+}
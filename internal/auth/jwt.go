@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // Claims represents the JWT claims
@@ -12,17 +13,71 @@ type Claims struct {
 	UserID  int64  `json:"user_id"`
 	Email   string `json:"email"`
 	IsAdmin bool   `json:"is_admin"`
+	// SessionID identifies the login session (refresh token family) the token was issued for
+	SessionID   string   `json:"sid,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
+	// OrgID is the organization the session is acting in and OrgRole the
+	// user's role there; both are empty until the user picks one
+	OrgID   int64  `json:"org_id,omitempty"`
+	OrgRole string `json:"org_role,omitempty"`
+	// APIKeyID is set when the request authenticated with an API key rather
+	// than an access token; it is never part of a token
+	APIKeyID int64 `json:"-"`
+	// ClientID and Scope are set on tokens issued to OAuth clients
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// Actor is set on impersonation tokens and names the admin acting as
+	// the user
+	Actor *Actor `json:"act,omitempty"`
+	// IssuedAtMicros is the issue time in microseconds since the Unix epoch.
+	// iat only has whole seconds, too coarse to order a token against a
+	// revocation made in the same second.
+	IssuedAtMicros int64 `json:"iat_us,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// JWTManager handles JWT token operations
+// Actor identifies who is acting on behalf of a token's user, after the
+// RFC 8693 act claim
+type Actor struct {
+	Subject string `json:"sub"`
+	UserID  int64  `json:"user_id"`
+	Email   string `json:"email"`
+}
+
+// issuedAt returns when the token was issued, to the microsecond if it
+// carries iat_us
+func (c *Claims) issuedAt() time.Time {
+	if c.IssuedAtMicros != 0 {
+		return time.UnixMicro(c.IssuedAtMicros)
+	}
+	if c.IssuedAt != nil {
+		return c.IssuedAt.Time
+	}
+	return time.Time{}
+}
+
+// HasPermission reports whether the token grants permission
+func (c *Claims) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// JWTManager handles JWT token operations. Tokens are signed either with a
+// shared HS256 secret or, when a KeyManager is configured, with rotating
+// asymmetric keys identified by the kid header.
 type JWTManager struct {
 	secretKey     string
+	keys          *KeyManager
 	accessExpiry  time.Duration
 	refreshExpiry time.Duration
 }
 
-// NewJWTManager creates a new JWT manager
+// NewJWTManager creates a new JWT manager that signs with an HS256 secret
 func NewJWTManager(secretKey string, accessExpiry, refreshExpiry time.Duration) *JWTManager {
 	return &JWTManager{
 		secretKey:     secretKey,
@@ -31,44 +86,130 @@ func NewJWTManager(secretKey string, accessExpiry, refreshExpiry time.Duration)
 	}
 }
 
+// NewAsymmetricJWTManager creates a JWT manager that signs with the active key
+// of keys and verifies against any key that has not expired
+func NewAsymmetricJWTManager(keys *KeyManager, accessExpiry, refreshExpiry time.Duration) *JWTManager {
+	return &JWTManager{
+		keys:          keys,
+		accessExpiry:  accessExpiry,
+		refreshExpiry: refreshExpiry,
+	}
+}
+
+// JWKS returns the public keys downstream services can verify tokens with.
+// It is empty when tokens are signed with a shared secret.
+func (m *JWTManager) JWKS() JWKS {
+	if m.keys == nil {
+		return JWKS{Keys: []JWK{}}
+	}
+	return m.keys.JWKS()
+}
+
+// sign serializes claims into a signed token
+func (m *JWTManager) sign(claims jwt.Claims) (string, error) {
+	if m.keys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(m.secretKey))
+	}
+
+	key, err := m.keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.Method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// keyFunc resolves the verification key for a token and rejects any
+// algorithm other than the one the key was issued for
+func (m *JWTManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	if m.keys == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(m.secretKey), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("token has no kid header")
+	}
+
+	key, err := m.keys.VerificationKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Private.Public(), nil
+}
+
 // GenerateAccessToken generates a new access token
 func (m *JWTManager) GenerateAccessToken(userID int64, email string, isAdmin bool) (string, error) {
-	claims := Claims{
+	return m.IssueAccessToken(Claims{
 		UserID:  userID,
 		Email:   email,
 		IsAdmin: isAdmin,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.accessExpiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+	})
+}
+
+// IssueAccessToken signs claims as an access token, filling in the jti and
+// the issued, not-before and expiry times. The issuer and subject are kept
+// as given.
+func (m *JWTManager) IssueAccessToken(claims Claims) (string, error) {
+	return m.IssueAccessTokenWithExpiry(claims, m.accessExpiry)
+}
+
+// IssueAccessTokenWithExpiry is IssueAccessToken for a token that expires
+// after expiry, capped at the regular access token expiry
+func (m *JWTManager) IssueAccessTokenWithExpiry(claims Claims, expiry time.Duration) (string, error) {
+	if expiry <= 0 || expiry > m.accessExpiry {
+		expiry = m.accessExpiry
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(m.secretKey))
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    claims.Issuer,
+		Subject:   claims.Subject,
+		ID:        uuid.New().String(),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+	}
+	claims.IssuedAtMicros = now.UnixMicro()
+
+	return m.sign(claims)
+}
+
+// AccessExpiry returns how long access tokens stay valid
+func (m *JWTManager) AccessExpiry() time.Duration {
+	return m.accessExpiry
 }
 
-// GenerateRefreshToken generates a new refresh token (longer expiry, simpler claims)
+// RefreshExpiry returns how long refresh tokens stay valid
+func (m *JWTManager) RefreshExpiry() time.Duration {
+	return m.refreshExpiry
+}
+
+// GenerateRefreshToken generates a new refresh token (longer expiry, simpler claims).
+// Each token carries a unique ID so two tokens issued in the same second differ.
 func (m *JWTManager) GenerateRefreshToken(userID int64) (string, error) {
 	claims := jwt.RegisteredClaims{
+		ID:        uuid.New().String(),
 		Subject:   fmt.Sprintf("%d", userID),
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.refreshExpiry)),
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(m.secretKey))
+	return m.sign(claims)
 }
 
 // ValidateToken validates and parses a token
 func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(m.secretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, m.keyFunc)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -79,17 +220,18 @@ func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, fmt.Errorf("invalid token")
 	}
 
+	// Access tokens have no audience; ID tokens are addressed to a client
+	// and must not be accepted in their place
+	if len(claims.Audience) > 0 {
+		return nil, fmt.Errorf("token is not an access token")
+	}
+
 	return claims, nil
 }
 
 // ValidateRefreshToken validates a refresh token and returns the user ID
 func (m *JWTManager) ValidateRefreshToken(tokenString string) (int64, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(m.secretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, m.keyFunc)
 
 	if err != nil {
 		return 0, fmt.Errorf("failed to parse refresh token: %w", err)
@@ -110,9 +252,7 @@ func (m *JWTManager) ValidateRefreshToken(tokenString string) (int64, error) {
 
 // GetTokenExpiry returns the expiry time of a token
 func (m *JWTManager) GetTokenExpiry(tokenString string) (time.Time, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(m.secretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, m.keyFunc)
 
 	if err != nil {
 		return time.Time{}, err
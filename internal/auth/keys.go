@@ -0,0 +1,458 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// Supported asymmetric signing algorithms
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// SigningKey is a private key used to sign tokens, identified by its kid
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	CreatedAt time.Time
+	// ExpiresAt is zero while the key is active. Once the key is rotated out it
+	// keeps verifying tokens until ExpiresAt.
+	ExpiresAt time.Time
+}
+
+// Method returns the JWT signing method for the key's algorithm
+func (k *SigningKey) Method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// KeyStore persists signing keys so every replica signs and verifies with the same set
+type KeyStore interface {
+	// ListKeys returns keys that have not expired, newest first
+	ListKeys(ctx context.Context) ([]SigningKey, error)
+	SaveKey(ctx context.Context, key SigningKey) error
+	// RetireKeys sets ExpiresAt on every active key older than activeID, so
+	// replicas rotating at the same time never retire each other's new key
+	RetireKeys(ctx context.Context, activeID string, expiresAt time.Time) error
+	DeleteExpiredKeys(ctx context.Context) error
+}
+
+// GenerateSigningKey creates a new key for algorithm with a random kid
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", algorithm, err)
+	}
+
+	return &SigningKey{
+		ID:        uuid.New().String(),
+		Algorithm: algorithm,
+		Private:   private,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// KeyManager holds the active signing key and every key that may still verify
+// outstanding tokens, and rotates them on a schedule
+type KeyManager struct {
+	store            KeyStore
+	algorithm        string
+	rotationInterval time.Duration
+	retention        time.Duration
+
+	mu         sync.RWMutex
+	keys       map[string]*SigningKey
+	active     *SigningKey
+	lastReload time.Time
+}
+
+// NewKeyManager creates a key manager. Retired keys keep verifying for
+// retention, which must cover the longest lived token they signed.
+func NewKeyManager(store KeyStore, algorithm string, rotationInterval, retention time.Duration) *KeyManager {
+	return &KeyManager{
+		store:            store,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		retention:        retention,
+		keys:             make(map[string]*SigningKey),
+	}
+}
+
+// Load reads keys from the store and rotates if there is no usable active key
+func (m *KeyManager) Load(ctx context.Context) error {
+	if err := m.reload(ctx); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	due := m.active == nil ||
+		m.active.Algorithm != m.algorithm ||
+		time.Since(m.active.CreatedAt) >= m.rotationInterval
+	m.mu.RUnlock()
+
+	if due {
+		return m.Rotate(ctx)
+	}
+	return nil
+}
+
+// Rotate generates a new active key and schedules the previous ones to expire.
+// When replicas rotate at once the newest of their keys ends up active.
+func (m *KeyManager) Rotate(ctx context.Context) error {
+	key, err := GenerateSigningKey(m.algorithm)
+	if err != nil {
+		return err
+	}
+
+	if err := m.store.SaveKey(ctx, *key); err != nil {
+		return fmt.Errorf("failed to save signing key: %w", err)
+	}
+
+	if err := m.store.RetireKeys(ctx, key.ID, time.Now().Add(m.retention)); err != nil {
+		return fmt.Errorf("failed to retire signing keys: %w", err)
+	}
+
+	return m.reload(ctx)
+}
+
+// Run rotates keys when they are due and picks up keys rotated by other
+// replicas. It blocks until ctx is cancelled.
+func (m *KeyManager) Run(ctx context.Context, checkInterval time.Duration, logger zerolog.Logger) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.store.DeleteExpiredKeys(ctx); err != nil {
+				logger.Error().Err(err).Msg("Failed to prune signing keys")
+			}
+			if err := m.Load(ctx); err != nil {
+				logger.Error().Err(err).Msg("Failed to rotate signing keys")
+			}
+		}
+	}
+}
+
+// reload replaces the in-memory key set with the store's contents
+func (m *KeyManager) reload(ctx context.Context) error {
+	stored, err := m.store.ListKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	keys := make(map[string]*SigningKey, len(stored))
+	var active *SigningKey
+	for i := range stored {
+		key := &stored[i]
+		keys[key.ID] = key
+		if key.ExpiresAt.IsZero() && (active == nil || newerKey(key, active)) {
+			active = key
+		}
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.active = active
+	m.lastReload = time.Now()
+	m.mu.Unlock()
+
+	return nil
+}
+
+// newerKey reports whether a was created after b, ordering keys created at
+// the same time by kid as the store does
+func newerKey(a, b *SigningKey) bool {
+	if a.CreatedAt.Equal(b.CreatedAt) {
+		return a.ID > b.ID
+	}
+	return a.CreatedAt.After(b.CreatedAt)
+}
+
+// SigningKey returns the key new tokens should be signed with
+func (m *KeyManager) SigningKey() (*SigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.active == nil {
+		return nil, fmt.Errorf("no active signing key")
+	}
+	return m.active, nil
+}
+
+// VerificationKey returns the public key for kid. Unknown kids trigger a
+// throttled reload, since another replica may have just rotated.
+func (m *KeyManager) VerificationKey(kid string) (*SigningKey, error) {
+	m.mu.RLock()
+	key, ok := m.keys[kid]
+	stale := time.Since(m.lastReload) > 10*time.Second
+	m.mu.RUnlock()
+
+	if !ok && stale {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := m.reload(ctx); err != nil {
+			return nil, err
+		}
+
+		m.mu.RLock()
+		key, ok = m.keys[kid]
+		m.mu.RUnlock()
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	if !key.ExpiresAt.IsZero() && time.Now().After(key.ExpiresAt) {
+		return nil, fmt.Errorf("signing key expired: %s", kid)
+	}
+	return key, nil
+}
+
+// JWK is a public key in RFC 7517 JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every key that may verify a live token
+func (m *KeyManager) JWKS() JWKS {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range m.keys {
+		if jwk, err := publicJWK(key); err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// publicJWK encodes the public half of key
+func publicJWK(key *SigningKey) (JWK, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+
+	switch pub := key.Private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	return jwk, nil
+}
+
+// PublicKey decodes the key for verifying signatures. Only keys published
+// for signing are accepted.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, fmt.Errorf("key %s is not a signing key", k.Kid)
+	}
+	b64 := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := b64(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := b64(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := b64(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+		y, err := b64(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("EC point is not on curve %s", k.Crv)
+		}
+		return pub, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := b64(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+// MemoryKeyStore keeps signing keys in process memory. Keys are lost on
+// restart and not shared between replicas, so it suits tests and single
+// instance deployments.
+type MemoryKeyStore struct {
+	mu   sync.Mutex
+	keys []SigningKey
+}
+
+// NewMemoryKeyStore creates an empty in-memory key store
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{}
+}
+
+// ListKeys returns keys that have not expired, newest first
+func (s *MemoryKeyStore) ListKeys(ctx context.Context) ([]SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	keys := []SigningKey{}
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		if s.keys[i].ExpiresAt.IsZero() || s.keys[i].ExpiresAt.After(now) {
+			keys = append(keys, s.keys[i])
+		}
+	}
+	return keys, nil
+}
+
+// SaveKey stores a new key
+func (s *MemoryKeyStore) SaveKey(ctx context.Context, key SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+// RetireKeys sets ExpiresAt on every active key older than activeID
+func (s *MemoryKeyStore) RetireKeys(ctx context.Context, activeID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Keys are kept in the order they were saved
+	for i := range s.keys {
+		if s.keys[i].ID != activeID {
+			continue
+		}
+		for j := range s.keys[:i] {
+			if s.keys[j].ExpiresAt.IsZero() {
+				s.keys[j].ExpiresAt = expiresAt
+			}
+		}
+		break
+	}
+	return nil
+}
+
+// DeleteExpiredKeys drops keys past their expiry
+func (s *MemoryKeyStore) DeleteExpiredKeys(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	kept := s.keys[:0]
+	for _, key := range s.keys {
+		if key.ExpiresAt.IsZero() || key.ExpiresAt.After(now) {
+			kept = append(kept, key)
+		}
+	}
+	s.keys = kept
+	return nil
+}
+
+// marshalPrivateKey encodes a signing key as PKCS #8 DER
+func marshalPrivateKey(key crypto.Signer) ([]byte, error) {
+	return x509.MarshalPKCS8PrivateKey(key)
+}
+
+// parsePrivateKey decodes a PKCS #8 DER signing key
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Two replicas rotating at once save their keys before either retires the
+// older ones. The newer key must stay active.
+func TestRotateConcurrently(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryKeyStore()
+
+	first, err := GenerateSigningKey(AlgorithmEdDSA)
+	require.NoError(t, err)
+	second, err := GenerateSigningKey(AlgorithmEdDSA)
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveKey(ctx, *first))
+	require.NoError(t, store.SaveKey(ctx, *second))
+	require.NoError(t, store.RetireKeys(ctx, first.ID, time.Now().Add(time.Hour)))
+	require.NoError(t, store.RetireKeys(ctx, second.ID, time.Now().Add(time.Hour)))
+
+	keys, err := store.ListKeys(ctx)
+	require.NoError(t, err)
+	var active []string
+	for _, key := range keys {
+		if key.ExpiresAt.IsZero() {
+			active = append(active, key.ID)
+		}
+	}
+	assert.Equal(t, []string{second.ID}, active)
+
+	// Both replicas sign with it, and the first key still verifies
+	manager := NewKeyManager(store, AlgorithmEdDSA, time.Hour, time.Hour)
+	require.NoError(t, manager.Load(ctx))
+	signing, err := manager.SigningKey()
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, signing.ID)
+	_, err = manager.VerificationKey(first.ID)
+	assert.NoError(t, err)
+}
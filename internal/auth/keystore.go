@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// PostgresKeyStore keeps signing keys in the signing_keys table. Private keys
+// are encrypted with cipher before they are written.
+type PostgresKeyStore struct {
+	queries sqlc.Querier
+	cipher  *Cipher
+}
+
+// NewPostgresKeyStore creates a key store backed by Postgres
+func NewPostgresKeyStore(queries sqlc.Querier, cipher *Cipher) *PostgresKeyStore {
+	return &PostgresKeyStore{
+		queries: queries,
+		cipher:  cipher,
+	}
+}
+
+// ListKeys returns keys that have not expired, newest first
+func (s *PostgresKeyStore) ListKeys(ctx context.Context) ([]SigningKey, error) {
+	rows, err := s.queries.ListSigningKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]SigningKey, 0, len(rows))
+	for _, row := range rows {
+		der, err := s.cipher.Decrypt(row.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt signing key %s: %w", row.ID, err)
+		}
+
+		private, err := parsePrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing key %s: %w", row.ID, err)
+		}
+
+		key := SigningKey{
+			ID:        row.ID,
+			Algorithm: row.Algorithm,
+			Private:   private,
+			CreatedAt: row.CreatedAt,
+		}
+		if row.ExpiresAt.Valid {
+			key.ExpiresAt = row.ExpiresAt.Time
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// SaveKey encrypts and stores a new key
+func (s *PostgresKeyStore) SaveKey(ctx context.Context, key SigningKey) error {
+	der, err := marshalPrivateKey(key.Private)
+	if err != nil {
+		return fmt.Errorf("failed to encode signing key: %w", err)
+	}
+
+	encrypted, err := s.cipher.Encrypt(der)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.queries.CreateSigningKey(ctx, sqlc.CreateSigningKeyParams{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: encrypted,
+	})
+	return err
+}
+
+// RetireKeys sets ExpiresAt on every active key older than activeID
+func (s *PostgresKeyStore) RetireKeys(ctx context.Context, activeID string, expiresAt time.Time) error {
+	return s.queries.RetireSigningKeys(ctx, sqlc.RetireSigningKeysParams{
+		ExpiresAt: expiresAt,
+		ActiveID:  activeID,
+	})
+}
+
+// DeleteExpiredKeys drops keys past their expiry
+func (s *PostgresKeyStore) DeleteExpiredKeys(ctx context.Context) error {
+	return s.queries.DeleteExpiredSigningKeys(ctx)
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// freeLoginAttempts is how many failures are tolerated before backoff starts
+const freeLoginAttempts = 3
+
+// LockoutPolicy configures how failed logins are throttled
+type LockoutPolicy struct {
+	// MaxAttempts is how many consecutive failures lock an account
+	MaxAttempts int
+	// IPMaxAttempts is how many failures lock out a client IP across all accounts
+	IPMaxAttempts int
+	// LockoutDuration is how long a lockout lasts. Failures older than this
+	// are forgotten.
+	LockoutDuration time.Duration
+	// BackoffBase is the delay after the first failure past the free attempts;
+	// it doubles with each further failure until the lockout threshold
+	BackoffBase time.Duration
+}
+
+// Lockout describes an account or IP that has just been locked out
+type Lockout struct {
+	Scope    string // "account" or "ip"
+	Subject  string // email or IP address
+	Failures int32
+	Until    time.Time
+}
+
+// LoginThrottle tracks failed logins per account and per client IP in
+// Postgres, delaying and then locking out further attempts. Counters are
+// shared by all replicas.
+type LoginThrottle struct {
+	queries sqlc.Querier
+	policy  LockoutPolicy
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewLoginThrottle creates a login throttle backed by Postgres
+func NewLoginThrottle(queries sqlc.Querier, policy LockoutPolicy) *LoginThrottle {
+	return &LoginThrottle{
+		queries: queries,
+		policy:  policy,
+	}
+}
+
+// Check returns how long the caller must wait before another login attempt
+// for email from ip is allowed, or zero if it may proceed
+func (t *LoginThrottle) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := time.Now()
+	t.prune(ctx, now)
+
+	var wait time.Duration
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		attempt, err := t.queries.GetLoginAttempt(ctx, key)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return 0, err
+		}
+		if attempt.BlockedUntil.Valid && attempt.BlockedUntil.Time.After(now) {
+			if d := attempt.BlockedUntil.Time.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait, nil
+}
+
+// Fail records a failed login for email from ip and applies backoff. It
+// returns the lockouts the failure triggered, if any.
+func (t *LoginThrottle) Fail(ctx context.Context, email, ip string) ([]Lockout, error) {
+	now := time.Now()
+
+	targets := []struct {
+		scope, subject, key string
+		max                 int
+	}{
+		{"account", normalizeEmail(email), accountKey(email), t.policy.MaxAttempts},
+		{"ip", ip, ipKey(ip), t.policy.IPMaxAttempts},
+	}
+
+	var lockouts []Lockout
+	for _, target := range targets {
+		attempt, err := t.queries.RecordLoginFailure(ctx, sqlc.RecordLoginFailureParams{
+			Key:         target.key,
+			FailedAt:    now,
+			ResetBefore: now.Add(-t.policy.LockoutDuration),
+		})
+		if err != nil {
+			return lockouts, err
+		}
+
+		delay, locked := t.backoff(int(attempt.Failures), target.max)
+		if delay == 0 {
+			continue
+		}
+
+		until := now.Add(delay)
+		if err := t.queries.BlockLoginKey(ctx, sqlc.BlockLoginKeyParams{
+			BlockedUntil: until,
+			Key:          target.key,
+		}); err != nil {
+			return lockouts, err
+		}
+
+		// Report the lockout once, when the threshold is crossed
+		if locked && int(attempt.Failures) == target.max {
+			lockouts = append(lockouts, Lockout{
+				Scope:    target.scope,
+				Subject:  target.subject,
+				Failures: attempt.Failures,
+				Until:    until,
+			})
+		}
+	}
+	return lockouts, nil
+}
+
+// Succeed resets the failure count for email after a successful login. The
+// IP counter is left alone so one good password cannot cover for a spray
+// across many accounts.
+func (t *LoginThrottle) Succeed(ctx context.Context, email string) error {
+	_, err := t.queries.DeleteLoginAttempt(ctx, accountKey(email))
+	return err
+}
+
+// Unlock clears failures and any lockout for email using q, so the caller can
+// do it in a transaction. It reports whether there was anything to clear.
+func (t *LoginThrottle) Unlock(ctx context.Context, q sqlc.Querier, email string) (bool, error) {
+	rows, err := q.DeleteLoginAttempt(ctx, accountKey(email))
+	return rows > 0, err
+}
+
+// backoff returns how long to block after the given number of consecutive
+// failures, and whether that block is a full lockout
+func (t *LoginThrottle) backoff(failures, max int) (time.Duration, bool) {
+	if max > 0 && failures >= max {
+		return t.policy.LockoutDuration, true
+	}
+	if failures <= freeLoginAttempts {
+		return 0, false
+	}
+
+	delay := t.policy.BackoffBase
+	for i := freeLoginAttempts + 1; i < failures && delay < t.policy.LockoutDuration; i++ {
+		delay *= 2
+	}
+	if delay > t.policy.LockoutDuration {
+		delay = t.policy.LockoutDuration
+	}
+	return delay, false
+}
+
+// prune drops counters that can no longer affect a login, at most once a minute
+func (t *LoginThrottle) prune(ctx context.Context, now time.Time) {
+	t.mu.Lock()
+	if now.Sub(t.lastPrune) < time.Minute {
+		t.mu.Unlock()
+		return
+	}
+	t.lastPrune = now
+	t.mu.Unlock()
+
+	// Best effort: stale counters are reset on the next failure anyway
+	_ = t.queries.DeleteStaleLoginAttempts(ctx, now.Add(-t.policy.LockoutDuration))
+}
+
+// normalizeEmail folds case and whitespace so variants of an address share a counter
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func accountKey(email string) string {
+	return "account:" + normalizeEmail(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -1,57 +1,262 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// MinPasswordLength is the minimum allowed password length
+const MinPasswordLength = 8
+
+// Password hashing algorithms
 const (
-	// MinPasswordLength is the minimum allowed password length
-	MinPasswordLength = 8
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
 
-	// BcryptCost is the computational cost for bcrypt hashing
-	// Higher is more secure but slower (range: 4-31, recommended: 10-14)
-	BcryptCost = 12
+var (
+	errInvalidPassword = errors.New("invalid password")
+	errUnknownHash     = errors.New("unrecognized password hash format")
 )
 
-// HashPassword generates a bcrypt hash of the password
-func HashPassword(password string) (string, error) {
-	if len(password) < MinPasswordLength {
-		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+// Hasher hashes passwords with one algorithm and set of parameters
+type Hasher interface {
+	// Hash returns the encoded hash of password
+	Hash(password string) (string, error)
+	// Verify checks password against an encoded hash made by this algorithm,
+	// using the parameters recorded in the hash
+	Verify(password, hash string) error
+	// NeedsRehash reports whether hash was made by another algorithm or
+	// with parameters other than this hasher's
+	NeedsRehash(hash string) bool
+}
+
+// Argon2idHasher hashes passwords with argon2id (RFC 9106), encoded in PHC
+// string format: $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32 // KiB
+	Time        uint32
+	Parallelism uint8
+}
+
+// DefaultArgon2idHasher uses the second recommended option of RFC 9106
+func DefaultArgon2idHasher() Argon2idHasher {
+	return Argon2idHasher{Memory: 64 * 1024, Time: 3, Parallelism: 4}
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var phcEncoding = base64.RawStdEncoding
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Parallelism,
+		phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Verify(password, hash string) error {
+	params, salt, key, err := parseArgon2id(hash)
 	if err != nil {
-		return "", fmt.Errorf("failed to hash password: %w", err)
+		return err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return errInvalidPassword
+	}
+	return nil
+}
+
+func (h Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := parseArgon2id(hash)
+	return err != nil || params != h
+}
+
+// parseArgon2id decodes a PHC argon2id string into its parameters, salt and key
+func parseArgon2id(hash string) (Argon2idHasher, []byte, []byte, error) {
+	var params Argon2idHasher
+
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, errUnknownHash
 	}
 
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version: %s", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+
+	salt, err := phcEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	key, err := phcEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2 key: %w", err)
+	}
+
+	return params, salt, key, nil
+}
+
+// BcryptHasher hashes passwords with bcrypt, in its standard modular crypt
+// format ($2a$12$...). bcrypt only reads the first 72 bytes of a password, so
+// longer passwords are rejected rather than silently truncated.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		if err == bcrypt.ErrPasswordTooLong {
+			return "", fmt.Errorf("password must be at most 72 bytes")
+		}
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
 	return string(hash), nil
 }
 
-// VerifyPassword checks if the provided password matches the hash
-func VerifyPassword(password, hash string) error {
+func (h BcryptHasher) Verify(password, hash string) error {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	if err != nil {
 		if err == bcrypt.ErrMismatchedHashAndPassword {
-			return fmt.Errorf("invalid password")
+			return errInvalidPassword
 		}
 		return fmt.Errorf("failed to verify password: %w", err)
 	}
 	return nil
 }
 
-// ValidatePasswordStrength checks if a password meets security requirements
-func ValidatePasswordStrength(password string) error {
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// hasherFor returns the hasher that made hash, with the parameters recorded
+// in it
+func hasherFor(hash string) (Hasher, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, _, _, err := parseArgon2id(hash)
+		if err != nil {
+			return nil, err
+		}
+		return params, nil
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return nil, errUnknownHash
+		}
+		return BcryptHasher{Cost: cost}, nil
+	default:
+		return nil, errUnknownHash
+	}
+}
+
+var (
+	passwordHasher Hasher = DefaultArgon2idHasher()
+
+	// dummyHasher is the hasher SimulatePasswordCheck imitates
+	dummyHasher   Hasher = passwordHasher
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// SetPasswordHasher changes how new passwords are hashed. Existing hashes keep
+// verifying and are reported as needing a rehash. Call it before serving
+// requests.
+func SetPasswordHasher(h Hasher) {
+	passwordHasher = h
+	dummyHasher = h
+	dummyHashOnce = sync.Once{}
+}
+
+// CalibratePasswordCheck makes SimulatePasswordCheck as slow as the slowest
+// of the configured hasher and the hashers that made hashes, a sample of the
+// stored password hashes. Hashes made with older algorithms or parameters
+// would otherwise take a different time to check than an unknown email. Call
+// it after SetPasswordHasher and before serving requests.
+func CalibratePasswordCheck(hashes []string) {
+	current, err := passwordHasher.Hash("dummy-password")
+	if err != nil {
+		return
+	}
+	slowest, slowestTime := passwordHasher, timeVerify(passwordHasher, current)
+
+	for _, hash := range hashes {
+		hasher, err := hasherFor(hash)
+		if err != nil {
+			continue
+		}
+		if elapsed := timeVerify(hasher, hash); elapsed > slowestTime {
+			slowest, slowestTime = hasher, elapsed
+		}
+	}
+
+	dummyHasher = slowest
+	dummyHashOnce = sync.Once{}
+}
+
+// timeVerify returns how long hasher takes to check a wrong password against
+// hash
+func timeVerify(hasher Hasher, hash string) time.Duration {
+	start := time.Now()
+	_ = hasher.Verify("wrong-password", hash)
+	return time.Since(start)
+}
+
+// HashPassword hashes the password with the configured hasher
+func HashPassword(password string) (string, error) {
 	if len(password) < MinPasswordLength {
-		return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
 	}
 
-	// Add additional checks as needed:
-	// - Must contain uppercase letter
-	// - Must contain number
-	// - Must contain special character
-	// etc.
+	return passwordHasher.Hash(password)
+}
 
-	return nil
+// VerifyPassword checks if the provided password matches the hash. On a match
+// it also reports whether the hash should be replaced by HashPassword(password)
+// because it uses an outdated algorithm or parameters.
+func VerifyPassword(password, hash string) (bool, error) {
+	hasher, err := hasherFor(hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify password: %w", err)
+	}
+	if err := hasher.Verify(password, hash); err != nil {
+		return false, err
+	}
+	return passwordHasher.NeedsRehash(hash), nil
+}
+
+// SimulatePasswordCheck takes as long as VerifyPassword does against a real
+// hash, the slowest one CalibratePasswordCheck found. Call it when there is
+// no hash to check, such as for an unknown email, so the response time does
+// not reveal which accounts exist.
+func SimulatePasswordCheck(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = dummyHasher.Hash("dummy-password")
+	})
+	_ = dummyHasher.Verify(password, dummyHash)
 }
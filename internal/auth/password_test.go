@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastArgon2idHasher keeps tests quick; bcrypt at cost 11 is far slower
+var fastArgon2idHasher = Argon2idHasher{Memory: 8 * 1024, Time: 1, Parallelism: 1}
+
+func TestCalibratePasswordCheck(t *testing.T) {
+	t.Cleanup(func() { SetPasswordHasher(DefaultArgon2idHasher()) })
+	SetPasswordHasher(fastArgon2idHasher)
+
+	legacy, err := BcryptHasher{Cost: 11}.Hash("legacy-password")
+	require.NoError(t, err)
+	current, err := fastArgon2idHasher.Hash("current-password")
+	require.NoError(t, err)
+
+	CalibratePasswordCheck([]string{current, "not-a-hash"})
+	assert.Equal(t, Hasher(fastArgon2idHasher), dummyHasher)
+
+	// Accounts still on bcrypt must not answer slower than unknown emails
+	CalibratePasswordCheck([]string{current, legacy})
+	assert.Equal(t, Hasher(BcryptHasher{Cost: 11}), dummyHasher)
+	SimulatePasswordCheck("password")
+	assert.True(t, strings.HasPrefix(dummyHash, "$2a$11$"), dummyHash)
+
+	// Changing the hasher starts over
+	SetPasswordHasher(fastArgon2idHasher)
+	assert.Equal(t, Hasher(fastArgon2idHasher), dummyHasher)
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordPolicy decides which new passwords are acceptable
+type PasswordPolicy struct {
+	MinLength int // in characters
+	MaxLength int // in characters; 0 means no limit
+
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// MinStrength is the lowest acceptable PasswordStrength score, 0-4
+	MinStrength int
+
+	// RejectPersonalInfo refuses passwords containing the user's email or name
+	RejectPersonalInfo bool
+
+	// Breached, if set, refuses passwords found in a breach corpus
+	Breached *BreachedPasswords
+}
+
+// PolicyViolation is one rule a password failed
+type PolicyViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Check returns every rule password breaks for a user with the given email
+// and name. An empty result means the password is acceptable.
+func (p *PasswordPolicy) Check(password, email, name string) ([]PolicyViolation, error) {
+	var violations []PolicyViolation
+	fail := func(rule, message string) {
+		violations = append(violations, PolicyViolation{Rule: rule, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		fail("min_length", fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		fail("max_length", fmt.Sprintf("must be at most %d characters", p.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		fail("uppercase", "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		fail("lowercase", "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		fail("digit", "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		fail("symbol", "must contain a symbol")
+	}
+
+	personal := personalTokens(email, name)
+	if p.RejectPersonalInfo {
+		lowered := strings.ToLower(password)
+		for _, token := range personal {
+			if strings.Contains(lowered, token) {
+				fail("personal_info", "must not contain your email address or name")
+				break
+			}
+		}
+	}
+
+	if p.MinStrength > 0 && PasswordStrength(password, personal...) < p.MinStrength {
+		fail("strength", "is too easy to guess; use a longer password or an unrelated phrase")
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check breached passwords: %w", err)
+		}
+		if breached {
+			fail("breached", "has appeared in a data breach; choose a different password")
+		}
+	}
+
+	return violations, nil
+}
+
+// personalTokens splits an email and name into the lowercase words an
+// attacker targeting this user would try first
+func personalTokens(email, name string) []string {
+	local := email
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		local = email[:at]
+	}
+
+	fields := strings.FieldsFunc(strings.ToLower(local+" "+name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var tokens []string
+	for _, field := range append(fields, strings.ToLower(local)) {
+		if utf8.RuneCountInString(field) >= 3 {
+			tokens = append(tokens, field)
+		}
+	}
+	return tokens
+}
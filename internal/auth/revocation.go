@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// RevocationList rejects access tokens before they expire, either one token
+// at a time by jti, every token of a login session by sid, or every token a
+// user was issued before a point in time. Tokens of deleted users and OAuth
+// clients are rejected too.
+// Lookups go to Postgres so all replicas agree; results are cached for
+// cacheTTL, so other replicas may accept a revoked token for that long.
+type RevocationList struct {
+	queries       sqlc.Querier
+	tokenLifetime time.Duration
+	cacheTTL      time.Duration
+
+	mu        sync.Mutex
+	cache     map[string]revocationEntry
+	lastPrune time.Time
+}
+
+type revocationEntry struct {
+	userID      int64
+	sessionID   string
+	clientID    string
+	revoked     bool
+	cachedUntil time.Time
+}
+
+// NewRevocationList creates a revocation list backed by Postgres.
+// tokenLifetime is the longest an access token stays valid, and so how long a
+// revoked session has to be remembered.
+func NewRevocationList(queries sqlc.Querier, tokenLifetime, cacheTTL time.Duration) *RevocationList {
+	return &RevocationList{
+		queries:       queries,
+		tokenLifetime: tokenLifetime,
+		cacheTTL:      cacheTTL,
+		cache:         make(map[string]revocationEntry),
+	}
+}
+
+// Revoke rejects a single access token until it expires
+func (r *RevocationList) Revoke(ctx context.Context, claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		// Tokens without a jti can only be revoked through RevokeUser
+		return r.RevokeUser(ctx, claims.UserID)
+	}
+
+	if err := r.queries.RevokeToken(ctx, sqlc.RevokeTokenParams{
+		Jti:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.cache[claims.ID] = revocationEntry{
+		userID:      claims.UserID,
+		sessionID:   claims.SessionID,
+		clientID:    claims.ClientID,
+		revoked:     true,
+		cachedUntil: claims.ExpiresAt.Time,
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// RevokeSession rejects every access token issued to userID for the login
+// session sessionID, the refresh token family named by the sid claim
+func (r *RevocationList) RevokeSession(ctx context.Context, userID int64, sessionID string) error {
+	if err := r.queries.RevokeSession(ctx, sqlc.RevokeSessionParams{
+		SessionID: sessionID,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(r.tokenLifetime),
+	}); err != nil {
+		return err
+	}
+
+	r.forget(func(entry revocationEntry) bool {
+		return entry.userID == userID && entry.sessionID == sessionID
+	})
+	return nil
+}
+
+// RevokeOtherSessions rejects the access tokens of every login session of
+// userID except currentSessionID. Sessions are found through their refresh
+// tokens, so call it before deleting them.
+func (r *RevocationList) RevokeOtherSessions(ctx context.Context, userID int64, currentSessionID string) error {
+	if err := r.queries.RevokeUserSessionsExcept(ctx, sqlc.RevokeUserSessionsExceptParams{
+		ExpiresAt:       time.Now().Add(r.tokenLifetime),
+		UserID:          userID,
+		ExceptSessionID: currentSessionID,
+	}); err != nil {
+		return err
+	}
+
+	r.forget(func(entry revocationEntry) bool {
+		return entry.userID == userID && entry.sessionID != currentSessionID
+	})
+	return nil
+}
+
+// RevokeUser rejects every access token issued to userID up to now. Tokens
+// issued after it returns are accepted.
+func (r *RevocationList) RevokeUser(ctx context.Context, userID int64) error {
+	// Postgres keeps microseconds, the precision of the iat_us claim
+	watermark := time.Now().Truncate(time.Microsecond)
+	if err := r.queries.InvalidateUserTokens(ctx, sqlc.InvalidateUserTokensParams{
+		TokensInvalidBefore: watermark,
+		ID:                  userID,
+	}); err != nil {
+		return err
+	}
+
+	r.forget(func(entry revocationEntry) bool {
+		return entry.userID == userID
+	})
+
+	// Tokens issued in the watermark's microsecond are rejected, so let it
+	// pass before the caller issues new ones
+	time.Sleep(time.Until(watermark.Add(time.Microsecond)))
+	return nil
+}
+
+// ClientDeleted drops cached verdicts for the tokens of the OAuth client
+// clientID once it has been deleted, so this replica rejects them at once
+func (r *RevocationList) ClientDeleted(clientID string) {
+	r.forget(func(entry revocationEntry) bool {
+		return entry.clientID == clientID
+	})
+}
+
+// forget drops cached verdicts that a token is still valid where match
+// returns true, so the next lookup sees a new revocation
+func (r *RevocationList) forget(match func(revocationEntry) bool) {
+	r.mu.Lock()
+	for jti, entry := range r.cache {
+		if !entry.revoked && match(entry) {
+			delete(r.cache, jti)
+		}
+	}
+	r.mu.Unlock()
+}
+
+// IsRevoked reports whether the token described by claims has been revoked
+func (r *RevocationList) IsRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	now := time.Now()
+	r.prune(ctx, now)
+
+	if claims.ID != "" {
+		r.mu.Lock()
+		entry, ok := r.cache[claims.ID]
+		r.mu.Unlock()
+		if ok && now.Before(entry.cachedUntil) {
+			return entry.revoked, nil
+		}
+	}
+
+	revoked, err := r.queries.IsTokenRevoked(ctx, sqlc.IsTokenRevokedParams{
+		Jti:       claims.ID,
+		SessionID: claims.SessionID,
+		UserID:    claims.UserID,
+		IssuedAt:  claims.issuedAt(),
+		ClientID:  claims.ClientID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if claims.ID != "" {
+		cachedUntil := now.Add(r.cacheTTL)
+		if revoked && claims.ExpiresAt != nil {
+			cachedUntil = claims.ExpiresAt.Time
+		}
+
+		r.mu.Lock()
+		r.cache[claims.ID] = revocationEntry{
+			userID:      claims.UserID,
+			sessionID:   claims.SessionID,
+			clientID:    claims.ClientID,
+			revoked:     revoked,
+			cachedUntil: cachedUntil,
+		}
+		r.mu.Unlock()
+	}
+
+	return revoked, nil
+}
+
+// prune drops stale cache entries and expired rows, at most once a minute
+func (r *RevocationList) prune(ctx context.Context, now time.Time) {
+	r.mu.Lock()
+	if now.Sub(r.lastPrune) < time.Minute {
+		r.mu.Unlock()
+		return
+	}
+	r.lastPrune = now
+
+	for jti, entry := range r.cache {
+		if now.After(entry.cachedUntil) {
+			delete(r.cache, jti)
+		}
+	}
+	r.mu.Unlock()
+
+	// Best effort: expired rows can no longer match a valid token
+	_ = r.queries.DeleteExpiredRevokedTokens(ctx)
+	_ = r.queries.DeleteExpiredRevokedSessions(ctx)
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
+)
+
+// revocationQueries answers the revocation queries the way Postgres does
+type revocationQueries struct {
+	sqlc.Querier
+	revoked         map[string]bool
+	revokedSessions map[string]bool
+	invalidBefore   map[int64]time.Time
+}
+
+func newRevocationQueries() *revocationQueries {
+	return &revocationQueries{
+		revoked:         map[string]bool{},
+		revokedSessions: map[string]bool{},
+		invalidBefore:   map[int64]time.Time{},
+	}
+}
+
+func (q *revocationQueries) RevokeToken(ctx context.Context, arg sqlc.RevokeTokenParams) error {
+	q.revoked[arg.Jti] = true
+	return nil
+}
+
+func (q *revocationQueries) RevokeSession(ctx context.Context, arg sqlc.RevokeSessionParams) error {
+	q.revokedSessions[arg.SessionID] = true
+	return nil
+}
+
+func (q *revocationQueries) InvalidateUserTokens(ctx context.Context, arg sqlc.InvalidateUserTokensParams) error {
+	q.invalidBefore[arg.ID] = arg.TokensInvalidBefore
+	return nil
+}
+
+func (q *revocationQueries) IsTokenRevoked(ctx context.Context, arg sqlc.IsTokenRevokedParams) (bool, error) {
+	watermark, ok := q.invalidBefore[arg.UserID]
+	return q.revoked[arg.Jti] || q.revokedSessions[arg.SessionID] || (ok && !arg.IssuedAt.After(watermark)), nil
+}
+
+func (q *revocationQueries) DeleteExpiredRevokedTokens(ctx context.Context) error {
+	return nil
+}
+
+func (q *revocationQueries) DeleteExpiredRevokedSessions(ctx context.Context) error {
+	return nil
+}
+
+func TestRevokeUser(t *testing.T) {
+	ctx := context.Background()
+	manager := NewJWTManager("test-secret-key", 15*time.Minute, 7*24*time.Hour)
+	queries := newRevocationQueries()
+	revocations := NewRevocationList(queries, 15*time.Minute, time.Minute)
+
+	issue := func() *Claims {
+		token, err := manager.GenerateAccessToken(1, "user@example.com", false)
+		require.NoError(t, err)
+		claims, err := manager.ValidateToken(token)
+		require.NoError(t, err)
+		return claims
+	}
+
+	before := issue()
+	require.NoError(t, revocations.RevokeUser(ctx, 1))
+	after := issue()
+
+	revoked, err := revocations.IsRevoked(ctx, before)
+	require.NoError(t, err)
+	assert.True(t, revoked, "a token issued before the revocation is rejected")
+
+	revoked, err = revocations.IsRevoked(ctx, after)
+	require.NoError(t, err)
+	assert.False(t, revoked, "a token issued after the revocation is accepted")
+
+	// Without iat_us only the second is known, so the whole revoking second
+	// is rejected
+	legacy := &Claims{UserID: 1}
+	legacy.IssuedAt = jwt.NewNumericDate(queries.invalidBefore[1])
+	revoked, err = revocations.IsRevoked(ctx, legacy)
+	require.NoError(t, err)
+	assert.True(t, revoked, "a token without iat_us from the revoking second is rejected")
+}
+
+func TestRevokeToken(t *testing.T) {
+	ctx := context.Background()
+	manager := NewJWTManager("test-secret-key", 15*time.Minute, 7*24*time.Hour)
+	revocations := NewRevocationList(newRevocationQueries(), 15*time.Minute, time.Minute)
+
+	var tokens []*Claims
+	for i := 0; i < 2; i++ {
+		token, err := manager.GenerateAccessToken(1, "user@example.com", false)
+		require.NoError(t, err)
+		claims, err := manager.ValidateToken(token)
+		require.NoError(t, err)
+		tokens = append(tokens, claims)
+	}
+
+	require.NoError(t, revocations.Revoke(ctx, tokens[0]))
+
+	revoked, err := revocations.IsRevoked(ctx, tokens[0])
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = revocations.IsRevoked(ctx, tokens[1])
+	require.NoError(t, err)
+	assert.False(t, revoked, "other tokens of the user are unaffected")
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonPasswordWords are fragments that dominate leaked password lists.
+// Attackers try them, with digits and substitutions around them, first.
+var commonPasswordWords = []string{
+	"password", "passwort", "pass", "welcome", "letmein", "admin", "administrator",
+	"login", "master", "secret", "changeme", "default", "access", "iloveyou",
+	"love", "monkey", "dragon", "shadow", "sunshine", "princess", "football",
+	"baseball", "soccer", "hockey", "superman", "batman", "starwars", "pokemon",
+	"trustno", "whatever", "freedom", "hello", "computer", "internet", "qwerty",
+	"azerty", "abc", "test", "guest", "user", "root", "money", "god", "jesus",
+	"michael", "jessica", "jordan", "charlie", "daniel", "thomas", "robert",
+	"andrew", "george", "hunter", "ranger", "killer", "mustang", "matrix",
+	"summer", "winter", "spring", "autumn", "flower", "cookie", "cheese",
+	"chocolate", "banana", "orange", "apple", "purple", "yellow", "silver",
+	"golden", "ninja", "company", "mypass", "temp",
+}
+
+// keyboardSequences are runs attackers walk along the keyboard or alphabet
+var keyboardSequences = []string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"0123456789",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+	"qwertzuiop",
+	"azertyuiop",
+}
+
+var leetReplacer = strings.NewReplacer(
+	"0", "o", "1", "l", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i",
+)
+
+// PasswordStrength estimates how hard password is to guess on the 0-4 scale
+// popularized by zxcvbn: 0 falls to a handful of guesses, 4 resists an offline
+// attack. Like zxcvbn it looks for common words, substitutions, repeats and
+// keyboard or alphabet runs instead of counting character classes. userInputs
+// are words specific to the user, such as their name, that count as trivially
+// guessable.
+func PasswordStrength(password string, userInputs ...string) int {
+	guesses := estimateGuessesLog10(password, userInputs)
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// estimateGuessesLog10 splits password greedily into the longest recognizable
+// patterns and sums the log10 of the guesses each would take
+func estimateGuessesLog10(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	unleet := []rune(leetReplacer.Replace(string(lower)))
+	if len(unleet) != len(lower) {
+		unleet = lower
+	}
+	bruteForce := math.Log10(float64(cardinality(runes)))
+
+	var total float64
+	for i := 0; i < len(runes); {
+		length, guesses := 1, bruteForce
+
+		// Words: common ones cost about as much as the list is long, the
+		// user's own next to nothing
+		for _, words := range []struct {
+			list  []string
+			log10 float64
+		}{
+			{commonPasswordWords, math.Log10(float64(len(commonPasswordWords)))},
+			{userInputs, 0.5},
+		} {
+			for _, word := range words.list {
+				n := len([]rune(word))
+				if n <= length || !hasPrefixRunes(unleet[i:], word) {
+					continue
+				}
+				cost := words.log10
+				if string(unleet[i:i+n]) != string(lower[i:i+n]) {
+					cost += 0.5 // substitutions
+				}
+				if string(runes[i:i+n]) != string(lower[i:i+n]) {
+					cost += 0.3 // capitalization
+				}
+				length, guesses = n, cost
+			}
+		}
+
+		// Repeats such as "aaaa"
+		n := 1
+		for i+n < len(lower) && lower[i+n] == lower[i] {
+			n++
+		}
+		if n >= 3 && n > length {
+			length, guesses = n, math.Log10(float64(cardinality(runes[i:i+1])*n))
+		}
+
+		// Runs such as "abcd", "4321" or "qwerty"
+		if n := sequenceLength(lower[i:]); n >= 3 && n > length {
+			length, guesses = n, math.Log10(float64(26*n))
+		}
+
+		// Years from 1900 to 2099
+		if length < 4 && isYear(lower[i:]) {
+			length, guesses = 4, 2
+		}
+
+		total += guesses
+		i += length
+	}
+	return total
+}
+
+// cardinality is the size of the smallest character set covering runes
+func cardinality(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			size += class.size
+		}
+	}
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
+
+// sequenceLength returns how many leading runes of s follow a keyboard or
+// alphabet run, forwards or backwards
+func sequenceLength(s []rune) int {
+	best := 0
+	for _, seq := range keyboardSequences {
+		for _, run := range []string{seq, reverse(seq)} {
+			start := strings.IndexRune(run, s[0])
+			if start < 0 {
+				continue
+			}
+			n := 0
+			for n < len(s) && start+n < len(run) && rune(run[start+n]) == s[n] {
+				n++
+			}
+			if n > best {
+				best = n
+			}
+		}
+	}
+	return best
+}
+
+// isYear reports whether s starts with a year from 1900 to 2099
+func isYear(s []rune) bool {
+	if len(s) < 4 {
+		return false
+	}
+	for _, r := range s[:4] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	century := string(s[:2])
+	return century == "19" || century == "20"
+}
+
+func hasPrefixRunes(s []rune, prefix string) bool {
+	return strings.HasPrefix(string(s), prefix)
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
@@ -0,0 +1,443 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// WebAuthnTimeout is how long a user has to complete a passkey ceremony
+const WebAuthnTimeout = 5 * time.Minute
+
+// COSE algorithm identifiers for the signatures passkeys may use
+const (
+	COSEAlgES256 int64 = -7
+	COSEAlgEdDSA int64 = -8
+	COSEAlgRS256 int64 = -257
+)
+
+// Authenticator data flags
+const (
+	authFlagUserPresent    = 0x01
+	authFlagUserVerified   = 0x04
+	authFlagBackupEligible = 0x08
+	authFlagBackedUp       = 0x10
+	authFlagAttestedData   = 0x40
+	authFlagExtensionData  = 0x80
+)
+
+// maxCredentialIDLength is the longest credential ID the spec allows
+const maxCredentialIDLength = 1023
+
+// ErrWebAuthnCounter means an authenticator reported a signature counter
+// that did not increase, which suggests the credential has been cloned
+var ErrWebAuthnCounter = errors.New("webauthn: signature counter did not increase")
+
+var webAuthnAlgorithms = []int64{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+
+// WebAuthn runs the relying party side of passkey registration and
+// sign-in. Every ceremony requires user verification, so a passkey proves
+// both possession of the authenticator and the user's PIN or biometric.
+// Attestation is not requested: authenticators are trusted only to hold the
+// private key.
+type WebAuthn struct {
+	rpID    string
+	rpName  string
+	origins []string
+}
+
+// NewWebAuthn creates a relying party. rpID is the domain passkeys are
+// scoped to and origins are the web origins allowed to run ceremonies.
+func NewWebAuthn(rpID, rpName string, origins []string) *WebAuthn {
+	return &WebAuthn{rpID: rpID, rpName: rpName, origins: origins}
+}
+
+// WebAuthnCredentialDescriptor identifies an existing credential to the browser
+type WebAuthnCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// NewWebAuthnCredentialDescriptor describes the credential with the given ID
+func NewWebAuthnCredentialDescriptor(id []byte, transports []string) WebAuthnCredentialDescriptor {
+	return WebAuthnCredentialDescriptor{
+		Type:       "public-key",
+		ID:         base64.RawURLEncoding.EncodeToString(id),
+		Transports: transports,
+	}
+}
+
+// WebAuthnCreationOptions is the JSON form of PublicKeyCredentialCreationOptions
+type WebAuthnCreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []webAuthnCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey        string `json:"residentKey"`
+		RequireResidentKey bool   `json:"requireResidentKey"`
+		UserVerification   string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+type webAuthnCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// WebAuthnRequestOptions is the JSON form of PublicKeyCredentialRequestOptions
+type WebAuthnRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	RPID             string                         `json:"rpId"`
+	Timeout          int64                          `json:"timeout"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+// WebAuthnCredential is a verified new passkey
+type WebAuthnCredential struct {
+	ID []byte
+	// PublicKey is the COSE_Key the authenticator returned
+	PublicKey      []byte
+	SignCount      uint32
+	AAGUID         []byte
+	BackupEligible bool
+	BackedUp       bool
+}
+
+// WebAuthnAssertion is the outcome of a verified sign-in
+type WebAuthnAssertion struct {
+	SignCount uint32
+	BackedUp  bool
+}
+
+// WebAuthnUserHandle returns the opaque handle a user's passkeys are stored
+// under. It identifies the account without revealing its email or name.
+func WebAuthnUserHandle(userID int64) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+// CreationOptions returns the options for registering a passkey for a user.
+// Credentials in exclude are already registered and will not be created again.
+func (w *WebAuthn) CreationOptions(challenge string, userID int64, name, displayName string, exclude []WebAuthnCredentialDescriptor) WebAuthnCreationOptions {
+	var opts WebAuthnCreationOptions
+	opts.Challenge = challenge
+	opts.RP.ID = w.rpID
+	opts.RP.Name = w.rpName
+	opts.User.ID = base64.RawURLEncoding.EncodeToString(WebAuthnUserHandle(userID))
+	opts.User.Name = name
+	opts.User.DisplayName = displayName
+	for _, alg := range webAuthnAlgorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, webAuthnCredentialParameter{Type: "public-key", Alg: alg})
+	}
+	opts.Timeout = WebAuthnTimeout.Milliseconds()
+	opts.ExcludeCredentials = exclude
+	if opts.ExcludeCredentials == nil {
+		opts.ExcludeCredentials = []WebAuthnCredentialDescriptor{}
+	}
+	opts.AuthenticatorSelection.ResidentKey = "required"
+	opts.AuthenticatorSelection.RequireResidentKey = true
+	opts.AuthenticatorSelection.UserVerification = "required"
+	opts.Attestation = "none"
+	return opts
+}
+
+// RequestOptions returns the options for signing in with any passkey the
+// authenticator holds for this relying party
+func (w *WebAuthn) RequestOptions(challenge string) WebAuthnRequestOptions {
+	return WebAuthnRequestOptions{
+		Challenge:        challenge,
+		RPID:             w.rpID,
+		Timeout:          WebAuthnTimeout.Milliseconds(),
+		AllowCredentials: []WebAuthnCredentialDescriptor{},
+		UserVerification: "required",
+	}
+}
+
+// clientData is the part of CollectedClientData the relying party checks
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// WebAuthnChallenge returns the challenge a ceremony's client data answers,
+// so the caller can look up the ceremony it belongs to
+func WebAuthnChallenge(clientDataJSON []byte) (string, error) {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return "", fmt.Errorf("webauthn: invalid client data: %w", err)
+	}
+	if data.Challenge == "" {
+		return "", errors.New("webauthn: client data has no challenge")
+	}
+	return data.Challenge, nil
+}
+
+// VerifyRegistration checks the response to CreationOptions and returns the
+// new credential
+func (w *WebAuthn) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (WebAuthnCredential, error) {
+	if err := w.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return WebAuthnCredential{}, err
+	}
+
+	decoded, rest, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return WebAuthnCredential{}, fmt.Errorf("webauthn: invalid attestation object: %w", err)
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok || len(rest) != 0 {
+		return WebAuthnCredential{}, errors.New("webauthn: invalid attestation object")
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return WebAuthnCredential{}, errors.New("webauthn: attestation object has no authenticator data")
+	}
+
+	authData, err := w.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return WebAuthnCredential{}, err
+	}
+	if authData.flags&authFlagAttestedData == 0 {
+		return WebAuthnCredential{}, errors.New("webauthn: no credential in authenticator data")
+	}
+	if _, _, err := parseCOSEKey(authData.publicKey); err != nil {
+		return WebAuthnCredential{}, err
+	}
+
+	return WebAuthnCredential{
+		ID:             authData.credentialID,
+		PublicKey:      authData.publicKey,
+		SignCount:      authData.signCount,
+		AAGUID:         authData.aaguid,
+		BackupEligible: authData.flags&authFlagBackupEligible != 0,
+		BackedUp:       authData.flags&authFlagBackedUp != 0,
+	}, nil
+}
+
+// VerifyAssertion checks a sign-in response against a stored credential.
+// signCount is the counter last seen for the credential.
+func (w *WebAuthn) VerifyAssertion(challenge string, publicKey []byte, signCount uint32, clientDataJSON, authenticatorData, signature []byte) (WebAuthnAssertion, error) {
+	if err := w.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return WebAuthnAssertion{}, err
+	}
+
+	authData, err := w.verifyAuthenticatorData(authenticatorData)
+	if err != nil {
+		return WebAuthnAssertion{}, err
+	}
+
+	key, alg, err := parseCOSEKey(publicKey)
+	if err != nil {
+		return WebAuthnAssertion{}, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authenticatorData...), clientDataHash[:]...)
+	if !verifyCOSESignature(key, alg, signed, signature) {
+		return WebAuthnAssertion{}, errors.New("webauthn: invalid signature")
+	}
+
+	// Authenticators that keep no counter always report zero
+	if (authData.signCount != 0 || signCount != 0) && authData.signCount <= signCount {
+		return WebAuthnAssertion{}, ErrWebAuthnCounter
+	}
+
+	return WebAuthnAssertion{
+		SignCount: authData.signCount,
+		BackedUp:  authData.flags&authFlagBackedUp != 0,
+	}, nil
+}
+
+// verifyClientData checks the ceremony type, challenge and origin the
+// browser recorded
+func (w *WebAuthn) verifyClientData(clientDataJSON []byte, ceremony, challenge string) error {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return fmt.Errorf("webauthn: invalid client data: %w", err)
+	}
+	if data.Type != ceremony {
+		return fmt.Errorf("webauthn: client data is for %q", data.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return errors.New("webauthn: challenge mismatch")
+	}
+	if data.CrossOrigin {
+		return errors.New("webauthn: cross-origin ceremonies are not allowed")
+	}
+	for _, origin := range w.origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("webauthn: origin %q is not allowed", data.Origin)
+}
+
+// authenticatorData is the parsed form of the authenticator's signed data
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// verifyAuthenticatorData parses data and checks it is scoped to this
+// relying party and the user was both present and verified
+func (w *WebAuthn) verifyAuthenticatorData(data []byte) (authenticatorData, error) {
+	authData, err := parseAuthenticatorData(data)
+	if err != nil {
+		return authenticatorData{}, err
+	}
+	rpIDHash := sha256.Sum256([]byte(w.rpID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return authenticatorData{}, errors.New("webauthn: credential is for another relying party")
+	}
+	if authData.flags&authFlagUserPresent == 0 {
+		return authenticatorData{}, errors.New("webauthn: user was not present")
+	}
+	if authData.flags&authFlagUserVerified == 0 {
+		return authenticatorData{}, errors.New("webauthn: user was not verified")
+	}
+	if authData.flags&authFlagBackedUp != 0 && authData.flags&authFlagBackupEligible == 0 {
+		return authenticatorData{}, errors.New("webauthn: invalid backup flags")
+	}
+	return authData, nil
+}
+
+// parseAuthenticatorData splits authenticator data into its fields
+func parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	if len(data) < 37 {
+		return authenticatorData{}, errors.New("webauthn: authenticator data too short")
+	}
+	authData := authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.flags&authFlagAttestedData != 0 {
+		if len(rest) < 18 {
+			return authenticatorData{}, errors.New("webauthn: attested credential data too short")
+		}
+		authData.aaguid = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > maxCredentialIDLength || idLength > len(rest) {
+			return authenticatorData{}, errors.New("webauthn: invalid credential ID length")
+		}
+		authData.credentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, fmt.Errorf("webauthn: invalid credential public key: %w", err)
+		}
+		authData.publicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+
+	if authData.flags&authFlagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, fmt.Errorf("webauthn: invalid extensions: %w", err)
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return authenticatorData{}, errors.New("webauthn: trailing bytes in authenticator data")
+	}
+	return authData, nil
+}
+
+// parseCOSEKey decodes a COSE_Key with one of the supported algorithms
+func parseCOSEKey(data []byte) (crypto.PublicKey, int64, error) {
+	decoded, rest, err := decodeCBOR(data)
+	if err != nil || len(rest) != 0 {
+		return nil, 0, errors.New("webauthn: invalid COSE key")
+	}
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("webauthn: invalid COSE key")
+	}
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+	crv, _ := key[int64(-1)].(int64)
+	x, _ := key[int64(-2)].([]byte)
+	y, _ := key[int64(-3)].([]byte)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256 && crv == 1:
+		if len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("webauthn: invalid P-256 key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, errors.New("webauthn: P-256 point is not on the curve")
+		}
+		return pub, alg, nil
+	case kty == 1 && alg == COSEAlgEdDSA && crv == 6:
+		if len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("webauthn: invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case kty == 3 && alg == COSEAlgRS256:
+		// RSA keys store the modulus and exponent under the labels EC keys
+		// use for the curve and x coordinate
+		n, _ := key[int64(-1)].([]byte)
+		e := new(big.Int).SetBytes(x)
+		if len(n) == 0 || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, 0, errors.New("webauthn: invalid RSA key")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(e.Int64())}
+		if pub.N.BitLen() < 2048 {
+			return nil, 0, errors.New("webauthn: RSA key is shorter than 2048 bits")
+		}
+		return pub, alg, nil
+	default:
+		return nil, 0, fmt.Errorf("webauthn: unsupported key type %d with algorithm %d", kty, alg)
+	}
+}
+
+// verifyCOSESignature checks sig over data with a key from parseCOSEKey
+func verifyCOSESignature(key crypto.PublicKey, alg int64, data, sig []byte) bool {
+	switch alg {
+	case COSEAlgES256:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest[:], sig)
+	case COSEAlgEdDSA:
+		return ed25519.Verify(key.(ed25519.PublicKey), data, sig)
+	case COSEAlgRS256:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], sig) == nil
+	default:
+		return false
+	}
+}
@@ -2,28 +2,133 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Config holds all application configuration
 type Config struct {
 	// Server
-	Port string
-	Env  string // "development", "staging", "production"
+	Port   string
+	Env    string // "development", "staging", "production"
+	AppURL string // base URL of the frontend, used to build links in emails
+
+	// IssuerURL is this service's public base URL, used as the iss of tokens
+	// issued to OAuth clients
+	IssuerURL string
 
 	// Database
 	DatabaseURL string
 
 	// JWT
-	JWTSecret         string
-	JWTAccessExpiry   time.Duration
-	JWTRefreshExpiry  time.Duration
+	JWTSecret              string
+	JWTAccessExpiry        time.Duration
+	JWTRefreshExpiry       time.Duration
+	JWTAlgorithm           string        // "HS256", "RS256", "ES256" or "EdDSA"
+	JWTKeyRotationInterval time.Duration // how often asymmetric signing keys rotate
+
+	// TokenRevocationCacheTTL bounds how long a replica may trust a cached
+	// "not revoked" answer for an access token
+	TokenRevocationCacheTTL time.Duration
+
+	// EncryptionKey encrypts secrets stored in the database, such as signing keys
+	EncryptionKey string
+
+	// Password hashing
+	PasswordHashAlgorithm string // "argon2id" or "bcrypt"
+	Argon2Memory          uint32 // KiB
+	Argon2Time            uint32
+	Argon2Parallelism     uint8
+	BcryptCost            int
+
+	// Password policy
+	PasswordMinLength          int
+	PasswordMaxLength          int
+	PasswordRequireUpper       bool
+	PasswordRequireLower       bool
+	PasswordRequireDigit       bool
+	PasswordRequireSymbol      bool
+	PasswordMinStrength        int    // 0-4
+	PasswordRejectPersonalInfo bool   // refuse passwords containing the email or name
+	BreachedPasswordsPath      string // Pwned Passwords file or range directory; empty disables
+
+	// Login throttling
+	LoginMaxAttempts     int // consecutive failures before an account is locked
+	LoginIPMaxAttempts   int // failures before a client IP is locked out
+	LoginLockoutDuration time.Duration
+	LoginBackoffBase     time.Duration
+
+	// PasswordResetExpiry is how long a password reset link stays valid
+	PasswordResetExpiry time.Duration
+
+	// Passwordless sign-in links
+	MagicLinkExpiry        time.Duration
+	MagicLinkEmailRequests int // links that may be requested for one email per window
+	MagicLinkEmailWindow   time.Duration
+
+	// MFAIssuer names the service in authenticator apps
+	MFAIssuer string
+
+	// Passkeys
+	WebAuthnRPID    string   // domain passkeys are bound to
+	WebAuthnRPName  string   // service name shown by authenticators
+	WebAuthnOrigins []string // web origins allowed to use passkeys
+
+	// Email verification
+	EmailVerificationExpiry  time.Duration
+	RequireEmailVerification bool // reject login until the email is verified
+
+	// ConcealRegisteredEmails makes registering an existing email look like a
+	// successful registration and emails the owner instead
+	ConcealRegisteredEmails bool
+
+	// Invitations
+	InviteOnly       bool // only invited users can create accounts
+	InvitationExpiry time.Duration
+
+	// ImpersonationExpiry is how long a token issued to an admin acting as
+	// another user stays valid, at most JWTAccessExpiry
+	ImpersonationExpiry time.Duration
+
+	// OrgRowLevelSecurity has Postgres row-level security scope queries on
+	// active organization routes to that organization
+	OrgRowLevelSecurity bool
+
+	// Identity providers, each enabled when its client ID is set
+	GoogleClientID     string
+	GoogleClientSecret string
+	GitHubClientID     string
+	GitHubClientSecret string
+	OIDCProviderID     string // provider name in URLs and the identities table
+	OIDCProviderName   string // provider name shown to users
+	OIDCIssuerURL      string
+	OIDCClientID       string
+	OIDCClientSecret   string
+
+	// Mail
+	MailDriver    string // "smtp" or "file"
+	MailFrom      string
+	MailOutboxDir string // where the file driver writes messages
+	SMTPHost      string
+	SMTPPort      string
+	SMTPUsername  string
+	SMTPPassword  string
 
 	// Rate Limiting
-	RateLimitRequests int
-	RateLimitWindow   time.Duration
+	RateLimitRequests     int
+	RateLimitWindow       time.Duration
+	RateLimitAuthRequests int // tighter limit for credential endpoints under /auth
+	RateLimitAuthWindow   time.Duration
+	RateLimitAlgorithm    string // "token_bucket" or "sliding_window"
+	RateLimitStore        string // "memory" or "postgres"
+
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies whose
+	// X-Forwarded-For header names the client. None are trusted by default.
+	TrustedProxies []string
 
 	// CORS
 	CORSAllowedOrigins []string
@@ -32,9 +137,11 @@ type Config struct {
 // Load reads configuration from environment variables
 func Load() (*Config, error) {
 	cfg := &Config{
-		Port: getEnv("PORT", "8080"),
-		Env:  getEnv("ENV", "development"),
+		Port:   getEnv("PORT", "8080"),
+		Env:    getEnv("ENV", "development"),
+		AppURL: strings.TrimSuffix(getEnv("APP_URL", "http://localhost:3000"), "/"),
 	}
+	cfg.IssuerURL = strings.TrimSuffix(getEnv("ISSUER_URL", "http://localhost:"+cfg.Port), "/")
 
 	// Database - required
 	cfg.DatabaseURL = os.Getenv("DATABASE_URL")
@@ -61,19 +168,332 @@ func Load() (*Config, error) {
 	}
 	cfg.JWTRefreshExpiry = refreshExpiry
 
+	cfg.JWTAlgorithm = getEnv("JWT_ALGORITHM", "HS256")
+	switch cfg.JWTAlgorithm {
+	case "HS256", "RS256", "ES256", "EdDSA":
+	default:
+		return nil, fmt.Errorf("invalid JWT_ALGORITHM: %s", cfg.JWTAlgorithm)
+	}
+
+	keyRotationInterval, err := time.ParseDuration(getEnv("JWT_KEY_ROTATION_INTERVAL", "720h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_KEY_ROTATION_INTERVAL: %w", err)
+	}
+	cfg.JWTKeyRotationInterval = keyRotationInterval
+
+	revocationCacheTTL, err := time.ParseDuration(getEnv("TOKEN_REVOCATION_CACHE_TTL", "5s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOKEN_REVOCATION_CACHE_TTL: %w", err)
+	}
+	cfg.TokenRevocationCacheTTL = revocationCacheTTL
+
+	// Encryption key falls back to the JWT secret so existing deployments keep working
+	cfg.EncryptionKey = getEnv("ENCRYPTION_KEY", cfg.JWTSecret)
+
+	// Password hashing
+	cfg.PasswordHashAlgorithm = getEnv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	if cfg.PasswordHashAlgorithm != "argon2id" && cfg.PasswordHashAlgorithm != "bcrypt" {
+		return nil, fmt.Errorf("invalid PASSWORD_HASH_ALGORITHM: %s", cfg.PasswordHashAlgorithm)
+	}
+
+	argon2Memory, err := strconv.ParseUint(getEnv("ARGON2_MEMORY", "65536"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ARGON2_MEMORY: %w", err)
+	}
+	cfg.Argon2Memory = uint32(argon2Memory)
+
+	argon2Time, err := strconv.ParseUint(getEnv("ARGON2_TIME", "3"), 10, 32)
+	if err != nil || argon2Time < 1 {
+		return nil, fmt.Errorf("invalid ARGON2_TIME: %s", getEnv("ARGON2_TIME", "3"))
+	}
+	cfg.Argon2Time = uint32(argon2Time)
+
+	argon2Parallelism, err := strconv.ParseUint(getEnv("ARGON2_PARALLELISM", "4"), 10, 8)
+	if err != nil || argon2Parallelism < 1 {
+		return nil, fmt.Errorf("invalid ARGON2_PARALLELISM: %s", getEnv("ARGON2_PARALLELISM", "4"))
+	}
+	cfg.Argon2Parallelism = uint8(argon2Parallelism)
+
+	// argon2 requires at least 8 KiB per lane
+	if cfg.Argon2Memory < 8*uint32(cfg.Argon2Parallelism) {
+		return nil, fmt.Errorf("invalid ARGON2_MEMORY: must be at least %d KiB", 8*uint32(cfg.Argon2Parallelism))
+	}
+
+	bcryptCost, err := strconv.Atoi(getEnv("BCRYPT_COST", "12"))
+	if err != nil || bcryptCost < 4 || bcryptCost > 31 {
+		return nil, fmt.Errorf("invalid BCRYPT_COST: %s", getEnv("BCRYPT_COST", "12"))
+	}
+	cfg.BcryptCost = bcryptCost
+
+	// Password policy
+	passwordMinLength, err := strconv.Atoi(getEnv("PASSWORD_MIN_LENGTH", "8"))
+	if err != nil || passwordMinLength < 8 {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_LENGTH: must be a number of at least 8")
+	}
+	cfg.PasswordMinLength = passwordMinLength
+
+	passwordMaxLength, err := strconv.Atoi(getEnv("PASSWORD_MAX_LENGTH", "128"))
+	if err != nil || passwordMaxLength < passwordMinLength {
+		return nil, fmt.Errorf("invalid PASSWORD_MAX_LENGTH: must be a number of at least PASSWORD_MIN_LENGTH")
+	}
+	cfg.PasswordMaxLength = passwordMaxLength
+
+	for _, flag := range []struct {
+		key   string
+		def   string
+		value *bool
+	}{
+		{"PASSWORD_REQUIRE_UPPER", "false", &cfg.PasswordRequireUpper},
+		{"PASSWORD_REQUIRE_LOWER", "false", &cfg.PasswordRequireLower},
+		{"PASSWORD_REQUIRE_DIGIT", "false", &cfg.PasswordRequireDigit},
+		{"PASSWORD_REQUIRE_SYMBOL", "false", &cfg.PasswordRequireSymbol},
+		{"PASSWORD_REJECT_PERSONAL_INFO", "true", &cfg.PasswordRejectPersonalInfo},
+	} {
+		value, err := strconv.ParseBool(getEnv(flag.key, flag.def))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", flag.key, err)
+		}
+		*flag.value = value
+	}
+
+	passwordMinStrength, err := strconv.Atoi(getEnv("PASSWORD_MIN_STRENGTH", "2"))
+	if err != nil || passwordMinStrength < 0 || passwordMinStrength > 4 {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_STRENGTH: must be between 0 and 4")
+	}
+	cfg.PasswordMinStrength = passwordMinStrength
+
+	cfg.BreachedPasswordsPath = getEnv("BREACHED_PASSWORDS_PATH", "")
+
+	// Login throttling
+	loginMaxAttempts, err := strconv.Atoi(getEnv("LOGIN_MAX_ATTEMPTS", "10"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_MAX_ATTEMPTS: %w", err)
+	}
+	cfg.LoginMaxAttempts = loginMaxAttempts
+
+	loginIPMaxAttempts, err := strconv.Atoi(getEnv("LOGIN_IP_MAX_ATTEMPTS", "100"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_IP_MAX_ATTEMPTS: %w", err)
+	}
+	cfg.LoginIPMaxAttempts = loginIPMaxAttempts
+
+	loginLockoutDuration, err := time.ParseDuration(getEnv("LOGIN_LOCKOUT_DURATION", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_LOCKOUT_DURATION: %w", err)
+	}
+	cfg.LoginLockoutDuration = loginLockoutDuration
+
+	loginBackoffBase, err := time.ParseDuration(getEnv("LOGIN_BACKOFF_BASE", "1s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_BACKOFF_BASE: %w", err)
+	}
+	cfg.LoginBackoffBase = loginBackoffBase
+
+	passwordResetExpiry, err := time.ParseDuration(getEnv("PASSWORD_RESET_EXPIRY", "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_RESET_EXPIRY: %w", err)
+	}
+	cfg.PasswordResetExpiry = passwordResetExpiry
+
+	magicLinkExpiry, err := time.ParseDuration(getEnv("MAGIC_LINK_EXPIRY", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAGIC_LINK_EXPIRY: %w", err)
+	}
+	cfg.MagicLinkExpiry = magicLinkExpiry
+
+	magicLinkEmailRequests, err := strconv.Atoi(getEnv("MAGIC_LINK_EMAIL_REQUESTS", "3"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAGIC_LINK_EMAIL_REQUESTS: %w", err)
+	}
+	if magicLinkEmailRequests <= 0 {
+		return nil, fmt.Errorf("invalid MAGIC_LINK_EMAIL_REQUESTS: must be positive")
+	}
+	cfg.MagicLinkEmailRequests = magicLinkEmailRequests
+
+	magicLinkEmailWindow, err := time.ParseDuration(getEnv("MAGIC_LINK_EMAIL_WINDOW", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAGIC_LINK_EMAIL_WINDOW: %w", err)
+	}
+	if magicLinkEmailWindow <= 0 {
+		return nil, fmt.Errorf("invalid MAGIC_LINK_EMAIL_WINDOW: must be positive")
+	}
+	cfg.MagicLinkEmailWindow = magicLinkEmailWindow
+
+	emailVerificationExpiry, err := time.ParseDuration(getEnv("EMAIL_VERIFICATION_EXPIRY", "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_VERIFICATION_EXPIRY: %w", err)
+	}
+	cfg.EmailVerificationExpiry = emailVerificationExpiry
+
+	requireEmailVerification, err := strconv.ParseBool(getEnv("REQUIRE_EMAIL_VERIFICATION", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REQUIRE_EMAIL_VERIFICATION: %w", err)
+	}
+	cfg.RequireEmailVerification = requireEmailVerification
+
+	concealRegisteredEmails, err := strconv.ParseBool(getEnv("CONCEAL_REGISTERED_EMAILS", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONCEAL_REGISTERED_EMAILS: %w", err)
+	}
+	// Registration can only look the same for new and existing emails when
+	// neither response carries tokens
+	if concealRegisteredEmails && !requireEmailVerification {
+		return nil, fmt.Errorf("CONCEAL_REGISTERED_EMAILS requires REQUIRE_EMAIL_VERIFICATION")
+	}
+	cfg.ConcealRegisteredEmails = concealRegisteredEmails
+
+	inviteOnly, err := strconv.ParseBool(getEnv("INVITE_ONLY", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid INVITE_ONLY: %w", err)
+	}
+	cfg.InviteOnly = inviteOnly
+
+	invitationExpiry, err := time.ParseDuration(getEnv("INVITATION_EXPIRY", "168h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid INVITATION_EXPIRY: %w", err)
+	}
+	cfg.InvitationExpiry = invitationExpiry
+
+	impersonationExpiry, err := time.ParseDuration(getEnv("IMPERSONATION_EXPIRY", "10m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMPERSONATION_EXPIRY: %w", err)
+	}
+	if impersonationExpiry <= 0 || impersonationExpiry > cfg.JWTAccessExpiry {
+		return nil, fmt.Errorf("invalid IMPERSONATION_EXPIRY: must be positive and at most JWT_ACCESS_EXPIRY")
+	}
+	cfg.ImpersonationExpiry = impersonationExpiry
+
+	orgRowLevelSecurity, err := strconv.ParseBool(getEnv("ORG_ROW_LEVEL_SECURITY", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ORG_ROW_LEVEL_SECURITY: %w", err)
+	}
+	cfg.OrgRowLevelSecurity = orgRowLevelSecurity
+
+	cfg.MFAIssuer = getEnv("MFA_ISSUER", "Go API")
+
+	// Passkeys default to the frontend's domain
+	appURL, err := url.Parse(cfg.AppURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APP_URL: %w", err)
+	}
+	cfg.WebAuthnRPID = getEnv("WEBAUTHN_RP_ID", appURL.Hostname())
+	cfg.WebAuthnRPName = getEnv("WEBAUTHN_RP_NAME", cfg.MFAIssuer)
+	for _, origin := range strings.Split(getEnv("WEBAUTHN_ORIGINS", appURL.Scheme+"://"+appURL.Host), ",") {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+			cfg.WebAuthnOrigins = append(cfg.WebAuthnOrigins, origin)
+		}
+	}
+	if cfg.WebAuthnRPID == "" || len(cfg.WebAuthnOrigins) == 0 {
+		return nil, fmt.Errorf("WEBAUTHN_RP_ID and WEBAUTHN_ORIGINS are required when APP_URL has no host")
+	}
+
+	// Identity providers
+	cfg.GoogleClientID = os.Getenv("GOOGLE_CLIENT_ID")
+	cfg.GoogleClientSecret = os.Getenv("GOOGLE_CLIENT_SECRET")
+	cfg.GitHubClientID = os.Getenv("GITHUB_CLIENT_ID")
+	cfg.GitHubClientSecret = os.Getenv("GITHUB_CLIENT_SECRET")
+	cfg.OIDCProviderID = getEnv("OIDC_PROVIDER_ID", "oidc")
+	cfg.OIDCProviderName = getEnv("OIDC_PROVIDER_NAME", "Single Sign-On")
+	cfg.OIDCIssuerURL = os.Getenv("OIDC_ISSUER_URL")
+	cfg.OIDCClientID = os.Getenv("OIDC_CLIENT_ID")
+	cfg.OIDCClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	for _, provider := range []struct {
+		prefix       string
+		clientID     string
+		clientSecret string
+	}{
+		{"GOOGLE", cfg.GoogleClientID, cfg.GoogleClientSecret},
+		{"GITHUB", cfg.GitHubClientID, cfg.GitHubClientSecret},
+		{"OIDC", cfg.OIDCClientID, cfg.OIDCClientSecret},
+	} {
+		if provider.clientID != "" && provider.clientSecret == "" {
+			return nil, fmt.Errorf("%s_CLIENT_SECRET is required when %s_CLIENT_ID is set", provider.prefix, provider.prefix)
+		}
+	}
+	if cfg.OIDCClientID != "" && cfg.OIDCIssuerURL == "" {
+		return nil, fmt.Errorf("OIDC_ISSUER_URL is required when OIDC_CLIENT_ID is set")
+	}
+	if len(cfg.OIDCProviderID) > 50 || strings.Trim(cfg.OIDCProviderID, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" ||
+		cfg.OIDCProviderID == "google" || cfg.OIDCProviderID == "github" {
+		return nil, fmt.Errorf("invalid OIDC_PROVIDER_ID: %s", cfg.OIDCProviderID)
+	}
+
+	// Mail
+	cfg.MailDriver = getEnv("MAIL_DRIVER", "file")
+	if cfg.MailDriver != "smtp" && cfg.MailDriver != "file" {
+		return nil, fmt.Errorf("invalid MAIL_DRIVER: %s", cfg.MailDriver)
+	}
+	cfg.MailFrom = getEnv("MAIL_FROM", "noreply@example.com")
+	cfg.MailOutboxDir = getEnv("MAIL_OUTBOX_DIR", "tmp/outbox")
+	cfg.SMTPHost = os.Getenv("SMTP_HOST")
+	cfg.SMTPPort = getEnv("SMTP_PORT", "587")
+	cfg.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	cfg.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	if cfg.MailDriver == "smtp" && cfg.SMTPHost == "" {
+		return nil, fmt.Errorf("SMTP_HOST is required when MAIL_DRIVER is smtp")
+	}
+
 	// Rate limiting
 	rateLimitRequests, err := strconv.Atoi(getEnv("RATE_LIMIT_REQUESTS", "100"))
 	if err != nil {
 		return nil, fmt.Errorf("invalid RATE_LIMIT_REQUESTS: %w", err)
 	}
+	if rateLimitRequests <= 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_REQUESTS: must be positive")
+	}
 	cfg.RateLimitRequests = rateLimitRequests
 
 	rateLimitWindow, err := time.ParseDuration(getEnv("RATE_LIMIT_WINDOW", "1m"))
 	if err != nil {
 		return nil, fmt.Errorf("invalid RATE_LIMIT_WINDOW: %w", err)
 	}
+	if rateLimitWindow <= 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_WINDOW: must be positive")
+	}
 	cfg.RateLimitWindow = rateLimitWindow
 
+	rateLimitAuthRequests, err := strconv.Atoi(getEnv("RATE_LIMIT_AUTH_REQUESTS", "10"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_AUTH_REQUESTS: %w", err)
+	}
+	if rateLimitAuthRequests <= 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_AUTH_REQUESTS: must be positive")
+	}
+	cfg.RateLimitAuthRequests = rateLimitAuthRequests
+
+	rateLimitAuthWindow, err := time.ParseDuration(getEnv("RATE_LIMIT_AUTH_WINDOW", "1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_AUTH_WINDOW: %w", err)
+	}
+	if rateLimitAuthWindow <= 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_AUTH_WINDOW: must be positive")
+	}
+	cfg.RateLimitAuthWindow = rateLimitAuthWindow
+
+	cfg.RateLimitAlgorithm = getEnv("RATE_LIMIT_ALGORITHM", "token_bucket")
+	if cfg.RateLimitAlgorithm != "token_bucket" && cfg.RateLimitAlgorithm != "sliding_window" {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_ALGORITHM: %s", cfg.RateLimitAlgorithm)
+	}
+
+	cfg.RateLimitStore = getEnv("RATE_LIMIT_STORE", "memory")
+	if cfg.RateLimitStore != "memory" && cfg.RateLimitStore != "postgres" {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_STORE: %s", cfg.RateLimitStore)
+	}
+
+	// Client IPs are taken from X-Forwarded-For only behind trusted proxies
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %s", proxy)
+			}
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, proxy)
+	}
+
 	// CORS
 	corsOrigins := getEnv("CORS_ALLOWED_ORIGINS", "*")
 	if corsOrigins == "*" {
@@ -0,0 +1,12 @@
+-- Create rate_limit_buckets table shared by all API replicas
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE PRECISION DEFAULT 0 NOT NULL,
+    window_start TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    window_count INTEGER DEFAULT 0 NOT NULL,
+    prev_count INTEGER DEFAULT 0 NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create index on updated_at for pruning stale buckets
+CREATE INDEX idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at);
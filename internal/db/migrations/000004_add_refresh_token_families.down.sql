@@ -0,0 +1,7 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+
+-- Drop columns
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS consumed_at,
+    DROP COLUMN IF EXISTS family_id;
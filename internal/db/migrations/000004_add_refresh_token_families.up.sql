@@ -0,0 +1,7 @@
+-- Track the lineage of rotated refresh tokens so a replayed token can revoke its descendants
+ALTER TABLE refresh_tokens
+    ADD COLUMN family_id UUID DEFAULT gen_random_uuid() NOT NULL,
+    ADD COLUMN consumed_at TIMESTAMP WITH TIME ZONE;
+
+-- Create index on family_id for revoking a whole family
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
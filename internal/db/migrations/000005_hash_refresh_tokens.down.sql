@@ -0,0 +1,9 @@
+-- Digests cannot be turned back into tokens, so existing sessions are dropped
+DELETE FROM refresh_tokens;
+
+-- Rename index
+ALTER INDEX idx_refresh_tokens_token_hash RENAME TO idx_refresh_tokens_token;
+
+-- Restore column
+ALTER TABLE refresh_tokens ALTER COLUMN token_hash TYPE VARCHAR(500);
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
@@ -0,0 +1,11 @@
+-- Store only a SHA-256 digest of each refresh token so a database leak cannot be replayed
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+
+-- Convert existing raw tokens to their digest
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+ALTER TABLE refresh_tokens ALTER COLUMN token_hash TYPE VARCHAR(64);
+
+-- Rename index to match the column
+ALTER INDEX idx_refresh_tokens_token RENAME TO idx_refresh_tokens_token_hash;
@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_signing_keys_expires_at;
+
+-- Drop table
+DROP TABLE IF EXISTS signing_keys;
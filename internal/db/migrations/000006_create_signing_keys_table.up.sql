@@ -0,0 +1,11 @@
+-- Create signing_keys table holding asymmetric JWT keys shared by all replicas
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(16) NOT NULL,
+    private_key BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create index on expires_at for pruning retired keys
+CREATE INDEX idx_signing_keys_expires_at ON signing_keys(expires_at);
@@ -0,0 +1,8 @@
+-- Drop column
+ALTER TABLE users DROP COLUMN IF EXISTS tokens_invalid_before;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_revoked_tokens_expires_at;
+
+-- Drop table
+DROP TABLE IF EXISTS revoked_tokens;
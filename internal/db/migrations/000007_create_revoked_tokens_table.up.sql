@@ -0,0 +1,13 @@
+-- Create revoked_tokens table listing access tokens invalidated before expiry
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create index on expires_at for pruning
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+
+-- Access tokens issued before this instant are rejected (logout everywhere, password change)
+ALTER TABLE users ADD COLUMN tokens_invalid_before TIMESTAMP WITH TIME ZONE;
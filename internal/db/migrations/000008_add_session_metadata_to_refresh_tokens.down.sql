@@ -0,0 +1,7 @@
+-- Drop columns
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS last_used_at,
+    DROP COLUMN IF EXISTS session_started_at,
+    DROP COLUMN IF EXISTS device_name,
+    DROP COLUMN IF EXISTS ip_address,
+    DROP COLUMN IF EXISTS user_agent;
@@ -0,0 +1,11 @@
+-- Describe the login session each refresh token family belongs to
+ALTER TABLE refresh_tokens
+    ADD COLUMN user_agent VARCHAR(512) DEFAULT '' NOT NULL,
+    ADD COLUMN ip_address VARCHAR(45) DEFAULT '' NOT NULL,
+    ADD COLUMN device_name VARCHAR(100),
+    ADD COLUMN session_started_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    ADD COLUMN last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL;
+
+-- Existing families started no later than their oldest token
+UPDATE refresh_tokens
+SET session_started_at = created_at, last_used_at = created_at;
@@ -0,0 +1,8 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_email_verification_tokens_expires_at;
+DROP INDEX IF EXISTS idx_email_verification_tokens_user_id;
+
+-- Drop table
+DROP TABLE IF EXISTS email_verification_tokens;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
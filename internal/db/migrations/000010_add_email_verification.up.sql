@@ -0,0 +1,23 @@
+-- Track when a user proved ownership of their email address
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- Accounts created before verification existed are trusted as verified
+UPDATE users SET email_verified_at = created_at;
+
+-- Create email_verification_tokens table; email is the address being verified,
+-- which differs from the user's current email during an email change
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create index on user_id for faster lookups
+CREATE INDEX idx_email_verification_tokens_user_id ON email_verification_tokens(user_id);
+
+-- Create index on expires_at for cleanup
+CREATE INDEX idx_email_verification_tokens_expires_at ON email_verification_tokens(expires_at);
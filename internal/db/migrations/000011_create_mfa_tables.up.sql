@@ -0,0 +1,34 @@
+-- Create totp_credentials table; secrets are encrypted with ENCRYPTION_KEY.
+-- A credential is pending until confirmed_at is set by a valid code.
+CREATE TABLE IF NOT EXISTS totp_credentials (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret_encrypted BYTEA NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    -- Time step of the last accepted code, so a code cannot be replayed
+    last_used_step BIGINT DEFAULT 0 NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create mfa_recovery_codes table; only SHA-256 digests of codes are stored
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    UNIQUE (user_id, code_hash)
+);
+
+-- Create mfa_challenges table for logins waiting on a second factor
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    device_name VARCHAR(100),
+    attempts INTEGER DEFAULT 0 NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create index on expires_at for cleanup
+CREATE INDEX idx_mfa_challenges_expires_at ON mfa_challenges(expires_at);
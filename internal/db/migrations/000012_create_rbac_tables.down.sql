@@ -0,0 +1,11 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_user_roles_role_id;
+
+-- Drop trigger
+DROP TRIGGER IF EXISTS update_roles_updated_at ON roles;
+
+-- Drop tables
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,12 @@
+-- Create login_attempts table counting failed logins per account and per IP.
+-- Accounts are keyed by email rather than user ID so unknown emails are
+-- throttled exactly like real ones.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INTEGER DEFAULT 0 NOT NULL,
+    last_failure_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    blocked_until TIMESTAMP WITH TIME ZONE
+);
+
+-- Create index on last_failure_at for pruning
+CREATE INDEX idx_login_attempts_last_failure_at ON login_attempts(last_failure_at);
@@ -0,0 +1,18 @@
+-- Create api_keys table for personal machine access. Keys look like
+-- sk_<prefix>_<secret>; the prefix identifies the key and only a SHA-256
+-- digest of the whole key is stored.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) UNIQUE NOT NULL,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes TEXT[] DEFAULT '{}' NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    last_used_ip VARCHAR(45),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create index on user_id for faster lookups
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
@@ -0,0 +1,20 @@
+-- Drop permissions
+DELETE FROM permissions WHERE name IN ('clients:read', 'clients:write');
+
+-- Drop OAuth refresh tokens and their columns
+DELETE FROM refresh_tokens WHERE client_id IS NOT NULL;
+DROP INDEX IF EXISTS idx_refresh_tokens_client_id;
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS scopes,
+    DROP COLUMN IF EXISTS client_id;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_oauth_authorization_codes_expires_at;
+
+-- Drop trigger
+DROP TRIGGER IF EXISTS update_oauth_clients_updated_at ON oauth_clients;
+
+-- Drop tables
+DROP TABLE IF EXISTS oauth_consents;
+DROP TABLE IF EXISTS oauth_authorization_codes;
+DROP TABLE IF EXISTS oauth_clients;
@@ -0,0 +1,64 @@
+-- Create oauth_clients table for applications that sign users in through
+-- this service. Public clients (browser and native apps) have no secret.
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id BIGSERIAL PRIMARY KEY,
+    client_id VARCHAR(64) UNIQUE NOT NULL,
+    client_secret_hash VARCHAR(64),
+    name VARCHAR(100) NOT NULL,
+    redirect_uris TEXT[] DEFAULT '{}' NOT NULL,
+    grant_types TEXT[] DEFAULT '{}' NOT NULL,
+    scopes TEXT[] DEFAULT '{}' NOT NULL,
+    trusted BOOLEAN DEFAULT false NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create trigger to automatically update updated_at
+CREATE TRIGGER update_oauth_clients_updated_at
+    BEFORE UPDATE ON oauth_clients
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Create oauth_authorization_codes table; only SHA-256 digests of codes are stored
+CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+    id BIGSERIAL PRIMARY KEY,
+    code_hash VARCHAR(64) UNIQUE NOT NULL,
+    client_id BIGINT NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT[] DEFAULT '{}' NOT NULL,
+    code_challenge VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create index on expires_at for cleanup
+CREATE INDEX idx_oauth_authorization_codes_expires_at ON oauth_authorization_codes(expires_at);
+
+-- Create oauth_consents table recording the scopes each user granted each client
+CREATE TABLE IF NOT EXISTS oauth_consents (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id BIGINT NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    scopes TEXT[] DEFAULT '{}' NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, client_id)
+);
+
+-- OAuth refresh tokens live alongside session refresh tokens, tagged with
+-- the client and the scopes they were granted
+ALTER TABLE refresh_tokens
+    ADD COLUMN client_id BIGINT REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    ADD COLUMN scopes TEXT[] DEFAULT '{}' NOT NULL;
+
+-- Create index on client_id for revoking a client's tokens
+CREATE INDEX idx_refresh_tokens_client_id ON refresh_tokens(client_id);
+
+-- Seed permissions
+INSERT INTO permissions (name, description) VALUES
+    ('clients:read', 'View registered OAuth clients'),
+    ('clients:write', 'Register and delete OAuth clients');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name IN ('clients:read', 'clients:write')
+WHERE r.name = 'admin';
@@ -0,0 +1,3 @@
+-- OpenID Connect clients send a nonce with the authorization request that
+-- must come back in the ID token issued for the code
+ALTER TABLE oauth_authorization_codes ADD COLUMN nonce VARCHAR(255) DEFAULT '' NOT NULL;
@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_external_login_states_expires_at;
+
+-- Drop tables
+DROP TABLE IF EXISTS external_login_states;
+DROP TABLE IF EXISTS identities;
@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_magic_link_tokens_expires_at;
+DROP INDEX IF EXISTS idx_magic_link_tokens_user_id;
+
+-- Drop table
+DROP TABLE IF EXISTS magic_link_tokens;
@@ -0,0 +1,18 @@
+-- Create magic_link_tokens table for passwordless sign-in; only SHA-256
+-- digests of tokens are stored. A link is valid only while the account still
+-- uses the email it was sent to.
+CREATE TABLE IF NOT EXISTS magic_link_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create index on user_id for faster lookups
+CREATE INDEX idx_magic_link_tokens_user_id ON magic_link_tokens(user_id);
+
+-- Create index on expires_at for cleanup
+CREATE INDEX idx_magic_link_tokens_expires_at ON magic_link_tokens(expires_at);
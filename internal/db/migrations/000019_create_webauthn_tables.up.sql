@@ -0,0 +1,34 @@
+-- Create webauthn_credentials table holding users' passkeys. public_key is
+-- the COSE_Key the authenticator returned at registration.
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id BYTEA UNIQUE NOT NULL,
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT DEFAULT 0 NOT NULL,
+    transports TEXT[] DEFAULT '{}' NOT NULL,
+    aaguid BYTEA NOT NULL,
+    backup_eligible BOOLEAN DEFAULT false NOT NULL,
+    backed_up BOOLEAN DEFAULT false NOT NULL,
+    nickname VARCHAR(100) NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create index on user_id for faster lookups
+CREATE INDEX idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+
+-- Create webauthn_challenges table for ceremonies in progress; only SHA-256
+-- digests of challenges are stored. Registrations belong to a user, sign-ins
+-- have none.
+CREATE TABLE IF NOT EXISTS webauthn_challenges (
+    id BIGSERIAL PRIMARY KEY,
+    challenge_hash VARCHAR(64) UNIQUE NOT NULL,
+    ceremony VARCHAR(20) NOT NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create index on expires_at for cleanup
+CREATE INDEX idx_webauthn_challenges_expires_at ON webauthn_challenges(expires_at);
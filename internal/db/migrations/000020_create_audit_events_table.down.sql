@@ -0,0 +1,20 @@
+-- Drop permissions
+DELETE FROM permissions WHERE name = 'audit:read';
+
+-- Drop triggers
+DROP TRIGGER IF EXISTS protect_audit_events_truncate ON audit_events;
+DROP TRIGGER IF EXISTS protect_audit_events ON audit_events;
+DROP TRIGGER IF EXISTS chain_audit_events ON audit_events;
+
+-- Drop functions
+DROP FUNCTION IF EXISTS reject_audit_event_change();
+DROP FUNCTION IF EXISTS chain_audit_event();
+DROP FUNCTION IF EXISTS audit_event_hash(audit_events, VARCHAR);
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_audit_events_occurred_at;
+DROP INDEX IF EXISTS idx_audit_events_action;
+DROP INDEX IF EXISTS idx_audit_events_actor_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS audit_events;
@@ -0,0 +1,91 @@
+-- Create audit_events table, an append-only record of security-relevant
+-- actions. Each row carries the hash of the row before it, so editing or
+-- removing a row breaks the chain from that point on.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    actor_id BIGINT,
+    action VARCHAR(100) NOT NULL,
+    target_type VARCHAR(50) DEFAULT '' NOT NULL,
+    target_id BIGINT,
+    ip_address VARCHAR(45) DEFAULT '' NOT NULL,
+    user_agent VARCHAR(512) DEFAULT '' NOT NULL,
+    request_id VARCHAR(128) DEFAULT '' NOT NULL,
+    diff JSONB DEFAULT '{}' NOT NULL,
+    prev_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) UNIQUE NOT NULL
+);
+
+-- Create indexes for the admin filters
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id);
+CREATE INDEX idx_audit_events_action ON audit_events(action);
+CREATE INDEX idx_audit_events_occurred_at ON audit_events(occurred_at);
+
+-- Create hash function covering every column of an event and the hash of the
+-- event before it. Timestamps are rendered in UTC so the result does not
+-- depend on the session time zone.
+CREATE OR REPLACE FUNCTION audit_event_hash(e audit_events, prev_hash VARCHAR)
+RETURNS VARCHAR AS $$
+    SELECT encode(sha256(convert_to(jsonb_build_array(
+        prev_hash,
+        e.id,
+        to_char(e.occurred_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'),
+        e.actor_id,
+        e.action,
+        e.target_type,
+        e.target_id,
+        e.ip_address,
+        e.user_agent,
+        e.request_id,
+        e.diff
+    )::text, 'UTF8')), 'hex');
+$$ language 'sql' STABLE;
+
+-- Create chaining trigger function. Writers take turns on an advisory lock
+-- held until commit, and ids are drawn under it, so id order is chain order.
+CREATE OR REPLACE FUNCTION chain_audit_event()
+RETURNS TRIGGER AS $$
+DECLARE
+    last_hash VARCHAR(64);
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('audit_events'));
+    NEW.id = nextval(pg_get_serial_sequence('audit_events', 'id'));
+    SELECT hash INTO last_hash FROM audit_events ORDER BY id DESC LIMIT 1;
+    NEW.prev_hash = COALESCE(last_hash, repeat('0', 64));
+    NEW.hash = audit_event_hash(NEW, NEW.prev_hash);
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+-- Create trigger to chain each new event to the last one
+CREATE TRIGGER chain_audit_events
+    BEFORE INSERT ON audit_events
+    FOR EACH ROW
+    EXECUTE FUNCTION chain_audit_event();
+
+-- Create trigger function refusing changes to recorded events
+CREATE OR REPLACE FUNCTION reject_audit_event_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ language 'plpgsql';
+
+-- Create triggers to keep audit_events append-only
+CREATE TRIGGER protect_audit_events
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_audit_event_change();
+
+CREATE TRIGGER protect_audit_events_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION reject_audit_event_change();
+
+-- Seed permissions
+INSERT INTO permissions (name, description) VALUES
+    ('audit:read', 'View and verify the audit log');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'audit:read'
+WHERE r.name = 'admin';
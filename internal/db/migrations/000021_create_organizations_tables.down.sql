@@ -0,0 +1,17 @@
+-- Drop policies
+DROP POLICY IF EXISTS organization_members_tenant_isolation ON organization_members;
+DROP POLICY IF EXISTS organizations_tenant_isolation ON organizations;
+
+-- Drop session organization
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS active_org_id;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_organization_members_user_id;
+
+-- Drop trigger
+DROP TRIGGER IF EXISTS update_organizations_updated_at ON organizations;
+
+-- Drop tables
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
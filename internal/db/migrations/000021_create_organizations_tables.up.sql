@@ -0,0 +1,46 @@
+-- Create organizations table for the companies users work in
+CREATE TABLE IF NOT EXISTS organizations (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(63) UNIQUE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create trigger to automatically update updated_at
+CREATE TRIGGER update_organizations_updated_at
+    BEFORE UPDATE ON organizations
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Create organization_members table; role is the member's role within the
+-- organization, separate from their system-wide roles
+CREATE TABLE IF NOT EXISTS organization_members (
+    org_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (org_id, user_id)
+);
+
+-- Create index on user_id for listing a user's organizations
+CREATE INDEX idx_organization_members_user_id ON organization_members(user_id);
+
+-- Sessions remember the organization they act in across refreshes
+ALTER TABLE refresh_tokens
+    ADD COLUMN active_org_id BIGINT REFERENCES organizations(id) ON DELETE SET NULL;
+
+-- Row-level security confines a transaction that sets app.org_id to that
+-- organization's rows. Transactions that leave it unset see every row, so the
+-- policies only bite where the application opts in. Superusers bypass them.
+ALTER TABLE organizations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE organizations FORCE ROW LEVEL SECURITY;
+CREATE POLICY organizations_tenant_isolation ON organizations
+    USING (COALESCE(current_setting('app.org_id', true), '') = ''
+        OR id = current_setting('app.org_id', true)::bigint);
+
+ALTER TABLE organization_members ENABLE ROW LEVEL SECURITY;
+ALTER TABLE organization_members FORCE ROW LEVEL SECURITY;
+CREATE POLICY organization_members_tenant_isolation ON organization_members
+    USING (COALESCE(current_setting('app.org_id', true), '') = ''
+        OR org_id = current_setting('app.org_id', true)::bigint);
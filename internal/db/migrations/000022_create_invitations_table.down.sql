@@ -0,0 +1,9 @@
+-- Drop policy
+DROP POLICY IF EXISTS invitations_tenant_isolation ON invitations;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_invitations_expires_at;
+DROP INDEX IF EXISTS idx_invitations_org_id;
+
+-- Drop table
+DROP TABLE IF EXISTS invitations;
@@ -0,0 +1,36 @@
+-- Create invitations table for onboarding users into the system or an
+-- organization; only SHA-256 digests of tokens are stored. Organization
+-- invitations carry the member role to grant, system invitations an
+-- optional role from the roles table.
+CREATE TABLE IF NOT EXISTS invitations (
+    id BIGSERIAL PRIMARY KEY,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    org_id BIGINT REFERENCES organizations(id) ON DELETE CASCADE,
+    org_role VARCHAR(20) NOT NULL DEFAULT '',
+    role_id BIGINT REFERENCES roles(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    email_domain VARCHAR(255) NOT NULL DEFAULT '',
+    invited_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CHECK (
+        (org_id IS NULL AND org_role = '')
+        OR (org_id IS NOT NULL AND role_id IS NULL AND org_role IN ('owner', 'admin', 'member'))
+    )
+);
+
+-- Create index on org_id for listing an organization's invitations
+CREATE INDEX idx_invitations_org_id ON invitations(org_id);
+
+-- Create index on expires_at for cleanup
+CREATE INDEX idx_invitations_expires_at ON invitations(expires_at);
+
+-- Organization invitations follow the same row-level security as members.
+-- System invitations have no org_id and are hidden inside an organization.
+ALTER TABLE invitations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE invitations FORCE ROW LEVEL SECURITY;
+CREATE POLICY invitations_tenant_isolation ON invitations
+    USING (COALESCE(current_setting('app.org_id', true), '') = ''
+        OR org_id = current_setting('app.org_id', true)::bigint);
@@ -0,0 +1,3 @@
+-- Accounts created by an admin start with a temporary password the user is
+-- asked to replace
+ALTER TABLE users ADD COLUMN password_change_required BOOLEAN DEFAULT false NOT NULL;
@@ -0,0 +1,7 @@
+-- Support staff can sign in as a user to see what they see
+INSERT INTO permissions (name, description) VALUES
+    ('users:impersonate', 'Act as another user with a short-lived token');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'users:impersonate'
+WHERE r.name = 'admin';
@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_revoked_sessions_expires_at;
+
+-- Drop table
+DROP TABLE IF EXISTS revoked_sessions;
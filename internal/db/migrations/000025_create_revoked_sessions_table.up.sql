@@ -0,0 +1,12 @@
+-- Create revoked_sessions table listing login sessions whose access tokens are
+-- rejected before expiry; session_id is the refresh token family the tokens'
+-- sid claim names
+CREATE TABLE IF NOT EXISTS revoked_sessions (
+    session_id VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Create index on expires_at for pruning
+CREATE INDEX idx_revoked_sessions_expires_at ON revoked_sessions(expires_at);
@@ -0,0 +1,3 @@
+-- A redirect_uri left out of the authorization request may be left out of
+-- the token request too (RFC 6749 section 4.1.3)
+ALTER TABLE oauth_authorization_codes ADD COLUMN redirect_uri_supplied BOOLEAN DEFAULT TRUE NOT NULL;
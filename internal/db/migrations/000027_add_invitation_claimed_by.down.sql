@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_invitations_claimed_by;
+ALTER TABLE invitations DROP COLUMN IF EXISTS claimed_by;
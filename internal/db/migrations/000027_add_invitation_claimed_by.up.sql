@@ -0,0 +1,6 @@
+-- Registering with a domain invitation claims it for the new account, whose
+-- email is unverified; what it grants is given once the email is verified
+ALTER TABLE invitations ADD COLUMN claimed_by BIGINT REFERENCES users(id) ON DELETE CASCADE;
+
+-- Create index on claimed_by for granting claims on verification
+CREATE INDEX idx_invitations_claimed_by ON invitations(claimed_by);
@@ -0,0 +1,25 @@
+-- name: CreateAuditEvent :exec
+-- The chain columns and id are filled in by the chain_audit_events trigger
+INSERT INTO audit_events (actor_id, action, target_type, target_id, ip_address, user_agent, request_id, diff)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE (sqlc.narg('actor_id')::bigint IS NULL OR actor_id = sqlc.narg('actor_id'))
+  AND (sqlc.narg('action')::varchar IS NULL OR action = sqlc.narg('action'))
+  AND (sqlc.narg('since')::timestamptz IS NULL OR occurred_at >= sqlc.narg('since'))
+  AND (sqlc.narg('until')::timestamptz IS NULL OR occurred_at < sqlc.narg('until'))
+  AND (sqlc.narg('before_id')::bigint IS NULL OR id < sqlc.narg('before_id'))
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: VerifyAuditChain :one
+-- Recomputes every hash and link; broken_id is the first event that does
+-- not match, or 0 when the chain is intact
+SELECT COUNT(*) AS checked, COALESCE(MIN(id) FILTER (WHERE broken), 0)::bigint AS broken_id
+FROM (
+    SELECT id,
+        hash <> audit_event_hash(e, prev_hash)
+            OR prev_hash <> COALESCE(lag(hash) OVER (ORDER BY id), repeat('0', 64)) AS broken
+    FROM audit_events e
+) chain;
@@ -0,0 +1,57 @@
+-- name: CreateIdentity :one
+INSERT INTO identities (user_id, provider, subject, email)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetIdentity :one
+SELECT * FROM identities
+WHERE provider = $1 AND subject = $2
+LIMIT 1;
+
+-- name: ListUserIdentities :many
+SELECT * FROM identities
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: ListUserIdentitiesForUpdate :many
+-- Locking every identity of the user makes concurrent unlinks take turns
+SELECT * FROM identities
+WHERE user_id = $1
+ORDER BY created_at
+FOR UPDATE;
+
+-- name: TouchIdentity :exec
+UPDATE identities
+SET email = $2, last_login_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: DeleteUserIdentity :execrows
+DELETE FROM identities
+WHERE user_id = $1 AND provider = $2;
+
+-- name: CreateExternalUser :one
+-- Creates a user without a password together with the identity they signed
+-- in with. The provider has verified the email.
+WITH new_user AS (
+    INSERT INTO users (email, password_hash, full_name, email_verified_at)
+    VALUES (sqlc.arg(email)::varchar, '', sqlc.arg(full_name)::varchar, CURRENT_TIMESTAMP)
+    RETURNING *
+), new_identity AS (
+    INSERT INTO identities (user_id, provider, subject, email, last_login_at)
+    SELECT id, sqlc.arg(provider)::varchar, sqlc.arg(subject)::varchar, email, CURRENT_TIMESTAMP FROM new_user
+)
+SELECT * FROM new_user;
+
+-- name: CreateExternalLoginState :exec
+INSERT INTO external_login_states (state_hash, provider, nonce, code_verifier, user_id, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: ConsumeExternalLoginState :one
+-- States work once, whether or not the sign-in succeeds
+DELETE FROM external_login_states
+WHERE state_hash = $1 AND provider = $2 AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: DeleteExpiredExternalLoginStates :exec
+DELETE FROM external_login_states
+WHERE expires_at < CURRENT_TIMESTAMP;
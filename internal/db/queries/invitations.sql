@@ -0,0 +1,62 @@
+-- name: CreateInvitation :one
+INSERT INTO invitations (token_hash, org_id, org_role, role_id, email, email_domain, invited_by, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetInvitation :one
+-- Looks an invitation up without accepting it
+SELECT * FROM invitations
+WHERE token_hash = $1 AND accepted_at IS NULL AND claimed_by IS NULL AND expires_at > CURRENT_TIMESTAMP
+LIMIT 1;
+
+-- name: AcceptInvitation :one
+-- Accepts an invitation; concurrent requests cannot both succeed
+UPDATE invitations
+SET accepted_by = sqlc.arg(accepted_by), accepted_at = CURRENT_TIMESTAMP
+WHERE token_hash = sqlc.arg(token_hash) AND accepted_at IS NULL AND claimed_by IS NULL AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: ClaimInvitation :one
+-- Reserves an invitation for an account that cannot accept it yet; concurrent
+-- requests cannot both succeed
+UPDATE invitations
+SET claimed_by = sqlc.arg(claimed_by)
+WHERE token_hash = sqlc.arg(token_hash) AND accepted_at IS NULL AND claimed_by IS NULL AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: ListClaimedInvitations :many
+SELECT * FROM invitations
+WHERE claimed_by = $1 AND accepted_at IS NULL
+ORDER BY created_at;
+
+-- name: AcceptClaimedInvitation :one
+-- Accepts a claimed invitation for the account that claimed it, even once
+-- it has expired
+UPDATE invitations
+SET accepted_by = claimed_by, accepted_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND claimed_by IS NOT NULL AND accepted_at IS NULL
+RETURNING *;
+
+-- name: ListSystemInvitations :many
+SELECT * FROM invitations
+WHERE org_id IS NULL AND accepted_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+ORDER BY created_at DESC;
+
+-- name: ListOrganizationInvitations :many
+SELECT * FROM invitations
+WHERE org_id = $1 AND accepted_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+ORDER BY created_at DESC;
+
+-- name: DeleteSystemInvitation :one
+DELETE FROM invitations
+WHERE id = $1 AND org_id IS NULL AND accepted_at IS NULL
+RETURNING *;
+
+-- name: DeleteOrganizationInvitation :one
+DELETE FROM invitations
+WHERE id = $1 AND org_id = $2 AND accepted_at IS NULL
+RETURNING *;
+
+-- name: DeleteExpiredInvitations :exec
+DELETE FROM invitations
+WHERE expires_at < CURRENT_TIMESTAMP AND accepted_at IS NULL AND claimed_by IS NULL;
@@ -0,0 +1,29 @@
+-- name: GetLoginAttempt :one
+SELECT * FROM login_attempts
+WHERE key = $1
+LIMIT 1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_attempts (key, failures, last_failure_at)
+VALUES (sqlc.arg(key), 1, sqlc.arg(failed_at)::timestamptz)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_attempts.last_failure_at < sqlc.arg(reset_before)::timestamptz THEN 1
+        ELSE login_attempts.failures + 1
+    END,
+    last_failure_at = EXCLUDED.last_failure_at
+RETURNING *;
+
+-- name: BlockLoginKey :exec
+UPDATE login_attempts
+SET blocked_until = sqlc.arg(blocked_until)::timestamptz
+WHERE key = sqlc.arg(key);
+
+-- name: DeleteLoginAttempt :execrows
+DELETE FROM login_attempts
+WHERE key = $1;
+
+-- name: DeleteStaleLoginAttempts :exec
+DELETE FROM login_attempts
+WHERE last_failure_at < sqlc.arg(before)::timestamptz
+    AND (blocked_until IS NULL OR blocked_until < sqlc.arg(before)::timestamptz);
@@ -0,0 +1,25 @@
+-- name: CreateMagicLinkToken :one
+INSERT INTO magic_link_tokens (user_id, email, token_hash, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetMagicLinkToken :one
+-- Looks a link up without redeeming it
+SELECT * FROM magic_link_tokens
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+LIMIT 1;
+
+-- name: ConsumeMagicLinkToken :one
+-- Redeems a link; concurrent requests cannot both succeed
+UPDATE magic_link_tokens
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: DeleteUserMagicLinkTokens :exec
+DELETE FROM magic_link_tokens
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: DeleteExpiredMagicLinkTokens :exec
+DELETE FROM magic_link_tokens
+WHERE expires_at < CURRENT_TIMESTAMP;
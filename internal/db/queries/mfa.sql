@@ -0,0 +1,76 @@
+-- name: UpsertPendingTOTPCredential :execrows
+INSERT INTO totp_credentials (user_id, secret_encrypted)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret_encrypted = EXCLUDED.secret_encrypted,
+    last_used_step = 0,
+    created_at = CURRENT_TIMESTAMP
+WHERE totp_credentials.confirmed_at IS NULL;
+
+-- name: GetTOTPCredentialForUpdate :one
+SELECT * FROM totp_credentials
+WHERE user_id = $1
+LIMIT 1
+FOR UPDATE;
+
+-- name: ConfirmTOTPCredential :exec
+UPDATE totp_credentials
+SET confirmed_at = CURRENT_TIMESTAMP, last_used_step = $2
+WHERE user_id = $1;
+
+-- name: UpdateTOTPLastUsedStep :exec
+UPDATE totp_credentials
+SET last_used_step = $2
+WHERE user_id = $1;
+
+-- name: DeleteTOTPCredential :exec
+DELETE FROM totp_credentials
+WHERE user_id = $1;
+
+-- name: IsMFAEnabled :one
+SELECT EXISTS (
+    SELECT 1 FROM totp_credentials
+    WHERE user_id = $1 AND confirmed_at IS NOT NULL
+);
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO mfa_recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: UseRecoveryCode :execrows
+UPDATE mfa_recovery_codes
+SET used_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM mfa_recovery_codes
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: DeleteUserRecoveryCodes :exec
+DELETE FROM mfa_recovery_codes
+WHERE user_id = $1;
+
+-- name: CreateMFAChallenge :one
+INSERT INTO mfa_challenges (user_id, token_hash, device_name, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetMFAChallengeForUpdate :one
+SELECT * FROM mfa_challenges
+WHERE token_hash = $1 AND expires_at > CURRENT_TIMESTAMP
+LIMIT 1
+FOR UPDATE;
+
+-- name: IncrementMFAChallengeAttempts :one
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE id = $1
+RETURNING attempts;
+
+-- name: DeleteMFAChallenge :exec
+DELETE FROM mfa_challenges
+WHERE id = $1;
+
+-- name: DeleteExpiredMFAChallenges :exec
+DELETE FROM mfa_challenges
+WHERE expires_at < CURRENT_TIMESTAMP;
@@ -0,0 +1,68 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (client_id, client_secret_hash, name, redirect_uris, grant_types, scopes, trusted)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetOAuthClientByClientID :one
+SELECT * FROM oauth_clients
+WHERE client_id = $1
+LIMIT 1;
+
+-- name: ListOAuthClients :many
+SELECT * FROM oauth_clients
+ORDER BY created_at;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE client_id = $1;
+
+-- name: DeleteOAuthClientRefreshTokens :many
+-- Deletes the refresh tokens issued to a client and returns their sessions
+DELETE FROM refresh_tokens
+WHERE client_id = (SELECT id FROM oauth_clients WHERE oauth_clients.client_id = $1)
+RETURNING user_id, family_id;
+
+-- name: CreateAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, redirect_uri_supplied, scopes, code_challenge, nonce, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+
+-- name: ConsumeAuthorizationCode :one
+-- Deleting the code as it is read makes it single use
+DELETE FROM oauth_authorization_codes
+WHERE code_hash = $1
+RETURNING *;
+
+-- name: DeleteExpiredAuthorizationCodes :exec
+DELETE FROM oauth_authorization_codes
+WHERE expires_at < CURRENT_TIMESTAMP;
+
+-- name: GetOAuthConsent :one
+SELECT * FROM oauth_consents
+WHERE user_id = $1 AND client_id = $2
+LIMIT 1;
+
+-- name: UpsertOAuthConsent :exec
+-- Consent accumulates, so approving fewer scopes later keeps the rest
+INSERT INTO oauth_consents (user_id, client_id, scopes)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, client_id)
+DO UPDATE SET
+    scopes = ARRAY(SELECT unnest(oauth_consents.scopes) UNION SELECT unnest(EXCLUDED.scopes)),
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: ListUserOAuthConsents :many
+SELECT c.client_id, c.name, oc.scopes, oc.created_at, oc.updated_at
+FROM oauth_consents oc
+JOIN oauth_clients c ON c.id = oc.client_id
+WHERE oc.user_id = $1
+ORDER BY oc.updated_at DESC;
+
+-- name: DeleteOAuthConsent :execrows
+DELETE FROM oauth_consents
+WHERE user_id = $1 AND client_id = $2;
+
+-- name: DeleteUserClientRefreshTokens :many
+-- Deletes a user's refresh tokens for a client and returns their sessions
+DELETE FROM refresh_tokens
+WHERE user_id = $1 AND client_id = $2
+RETURNING family_id;
@@ -0,0 +1,65 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (name, slug)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: GetOrganization :one
+SELECT * FROM organizations
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetOrganizationForUpdate :one
+SELECT * FROM organizations
+WHERE id = $1
+LIMIT 1
+FOR UPDATE;
+
+-- name: ListUserOrganizations :many
+SELECT o.*, m.role FROM organizations o
+JOIN organization_members m ON m.org_id = o.id
+WHERE m.user_id = $1
+ORDER BY o.name;
+
+-- name: AddOrganizationMember :one
+INSERT INTO organization_members (org_id, user_id, role)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetOrganizationMember :one
+SELECT * FROM organization_members
+WHERE org_id = $1 AND user_id = $2
+LIMIT 1;
+
+-- name: ListOrganizationMembers :many
+SELECT m.user_id, u.email, u.full_name, m.role, m.created_at FROM organization_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.org_id = $1 AND u.is_active = true
+ORDER BY m.created_at;
+
+-- name: UpdateOrganizationMemberRole :one
+UPDATE organization_members
+SET role = $3
+WHERE org_id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: RemoveOrganizationMember :execrows
+DELETE FROM organization_members
+WHERE org_id = $1 AND user_id = $2;
+
+-- name: CountOrganizationOwners :one
+SELECT COUNT(*) FROM organization_members
+WHERE org_id = $1 AND role = 'owner';
+
+-- name: SetSessionActiveOrganization :exec
+UPDATE refresh_tokens
+SET active_org_id = $3
+WHERE family_id = $1 AND user_id = $2;
+
+-- name: CountSoleOwnedOrganizations :one
+-- Organizations that would be left without an owner if the user went away
+SELECT COUNT(*) FROM organization_members m
+WHERE m.user_id = $1 AND m.role = 'owner'
+  AND NOT EXISTS (
+      SELECT 1 FROM organization_members o
+      WHERE o.org_id = m.org_id AND o.role = 'owner' AND o.user_id <> m.user_id
+  );
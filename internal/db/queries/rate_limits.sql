@@ -0,0 +1,23 @@
+-- name: EnsureRateLimitBucket :exec
+INSERT INTO rate_limit_buckets (key, tokens)
+VALUES ($1, $2)
+ON CONFLICT (key) DO NOTHING;
+
+-- name: GetRateLimitBucketForUpdate :one
+SELECT * FROM rate_limit_buckets
+WHERE key = $1
+FOR UPDATE;
+
+-- name: UpdateRateLimitBucket :exec
+UPDATE rate_limit_buckets
+SET
+    tokens = $2,
+    window_start = $3,
+    window_count = $4,
+    prev_count = $5,
+    updated_at = $6
+WHERE key = $1;
+
+-- name: DeleteStaleRateLimitBuckets :exec
+DELETE FROM rate_limit_buckets
+WHERE updated_at < $1;
@@ -0,0 +1,80 @@
+-- name: CreateRole :one
+INSERT INTO roles (name, description)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: GetRoleByID :one
+SELECT * FROM roles
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetRoleByName :one
+SELECT * FROM roles
+WHERE name = $1
+LIMIT 1;
+
+-- name: GetRoleForUpdate :one
+SELECT * FROM roles
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ListRoles :many
+SELECT * FROM roles
+ORDER BY name;
+
+-- name: UpdateRoleDescription :one
+UPDATE roles
+SET description = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteRole :execrows
+DELETE FROM roles
+WHERE id = $1;
+
+-- name: ListPermissions :many
+SELECT * FROM permissions
+ORDER BY name;
+
+-- name: ListRolePermissions :many
+SELECT p.name FROM permissions p
+JOIN role_permissions rp ON rp.permission_id = p.id
+WHERE rp.role_id = $1
+ORDER BY p.name;
+
+-- name: AddRolePermission :execrows
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT sqlc.arg(role_id)::bigint, id FROM permissions
+WHERE name = sqlc.arg(permission)::text
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteRolePermissions :exec
+DELETE FROM role_permissions
+WHERE role_id = $1;
+
+-- name: ListUserRoles :many
+SELECT r.name FROM roles r
+JOIN user_roles ur ON ur.role_id = r.id
+WHERE ur.user_id = $1
+ORDER BY r.name;
+
+-- name: ListUserPermissions :many
+SELECT p.name FROM permissions p
+JOIN role_permissions rp ON rp.permission_id = p.id
+JOIN user_roles ur ON ur.role_id = rp.role_id
+WHERE ur.user_id = $1
+GROUP BY p.name
+ORDER BY p.name;
+
+-- name: AssignUserRole :exec
+INSERT INTO user_roles (user_id, role_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveUserRole :execrows
+DELETE FROM user_roles
+WHERE user_id = $1 AND role_id = $2;
+
+-- name: ListRoleUserIDs :many
+SELECT user_id FROM user_roles
+WHERE role_id = $1;
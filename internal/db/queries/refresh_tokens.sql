@@ -1,16 +1,61 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (user_id, token, expires_at)
-VALUES ($1, $2, $3)
+INSERT INTO refresh_tokens (
+    user_id, token_hash, expires_at, family_id,
+    user_agent, ip_address, device_name, session_started_at, active_org_id
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: CreateOAuthRefreshToken :one
+INSERT INTO refresh_tokens (
+    user_id, token_hash, expires_at, family_id,
+    user_agent, ip_address, client_id, scopes
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING *;
 
 -- name: GetRefreshToken :one
 SELECT * FROM refresh_tokens
-WHERE token = $1 AND expires_at > CURRENT_TIMESTAMP
+WHERE token_hash = $1 AND expires_at > CURRENT_TIMESTAMP
 LIMIT 1;
 
+-- name: GetRefreshTokenForUpdate :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1 AND expires_at > CURRENT_TIMESTAMP
+LIMIT 1
+FOR UPDATE;
+
+-- name: ConsumeRefreshToken :execrows
+UPDATE refresh_tokens
+SET consumed_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND consumed_at IS NULL;
+
 -- name: DeleteRefreshToken :exec
 DELETE FROM refresh_tokens
-WHERE token = $1;
+WHERE token_hash = $1;
+
+-- name: DeleteRefreshTokenFamily :exec
+DELETE FROM refresh_tokens
+WHERE family_id = $1;
+
+-- name: ListUserSessions :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1 AND client_id IS NULL AND consumed_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+ORDER BY last_used_at DESC;
+
+-- name: DeleteUserSession :execrows
+-- Leaves OAuth grants, which are revoked as authorized apps
+DELETE FROM refresh_tokens
+WHERE user_id = $1 AND client_id IS NULL AND family_id = $2;
+
+-- name: DeleteUserSessionsExcept :exec
+-- Leaves OAuth grants, which are revoked as authorized apps
+DELETE FROM refresh_tokens
+WHERE user_id = $1 AND client_id IS NULL AND family_id <> $2;
+
+-- name: DeleteUserRefreshTokensExceptFamily :exec
+DELETE FROM refresh_tokens
+WHERE user_id = $1 AND family_id <> $2;
 
 -- name: DeleteUserRefreshTokens :exec
 DELETE FROM refresh_tokens
@@ -0,0 +1,46 @@
+-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (jti, user_id, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: RevokeSession :exec
+INSERT INTO revoked_sessions (session_id, user_id, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (session_id) DO NOTHING;
+
+-- name: RevokeUserSessionsExcept :exec
+-- Revokes every login session the user has refresh tokens for but the given
+-- one. OAuth grants are left alone.
+INSERT INTO revoked_sessions (session_id, user_id, expires_at)
+SELECT DISTINCT family_id::text, user_id, sqlc.arg(expires_at)::timestamptz
+FROM refresh_tokens
+WHERE user_id = sqlc.arg(user_id) AND client_id IS NULL AND family_id::text <> sqlc.arg(except_session_id)::text
+ON CONFLICT (session_id) DO NOTHING;
+
+-- name: IsTokenRevoked :one
+-- Tokens of deleted users and deleted OAuth clients are revoked; client
+-- tokens have no user
+SELECT (
+    EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = sqlc.arg(jti))
+    OR EXISTS (SELECT 1 FROM revoked_sessions WHERE session_id = sqlc.arg(session_id))
+    OR EXISTS (
+        SELECT 1 FROM users
+        WHERE id = sqlc.arg(user_id) AND tokens_invalid_before >= sqlc.arg(issued_at)::timestamptz
+    )
+    OR (
+        sqlc.arg(user_id)::bigint <> 0
+        AND NOT EXISTS (SELECT 1 FROM users WHERE id = sqlc.arg(user_id))
+    )
+    OR (
+        sqlc.arg(client_id)::text <> ''
+        AND NOT EXISTS (SELECT 1 FROM oauth_clients WHERE client_id = sqlc.arg(client_id))
+    )
+)::boolean AS revoked;
+
+-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at < CURRENT_TIMESTAMP;
+
+-- name: DeleteExpiredRevokedSessions :exec
+DELETE FROM revoked_sessions
+WHERE expires_at < CURRENT_TIMESTAMP;
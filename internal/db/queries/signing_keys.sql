@@ -0,0 +1,21 @@
+-- name: CreateSigningKey :one
+INSERT INTO signing_keys (id, algorithm, private_key)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: ListSigningKeys :many
+SELECT * FROM signing_keys
+WHERE expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP
+ORDER BY created_at DESC;
+
+-- name: RetireSigningKeys :exec
+-- Retires the active keys older than active_id. A key saved meanwhile by a
+-- replica rotating at the same time is newer and stays active.
+UPDATE signing_keys
+SET expires_at = sqlc.arg(expires_at)::timestamptz
+WHERE expires_at IS NULL
+  AND (created_at, id) < (SELECT created_at, id FROM signing_keys WHERE id = sqlc.arg(active_id));
+
+-- name: DeleteExpiredSigningKeys :exec
+DELETE FROM signing_keys
+WHERE expires_at < CURRENT_TIMESTAMP;
@@ -24,9 +24,16 @@ RETURNING *;
 
 -- name: UpdateUserPassword :exec
 UPDATE users
-SET password_hash = $2, updated_at = CURRENT_TIMESTAMP
+SET password_hash = $2, password_change_required = false, updated_at = CURRENT_TIMESTAMP
 WHERE id = $1;
 
+-- name: RehashUserPassword :exec
+-- Stores a new hash of the same password, unless the password was changed
+-- since old_hash was read
+UPDATE users
+SET password_hash = sqlc.arg(password_hash)
+WHERE id = sqlc.arg(id) AND password_hash = sqlc.arg(old_hash);
+
 -- name: DeleteUser :exec
 UPDATE users
 SET is_active = false, updated_at = CURRENT_TIMESTAMP
@@ -41,3 +48,77 @@ LIMIT $1 OFFSET $2;
 -- name: CountUsers :one
 SELECT COUNT(*) FROM users
 WHERE is_active = true;
+
+-- name: InvalidateUserTokens :exec
+UPDATE users
+SET tokens_invalid_before = sqlc.arg(tokens_invalid_before)::timestamptz
+WHERE id = sqlc.arg(id);
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET email = sqlc.arg(email), email_verified_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND is_active = true
+RETURNING *;
+
+-- name: AdminCreateUser :one
+INSERT INTO users (email, password_hash, full_name, is_admin, email_verified_at, password_change_required)
+VALUES ($1, $2, $3, $4, $5, true)
+RETURNING *;
+
+-- name: AdminGetUser :one
+-- Includes deactivated accounts
+SELECT * FROM users
+WHERE id = $1
+LIMIT 1;
+
+-- name: AdminGetUserForUpdate :one
+SELECT * FROM users
+WHERE id = $1
+FOR UPDATE;
+
+-- name: AdminListUsers :many
+-- Filters on is_active when it is given
+SELECT * FROM users
+WHERE sqlc.narg('is_active')::boolean IS NULL OR is_active = sqlc.narg('is_active')::boolean
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: AdminCountUsers :one
+SELECT COUNT(*) FROM users
+WHERE sqlc.narg('is_active')::boolean IS NULL OR is_active = sqlc.narg('is_active')::boolean;
+
+-- name: AdminUpdateUser :one
+UPDATE users
+SET
+    email = $2,
+    full_name = $3,
+    is_admin = $4,
+    email_verified_at = $5,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserActive :one
+UPDATE users
+SET is_active = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: ClearUserPassword :exec
+-- Leaves the account without a password until it is reset
+UPDATE users
+SET password_hash = '', updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: HardDeleteUser :execrows
+DELETE FROM users
+WHERE id = $1;
+
+-- name: ListPasswordHashSamples :many
+-- One stored hash for each algorithm and parameter set in use. Salts and
+-- digests are the only fields of 22 characters or more, so dropping them
+-- leaves what the hashes share.
+SELECT MIN(password_hash)::text AS password_hash
+FROM users
+WHERE password_hash <> ''
+GROUP BY regexp_replace(password_hash, '[^$]{22,}', '', 'g');
@@ -0,0 +1,46 @@
+-- name: CreateWebAuthnCredential :one
+INSERT INTO webauthn_credentials (user_id, credential_id, public_key, sign_count, transports, aaguid, backup_eligible, backed_up, nickname)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: GetWebAuthnCredential :one
+SELECT * FROM webauthn_credentials
+WHERE credential_id = $1
+LIMIT 1;
+
+-- name: ListUserWebAuthnCredentials :many
+SELECT * FROM webauthn_credentials
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: UpdateWebAuthnCredentialUsage :execrows
+-- Only moves the signature counter forward, so of two sign-ins with the same
+-- counter only one updates the row. Authenticators without a counter always
+-- report zero.
+UPDATE webauthn_credentials
+SET sign_count = $2, backed_up = $3, last_used_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND (sign_count < $2 OR (sign_count = 0 AND $2 = 0));
+
+-- name: RenameUserWebAuthnCredential :one
+UPDATE webauthn_credentials
+SET nickname = $3
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteUserWebAuthnCredential :execrows
+DELETE FROM webauthn_credentials
+WHERE id = $1 AND user_id = $2;
+
+-- name: CreateWebAuthnChallenge :exec
+INSERT INTO webauthn_challenges (challenge_hash, ceremony, user_id, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: ConsumeWebAuthnChallenge :one
+-- Challenges work once, whether or not the ceremony succeeds
+DELETE FROM webauthn_challenges
+WHERE challenge_hash = $1 AND ceremony = $2 AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: DeleteExpiredWebAuthnChallenges :exec
+DELETE FROM webauthn_challenges
+WHERE expires_at < CURRENT_TIMESTAMP;
@@ -5,24 +5,298 @@
 package sqlc
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
-type RefreshToken struct {
+type ApiKey struct {
+	ID         int64          `json:"id"`
+	UserID     int64          `json:"user_id"`
+	Name       string         `json:"name"`
+	Prefix     string         `json:"prefix"`
+	KeyHash    string         `json:"key_hash"`
+	Scopes     []string       `json:"scopes"`
+	ExpiresAt  sql.NullTime   `json:"expires_at"`
+	LastUsedAt sql.NullTime   `json:"last_used_at"`
+	LastUsedIp sql.NullString `json:"last_used_ip"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorID    sql.NullInt64   `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   sql.NullInt64   `json:"target_id"`
+	IpAddress  string          `json:"ip_address"`
+	UserAgent  string          `json:"user_agent"`
+	RequestID  string          `json:"request_id"`
+	Diff       json.RawMessage `json:"diff"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+type EmailVerificationToken struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	Email     string       `json:"email"`
+	TokenHash string       `json:"token_hash"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type ExternalLoginState struct {
+	ID           int64         `json:"id"`
+	StateHash    string        `json:"state_hash"`
+	Provider     string        `json:"provider"`
+	Nonce        string        `json:"nonce"`
+	CodeVerifier string        `json:"code_verifier"`
+	UserID       sql.NullInt64 `json:"user_id"`
+	ExpiresAt    time.Time     `json:"expires_at"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+type Identity struct {
+	ID          int64        `json:"id"`
+	UserID      int64        `json:"user_id"`
+	Provider    string       `json:"provider"`
+	Subject     string       `json:"subject"`
+	Email       string       `json:"email"`
+	LastLoginAt sql.NullTime `json:"last_login_at"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+type Invitation struct {
+	ID          int64         `json:"id"`
+	TokenHash   string        `json:"token_hash"`
+	OrgID       sql.NullInt64 `json:"org_id"`
+	OrgRole     string        `json:"org_role"`
+	RoleID      sql.NullInt64 `json:"role_id"`
+	Email       string        `json:"email"`
+	EmailDomain string        `json:"email_domain"`
+	InvitedBy   sql.NullInt64 `json:"invited_by"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+	AcceptedBy  sql.NullInt64 `json:"accepted_by"`
+	AcceptedAt  sql.NullTime  `json:"accepted_at"`
+	CreatedAt   time.Time     `json:"created_at"`
+	ClaimedBy   sql.NullInt64 `json:"claimed_by"`
+}
+
+type LoginAttempt struct {
+	Key           string       `json:"key"`
+	Failures      int32        `json:"failures"`
+	LastFailureAt time.Time    `json:"last_failure_at"`
+	BlockedUntil  sql.NullTime `json:"blocked_until"`
+}
+
+type MagicLinkToken struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	Email     string       `json:"email"`
+	TokenHash string       `json:"token_hash"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type MfaChallenge struct {
+	ID         int64          `json:"id"`
+	UserID     int64          `json:"user_id"`
+	TokenHash  string         `json:"token_hash"`
+	DeviceName sql.NullString `json:"device_name"`
+	Attempts   int32          `json:"attempts"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+type MfaRecoveryCode struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type OauthAuthorizationCode struct {
+	ID                  int64     `json:"id"`
+	CodeHash            string    `json:"code_hash"`
+	ClientID            int64     `json:"client_id"`
+	UserID              int64     `json:"user_id"`
+	RedirectUri         string    `json:"redirect_uri"`
+	Scopes              []string  `json:"scopes"`
+	CodeChallenge       string    `json:"code_challenge"`
+	ExpiresAt           time.Time `json:"expires_at"`
+	CreatedAt           time.Time `json:"created_at"`
+	Nonce               string    `json:"nonce"`
+	RedirectUriSupplied bool      `json:"redirect_uri_supplied"`
+}
+
+type OauthClient struct {
+	ID               int64          `json:"id"`
+	ClientID         string         `json:"client_id"`
+	ClientSecretHash sql.NullString `json:"client_secret_hash"`
+	Name             string         `json:"name"`
+	RedirectUris     []string       `json:"redirect_uris"`
+	GrantTypes       []string       `json:"grant_types"`
+	Scopes           []string       `json:"scopes"`
+	Trusted          bool           `json:"trusted"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+type OauthConsent struct {
+	UserID    int64     `json:"user_id"`
+	ClientID  int64     `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Organization struct {
 	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type OrganizationMember struct {
+	OrgID     int64     `json:"org_id"`
+	UserID    int64     `json:"user_id"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PasswordResetToken struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	TokenHash string       `json:"token_hash"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type Permission struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RateLimitBucket struct {
+	Key         string    `json:"key"`
+	Tokens      float64   `json:"tokens"`
+	WindowStart time.Time `json:"window_start"`
+	WindowCount int32     `json:"window_count"`
+	PrevCount   int32     `json:"prev_count"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RefreshToken struct {
+	ID               int64          `json:"id"`
+	UserID           int64          `json:"user_id"`
+	TokenHash        string         `json:"token_hash"`
+	ExpiresAt        time.Time      `json:"expires_at"`
+	CreatedAt        time.Time      `json:"created_at"`
+	FamilyID         uuid.UUID      `json:"family_id"`
+	ConsumedAt       sql.NullTime   `json:"consumed_at"`
+	UserAgent        string         `json:"user_agent"`
+	IpAddress        string         `json:"ip_address"`
+	DeviceName       sql.NullString `json:"device_name"`
+	SessionStartedAt time.Time      `json:"session_started_at"`
+	LastUsedAt       time.Time      `json:"last_used_at"`
+	ClientID         sql.NullInt64  `json:"client_id"`
+	Scopes           []string       `json:"scopes"`
+	ActiveOrgID      sql.NullInt64  `json:"active_org_id"`
+}
+
+type RevokedSession struct {
+	SessionID string    `json:"session_id"`
+	UserID    int64     `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RevokedToken struct {
+	Jti       string    `json:"jti"`
 	UserID    int64     `json:"user_id"`
-	Token     string    `json:"token"`
 	ExpiresAt time.Time `json:"expires_at"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+type Role struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RolePermission struct {
+	RoleID       int64 `json:"role_id"`
+	PermissionID int64 `json:"permission_id"`
+}
+
+type SigningKey struct {
+	ID         string       `json:"id"`
+	Algorithm  string       `json:"algorithm"`
+	PrivateKey []byte       `json:"private_key"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+}
+
+type TotpCredential struct {
+	UserID          int64        `json:"user_id"`
+	SecretEncrypted []byte       `json:"secret_encrypted"`
+	ConfirmedAt     sql.NullTime `json:"confirmed_at"`
+	LastUsedStep    int64        `json:"last_used_step"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
 type User struct {
-	ID           int64     `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"password_hash"`
-	FullName     string    `json:"full_name"`
-	IsActive     bool      `json:"is_active"`
-	IsAdmin      bool      `json:"is_admin"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                     int64        `json:"id"`
+	Email                  string       `json:"email"`
+	PasswordHash           string       `json:"password_hash"`
+	FullName               string       `json:"full_name"`
+	IsActive               bool         `json:"is_active"`
+	IsAdmin                bool         `json:"is_admin"`
+	CreatedAt              time.Time    `json:"created_at"`
+	UpdatedAt              time.Time    `json:"updated_at"`
+	TokensInvalidBefore    sql.NullTime `json:"tokens_invalid_before"`
+	EmailVerifiedAt        sql.NullTime `json:"email_verified_at"`
+	PasswordChangeRequired bool         `json:"password_change_required"`
+}
+
+type UserRole struct {
+	UserID    int64     `json:"user_id"`
+	RoleID    int64     `json:"role_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebauthnChallenge struct {
+	ID            int64         `json:"id"`
+	ChallengeHash string        `json:"challenge_hash"`
+	Ceremony      string        `json:"ceremony"`
+	UserID        sql.NullInt64 `json:"user_id"`
+	ExpiresAt     time.Time     `json:"expires_at"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+type WebauthnCredential struct {
+	ID             int64        `json:"id"`
+	UserID         int64        `json:"user_id"`
+	CredentialID   []byte       `json:"credential_id"`
+	PublicKey      []byte       `json:"public_key"`
+	SignCount      int64        `json:"sign_count"`
+	Transports     []string     `json:"transports"`
+	Aaguid         []byte       `json:"aaguid"`
+	BackupEligible bool         `json:"backup_eligible"`
+	BackedUp       bool         `json:"backed_up"`
+	Nickname       string       `json:"nickname"`
+	LastUsedAt     sql.NullTime `json:"last_used_at"`
+	CreatedAt      time.Time    `json:"created_at"`
 }
@@ -6,22 +6,216 @@ package sqlc
 
 import (
 	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type Querier interface {
+	// Accepts a claimed invitation for the account that claimed it, even once
+	// it has expired
+	AcceptClaimedInvitation(ctx context.Context, id int64) (Invitation, error)
+	// Accepts an invitation; concurrent requests cannot both succeed
+	AcceptInvitation(ctx context.Context, arg AcceptInvitationParams) (Invitation, error)
+	AddOrganizationMember(ctx context.Context, arg AddOrganizationMemberParams) (OrganizationMember, error)
+	AddRolePermission(ctx context.Context, arg AddRolePermissionParams) (int64, error)
+	AdminCountUsers(ctx context.Context, isActive *bool) (int64, error)
+	AdminCreateUser(ctx context.Context, arg AdminCreateUserParams) (User, error)
+	// Includes deactivated accounts
+	AdminGetUser(ctx context.Context, id int64) (User, error)
+	AdminGetUserForUpdate(ctx context.Context, id int64) (User, error)
+	// Filters on is_active when it is given
+	AdminListUsers(ctx context.Context, arg AdminListUsersParams) ([]User, error)
+	AdminUpdateUser(ctx context.Context, arg AdminUpdateUserParams) (User, error)
+	AssignUserRole(ctx context.Context, arg AssignUserRoleParams) error
+	BlockLoginKey(ctx context.Context, arg BlockLoginKeyParams) error
+	// Leaves the account without a password until it is reset
+	// Reserves an invitation for an account that cannot accept it yet; concurrent
+	// requests cannot both succeed
+	ClaimInvitation(ctx context.Context, arg ClaimInvitationParams) (Invitation, error)
+	ClearUserPassword(ctx context.Context, id int64) error
+	ConfirmTOTPCredential(ctx context.Context, arg ConfirmTOTPCredentialParams) error
+	// Deleting the code as it is read makes it single use
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (OauthAuthorizationCode, error)
+	// States work once, whether or not the sign-in succeeds
+	ConsumeExternalLoginState(ctx context.Context, arg ConsumeExternalLoginStateParams) (ExternalLoginState, error)
+	// Redeems a link; concurrent requests cannot both succeed
+	ConsumeMagicLinkToken(ctx context.Context, tokenHash string) (MagicLinkToken, error)
+	ConsumeRefreshToken(ctx context.Context, id int64) (int64, error)
+	// Challenges work once, whether or not the ceremony succeeds
+	ConsumeWebAuthnChallenge(ctx context.Context, arg ConsumeWebAuthnChallengeParams) (WebauthnChallenge, error)
+	CountOrganizationOwners(ctx context.Context, orgID int64) (int64, error)
+	// Organizations that would be left without an owner if the user went away
+	CountSoleOwnedOrganizations(ctx context.Context, userID int64) (int64, error)
+	CountUnusedRecoveryCodes(ctx context.Context, userID int64) (int64, error)
 	CountUsers(ctx context.Context) (int64, error)
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
+	// The chain columns and id are filled in by the chain_audit_events trigger
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error
+	CreateAuthorizationCode(ctx context.Context, arg CreateAuthorizationCodeParams) error
+	CreateEmailVerificationToken(ctx context.Context, arg CreateEmailVerificationTokenParams) (EmailVerificationToken, error)
+	CreateExternalLoginState(ctx context.Context, arg CreateExternalLoginStateParams) error
+	// Creates a user without a password together with the identity they signed
+	// in with. The provider has verified the email.
+	CreateExternalUser(ctx context.Context, arg CreateExternalUserParams) (User, error)
+	CreateIdentity(ctx context.Context, arg CreateIdentityParams) (Identity, error)
+	CreateInvitation(ctx context.Context, arg CreateInvitationParams) (Invitation, error)
+	CreateMFAChallenge(ctx context.Context, arg CreateMFAChallengeParams) (MfaChallenge, error)
+	CreateMagicLinkToken(ctx context.Context, arg CreateMagicLinkTokenParams) (MagicLinkToken, error)
+	CreateOAuthClient(ctx context.Context, arg CreateOAuthClientParams) (OauthClient, error)
+	CreateOAuthRefreshToken(ctx context.Context, arg CreateOAuthRefreshTokenParams) (RefreshToken, error)
+	CreateOrganization(ctx context.Context, arg CreateOrganizationParams) (Organization, error)
+	CreatePasswordResetToken(ctx context.Context, arg CreatePasswordResetTokenParams) (PasswordResetToken, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error
 	CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error)
+	CreateRole(ctx context.Context, arg CreateRoleParams) (Role, error)
+	CreateSigningKey(ctx context.Context, arg CreateSigningKeyParams) (SigningKey, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
-	DeleteRefreshToken(ctx context.Context, token string) error
+	CreateWebAuthnChallenge(ctx context.Context, arg CreateWebAuthnChallengeParams) error
+	CreateWebAuthnCredential(ctx context.Context, arg CreateWebAuthnCredentialParams) (WebauthnCredential, error)
+	DeleteExpiredAuthorizationCodes(ctx context.Context) error
+	DeleteExpiredEmailVerificationTokens(ctx context.Context) error
+	DeleteExpiredExternalLoginStates(ctx context.Context) error
+	DeleteExpiredInvitations(ctx context.Context) error
+	DeleteExpiredMFAChallenges(ctx context.Context) error
+	DeleteExpiredMagicLinkTokens(ctx context.Context) error
+	DeleteExpiredPasswordResetTokens(ctx context.Context) error
 	DeleteExpiredRefreshTokens(ctx context.Context) error
+	DeleteExpiredRevokedSessions(ctx context.Context) error
+	DeleteExpiredRevokedTokens(ctx context.Context) error
+	DeleteExpiredSigningKeys(ctx context.Context) error
+	DeleteExpiredWebAuthnChallenges(ctx context.Context) error
+	DeleteLoginAttempt(ctx context.Context, key string) (int64, error)
+	DeleteMFAChallenge(ctx context.Context, id int64) error
+	DeleteOAuthClient(ctx context.Context, clientID string) (int64, error)
+	// Deletes the refresh tokens issued to a client and returns their sessions
+	DeleteOAuthClientRefreshTokens(ctx context.Context, clientID string) ([]DeleteOAuthClientRefreshTokensRow, error)
+	DeleteOAuthConsent(ctx context.Context, arg DeleteOAuthConsentParams) (int64, error)
+	DeleteOrganizationInvitation(ctx context.Context, arg DeleteOrganizationInvitationParams) (Invitation, error)
+	DeleteRefreshToken(ctx context.Context, tokenHash string) error
+	DeleteRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	DeleteRole(ctx context.Context, id int64) (int64, error)
+	DeleteRolePermissions(ctx context.Context, roleID int64) error
+	DeleteStaleLoginAttempts(ctx context.Context, before time.Time) error
+	DeleteStaleRateLimitBuckets(ctx context.Context, updatedAt time.Time) error
+	DeleteSystemInvitation(ctx context.Context, id int64) (Invitation, error)
+	DeleteTOTPCredential(ctx context.Context, userID int64) error
 	DeleteUser(ctx context.Context, id int64) error
+	DeleteUserAPIKey(ctx context.Context, arg DeleteUserAPIKeyParams) (int64, error)
+	// Deletes a user's refresh tokens for a client and returns their sessions
+	DeleteUserClientRefreshTokens(ctx context.Context, arg DeleteUserClientRefreshTokensParams) ([]uuid.UUID, error)
+	DeleteUserEmailVerificationTokens(ctx context.Context, arg DeleteUserEmailVerificationTokensParams) error
+	DeleteUserIdentity(ctx context.Context, arg DeleteUserIdentityParams) (int64, error)
+	DeleteUserMagicLinkTokens(ctx context.Context, userID int64) error
+	DeleteUserPasswordResetTokens(ctx context.Context, userID int64) error
+	DeleteUserRecoveryCodes(ctx context.Context, userID int64) error
 	DeleteUserRefreshTokens(ctx context.Context, userID int64) error
-	GetRefreshToken(ctx context.Context, token string) (RefreshToken, error)
+	DeleteUserRefreshTokensExceptFamily(ctx context.Context, arg DeleteUserRefreshTokensExceptFamilyParams) error
+	// Leaves OAuth grants, which are revoked as authorized apps
+	DeleteUserSession(ctx context.Context, arg DeleteUserSessionParams) (int64, error)
+	// Leaves OAuth grants, which are revoked as authorized apps
+	DeleteUserSessionsExcept(ctx context.Context, arg DeleteUserSessionsExceptParams) error
+	DeleteUserWebAuthnCredential(ctx context.Context, arg DeleteUserWebAuthnCredentialParams) (int64, error)
+	EnsureRateLimitBucket(ctx context.Context, arg EnsureRateLimitBucketParams) error
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (ApiKey, error)
+	GetEmailVerificationTokenForUpdate(ctx context.Context, tokenHash string) (EmailVerificationToken, error)
+	GetIdentity(ctx context.Context, arg GetIdentityParams) (Identity, error)
+	// Looks an invitation up without accepting it
+	GetInvitation(ctx context.Context, tokenHash string) (Invitation, error)
+	GetLoginAttempt(ctx context.Context, key string) (LoginAttempt, error)
+	GetMFAChallengeForUpdate(ctx context.Context, tokenHash string) (MfaChallenge, error)
+	// Looks a link up without redeeming it
+	GetMagicLinkToken(ctx context.Context, tokenHash string) (MagicLinkToken, error)
+	GetOAuthClientByClientID(ctx context.Context, clientID string) (OauthClient, error)
+	GetOAuthConsent(ctx context.Context, arg GetOAuthConsentParams) (OauthConsent, error)
+	GetOrganization(ctx context.Context, id int64) (Organization, error)
+	GetOrganizationForUpdate(ctx context.Context, id int64) (Organization, error)
+	GetOrganizationMember(ctx context.Context, arg GetOrganizationMemberParams) (OrganizationMember, error)
+	GetPasswordResetTokenForUpdate(ctx context.Context, tokenHash string) (PasswordResetToken, error)
+	GetRateLimitBucketForUpdate(ctx context.Context, key string) (RateLimitBucket, error)
+	GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error)
+	GetRefreshTokenForUpdate(ctx context.Context, tokenHash string) (RefreshToken, error)
+	GetRoleByID(ctx context.Context, id int64) (Role, error)
+	GetRoleByName(ctx context.Context, name string) (Role, error)
+	GetRoleForUpdate(ctx context.Context, id int64) (Role, error)
+	GetTOTPCredentialForUpdate(ctx context.Context, userID int64) (TotpCredential, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	GetUserByID(ctx context.Context, id int64) (User, error)
+	GetWebAuthnCredential(ctx context.Context, credentialID []byte) (WebauthnCredential, error)
+	HardDeleteUser(ctx context.Context, id int64) (int64, error)
+	IncrementMFAChallengeAttempts(ctx context.Context, id int64) (int32, error)
+	InvalidateUserTokens(ctx context.Context, arg InvalidateUserTokensParams) error
+	IsMFAEnabled(ctx context.Context, userID int64) (bool, error)
+	// Tokens of deleted users and deleted OAuth clients are revoked; client
+	// tokens have no user
+	IsTokenRevoked(ctx context.Context, arg IsTokenRevokedParams) (bool, error)
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error)
+	ListClaimedInvitations(ctx context.Context, claimedBy sql.NullInt64) ([]Invitation, error)
+	ListOAuthClients(ctx context.Context) ([]OauthClient, error)
+	ListOrganizationInvitations(ctx context.Context, orgID sql.NullInt64) ([]Invitation, error)
+	ListOrganizationMembers(ctx context.Context, orgID int64) ([]ListOrganizationMembersRow, error)
+	// One stored hash for each algorithm and parameter set in use. Salts and
+	// digests are the only fields of 22 characters or more, so dropping them
+	// leaves what the hashes share.
+	ListPasswordHashSamples(ctx context.Context) ([]string, error)
+	ListPermissions(ctx context.Context) ([]Permission, error)
+	ListRolePermissions(ctx context.Context, roleID int64) ([]string, error)
+	ListRoleUserIDs(ctx context.Context, roleID int64) ([]int64, error)
+	ListRoles(ctx context.Context) ([]Role, error)
+	ListSigningKeys(ctx context.Context) ([]SigningKey, error)
+	ListSystemInvitations(ctx context.Context) ([]Invitation, error)
+	ListUserAPIKeys(ctx context.Context, userID int64) ([]ApiKey, error)
+	ListUserIdentities(ctx context.Context, userID int64) ([]Identity, error)
+	// Locking every identity of the user makes concurrent unlinks take turns
+	ListUserIdentitiesForUpdate(ctx context.Context, userID int64) ([]Identity, error)
+	ListUserOAuthConsents(ctx context.Context, userID int64) ([]ListUserOAuthConsentsRow, error)
+	ListUserOrganizations(ctx context.Context, userID int64) ([]ListUserOrganizationsRow, error)
+	ListUserPermissions(ctx context.Context, userID int64) ([]string, error)
+	ListUserRoles(ctx context.Context, userID int64) ([]string, error)
+	ListUserSessions(ctx context.Context, userID int64) ([]RefreshToken, error)
+	ListUserWebAuthnCredentials(ctx context.Context, userID int64) ([]WebauthnCredential, error)
 	ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error)
+	MarkEmailVerificationTokenUsed(ctx context.Context, id int64) error
+	MarkPasswordResetTokenUsed(ctx context.Context, id int64) error
+	RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginAttempt, error)
+	// Stores a new hash of the same password, unless the password was changed
+	// since old_hash was read
+	RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error
+	RemoveOrganizationMember(ctx context.Context, arg RemoveOrganizationMemberParams) (int64, error)
+	RemoveUserRole(ctx context.Context, arg RemoveUserRoleParams) (int64, error)
+	RenameUserWebAuthnCredential(ctx context.Context, arg RenameUserWebAuthnCredentialParams) (WebauthnCredential, error)
+	// Retires the active keys older than active_id. A key saved meanwhile by a
+	// replica rotating at the same time is newer and stays active.
+	RetireSigningKeys(ctx context.Context, arg RetireSigningKeysParams) error
+	RevokeSession(ctx context.Context, arg RevokeSessionParams) error
+	RevokeToken(ctx context.Context, arg RevokeTokenParams) error
+	// Revokes every login session the user has refresh tokens for but the given
+	// one. OAuth grants are left alone.
+	RevokeUserSessionsExcept(ctx context.Context, arg RevokeUserSessionsExceptParams) error
+	SetSessionActiveOrganization(ctx context.Context, arg SetSessionActiveOrganizationParams) error
+	SetUserActive(ctx context.Context, arg SetUserActiveParams) (User, error)
+	// Writes at most once a minute per key to keep authentication cheap
+	TouchAPIKey(ctx context.Context, arg TouchAPIKeyParams) error
+	TouchIdentity(ctx context.Context, arg TouchIdentityParams) error
+	UpdateOrganizationMemberRole(ctx context.Context, arg UpdateOrganizationMemberRoleParams) (OrganizationMember, error)
+	UpdateRateLimitBucket(ctx context.Context, arg UpdateRateLimitBucketParams) error
+	UpdateRoleDescription(ctx context.Context, arg UpdateRoleDescriptionParams) (Role, error)
+	UpdateTOTPLastUsedStep(ctx context.Context, arg UpdateTOTPLastUsedStepParams) error
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
 	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error
+	// Only moves the signature counter forward, so of two sign-ins with the same
+	// counter only one updates the row. Authenticators without a counter always
+	// report zero.
+	UpdateWebAuthnCredentialUsage(ctx context.Context, arg UpdateWebAuthnCredentialUsageParams) (int64, error)
+	// Consent accumulates, so approving fewer scopes later keeps the rest
+	UpsertOAuthConsent(ctx context.Context, arg UpsertOAuthConsentParams) error
+	UpsertPendingTOTPCredential(ctx context.Context, arg UpsertPendingTOTPCredentialParams) (int64, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error)
+	// Recomputes every hash and link; broken_id is the first event that does
+	// not match, or 0 when the chain is intact
+	VerifyAuditChain(ctx context.Context) (VerifyAuditChainRow, error)
+	VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -7,30 +7,129 @@ package sqlc
 
 import (
 	"context"
+	"database/sql"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const consumeRefreshToken = `-- name: ConsumeRefreshToken :execrows
+UPDATE refresh_tokens
+SET consumed_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND consumed_at IS NULL
+`
+
+func (q *Queries) ConsumeRefreshToken(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, consumeRefreshToken, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createOAuthRefreshToken = `-- name: CreateOAuthRefreshToken :one
+INSERT INTO refresh_tokens (
+    user_id, token_hash, expires_at, family_id,
+    user_agent, ip_address, client_id, scopes
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, user_id, token_hash, expires_at, created_at, family_id, consumed_at, user_agent, ip_address, device_name, session_started_at, last_used_at, client_id, scopes, active_org_id
+`
+
+type CreateOAuthRefreshTokenParams struct {
+	UserID    int64         `json:"user_id"`
+	TokenHash string        `json:"token_hash"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	FamilyID  uuid.UUID     `json:"family_id"`
+	UserAgent string        `json:"user_agent"`
+	IpAddress string        `json:"ip_address"`
+	ClientID  sql.NullInt64 `json:"client_id"`
+	Scopes    []string      `json:"scopes"`
+}
+
+func (q *Queries) CreateOAuthRefreshToken(ctx context.Context, arg CreateOAuthRefreshTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, createOAuthRefreshToken,
+		arg.UserID,
+		arg.TokenHash,
+		arg.ExpiresAt,
+		arg.FamilyID,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.ClientID,
+		pq.Array(arg.Scopes),
+	)
+	var i RefreshToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.FamilyID,
+		&i.ConsumedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.DeviceName,
+		&i.SessionStartedAt,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+		&i.ActiveOrgID,
+	)
+	return i, err
+}
+
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (user_id, token, expires_at)
-VALUES ($1, $2, $3)
-RETURNING id, user_id, token, expires_at, created_at
+INSERT INTO refresh_tokens (
+    user_id, token_hash, expires_at, family_id,
+    user_agent, ip_address, device_name, session_started_at, active_org_id
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, user_id, token_hash, expires_at, created_at, family_id, consumed_at, user_agent, ip_address, device_name, session_started_at, last_used_at, client_id, scopes, active_org_id
 `
 
 type CreateRefreshTokenParams struct {
-	UserID    int64     `json:"user_id"`
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expires_at"`
+	UserID           int64          `json:"user_id"`
+	TokenHash        string         `json:"token_hash"`
+	ExpiresAt        time.Time      `json:"expires_at"`
+	FamilyID         uuid.UUID      `json:"family_id"`
+	UserAgent        string         `json:"user_agent"`
+	IpAddress        string         `json:"ip_address"`
+	DeviceName       sql.NullString `json:"device_name"`
+	SessionStartedAt time.Time      `json:"session_started_at"`
+	ActiveOrgID      sql.NullInt64  `json:"active_org_id"`
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.UserID, arg.Token, arg.ExpiresAt)
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.UserID,
+		arg.TokenHash,
+		arg.ExpiresAt,
+		arg.FamilyID,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.DeviceName,
+		arg.SessionStartedAt,
+		arg.ActiveOrgID,
+	)
 	var i RefreshToken
 	err := row.Scan(
 		&i.ID,
 		&i.UserID,
-		&i.Token,
+		&i.TokenHash,
 		&i.ExpiresAt,
 		&i.CreatedAt,
+		&i.FamilyID,
+		&i.ConsumedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.DeviceName,
+		&i.SessionStartedAt,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+		&i.ActiveOrgID,
 	)
 	return i, err
 }
@@ -47,11 +146,21 @@ func (q *Queries) DeleteExpiredRefreshTokens(ctx context.Context) error {
 
 const deleteRefreshToken = `-- name: DeleteRefreshToken :exec
 DELETE FROM refresh_tokens
-WHERE token = $1
+WHERE token_hash = $1
 `
 
-func (q *Queries) DeleteRefreshToken(ctx context.Context, token string) error {
-	_, err := q.db.ExecContext(ctx, deleteRefreshToken, token)
+func (q *Queries) DeleteRefreshToken(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, deleteRefreshToken, tokenHash)
+	return err
+}
+
+const deleteRefreshTokenFamily = `-- name: DeleteRefreshTokenFamily :exec
+DELETE FROM refresh_tokens
+WHERE family_id = $1
+`
+
+func (q *Queries) DeleteRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteRefreshTokenFamily, familyID)
 	return err
 }
 
@@ -65,21 +174,156 @@ func (q *Queries) DeleteUserRefreshTokens(ctx context.Context, userID int64) err
 	return err
 }
 
+const deleteUserRefreshTokensExceptFamily = `-- name: DeleteUserRefreshTokensExceptFamily :exec
+DELETE FROM refresh_tokens
+WHERE user_id = $1 AND family_id <> $2
+`
+
+type DeleteUserRefreshTokensExceptFamilyParams struct {
+	UserID   int64     `json:"user_id"`
+	FamilyID uuid.UUID `json:"family_id"`
+}
+
+func (q *Queries) DeleteUserRefreshTokensExceptFamily(ctx context.Context, arg DeleteUserRefreshTokensExceptFamilyParams) error {
+	_, err := q.db.ExecContext(ctx, deleteUserRefreshTokensExceptFamily, arg.UserID, arg.FamilyID)
+	return err
+}
+
+const deleteUserSession = `-- name: DeleteUserSession :execrows
+DELETE FROM refresh_tokens
+WHERE user_id = $1 AND client_id IS NULL AND family_id = $2
+`
+
+type DeleteUserSessionParams struct {
+	UserID   int64     `json:"user_id"`
+	FamilyID uuid.UUID `json:"family_id"`
+}
+
+// Leaves OAuth grants, which are revoked as authorized apps
+func (q *Queries) DeleteUserSession(ctx context.Context, arg DeleteUserSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUserSession, arg.UserID, arg.FamilyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteUserSessionsExcept = `-- name: DeleteUserSessionsExcept :exec
+DELETE FROM refresh_tokens
+WHERE user_id = $1 AND client_id IS NULL AND family_id <> $2
+`
+
+type DeleteUserSessionsExceptParams struct {
+	UserID   int64     `json:"user_id"`
+	FamilyID uuid.UUID `json:"family_id"`
+}
+
+// Leaves OAuth grants, which are revoked as authorized apps
+func (q *Queries) DeleteUserSessionsExcept(ctx context.Context, arg DeleteUserSessionsExceptParams) error {
+	_, err := q.db.ExecContext(ctx, deleteUserSessionsExcept, arg.UserID, arg.FamilyID)
+	return err
+}
+
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT id, user_id, token, expires_at, created_at FROM refresh_tokens
-WHERE token = $1 AND expires_at > CURRENT_TIMESTAMP
+SELECT id, user_id, token_hash, expires_at, created_at, family_id, consumed_at, user_agent, ip_address, device_name, session_started_at, last_used_at, client_id, scopes, active_org_id FROM refresh_tokens
+WHERE token_hash = $1 AND expires_at > CURRENT_TIMESTAMP
 LIMIT 1
 `
 
-func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, getRefreshToken, token)
+func (q *Queries) GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshToken, tokenHash)
 	var i RefreshToken
 	err := row.Scan(
 		&i.ID,
 		&i.UserID,
-		&i.Token,
+		&i.TokenHash,
 		&i.ExpiresAt,
 		&i.CreatedAt,
+		&i.FamilyID,
+		&i.ConsumedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.DeviceName,
+		&i.SessionStartedAt,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+		&i.ActiveOrgID,
 	)
 	return i, err
 }
+
+const getRefreshTokenForUpdate = `-- name: GetRefreshTokenForUpdate :one
+SELECT id, user_id, token_hash, expires_at, created_at, family_id, consumed_at, user_agent, ip_address, device_name, session_started_at, last_used_at, client_id, scopes, active_org_id FROM refresh_tokens
+WHERE token_hash = $1 AND expires_at > CURRENT_TIMESTAMP
+LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) GetRefreshTokenForUpdate(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshTokenForUpdate, tokenHash)
+	var i RefreshToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.FamilyID,
+		&i.ConsumedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.DeviceName,
+		&i.SessionStartedAt,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+		&i.ActiveOrgID,
+	)
+	return i, err
+}
+
+const listUserSessions = `-- name: ListUserSessions :many
+SELECT id, user_id, token_hash, expires_at, created_at, family_id, consumed_at, user_agent, ip_address, device_name, session_started_at, last_used_at, client_id, scopes, active_org_id FROM refresh_tokens
+WHERE user_id = $1 AND client_id IS NULL AND consumed_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+ORDER BY last_used_at DESC
+`
+
+func (q *Queries) ListUserSessions(ctx context.Context, userID int64) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, listUserSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []RefreshToken{}
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.TokenHash,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+			&i.FamilyID,
+			&i.ConsumedAt,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.DeviceName,
+			&i.SessionStartedAt,
+			&i.LastUsedAt,
+			&i.ClientID,
+			pq.Array(&i.Scopes),
+			&i.ActiveOrgID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
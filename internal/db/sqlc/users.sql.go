@@ -7,8 +7,218 @@ package sqlc
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
+const adminCountUsers = `-- name: AdminCountUsers :one
+SELECT COUNT(*) FROM users
+WHERE $1::boolean IS NULL OR is_active = $1::boolean
+`
+
+func (q *Queries) AdminCountUsers(ctx context.Context, isActive *bool) (int64, error) {
+	row := q.db.QueryRowContext(ctx, adminCountUsers, isActive)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const adminCreateUser = `-- name: AdminCreateUser :one
+INSERT INTO users (email, password_hash, full_name, is_admin, email_verified_at, password_change_required)
+VALUES ($1, $2, $3, $4, $5, true)
+RETURNING id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at, tokens_invalid_before, email_verified_at, password_change_required
+`
+
+type AdminCreateUserParams struct {
+	Email           string       `json:"email"`
+	PasswordHash    string       `json:"password_hash"`
+	FullName        string       `json:"full_name"`
+	IsAdmin         bool         `json:"is_admin"`
+	EmailVerifiedAt sql.NullTime `json:"email_verified_at"`
+}
+
+func (q *Queries) AdminCreateUser(ctx context.Context, arg AdminCreateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, adminCreateUser,
+		arg.Email,
+		arg.PasswordHash,
+		arg.FullName,
+		arg.IsAdmin,
+		arg.EmailVerifiedAt,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.IsActive,
+		&i.IsAdmin,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TokensInvalidBefore,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangeRequired,
+	)
+	return i, err
+}
+
+const adminGetUser = `-- name: AdminGetUser :one
+SELECT id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at, tokens_invalid_before, email_verified_at, password_change_required FROM users
+WHERE id = $1
+LIMIT 1
+`
+
+// Includes deactivated accounts
+func (q *Queries) AdminGetUser(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRowContext(ctx, adminGetUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.IsActive,
+		&i.IsAdmin,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TokensInvalidBefore,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangeRequired,
+	)
+	return i, err
+}
+
+const adminGetUserForUpdate = `-- name: AdminGetUserForUpdate :one
+SELECT id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at, tokens_invalid_before, email_verified_at, password_change_required FROM users
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) AdminGetUserForUpdate(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRowContext(ctx, adminGetUserForUpdate, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.IsActive,
+		&i.IsAdmin,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TokensInvalidBefore,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangeRequired,
+	)
+	return i, err
+}
+
+const adminListUsers = `-- name: AdminListUsers :many
+SELECT id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at, tokens_invalid_before, email_verified_at, password_change_required FROM users
+WHERE $1::boolean IS NULL OR is_active = $1::boolean
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type AdminListUsersParams struct {
+	IsActive *bool `json:"is_active"`
+	Limit    int32 `json:"limit"`
+	Offset   int32 `json:"offset"`
+}
+
+// Filters on is_active when it is given
+func (q *Queries) AdminListUsers(ctx context.Context, arg AdminListUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, adminListUsers, arg.IsActive, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.PasswordHash,
+			&i.FullName,
+			&i.IsActive,
+			&i.IsAdmin,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.TokensInvalidBefore,
+			&i.EmailVerifiedAt,
+			&i.PasswordChangeRequired,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const adminUpdateUser = `-- name: AdminUpdateUser :one
+UPDATE users
+SET
+    email = $2,
+    full_name = $3,
+    is_admin = $4,
+    email_verified_at = $5,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at, tokens_invalid_before, email_verified_at, password_change_required
+`
+
+type AdminUpdateUserParams struct {
+	ID              int64        `json:"id"`
+	Email           string       `json:"email"`
+	FullName        string       `json:"full_name"`
+	IsAdmin         bool         `json:"is_admin"`
+	EmailVerifiedAt sql.NullTime `json:"email_verified_at"`
+}
+
+func (q *Queries) AdminUpdateUser(ctx context.Context, arg AdminUpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, adminUpdateUser,
+		arg.ID,
+		arg.Email,
+		arg.FullName,
+		arg.IsAdmin,
+		arg.EmailVerifiedAt,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.IsActive,
+		&i.IsAdmin,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TokensInvalidBefore,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangeRequired,
+	)
+	return i, err
+}
+
+const clearUserPassword = `-- name: ClearUserPassword :exec
+UPDATE users
+SET password_hash = '', updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+// Leaves the account without a password until it is reset
+func (q *Queries) ClearUserPassword(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, clearUserPassword, id)
+	return err
+}
+
 const countUsers = `-- name: CountUsers :one
 SELECT COUNT(*) FROM users
 WHERE is_active = true
@@ -24,7 +234,7 @@ func (q *Queries) CountUsers(ctx context.Context) (int64, error) {
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (email, password_hash, full_name)
 VALUES ($1, $2, $3)
-RETURNING id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at
+RETURNING id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at, tokens_invalid_before, email_verified_at, password_change_required
 `
 
 type CreateUserParams struct {
@@ -45,6 +255,9 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.IsAdmin,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.TokensInvalidBefore,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangeRequired,
 	)
 	return i, err
 }
@@ -61,7 +274,7 @@ func (q *Queries) DeleteUser(ctx context.Context, id int64) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at FROM users
+SELECT id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at, tokens_invalid_before, email_verified_at, password_change_required FROM users
 WHERE email = $1 AND is_active = true
 LIMIT 1
 `
@@ -78,12 +291,15 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.IsAdmin,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.TokensInvalidBefore,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangeRequired,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at FROM users
+SELECT id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at, tokens_invalid_before, email_verified_at, password_change_required FROM users
 WHERE id = $1 AND is_active = true
 LIMIT 1
 `
@@ -100,12 +316,77 @@ func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
 		&i.IsAdmin,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.TokensInvalidBefore,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangeRequired,
 	)
 	return i, err
 }
 
+const hardDeleteUser = `-- name: HardDeleteUser :execrows
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) HardDeleteUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, hardDeleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const invalidateUserTokens = `-- name: InvalidateUserTokens :exec
+UPDATE users
+SET tokens_invalid_before = $1::timestamptz
+WHERE id = $2
+`
+
+type InvalidateUserTokensParams struct {
+	TokensInvalidBefore time.Time `json:"tokens_invalid_before"`
+	ID                  int64     `json:"id"`
+}
+
+func (q *Queries) InvalidateUserTokens(ctx context.Context, arg InvalidateUserTokensParams) error {
+	_, err := q.db.ExecContext(ctx, invalidateUserTokens, arg.TokensInvalidBefore, arg.ID)
+	return err
+}
+
+const listPasswordHashSamples = `-- name: ListPasswordHashSamples :many
+SELECT MIN(password_hash)::text AS password_hash
+FROM users
+WHERE password_hash <> ''
+GROUP BY regexp_replace(password_hash, '[^$]{22,}', '', 'g')
+`
+
+// One stored hash for each algorithm and parameter set in use. Salts and
+// digests are the only fields of 22 characters or more, so dropping them
+// leaves what the hashes share.
+func (q *Queries) ListPasswordHashSamples(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listPasswordHashSamples)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var passwordHash string
+		if err := rows.Scan(&passwordHash); err != nil {
+			return nil, err
+		}
+		items = append(items, passwordHash)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listUsers = `-- name: ListUsers :many
-SELECT id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at FROM users
+SELECT id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at, tokens_invalid_before, email_verified_at, password_change_required FROM users
 WHERE is_active = true
 ORDER BY created_at DESC
 LIMIT $1 OFFSET $2
@@ -134,6 +415,9 @@ func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, e
 			&i.IsAdmin,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.TokensInvalidBefore,
+			&i.EmailVerifiedAt,
+			&i.PasswordChangeRequired,
 		); err != nil {
 			return nil, err
 		}
@@ -148,6 +432,56 @@ func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, e
 	return items, nil
 }
 
+const rehashUserPassword = `-- name: RehashUserPassword :exec
+UPDATE users
+SET password_hash = $1
+WHERE id = $2 AND password_hash = $3
+`
+
+type RehashUserPasswordParams struct {
+	PasswordHash string `json:"password_hash"`
+	ID           int64  `json:"id"`
+	OldHash      string `json:"old_hash"`
+}
+
+// Stores a new hash of the same password, unless the password was changed
+// since old_hash was read
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashUserPassword, arg.PasswordHash, arg.ID, arg.OldHash)
+	return err
+}
+
+const setUserActive = `-- name: SetUserActive :one
+UPDATE users
+SET is_active = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at, tokens_invalid_before, email_verified_at, password_change_required
+`
+
+type SetUserActiveParams struct {
+	ID       int64 `json:"id"`
+	IsActive bool  `json:"is_active"`
+}
+
+func (q *Queries) SetUserActive(ctx context.Context, arg SetUserActiveParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserActive, arg.ID, arg.IsActive)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.IsActive,
+		&i.IsAdmin,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TokensInvalidBefore,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangeRequired,
+	)
+	return i, err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET 
@@ -155,7 +489,7 @@ SET
     email = COALESCE($3, email),
     updated_at = CURRENT_TIMESTAMP
 WHERE id = $1 AND is_active = true
-RETURNING id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at
+RETURNING id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at, tokens_invalid_before, email_verified_at, password_change_required
 `
 
 type UpdateUserParams struct {
@@ -176,13 +510,16 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.IsAdmin,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.TokensInvalidBefore,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangeRequired,
 	)
 	return i, err
 }
 
 const updateUserPassword = `-- name: UpdateUserPassword :exec
 UPDATE users
-SET password_hash = $2, updated_at = CURRENT_TIMESTAMP
+SET password_hash = $2, password_change_required = false, updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
 `
 
@@ -195,3 +532,34 @@ func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPassword
 	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.PasswordHash)
 	return err
 }
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET email = $1, email_verified_at = CURRENT_TIMESTAMP
+WHERE id = $2 AND is_active = true
+RETURNING id, email, password_hash, full_name, is_active, is_admin, created_at, updated_at, tokens_invalid_before, email_verified_at, password_change_required
+`
+
+type VerifyUserEmailParams struct {
+	Email string `json:"email"`
+	ID    int64  `json:"id"`
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, arg.Email, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FullName,
+		&i.IsActive,
+		&i.IsAdmin,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TokensInvalidBefore,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangeRequired,
+	)
+	return i, err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
 
 	"github.com/yourusername/go-sqlc-starter/internal/db/sqlc"
 )
@@ -11,7 +12,7 @@ import (
 // Store provides all functions to execute database queries and transactions
 type Store interface {
 	sqlc.Querier
-	ExecTx(ctx context.Context, fn func(*sqlc.Queries) error) error
+	ExecTx(ctx context.Context, fn func(sqlc.Querier) error) error
 }
 
 // SQLStore provides all functions to execute SQL queries and transactions
@@ -28,15 +29,21 @@ func NewStore(db *sql.DB) Store {
 	}
 }
 
-// ExecTx executes a function within a database transaction
-func (store *SQLStore) ExecTx(ctx context.Context, fn func(*sqlc.Queries) error) error {
+// ExecTx executes a function within a database transaction. When ctx carries
+// an organization from WithOrgID, the transaction is confined to it.
+func (store *SQLStore) ExecTx(ctx context.Context, fn func(sqlc.Querier) error) error {
 	tx, err := store.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	q := sqlc.New(tx)
-	err = fn(q)
+	// The setting lasts until the transaction ends
+	if orgID, ok := OrgIDFromContext(ctx); ok {
+		_, err = tx.ExecContext(ctx, "SELECT set_config('app.org_id', $1, true)", strconv.FormatInt(orgID, 10))
+	}
+	if err == nil {
+		err = fn(sqlc.New(tx))
+	}
 	if err != nil {
 		if rbErr := tx.Rollback(); rbErr != nil {
 			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
@@ -0,0 +1,17 @@
+package db
+
+import "context"
+
+type orgIDKey struct{}
+
+// WithOrgID returns a copy of ctx whose transactions run as the organization
+// orgID, so row-level security hides every other organization's rows
+func WithOrgID(ctx context.Context, orgID int64) context.Context {
+	return context.WithValue(ctx, orgIDKey{}, orgID)
+}
+
+// OrgIDFromContext returns the organization set with WithOrgID, if any
+func OrgIDFromContext(ctx context.Context) (int64, bool) {
+	orgID, ok := ctx.Value(orgIDKey{}).(int64)
+	return orgID, ok
+}
@@ -0,0 +1,57 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileMailer writes each message to a file in an outbox directory instead of
+// delivering it. It suits local development and tests.
+type FileMailer struct {
+	dir  string
+	from string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewFileMailer creates a mailer writing to dir, creating it if needed
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox: %w", err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send writes msg to the outbox as an .eml file
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	body, err := format(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%d-%04d.eml", time.Now().UnixNano(), m.seq)
+	m.mu.Unlock()
+
+	if err := os.WriteFile(filepath.Join(m.dir, name), body, 0o600); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+	return nil
+}
+
+// Messages returns the paths of every message in the outbox, oldest first
+func (m *FileMailer) Messages() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(m.dir, "*.eml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
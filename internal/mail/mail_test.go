@@ -0,0 +1,39 @@
+package mail_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/go-sqlc-starter/internal/mail"
+)
+
+func TestFileMailer(t *testing.T) {
+	mailer, err := mail.NewFileMailer(t.TempDir(), "noreply@example.com")
+	require.NoError(t, err)
+
+	err = mailer.Send(context.Background(), mail.Message{
+		To:      "user@example.com",
+		Subject: "Reset your password",
+		Body:    "Follow this link:\nhttps://example.com/reset?token=abc",
+	})
+	require.NoError(t, err)
+
+	messages, err := mailer.Messages()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	raw, err := os.ReadFile(messages[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "To: user@example.com\r\n")
+	assert.Contains(t, string(raw), "https://example.com/reset?token=abc")
+
+	// Header injection is rejected
+	err = mailer.Send(context.Background(), mail.Message{
+		To:      "user@example.com\r\nBcc: victim@example.com",
+		Subject: "Hi",
+	})
+	assert.Error(t, err)
+}